@@ -0,0 +1,292 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/dpos"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// DposSimulatedBackend simulates a delegated proof-of-stake blockchain with a
+// fully controllable clock for testing vote flows, epoch transitions and
+// reward distribution without running a full node. It implements the
+// bind.ContractCaller and bind.ContractTransactor interfaces.
+type DposSimulatedBackend struct {
+	database   ethdb.Database   // In memory database to store our testing data
+	blockchain *core.BlockChain // Ethereum blockchain to handle the consensus
+	engine     *dpos.Dpos
+
+	mu      sync.Mutex
+	clock   int64 // Unix time of the most recently sealed block
+	pending []*types.Transaction
+
+	keys   map[common.Address]*ecdsa.PrivateKey // Validator keys used to seal blocks
+	config *params.ChainConfig
+}
+
+// NewDposSimulatedBackend creates a simulated dpos blockchain. The given keys
+// control the genesis validators; blocks are sealed with whichever key owns
+// the slot the simulated clock lands on. The clock starts one epoch in the
+// past so tests can drive epoch transitions without colliding with the
+// future-block check.
+func NewDposSimulatedBackend(alloc core.GenesisAlloc, validatorKeys []*ecdsa.PrivateKey) *DposSimulatedBackend {
+	validators := make([]common.Address, 0, len(validatorKeys))
+	keys := make(map[common.Address]*ecdsa.PrivateKey)
+	for _, key := range validatorKeys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		validators = append(validators, addr)
+		keys[addr] = key
+	}
+	config := &params.ChainConfig{
+		ChainID:        big.NewInt(1337),
+		HomesteadBlock: big.NewInt(0),
+		EIP150Block:    big.NewInt(0),
+		EIP155Block:    big.NewInt(0),
+		EIP158Block:    big.NewInt(0),
+		ByzantiumBlock: big.NewInt(0),
+		Dpos:           &params.DposConfig{Validators: validators},
+	}
+	start := dpos.PrevSlot(time.Now().Unix() - 86400)
+
+	database := ethdb.NewMemDatabase()
+	genesis := core.Genesis{Config: config, Alloc: alloc, Timestamp: uint64(start)}
+	genesis.MustCommit(database)
+
+	engine := dpos.New(config.Dpos, database)
+	blockchain, _ := core.NewBlockChain(database, nil, config, engine, vm.Config{})
+
+	return &DposSimulatedBackend{
+		database:   database,
+		blockchain: blockchain,
+		engine:     engine,
+		clock:      start,
+		keys:       keys,
+		config:     config,
+	}
+}
+
+// Blockchain exposes the underlying chain for assertions on sealed blocks.
+func (b *DposSimulatedBackend) Blockchain() *core.BlockChain {
+	return b.blockchain
+}
+
+// AdjustTime moves the simulated clock forward, e.g. across an epoch
+// boundary. The next committed block is sealed in the slot the clock lands
+// on.
+func (b *DposSimulatedBackend) AdjustTime(adjustment time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clock += int64(adjustment / time.Second)
+}
+
+// SendTransaction queues a signed transaction for inclusion in the next
+// committed block.
+func (b *DposSimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, tx)
+	return nil
+}
+
+// Commit advances the clock to the next slot and seals the queued
+// transactions into a new block, signed by the validator owning the slot.
+func (b *DposSimulatedBackend) Commit() (*types.Block, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	parent := b.blockchain.CurrentBlock()
+	b.clock = dpos.NextSlot(b.clock + 1)
+
+	statedb, err := b.blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	dposContext, err := b.blockchain.DposContextAt(parent.Header())
+	if err != nil {
+		return nil, err
+	}
+	validator, err := dpos.LookupValidator(dposContext, b.clock)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := b.keys[validator]
+	if !ok {
+		return nil, fmt.Errorf("no key for slot validator %x", validator)
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   core.CalcGasLimit(parent),
+		Time:       big.NewInt(b.clock),
+		Difficulty: big.NewInt(1),
+		Coinbase:   validator,
+		Validator:  validator,
+		Extra:      make([]byte, 32+65),
+	}
+	// Apply the queued transactions on top of the parent state
+	var (
+		gasPool  = new(core.GasPool).AddGas(header.GasLimit)
+		receipts types.Receipts
+		included []*types.Transaction
+		usedGas  = new(uint64)
+	)
+	for i, tx := range b.pending {
+		statedb.Prepare(tx.Hash(), common.Hash{}, i)
+		receipt, _, err := core.ApplyTransaction(b.config, b.blockchain, &validator, gasPool, statedb, header, tx, usedGas, vm.Config{}, dposContext)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+		included = append(included, tx)
+	}
+	b.pending = nil
+	header.GasUsed = *usedGas
+
+	block, err := b.engine.Finalize(b.blockchain, header, statedb, included, nil, receipts, dposContext)
+	if err != nil {
+		return nil, err
+	}
+	// Seal the block with the slot validator's key
+	sig, err := crypto.Sign(dpos.SigHash(block.Header()).Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+	header = block.Header()
+	copy(header.Extra[len(header.Extra)-65:], sig)
+	sealed := block.WithSeal(header)
+
+	if _, err := b.blockchain.WriteBlockWithState(sealed, receipts, statedb); err != nil {
+		return nil, err
+	}
+	return sealed, nil
+}
+
+// CodeAt returns the code of the given account at the head block.
+func (b *DposSimulatedBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
+		return nil, errBlockNumberUnsupported
+	}
+	statedb, err := b.blockchain.State()
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetCode(contract), nil
+}
+
+// PendingCodeAt returns the code of the given account at the head block;
+// queued transactions are not executed speculatively.
+func (b *DposSimulatedBackend) PendingCodeAt(ctx context.Context, contract common.Address) ([]byte, error) {
+	return b.CodeAt(ctx, contract, nil)
+}
+
+// CallContract executes a contract call at the head block.
+func (b *DposSimulatedBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
+		return nil, errBlockNumberUnsupported
+	}
+	statedb, err := b.blockchain.State()
+	if err != nil {
+		return nil, err
+	}
+	rval, _, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), statedb)
+	return rval, err
+}
+
+// PendingNonceAt returns the nonce the given account would have after the
+// queued transactions are committed.
+func (b *DposSimulatedBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	statedb, err := b.blockchain.State()
+	if err != nil {
+		return 0, err
+	}
+	nonce := statedb.GetNonce(account)
+	for _, tx := range b.pending {
+		from, err := types.Sender(types.NewEIP155Signer(b.config.ChainID), tx)
+		if err == nil && from == account {
+			nonce++
+		}
+	}
+	return nonce, nil
+}
+
+// SuggestGasPrice implements bind.ContractTransactor. Gas prices do not
+// matter on the simulated chain, so a constant of 1 is returned.
+func (b *DposSimulatedBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+// EstimateGas executes the call against the head state and returns the gas
+// used, doubling it as a safety margin for the real execution.
+func (b *DposSimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	statedb, err := b.blockchain.State()
+	if err != nil {
+		return 0, err
+	}
+	if call.Gas == 0 {
+		call.Gas = b.blockchain.CurrentBlock().GasLimit()
+	}
+	_, gas, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), statedb)
+	if err != nil {
+		return 0, err
+	}
+	return gas * 2, nil
+}
+
+// callContract implements common code between normal and pending contract
+// calls, mirroring SimulatedBackend.
+func (b *DposSimulatedBackend) callContract(ctx context.Context, call ethereum.CallMsg, block *types.Block, statedb *state.StateDB) ([]byte, uint64, bool, error) {
+	if call.GasPrice == nil {
+		call.GasPrice = big.NewInt(1)
+	}
+	if call.Gas == 0 {
+		call.Gas = 50000000
+	}
+	if call.Value == nil {
+		call.Value = new(big.Int)
+	}
+	from := statedb.GetOrNewStateObject(call.From)
+	from.SetBalance(new(big.Int).SetUint64(0xffffffffffffffff))
+	msg := callmsg{call}
+	evmContext := core.NewEVMContext(msg, block.Header(), b.blockchain, nil)
+	vmenv := vm.NewEVM(evmContext, statedb, b.config, vm.Config{})
+	gaspool := new(core.GasPool).AddGas(18446744073709551615)
+	ret, usedGas, failed, err := core.NewStateTransition(vmenv, msg, gaspool).TransitionDb()
+	return ret, usedGas, failed, err
+}
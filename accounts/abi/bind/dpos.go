@@ -0,0 +1,132 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// dposTxGasLimit covers the intrinsic gas of any of the fixed size dpos
+// operations with a comfortable margin.
+const dposTxGasLimit = uint64(100000)
+
+// LoginCandidate creates, signs and submits a transaction registering the
+// transacting account as an election candidate.
+func LoginCandidate(opts *TransactOpts, backend ContractTransactor) (*types.Transaction, error) {
+	return transactDpos(opts, backend, func(nonce uint64) *types.Transaction {
+		return types.NewLoginCandidateTransaction(nonce, gasLimitOf(opts), opts.GasPrice)
+	})
+}
+
+// LogoutCandidate creates, signs and submits a transaction removing the
+// transacting account from the candidate list.
+func LogoutCandidate(opts *TransactOpts, backend ContractTransactor) (*types.Transaction, error) {
+	return transactDpos(opts, backend, func(nonce uint64) *types.Transaction {
+		return types.NewLogoutCandidateTransaction(nonce, gasLimitOf(opts), opts.GasPrice)
+	})
+}
+
+// Delegate creates, signs and submits a transaction voting for the given
+// candidate with the transacting account.
+func Delegate(opts *TransactOpts, backend ContractTransactor, candidate common.Address) (*types.Transaction, error) {
+	return transactDpos(opts, backend, func(nonce uint64) *types.Transaction {
+		return types.NewDelegateTransaction(nonce, candidate, gasLimitOf(opts), opts.GasPrice)
+	})
+}
+
+// UnDelegate creates, signs and submits a transaction retracting the vote of
+// the transacting account from the given candidate.
+func UnDelegate(opts *TransactOpts, backend ContractTransactor, candidate common.Address) (*types.Transaction, error) {
+	return transactDpos(opts, backend, func(nonce uint64) *types.Transaction {
+		return types.NewUnDelegateTransaction(nonce, candidate, gasLimitOf(opts), opts.GasPrice)
+	})
+}
+
+// SetCommission creates, signs and submits a transaction declaring the
+// commission rate, in percent, the transacting candidate deducts from
+// delegator rewards.
+func SetCommission(opts *TransactOpts, backend ContractTransactor, rate uint64) (*types.Transaction, error) {
+	return transactDpos(opts, backend, func(nonce uint64) *types.Transaction {
+		return types.NewSetCommissionTransaction(nonce, rate, gasLimitOf(opts), opts.GasPrice)
+	})
+}
+
+// RelayMetaVote wraps a vote signed off-chain by a delegator into a MetaVote
+// transaction paid for by the transacting account and submits it.
+func RelayMetaVote(opts *TransactOpts, backend ContractTransactor, vote *types.MetaVoteMsg) (*types.Transaction, error) {
+	var buildErr error
+	tx, err := transactDpos(opts, backend, func(nonce uint64) *types.Transaction {
+		tx, err := types.NewMetaVoteTransaction(nonce, vote, gasLimitOf(opts), opts.GasPrice)
+		if err != nil {
+			buildErr = err
+			return nil
+		}
+		return tx
+	})
+	if buildErr != nil {
+		return nil, buildErr
+	}
+	return tx, err
+}
+
+func gasLimitOf(opts *TransactOpts) uint64 {
+	if opts.GasLimit != 0 {
+		return opts.GasLimit
+	}
+	return dposTxGasLimit
+}
+
+// transactDpos resolves the nonce and gas price, builds the concrete dpos
+// transaction through the given constructor, signs it and submits it.
+func transactDpos(opts *TransactOpts, backend ContractTransactor, build func(nonce uint64) *types.Transaction) (*types.Transaction, error) {
+	var err error
+
+	var nonce uint64
+	if opts.Nonce == nil {
+		nonce, err = backend.PendingNonceAt(ensureContext(opts.Context), opts.From)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve account nonce: %v", err)
+		}
+	} else {
+		nonce = opts.Nonce.Uint64()
+	}
+	if opts.GasPrice == nil {
+		opts.GasPrice, err = backend.SuggestGasPrice(ensureContext(opts.Context))
+		if err != nil {
+			return nil, fmt.Errorf("failed to suggest gas price: %v", err)
+		}
+	}
+	rawTx := build(nonce)
+	if rawTx == nil {
+		return nil, errors.New("failed to build dpos transaction")
+	}
+	if opts.Signer == nil {
+		return nil, errors.New("no signer to authorize the transaction with")
+	}
+	signedTx, err := opts.Signer(types.HomesteadSigner{}, opts.From, rawTx)
+	if err != nil {
+		return nil, err
+	}
+	if err := backend.SendTransaction(ensureContext(opts.Context), signedTx); err != nil {
+		return nil, err
+	}
+	return signedTx, nil
+}
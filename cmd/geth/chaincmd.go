@@ -123,6 +123,37 @@ if already existing.`,
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 The export-preimages command export hash preimages to an RLP encoded stream`,
+	}
+	importDposCommand = cli.Command{
+		Action:    utils.MigrateFlags(importDpos),
+		Name:      "import-dpos",
+		Usage:     "Import the DPoS consensus tries from an RLP stream",
+		ArgsUsage: "<datafile>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.LightModeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The import-dpos command imports the DPoS consensus tries from an RLP encoded
+stream, so a node restored from a state snapshot can take part in consensus
+without re-executing the chain.`,
+	}
+	exportDposCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportDpos),
+		Name:      "export-dpos",
+		Usage:     "Export the DPoS consensus tries into an RLP stream",
+		ArgsUsage: "<dumpfile> [<blockNum>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.LightModeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-dpos command exports the DPoS consensus tries of the given block
+(the head block when omitted) to an RLP encoded stream.`,
 	}
 	copydbCommand = cli.Command{
 		Action:    utils.MigrateFlags(copyDb),
@@ -163,11 +194,20 @@ Remove blockchain and state databases`,
 			utils.DataDirFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
+			dumpDposFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 The arguments are interpreted as block numbers or hashes.
-Use "ethereum dump 0" to dump the genesis block.`,
+Use "ethereum dump 0" to dump the genesis block. With --dpos the dump also
+contains the full dpos consensus state committed by the block.`,
+	}
+
+	// dumpDposFlag extends a dump with the dpos consensus state, which lives
+	// outside the world state trie.
+	dumpDposFlag = cli.BoolFlag{
+		Name:  "dpos",
+		Usage: "Include the full dpos context (candidates, delegations, votes, validators, mint counters)",
 	}
 )
 
@@ -363,6 +403,48 @@ func exportPreimages(ctx *cli.Context) error {
 	return nil
 }
 
+// importDpos imports dpos consensus tries from the specified file.
+func importDpos(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack := makeFullNode(ctx)
+	diskdb := utils.MakeChainDatabase(ctx, stack)
+
+	start := time.Now()
+	if err := utils.ImportDposContext(diskdb, ctx.Args().First()); err != nil {
+		utils.Fatalf("Import error: %v\n", err)
+	}
+	fmt.Printf("Import done in %v\n", time.Since(start))
+	return nil
+}
+
+// exportDpos dumps the dpos consensus tries of a block to the specified file.
+func exportDpos(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+
+	header := chain.CurrentBlock().Header()
+	if len(ctx.Args()) > 1 {
+		num, err := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		if err != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+		}
+		if header = chain.GetHeaderByNumber(num); header == nil {
+			utils.Fatalf("Export error: block %d not found\n", num)
+		}
+	}
+	start := time.Now()
+	if err := utils.ExportDposContext(chainDb, header, ctx.Args().First()); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
 func copyDb(ctx *cli.Context) error {
 	// Ensure we have a source chain directory to copy
 	if len(ctx.Args()) != 1 {
@@ -460,6 +542,26 @@ func dump(ctx *cli.Context) error {
 				utils.Fatalf("could not create new state: %v", err)
 			}
 			fmt.Printf("%s\n", state.Dump())
+			// The consensus state lives outside the world state; print the
+			// dpos trie roots so snapshots can be checked for completeness,
+			// or the full trie contents when explicitly requested
+			if proto := block.Header().DposContext; proto != nil {
+				if ctx.GlobalBool(dumpDposFlag.Name) {
+					dposContext, err := types.NewDposContextFromProto(trie.NewDatabase(chainDb), proto)
+					if err != nil {
+						utils.Fatalf("could not open dpos context: %v", err)
+					}
+					dposDump, err := dposContext.RawDump()
+					if err != nil {
+						utils.Fatalf("could not dump dpos context: %v", err)
+					}
+					if enc, err := json.MarshalIndent(dposDump, "", "    "); err == nil {
+						fmt.Printf("%s\n", enc)
+					}
+				} else if enc, err := json.MarshalIndent(proto, "", "    "); err == nil {
+					fmt.Printf("%s\n", enc)
+				}
+			}
 		}
 	}
 	chainDb.Close()
@@ -0,0 +1,677 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/dpos"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dposBlockFlag = cli.Uint64Flag{
+		Name:  "block",
+		Usage: "Block number to inspect the dpos context at (default: latest)",
+	}
+	dposReplayFromFlag = cli.Uint64Flag{
+		Name:  "from",
+		Usage: "First block of the range to replay elections in",
+	}
+	dposReplayToFlag = cli.Uint64Flag{
+		Name:  "to",
+		Usage: "Last block of the range to replay elections in (default: latest)",
+	}
+	dposIndexFromFlag = cli.Uint64Flag{
+		Name:  "from",
+		Usage: "First block to replay delegation changes from",
+	}
+	dposEpochFlag = cli.Uint64Flag{
+		Name:  "epoch",
+		Usage: "Epoch to export the validator set of (default: head epoch)",
+	}
+	dposImportEpochFlag = cli.Uint64Flag{
+		Name:  "epoch",
+		Usage: "Epoch to install the validator set at (default: the epoch in the export)",
+	}
+	dposKeyFileFlag = cli.StringFlag{
+		Name:  "keyfile",
+		Usage: "Import the sealing key from a hex encoded private key file instead of generating one",
+	}
+	dposSendFlag = cli.StringFlag{
+		Name:  "send",
+		Usage: "RPC endpoint of a synced node to submit the candidate registration through",
+	}
+	dposNonceFlag = cli.Uint64Flag{
+		Name:  "nonce",
+		Usage: "Account nonce to sign the offline registration with (ignored with --send)",
+	}
+	dposGasPriceFlag = cli.Uint64Flag{
+		Name:  "gasprice",
+		Usage: "Gas price in wei to sign the offline registration with (ignored with --send)",
+		Value: params.Shannon,
+	}
+	dposChainIDFlag = cli.Uint64Flag{
+		Name:  "chainid",
+		Usage: "Chain ID to sign the offline registration with (ignored with --send)",
+	}
+	dposCommand = cli.Command{
+		Name:     "dpos",
+		Usage:    "Inspect the delegated proof-of-stake state",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The dpos subcommands open the database read-only and dump the contents of the
+dpos context tries at a given block, for offline debugging of consensus
+disagreements.`,
+		Subcommands: []cli.Command{
+			{
+				Action:    utils.MigrateFlags(dposValidators),
+				Name:      "validators",
+				Usage:     "Print the validator set of an epoch",
+				Flags:     []cli.Flag{utils.DataDirFlag, dposBlockFlag},
+				Category:  "BLOCKCHAIN COMMANDS",
+			},
+			{
+				Action:    utils.MigrateFlags(dposCandidates),
+				Name:      "candidates",
+				Usage:     "Print the registered election candidates",
+				Flags:     []cli.Flag{utils.DataDirFlag, dposBlockFlag},
+				Category:  "BLOCKCHAIN COMMANDS",
+			},
+			{
+				Action:    utils.MigrateFlags(dposVotes),
+				Name:      "votes",
+				Usage:     "Print the vote cast by a delegator",
+				ArgsUsage: "<address>",
+				Flags:     []cli.Flag{utils.DataDirFlag, dposBlockFlag},
+				Category:  "BLOCKCHAIN COMMANDS",
+			},
+			{
+				Action:    utils.MigrateFlags(dposCheck),
+				Name:      "check",
+				Usage:     "Verify the internal consistency of the dpos context",
+				Flags:     []cli.Flag{utils.DataDirFlag, dposBlockFlag},
+				Category:  "BLOCKCHAIN COMMANDS",
+			},
+			{
+				Action:    utils.MigrateFlags(dposReplayElection),
+				Name:      "replay-election",
+				Usage:     "Recompute historical elections and diff them against the chain",
+				Flags:     []cli.Flag{utils.DataDirFlag, dposReplayFromFlag, dposReplayToFlag},
+				Category:  "BLOCKCHAIN COMMANDS",
+				Description: `
+Recomputes countVotes and tryElect for every epoch boundary between --from and
+--to and compares the result against the validator sets actually recorded
+on-chain, printing any diffs. Useful for diagnosing suspected election
+manipulation or state corruption.`,
+			},
+			{
+				Action:    utils.MigrateFlags(dposInitValidator),
+				Name:      "init-validator",
+				Usage:     "Walk through setting up this node as a validator",
+				Flags:     []cli.Flag{utils.DataDirFlag, utils.PasswordFileFlag, dposKeyFileFlag, dposSendFlag, dposNonceFlag, dposGasPriceFlag, dposChainIDFlag},
+				Category:  "BLOCKCHAIN COMMANDS",
+				Description: `
+Performs the manual steps of validator onboarding in one pass: checks that the
+system clock is synchronised tightly enough for slot based sealing, generates
+or imports the sealing key into the keystore, signs the candidate registration
+transaction and either submits it through the node given with --send or prints
+it for offline submission, and finally prints the flags the sealing node has
+to be started with.`,
+			},
+			{
+				Action:    utils.MigrateFlags(dposIndex),
+				Name:      "index",
+				Usage:     "Build the delegation index offline from the existing database",
+				Flags:     []cli.Flag{utils.DataDirFlag, dposIndexFromFlag},
+				Category:  "BLOCKCHAIN COMMANDS",
+				Description: `
+Replays the delegate trie changes recorded in the existing database from
+--from up to the chain head and constructs the delegation index without a
+resync. Progress is flushed periodically and an interrupted run resumes from
+the last flushed block.`,
+			},
+			{
+				Action:    utils.MigrateFlags(dposExportValset),
+				Name:      "export-valset",
+				Usage:     "Export the validator set of an epoch for disaster recovery",
+				Flags:     []cli.Flag{utils.DataDirFlag, dposEpochFlag},
+				Category:  "BLOCKCHAIN COMMANDS",
+				Description: `
+Writes the validator set of an epoch to stdout as a JSON document, together
+with the typed data hash the governance signers have to sign to authorise
+installing it on another node. The payload is the same one the on-chain
+validator override transaction carries, so the collected signatures stay
+usable for a ForceValidators transaction once the network is live again.`,
+			},
+			{
+				Action:    utils.MigrateFlags(dposImportValset),
+				Name:      "import-valset",
+				Usage:     "Install an exported validator set on a recovering node",
+				ArgsUsage: "<exportfile>",
+				Flags:     []cli.Flag{utils.DataDirFlag, dposImportEpochFlag},
+				Category:  "BLOCKCHAIN COMMANDS",
+				Description: `
+Verifies that the export carries signatures from a quorum of the governance
+signers configured in genesis and records its validator set as the epoch
+summary of the target epoch. On the next start the consensus engine falls
+back to that summary whenever the epoch trie yields no usable validator set,
+letting the validators coordinate a restart after catastrophic data loss.`,
+			},
+			{
+				Action:    utils.MigrateFlags(dposMintCnt),
+				Name:      "mintcnt",
+				Usage:     "Print the per validator block production counters of an epoch",
+				ArgsUsage: "<epoch>",
+				Flags:     []cli.Flag{utils.DataDirFlag, dposBlockFlag},
+				Category:  "BLOCKCHAIN COMMANDS",
+			},
+		},
+	}
+)
+
+// makeDposContext opens the blockchain read-only and returns the dpos context
+// at the block selected with --block, defaulting to the chain head.
+func makeDposContext(ctx *cli.Context) (*types.DposContext, func()) {
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+
+	header := chain.CurrentHeader()
+	if ctx.IsSet(dposBlockFlag.Name) {
+		header = chain.GetHeaderByNumber(ctx.Uint64(dposBlockFlag.Name))
+	}
+	if header == nil {
+		utils.Fatalf("block not found")
+	}
+	if header.DposContext == nil {
+		utils.Fatalf("block %d carries no dpos context; is this a dpos chain?", header.Number)
+	}
+	dposContext, err := types.NewDposContextFromProto(trie.NewDatabase(chainDb), header.DposContext)
+	if err != nil {
+		utils.Fatalf("failed to open dpos context: %v", err)
+	}
+	return dposContext, func() { chainDb.Close() }
+}
+
+func dposValidators(ctx *cli.Context) error {
+	dposContext, release := makeDposContext(ctx)
+	defer release()
+
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		utils.Fatalf("failed to get validators: %v", err)
+	}
+	for i, validator := range validators {
+		fmt.Printf("%3d %s\n", i, validator.Hex())
+	}
+	return nil
+}
+
+func dposCandidates(ctx *cli.Context) error {
+	dposContext, release := makeDposContext(ctx)
+	defer release()
+
+	iter := trie.NewIterator(dposContext.CandidateTrie().NodeIterator(nil))
+	for iter.Next() {
+		if len(iter.Key) != common.AddressLength {
+			continue
+		}
+		addr := common.BytesToAddress(iter.Value)
+		fmt.Printf("%s commission=%d%%\n", addr.Hex(), dposContext.Commission(addr))
+	}
+	return nil
+}
+
+func dposVotes(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("delegator address required as argument")
+	}
+	delegator := common.HexToAddress(ctx.Args().First())
+
+	dposContext, release := makeDposContext(ctx)
+	defer release()
+
+	candidate := dposContext.VoteTrie().Get(delegator.Bytes())
+	if candidate == nil {
+		fmt.Println("no active vote")
+		return nil
+	}
+	fmt.Printf("%s -> %s\n", delegator.Hex(), common.BytesToAddress(candidate).Hex())
+	return nil
+}
+
+func dposCheck(ctx *cli.Context) error {
+	dposContext, release := makeDposContext(ctx)
+	defer release()
+
+	if err := dposContext.CheckConsistency(); err != nil {
+		utils.Fatalf("dpos context inconsistent: %v", err)
+	}
+	fmt.Println("dpos context consistent")
+	return nil
+}
+
+func dposReplayElection(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	from := ctx.Uint64(dposReplayFromFlag.Name)
+	to := chain.CurrentHeader().Number.Uint64()
+	if ctx.IsSet(dposReplayToFlag.Name) {
+		to = ctx.Uint64(dposReplayToFlag.Name)
+	}
+	if from == 0 {
+		from = 1 // the genesis validator set is not elected
+	}
+	genesis := chain.GetHeaderByNumber(0)
+	triedb := trie.NewDatabase(chainDb)
+
+	const epochInterval = int64(86400)
+	var mismatches int
+	for number := from; number <= to; number++ {
+		header := chain.GetHeaderByNumber(number)
+		if header == nil {
+			utils.Fatalf("block %d not found", number)
+		}
+		parent := chain.GetHeaderByNumber(number - 1)
+		// Only epoch boundary blocks run an election
+		if parent.Time.Int64()/epochInterval == header.Time.Int64()/epochInterval {
+			continue
+		}
+		dposContext, err := types.NewDposContextFromProto(triedb, parent.DposContext)
+		if err != nil {
+			utils.Fatalf("failed to open dpos context at block %d: %v", number-1, err)
+		}
+		statedb, err := chain.StateAt(parent.Root)
+		if err != nil {
+			utils.Fatalf("failed to open state at block %d: %v", number-1, err)
+		}
+		recomputed, err := dpos.ReplayElection(chain.Config().Dpos, dposContext, statedb, genesis, parent, header.Time.Int64())
+		if err != nil {
+			utils.Fatalf("failed to replay election at block %d: %v", number, err)
+		}
+		recorded, err := types.NewDposContextFromProto(triedb, header.DposContext)
+		if err != nil {
+			utils.Fatalf("failed to open dpos context at block %d: %v", number, err)
+		}
+		onchain, err := recorded.GetValidators()
+		if err != nil {
+			utils.Fatalf("failed to get validators at block %d: %v", number, err)
+		}
+		if validatorsEqual(recomputed, onchain) {
+			fmt.Printf("block %d: election matches (%d validators)\n", number, len(onchain))
+			continue
+		}
+		mismatches++
+		fmt.Printf("block %d: ELECTION MISMATCH\n", number)
+		fmt.Printf("  recomputed: %v\n", recomputed)
+		fmt.Printf("  on-chain:   %v\n", onchain)
+	}
+	if mismatches > 0 {
+		utils.Fatalf("%d epoch boundaries diverge from the recomputed election", mismatches)
+	}
+	return nil
+}
+
+func validatorsEqual(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// dposRegistrationGas covers the intrinsic gas of the candidate registration
+// with a comfortable margin.
+const dposRegistrationGas = uint64(100000)
+
+// maxOnboardingDrift is the largest clock drift the onboarding wizard lets
+// pass. A validator a second off its 10 second slots seals headers its peers
+// reject for timestamp drift, so anything beyond a modest fraction of a slot
+// is a setup error worth stopping for.
+const maxOnboardingDrift = time.Second
+
+func dposInitValidator(ctx *cli.Context) error {
+	// A validator sealing off-beat produces headers its peers reject, so
+	// check the clock before anything else
+	fmt.Println("Checking system clock against NTP...")
+	if drift, err := ntpDrift(); err != nil {
+		fmt.Printf("  could not measure drift: %v; make sure the clock is synchronised some other way\n", err)
+	} else if drift < -maxOnboardingDrift || drift > maxOnboardingDrift {
+		utils.Fatalf("System clock is off by %v; enable network time synchronisation and retry", drift)
+	} else {
+		fmt.Printf("  clock drift %v, ok\n", drift)
+	}
+	// Generate or import the sealing key into the keystore
+	stack, _ := makeConfigNode(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	password := getPassPhrase("The sealing key is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+
+	var (
+		account accounts.Account
+		err     error
+	)
+	if keyfile := ctx.String(dposKeyFileFlag.Name); keyfile != "" {
+		key, err2 := crypto.LoadECDSA(keyfile)
+		if err2 != nil {
+			utils.Fatalf("Failed to load the sealing key: %v", err2)
+		}
+		if account, err = ks.ImportECDSA(key, password); err != nil {
+			utils.Fatalf("Failed to import the sealing key: %v", err)
+		}
+		fmt.Printf("Imported sealing key %s\n", account.Address.Hex())
+	} else {
+		if account, err = ks.NewAccount(password); err != nil {
+			utils.Fatalf("Failed to generate the sealing key: %v", err)
+		}
+		fmt.Printf("Generated sealing key %s\n", account.Address.Hex())
+	}
+	// Sign the candidate registration and submit or print it
+	if endpoint := ctx.String(dposSendFlag.Name); endpoint != "" {
+		client, err := ethclient.Dial(endpoint)
+		if err != nil {
+			utils.Fatalf("Failed to dial %s: %v", endpoint, err)
+		}
+		nonce, err := client.PendingNonceAt(context.Background(), account.Address)
+		if err != nil {
+			utils.Fatalf("Failed to retrieve the account nonce: %v", err)
+		}
+		gasPrice, err := client.SuggestGasPrice(context.Background())
+		if err != nil {
+			utils.Fatalf("Failed to retrieve the gas price: %v", err)
+		}
+		chainID, err := client.NetworkID(context.Background())
+		if err != nil {
+			utils.Fatalf("Failed to retrieve the chain ID: %v", err)
+		}
+		tx := types.NewLoginCandidateTransaction(nonce, dposRegistrationGas, gasPrice)
+		signed, err := ks.SignTxWithPassphrase(account, password, tx, chainID)
+		if err != nil {
+			utils.Fatalf("Failed to sign the candidate registration: %v", err)
+		}
+		if err := client.SendTransaction(context.Background(), signed); err != nil {
+			utils.Fatalf("Failed to submit the candidate registration: %v", err)
+		}
+		fmt.Printf("Candidate registration submitted: %s\n", signed.Hash().Hex())
+	} else {
+		chainID := ctx.Uint64(dposChainIDFlag.Name)
+		if chainID == 0 {
+			utils.Fatalf("Either --send or --chainid is required to sign the candidate registration")
+		}
+		gasPrice := new(big.Int).SetUint64(ctx.Uint64(dposGasPriceFlag.Name))
+		tx := types.NewLoginCandidateTransaction(ctx.Uint64(dposNonceFlag.Name), dposRegistrationGas, gasPrice)
+		signed, err := ks.SignTxWithPassphrase(account, password, tx, new(big.Int).SetUint64(chainID))
+		if err != nil {
+			utils.Fatalf("Failed to sign the candidate registration: %v", err)
+		}
+		raw, err := rlp.EncodeToBytes(signed)
+		if err != nil {
+			utils.Fatalf("Failed to encode the candidate registration: %v", err)
+		}
+		fmt.Printf("Signed candidate registration, submit via eth_sendRawTransaction:\n  0x%x\n", raw)
+	}
+	// Print the flags the sealing node needs; the candidate address doubles
+	// as the etherbase the engine seals under
+	fmt.Println("\nSetup complete. Start the validator with:")
+	fmt.Printf("\n  geth --datadir %s --mine --etherbase %s --unlock %s --password <passwordfile>\n\n", stack.DataDir(), account.Address.Hex(), account.Address.Hex())
+	fmt.Println("The candidate still needs enough stake delegated to it to win a validator slot.")
+	return nil
+}
+
+// ntpDrift measures the local clock drift with a single simple-NTP exchange,
+// the same sanity check the p2p layer runs when discovery struggles. The
+// precision is tens of milliseconds, plenty to validate a slot clock.
+func ntpDrift() (time.Duration, error) {
+	addr, err := net.ResolveUDPAddr("udp", "pool.ntp.org:123")
+	if err != nil {
+		return 0, err
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	// An empty request with only the version and client mode bits set
+	request := make([]byte, 48)
+	request[0] = 3<<3 | 3
+
+	sent := time.Now()
+	if _, err = conn.Write(request); err != nil {
+		return 0, err
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	reply := make([]byte, 48)
+	if _, err = conn.Read(reply); err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(sent)
+
+	sec := uint64(reply[43]) | uint64(reply[42])<<8 | uint64(reply[41])<<16 | uint64(reply[40])<<24
+	frac := uint64(reply[47]) | uint64(reply[46])<<8 | uint64(reply[45])<<16 | uint64(reply[44])<<24
+	nanosec := sec*1e9 + (frac*1e9)>>32
+
+	t := time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(nanosec)).Local()
+	return sent.Sub(t) + elapsed/2, nil
+}
+
+func dposIndex(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	if err := chain.BackfillDelegationIndex(ctx.Uint64(dposIndexFromFlag.Name)); err != nil {
+		utils.Fatalf("index backfill failed: %v", err)
+	}
+	return nil
+}
+
+// valsetExport is the JSON document the export-valset and import-valset
+// subcommands exchange. The validator set and nonce double as the payload of
+// a types.ValidatorOverride, so the signing hash and the signatures the
+// governance signers attach are the same ones the on-chain override
+// transaction would carry.
+type valsetExport struct {
+	ChainID     *hexutil.Big      `json:"chainId"`
+	Epoch       uint64            `json:"epoch"`
+	Nonce       uint64            `json:"nonce"`
+	Validators  []common.Address  `json:"validators"`
+	SigningHash common.Hash       `json:"signingHash"`
+	Signatures  []valsetSignature `json:"signatures,omitempty"`
+}
+
+// valsetSignature is one governance signer's signature over the export's
+// signing hash, appended to the document out of band before importing.
+type valsetSignature struct {
+	V *hexutil.Big `json:"v"`
+	R *hexutil.Big `json:"r"`
+	S *hexutil.Big `json:"s"`
+}
+
+func dposExportValset(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	config := chain.Config()
+	if config.Dpos == nil {
+		utils.Fatalf("not a dpos chain")
+	}
+	head := chain.CurrentHeader()
+	if head.DposContext == nil {
+		utils.Fatalf("head block carries no dpos context")
+	}
+	dposContext, err := types.NewDposContextFromProto(trie.NewDatabase(chainDb), head.DposContext)
+	if err != nil {
+		utils.Fatalf("failed to open dpos context: %v", err)
+	}
+	headEpoch := uint64(head.Time.Int64() / config.Dpos.EpochIntervalSeconds())
+
+	epoch := headEpoch
+	if ctx.IsSet(dposEpochFlag.Name) {
+		epoch = ctx.Uint64(dposEpochFlag.Name)
+	}
+	// Prefer the epoch summary; the head epoch can also be read straight out
+	// of the epoch trie when no summary was recorded
+	var validators []common.Address
+	if summary := rawdb.ReadEpochSummary(chainDb, epoch); summary != nil {
+		validators = summary.Validators
+	} else if epoch == headEpoch {
+		if validators, err = dposContext.GetValidators(); err != nil {
+			utils.Fatalf("failed to get validators: %v", err)
+		}
+	} else {
+		utils.Fatalf("no summary recorded for epoch %d, only the head epoch %d can be exported without one", epoch, headEpoch)
+	}
+	if len(validators) == 0 {
+		utils.Fatalf("epoch %d has no validators to export", epoch)
+	}
+	override := &types.ValidatorOverride{Validators: validators, Nonce: dposContext.GovernanceNonce()}
+	export := &valsetExport{
+		ChainID:     (*hexutil.Big)(config.ChainID),
+		Epoch:       epoch,
+		Nonce:       override.Nonce,
+		Validators:  validators,
+		SigningHash: override.TypedDataHash(config.ChainID),
+	}
+	blob, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		utils.Fatalf("failed to encode export: %v", err)
+	}
+	fmt.Printf("%s\n", blob)
+	return nil
+}
+
+func dposImportValset(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("export file required as argument")
+	}
+	blob, err := ioutil.ReadFile(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("failed to read export: %v", err)
+	}
+	export := new(valsetExport)
+	if err := json.Unmarshal(blob, export); err != nil {
+		utils.Fatalf("failed to decode export: %v", err)
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	config := chain.Config()
+	if config.Dpos == nil {
+		utils.Fatalf("not a dpos chain")
+	}
+	if len(config.Dpos.GovernanceSigners) == 0 || config.Dpos.GovernanceThreshold == 0 {
+		utils.Fatalf("validator set import disabled: no governance signers configured")
+	}
+	if export.ChainID == nil || export.ChainID.ToInt().Cmp(config.ChainID) != 0 {
+		utils.Fatalf("export is for chain %v, this database is chain %v", export.ChainID, config.ChainID)
+	}
+	if len(export.Validators) == 0 {
+		utils.Fatalf("export carries no validators")
+	}
+	// Rebuild the override the signers authorised and verify the quorum with
+	// the same checks the on-chain transaction goes through
+	override := &types.ValidatorOverride{Validators: export.Validators, Nonce: export.Nonce}
+	for _, sig := range export.Signatures {
+		override.V = append(override.V, sig.V.ToInt())
+		override.R = append(override.R, sig.R.ToInt())
+		override.S = append(override.S, sig.S.ToInt())
+	}
+	signers, err := override.RecoverSigners(config.ChainID)
+	if err != nil {
+		utils.Fatalf("failed to recover the export signers: %v", err)
+	}
+	quorum := make(map[common.Address]struct{})
+	for _, signer := range signers {
+		authorized := false
+		for _, governor := range config.Dpos.GovernanceSigners {
+			if signer == governor {
+				authorized = true
+				break
+			}
+		}
+		if !authorized {
+			utils.Fatalf("export signer %x is not a governance signer", signer)
+		}
+		quorum[signer] = struct{}{}
+	}
+	if uint64(len(quorum)) < config.Dpos.GovernanceThreshold {
+		utils.Fatalf("export quorum not met: have %d, want %d", len(quorum), config.Dpos.GovernanceThreshold)
+	}
+	epoch := export.Epoch
+	if ctx.IsSet(dposImportEpochFlag.Name) {
+		epoch = ctx.Uint64(dposImportEpochFlag.Name)
+	}
+	rawdb.WriteEpochSummary(chainDb, &rawdb.EpochSummary{Epoch: epoch, Validators: export.Validators})
+	fmt.Printf("Installed %d validators as the epoch %d summary, signed by %d governance signers.\n", len(export.Validators), epoch, len(quorum))
+	fmt.Println("The engine falls back to the summary while the epoch trie yields no usable set.")
+	return nil
+}
+
+func dposMintCnt(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("epoch number required as argument")
+	}
+	epoch, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+	if err != nil {
+		utils.Fatalf("invalid epoch number: %v", err)
+	}
+	dposContext, release := makeDposContext(ctx)
+	defer release()
+
+	prefix := make([]byte, 8)
+	binary.BigEndian.PutUint64(prefix, epoch)
+	iter := trie.NewIterator(dposContext.MintCntTrie().NodeIterator(prefix))
+	for iter.Next() {
+		if !bytes.HasPrefix(iter.Key, prefix) {
+			break
+		}
+		validator := common.BytesToAddress(iter.Key[8:])
+		cnt := binary.BigEndian.Uint64(iter.Value)
+		fmt.Printf("%s %d\n", validator.Hex(), cnt)
+	}
+	return nil
+}
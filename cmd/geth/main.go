@@ -86,12 +86,14 @@ var (
 		utils.LightModeFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.DposArchiveFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
 		utils.CacheGCFlag,
+		utils.DposCacheFlag,
 		utils.TrieCacheGenFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
@@ -101,6 +103,13 @@ var (
 		utils.MinerThreadsFlag,
 		utils.MiningEnabledFlag,
 		utils.TargetGasLimitFlag,
+		utils.DposStandbyFlag,
+		utils.DposReplicaFlag,
+		utils.DposValidatorSlotsFlag,
+		utils.DposShutdownGraceFlag,
+		utils.DposHeartbeatSlotsFlag,
+		utils.DposForkWatchFlag,
+		utils.DposFaultInjectFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
@@ -109,6 +118,7 @@ var (
 		utils.NodeKeyHexFlag,
 		utils.DeveloperFlag,
 		utils.DeveloperPeriodFlag,
+		utils.DeveloperDposFlag,
 		utils.TestnetFlag,
 		utils.RinkebyFlag,
 		utils.VMEnableDebugFlag,
@@ -167,9 +177,13 @@ func init() {
 		exportCommand,
 		importPreimagesCommand,
 		exportPreimagesCommand,
+		importDposCommand,
+		exportDposCommand,
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		// See dposcmd.go:
+		dposCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
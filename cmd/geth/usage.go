@@ -76,6 +76,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RinkebyFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.DposArchiveFlag,
 			utils.EthStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightServFlag,
@@ -87,6 +88,8 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.DeveloperFlag,
 			utils.DeveloperPeriodFlag,
+			utils.DeveloperDposFlag,
+			utils.DposFaultInjectFlag,
 		},
 	},
 	{
@@ -131,6 +134,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.CacheFlag,
 			utils.CacheDatabaseFlag,
 			utils.CacheGCFlag,
+			utils.DposCacheFlag,
 			utils.TrieCacheGenFlag,
 		},
 	},
@@ -188,6 +192,12 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
 			utils.ExtraDataFlag,
+			utils.DposStandbyFlag,
+			utils.DposReplicaFlag,
+			utils.DposValidatorSlotsFlag,
+			utils.DposShutdownGraceFlag,
+			utils.DposHeartbeatSlotsFlag,
+			utils.DposForkWatchFlag,
 		},
 	},
 	{
@@ -52,6 +52,7 @@ func (w *wizard) makeGenesis() {
 	fmt.Println("Which consensus engine to use? (default = clique)")
 	fmt.Println(" 1. Ethash - proof-of-work")
 	fmt.Println(" 2. Clique - proof-of-authority")
+	fmt.Println(" 3. Dpos - delegated proof-of-stake")
 
 	choice := w.read()
 	switch {
@@ -98,6 +99,46 @@ func (w *wizard) makeGenesis() {
 			copy(genesis.ExtraData[32+i*common.AddressLength:], signer[:])
 		}
 
+	case choice == "3":
+		// In the case of dpos, configure the slot timing and the bootstrap
+		// validator set
+		genesis.Difficulty = big.NewInt(1)
+		genesis.Config.Dpos = new(params.DposConfig)
+
+		fmt.Println()
+		fmt.Println("How many seconds should blocks take? (default = 10)")
+		genesis.Config.Dpos.BlockInterval = uint64(w.readDefaultInt(10))
+
+		fmt.Println()
+		fmt.Println("How many seconds should an epoch last? (default = 86400)")
+		genesis.Config.Dpos.EpochInterval = uint64(w.readDefaultInt(86400))
+
+		fmt.Println()
+		fmt.Println("How many validators should be elected per epoch? (default = 21)")
+		genesis.Config.Dpos.MaxValidatorSize = uint64(w.readDefaultInt(21))
+
+		// We also need the initial validator set of the first epoch
+		fmt.Println()
+		fmt.Println("Which accounts are the initial validators? (mandatory at least one)")
+
+		var validators []common.Address
+		for {
+			if address := w.readAddress(); address != nil {
+				validators = append(validators, *address)
+				continue
+			}
+			if len(validators) > 0 {
+				break
+			}
+		}
+		if uint64(len(validators)) > genesis.Config.Dpos.MaxValidatorSize {
+			log.Crit("More initial validators than validator slots", "validators", len(validators), "slots", genesis.Config.Dpos.MaxValidatorSize)
+		}
+		// The genesis dpos context registers every initial validator as a
+		// candidate delegating to itself, seeding the first election
+		genesis.Config.Dpos.Validators = validators
+		genesis.ExtraData = make([]byte, 32+65)
+
 	default:
 		log.Crit("Invalid consensus engine choice", "choice", choice)
 	}
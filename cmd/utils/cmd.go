@@ -37,6 +37,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 const (
@@ -310,3 +311,122 @@ func ExportPreimages(db *ethdb.LDBDatabase, fn string) error {
 	log.Info("Exported preimages", "file", fn)
 	return nil
 }
+
+// dposTrieEntry is one key/value pair of a dpos trie in an export stream,
+// tagged with the trie it belongs to.
+type dposTrieEntry struct {
+	Trie  uint8
+	Key   []byte
+	Value []byte
+}
+
+// ExportDposContext exports the five dpos consensus tries rooted in the given
+// header into the specified file, so a state-only restore can produce a
+// consensus-capable node. The stream starts with the expected roots, followed
+// by the key/value pairs of every trie.
+func ExportDposContext(db ethdb.Database, header *types.Header, fn string) error {
+	log.Info("Exporting dpos context", "file", fn, "block", header.Number)
+
+	// Open the file handle and potentially wrap with a gzip stream
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	dposContext, err := types.NewDposContextFromProto(trie.NewDatabase(db), header.DposContext)
+	if err != nil {
+		return err
+	}
+	if err := rlp.Encode(writer, header.DposContext); err != nil {
+		return err
+	}
+	tries := []*trie.Trie{
+		dposContext.EpochTrie(),
+		dposContext.DelegateTrie(),
+		dposContext.VoteTrie(),
+		dposContext.CandidateTrie(),
+		dposContext.MintCntTrie(),
+	}
+	for i, t := range tries {
+		it := trie.NewIterator(t.NodeIterator(nil))
+		for it.Next() {
+			entry := &dposTrieEntry{Trie: uint8(i), Key: it.Key, Value: it.Value}
+			if err := rlp.Encode(writer, entry); err != nil {
+				return err
+			}
+		}
+	}
+	log.Info("Exported dpos context", "file", fn)
+	return nil
+}
+
+// ImportDposContext imports dpos consensus tries from an export stream,
+// rebuilding the tries key by key and verifying that the resulting roots
+// match the ones recorded at export time.
+func ImportDposContext(db ethdb.Database, fn string) error {
+	log.Info("Importing dpos context", "file", fn)
+
+	// Open the file handle and potentially unwrap the gzip stream
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	stream := rlp.NewStream(reader, 0)
+
+	expected := new(types.DposContextProto)
+	if err := stream.Decode(expected); err != nil {
+		return err
+	}
+	dposContext, err := types.NewDposContext(trie.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	tries := []*trie.Trie{
+		dposContext.EpochTrie(),
+		dposContext.DelegateTrie(),
+		dposContext.VoteTrie(),
+		dposContext.CandidateTrie(),
+		dposContext.MintCntTrie(),
+	}
+	for {
+		var entry dposTrieEntry
+		if err := stream.Decode(&entry); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if int(entry.Trie) >= len(tries) {
+			return fmt.Errorf("unknown dpos trie %d in import stream", entry.Trie)
+		}
+		if err := tries[entry.Trie].TryUpdate(entry.Key, entry.Value); err != nil {
+			return err
+		}
+	}
+	proto, err := dposContext.Commit()
+	if err != nil {
+		return err
+	}
+	if err := dposContext.Flush(); err != nil {
+		return err
+	}
+	if !proto.Equal(expected) {
+		return fmt.Errorf("imported dpos context mismatch: have %v, want %v", proto, expected)
+	}
+	log.Info("Imported dpos context", "file", fn)
+	return nil
+}
@@ -149,6 +149,38 @@ var (
 		Name:  "dev.period",
 		Usage: "Block period to use in developer mode (0 = mine only if transaction pending)",
 	}
+	DeveloperDposFlag = cli.BoolFlag{
+		Name:  "dev.dpos",
+		Usage: "Run the developer chain with a single-validator dpos engine instead of clique",
+	}
+	DposStandbyFlag = cli.BoolFlag{
+		Name:  "dpos.standby",
+		Usage: "Seal as hot-standby: yield to a live primary sharing the validator key, take over when it falls silent",
+	}
+	DposReplicaFlag = cli.BoolFlag{
+		Name:  "dpos.replica",
+		Usage: "Serve dpos queries as a read replica: no sealing, aggressive context caching, wide query pages",
+	}
+	DposValidatorSlotsFlag = cli.IntFlag{
+		Name:  "dpos.validatorslots",
+		Usage: "Reserve N peer connection slots for proven active validators so the validator mesh survives maxpeers (0 = no reservation)",
+	}
+	DposShutdownGraceFlag = cli.DurationFlag{
+		Name:  "dpos.shutdowngrace",
+		Usage: "Hold a shutdown until the imminent local slot is sealed or passed, for at most this duration (0 = stop immediately)",
+	}
+	DposHeartbeatSlotsFlag = cli.Uint64Flag{
+		Name:  "dpos.heartbeatslots",
+		Usage: "Skip sealing empty blocks, producing a heartbeat block every N slots instead (0 = seal every slot)",
+	}
+	DposForkWatchFlag = cli.Uint64Flag{
+		Name:  "dpos.forkwatch",
+		Usage: "Watch announced headers for double-signs and side forks longer than N blocks (0 = disabled)",
+	}
+	DposFaultInjectFlag = cli.StringFlag{
+		Name:  "dpos.faultinject",
+		Usage: "Deliberately misbehave for testing: comma separated skip=N, delay=D, doublesign (NEVER use in production)",
+	}
 	IdentityFlag = cli.StringFlag{
 		Name:  "identity",
 		Usage: "Custom node name",
@@ -177,6 +209,10 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	DposArchiveFlag = cli.BoolFlag{
+		Name:  "dpos.archive",
+		Usage: "Retain all historical DPoS tries for dpos queries at any block (implied by --gcmode=archive)",
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -307,6 +343,11 @@ var (
 		Usage: "Percentage of cache memory allowance to use for trie pruning",
 		Value: 25,
 	}
+	DposCacheFlag = cli.IntFlag{
+		Name:  "cache.dpos",
+		Usage: "Megabytes of memory allocated to caching dpos trie nodes (0 = disabled)",
+		Value: 0,
+	}
 	TrieCacheGenFlag = cli.IntFlag{
 		Name:  "trie-cache-gens",
 		Usage: "Number of trie node generations to keep in memory",
@@ -1087,6 +1128,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
+	cfg.DposArchive = ctx.GlobalBool(DposArchiveFlag.Name)
 
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
@@ -1107,6 +1149,30 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
 	}
+	if ctx.GlobalIsSet(DposStandbyFlag.Name) {
+		cfg.DposStandby = ctx.GlobalBool(DposStandbyFlag.Name)
+	}
+	if ctx.GlobalIsSet(DposReplicaFlag.Name) {
+		cfg.DposReplica = ctx.GlobalBool(DposReplicaFlag.Name)
+	}
+	if ctx.GlobalIsSet(DposValidatorSlotsFlag.Name) {
+		cfg.DposValidatorSlots = ctx.GlobalInt(DposValidatorSlotsFlag.Name)
+	}
+	if ctx.GlobalIsSet(DposShutdownGraceFlag.Name) {
+		cfg.DposShutdownGrace = ctx.GlobalDuration(DposShutdownGraceFlag.Name)
+	}
+	if ctx.GlobalIsSet(DposHeartbeatSlotsFlag.Name) {
+		cfg.DposHeartbeatSlots = ctx.GlobalUint64(DposHeartbeatSlotsFlag.Name)
+	}
+	if ctx.GlobalIsSet(DposForkWatchFlag.Name) {
+		cfg.DposForkWatch = ctx.GlobalUint64(DposForkWatchFlag.Name)
+	}
+	if ctx.GlobalIsSet(DposFaultInjectFlag.Name) {
+		cfg.DposFaultInject = ctx.GlobalString(DposFaultInjectFlag.Name)
+	}
+	if ctx.GlobalIsSet(DposCacheFlag.Name) {
+		cfg.DposCache = ctx.GlobalInt(DposCacheFlag.Name)
+	}
 
 	// Override any default configs for hard coded networks.
 	switch {
@@ -1142,7 +1208,11 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		}
 		log.Info("Using developer account", "address", developer.Address)
 
-		cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), developer.Address)
+		if ctx.GlobalBool(DeveloperDposFlag.Name) {
+			cfg.Genesis = core.DeveloperDposGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), developer.Address)
+		} else {
+			cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), developer.Address)
+		}
 		if !ctx.GlobalIsSet(GasPriceFlag.Name) {
 			cfg.GasPrice = big.NewInt(1)
 		}
@@ -1304,6 +1374,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
 		TrieNodeLimit: eth.DefaultConfig.TrieCache,
 		TrieTimeLimit: eth.DefaultConfig.TrieTimeout,
+		DposArchive:   ctx.GlobalBool(DposArchiveFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
@@ -0,0 +1,1629 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// API is a user facing RPC API to query the delegated proof-of-stake state.
+type API struct {
+	chain consensus.ChainReader
+	dpos  *Dpos
+}
+
+// headerByNumber resolves the rpc block number to a header of the local chain.
+func (api *API) headerByNumber(number *rpc.BlockNumber) *types.Header {
+	if number == nil || *number == rpc.LatestBlockNumber {
+		return api.chain.CurrentHeader()
+	}
+	return api.chain.GetHeaderByNumber(uint64(number.Int64()))
+}
+
+// dposContextAt opens the dpos context rooted in the given header.
+func (api *API) dposContextAt(header *types.Header) (*types.DposContext, error) {
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return types.NewDposContextFromProto(api.dpos.triedb, header.DposContext)
+}
+
+// GetValidators retrieves the list of validators at the specified block.
+func (api *API) GetValidators(number *rpc.BlockNumber) ([]common.Address, error) {
+	dposContext, err := api.dposContextAt(api.headerByNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	return dposContext.GetValidators()
+}
+
+// Candidate is the RPC representation of a registered election candidate,
+// carrying the aggregated figures wallets need to render a leaderboard in a
+// single call.
+type Candidate struct {
+	Address        common.Address `json:"address"`
+	Commission     uint64         `json:"commission"`
+	DelegatorCount int            `json:"delegatorCount"`
+	Stake          *big.Int       `json:"stake,omitempty"`     // Raw stake delegated to the candidate
+	Weight         *big.Int       `json:"weight,omitempty"`    // Effective vote weight the election sorts by
+	Share          float64        `json:"share"`               // Fraction of the total vote weight
+	SelfStake      *big.Int       `json:"selfStake,omitempty"` // Raw stake the candidate delegates to itself
+	SelfStakeShort bool           `json:"selfStakeShort"`      // Whether the self-stake floor bars the candidate from election
+	Group          common.Hash    `json:"group"`               // Declared operator group, zero when undeclared
+	Payout         common.Address `json:"payout"`              // Registered reward recipient, zero when paid directly
+}
+
+// GetCandidates retrieves the list of election candidates at the specified
+// block, together with their commission rates, delegator counts and vote
+// tallies. Stake figures require state access and are omitted without it.
+// The walk is cut off by the RPC execution budget on very large tries; the
+// chunked GetCandidatesChunk stays available past that point.
+func (api *API) GetCandidates(number *rpc.BlockNumber) ([]Candidate, error) {
+	header := api.headerByNumber(number)
+	dposContext, err := api.dposContextAt(header)
+	if err != nil {
+		return nil, err
+	}
+	budget := newWalkBudget()
+	// Count the delegators of every candidate in one delegate trie pass
+	delegators := make(map[common.Address]int)
+	iter := trie.NewIterator(dposContext.DelegateTrie().NodeIterator(nil))
+	for iter.Next() {
+		if err := budget.spend(); err != nil {
+			return nil, err
+		}
+		if len(iter.Key) != 2*common.AddressLength {
+			continue
+		}
+		delegators[common.BytesToAddress(iter.Key[:common.AddressLength])]++
+	}
+	// Tally stakes and weights when account state is available
+	var (
+		epochContext *EpochContext
+		raw, votes   map[common.Address]*big.Int
+		total        = new(big.Float)
+	)
+	if api.dpos.stateFn != nil {
+		if statedb, err := api.dpos.stateFn(header.Root); err == nil {
+			epochContext = &EpochContext{
+				DposContext: dposContext,
+				statedb:     statedb,
+				config:      api.dpos.config,
+				budget:      budget,
+			}
+			if raw, votes, err = epochContext.tallyVotes(); err != nil {
+				return nil, err
+			}
+			for _, weight := range votes {
+				total.Add(total, new(big.Float).SetInt(weight))
+			}
+		}
+	}
+	var candidates []Candidate
+	iter = trie.NewIterator(dposContext.CandidateTrie().NodeIterator(nil))
+	for iter.Next() {
+		if err := budget.spend(); err != nil {
+			return nil, err
+		}
+		// Skip auxiliary entries such as kickout cooldowns
+		if len(iter.Key) != common.AddressLength {
+			continue
+		}
+		addr := common.BytesToAddress(iter.Value)
+		candidate := Candidate{
+			Address:        addr,
+			Commission:     dposContext.Commission(addr),
+			DelegatorCount: delegators[addr],
+			Group:          dposContext.OperatorGroup(addr),
+			Payout:         dposContext.RewardRecipient(addr),
+		}
+		if votes != nil {
+			candidate.Stake = raw[addr]
+			candidate.Weight = votes[addr]
+			if candidate.Weight != nil && total.Sign() > 0 {
+				share, _ := new(big.Float).Quo(new(big.Float).SetInt(candidate.Weight), total).Float64()
+				candidate.Share = share
+			}
+			candidate.SelfStake = epochContext.selfStake(addr)
+			candidate.SelfStakeShort = epochContext.selfStakeShortfall(addr, raw[addr])
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+// candidateChunkSize is the number of candidates one chunk of a candidate
+// dump holds by default.
+const candidateChunkSize = 256
+
+// defaultChunk returns the default page size of a dump request. Read replicas
+// are tuned for analytics traffic and default to the maximum a caller could
+// ask for anyway.
+func (api *API) defaultChunk(size int) int {
+	if api.dpos.IsReplica() {
+		return 10 * size
+	}
+	return size
+}
+
+// CandidateChunk is one page of a candidate dump. Next is the continuation
+// token resuming the dump after the chunk, or empty once the trie is
+// exhausted.
+type CandidateChunk struct {
+	Candidates []Candidate   `json:"candidates"`
+	Next       hexutil.Bytes `json:"next,omitempty"`
+}
+
+// GetCandidatesChunk dumps the registered candidates at the specified block
+// one chunk at a time: at most limit entries are returned per call, starting
+// at the given continuation token, along with the token resuming the dump.
+// Delegator counts come from a per-candidate prefix walk of the delegate
+// trie; the vote tallies of the unchunked GetCandidates are omitted, since a
+// tally cannot be computed one page at a time — GetTally serves it whole.
+func (api *API) GetCandidatesChunk(number *rpc.BlockNumber, start hexutil.Bytes, limit int) (*CandidateChunk, error) {
+	dposContext, err := api.dposContextAt(api.headerByNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 10*candidateChunkSize {
+		limit = api.defaultChunk(candidateChunkSize)
+	}
+	budget := newWalkBudget()
+	chunk := &CandidateChunk{Candidates: make([]Candidate, 0, limit)}
+	delegateTrie := dposContext.DelegateTrie()
+	iter := trie.NewIterator(dposContext.CandidateTrie().NodeIterator(start))
+	for iter.Next() {
+		if err := budget.spend(); err != nil {
+			return nil, err
+		}
+		// Skip auxiliary entries such as kickout cooldowns
+		if len(iter.Key) != common.AddressLength {
+			continue
+		}
+		if len(chunk.Candidates) == limit {
+			chunk.Next = common.CopyBytes(iter.Key)
+			break
+		}
+		addr := common.BytesToAddress(iter.Value)
+		candidate := Candidate{
+			Address:    addr,
+			Commission: dposContext.Commission(addr),
+			Group:      dposContext.OperatorGroup(addr),
+			Payout:     dposContext.RewardRecipient(addr),
+		}
+		delegators := trie.NewIterator(delegateTrie.NodeIterator(iter.Value))
+		for delegators.Next() {
+			if err := budget.spend(); err != nil {
+				return nil, err
+			}
+			if !bytes.HasPrefix(delegators.Key, iter.Value) {
+				break
+			}
+			candidate.DelegatorCount++
+		}
+		chunk.Candidates = append(chunk.Candidates, candidate)
+	}
+	return chunk, nil
+}
+
+// Tally is the RPC representation of a candidate's election tally, exposing
+// both the raw delegated stake and the effective vote weight after the
+// configured per-delegator adjustments.
+type Tally struct {
+	Address common.Address `json:"address"`
+	Stake   *big.Int       `json:"stake"`  // Raw stake delegated to the candidate
+	Weight  *big.Int       `json:"weight"` // Effective vote weight the election sorts by
+}
+
+// GetTally recomputes the vote tally of every candidate at the specified
+// block, so the effect of vote weight caps is transparent to delegators.
+func (api *API) GetTally(number *rpc.BlockNumber) ([]Tally, error) {
+	header := api.headerByNumber(number)
+	dposContext, err := api.dposContextAt(header)
+	if err != nil {
+		return nil, err
+	}
+	if api.dpos.stateFn == nil {
+		return nil, ErrNoStateAccess
+	}
+	statedb, err := api.dpos.stateFn(header.Root)
+	if err != nil {
+		return nil, err
+	}
+	epochContext := &EpochContext{
+		DposContext: dposContext,
+		statedb:     statedb,
+		config:      api.dpos.config,
+		budget:      newWalkBudget(),
+	}
+	raw, votes, err := epochContext.tallyVotes()
+	if err != nil {
+		return nil, err
+	}
+	return talliesOf(raw, votes), nil
+}
+
+// talliesOf flattens raw and effective vote maps into a tally list sorted by
+// candidate address.
+func talliesOf(raw, votes map[common.Address]*big.Int) []Tally {
+	tallies := make([]Tally, 0, len(raw))
+	for candidate, stake := range raw {
+		tallies = append(tallies, Tally{
+			Address: candidate,
+			Stake:   stake,
+			Weight:  votes[candidate],
+		})
+	}
+	sort.Slice(tallies, func(i, j int) bool {
+		return bytes.Compare(tallies[i].Address[:], tallies[j].Address[:]) < 0
+	})
+	return tallies
+}
+
+// KickedValidator names a validator removed at an epoch transition, along
+// with the number of blocks it produced in the epoch it was removed for and
+// the production threshold that would have kept it in the set.
+type KickedValidator struct {
+	Address   common.Address `json:"address"`
+	MintCnt   int64          `json:"mintCnt"`
+	Threshold int64          `json:"threshold"`
+}
+
+// SlashedValidator is the record of a validator penalized for downtime during
+// an epoch transition.
+type SlashedValidator struct {
+	Address common.Address `json:"address"`
+	Penalty *big.Int       `json:"penalty"` // Amount in wei deducted from the validator's balance
+}
+
+// ElectionRecord is a machine readable account of one epoch transition, so
+// indexers and explorers don't have to reverse engineer elections from state
+// diffs.
+type ElectionRecord struct {
+	Epoch      int64              `json:"epoch"`      // Epoch the transition elected validators for
+	Tallies    []Tally            `json:"tallies"`    // Full vote tallies of all candidates
+	Kicked     []KickedValidator  `json:"kicked"`     // Validators removed for inactivity
+	Slashed    []SlashedValidator `json:"slashed"`    // Validators penalized for downtime
+	Seed       int64              `json:"seed"`       // Shuffle seed derived from the parent hash
+	Shuffle    uint64             `json:"shuffle"`    // Version of the shuffle algorithm the seed was fed to, zero when no shuffle ran
+	Validators []common.Address   `json:"validators"` // Final ordered validator set
+}
+
+// GetElectionRecord replays the election run by the epoch boundary block at
+// the given number and returns its full record.
+func (api *API) GetElectionRecord(number *rpc.BlockNumber) (*ElectionRecord, error) {
+	header := api.headerByNumber(number)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	parent := api.chain.GetHeaderByHash(header.ParentHash)
+	if parent == nil {
+		return nil, errUnknownBlock
+	}
+	if parent.Time.Int64()/api.dpos.epochInterval() == header.Time.Int64()/api.dpos.epochInterval() {
+		return nil, ErrNotEpochBoundary
+	}
+	if api.dpos.stateFn == nil {
+		return nil, ErrNoStateAccess
+	}
+	// Replay on throwaway copies of the parent context and state
+	dposContext, err := types.NewDposContextFromProto(api.dpos.triedb, parent.DposContext)
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := api.dpos.stateFn(parent.Root)
+	if err != nil {
+		return nil, err
+	}
+	epochContext := &EpochContext{
+		TimeStamp:   header.Time.Int64(),
+		DposContext: dposContext,
+		statedb:     statedb,
+		config:      api.dpos.config,
+		record:      new(ElectionRecord),
+	}
+	if err := epochContext.tryElect(api.chain.GetHeaderByNumber(0), parent); err != nil {
+		return nil, err
+	}
+	return epochContext.record, nil
+}
+
+// EstimateElection runs the election against the current head state as if the
+// epoch ended now and returns the record it would produce: tallies, kickouts
+// and the final ordered validator set. Nothing is mutated, so candidates and
+// delegators can check whether a pending vote changes the outcome before the
+// boundary. The shuffle seed is taken from the current head and will differ
+// from the one the real boundary block sees.
+func (api *API) EstimateElection() (*ElectionRecord, error) {
+	head := api.chain.CurrentHeader()
+	if head == nil {
+		return nil, errUnknownBlock
+	}
+	if api.dpos.stateFn == nil {
+		return nil, ErrNoStateAccess
+	}
+	// Run on throwaway copies of the head context and state, pretending the
+	// next block lands in the following epoch
+	dposContext, err := types.NewDposContextFromProto(api.dpos.triedb, head.DposContext)
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := api.dpos.stateFn(head.Root)
+	if err != nil {
+		return nil, err
+	}
+	epochContext := &EpochContext{
+		TimeStamp:   (head.Time.Int64()/api.dpos.epochInterval() + 1) * api.dpos.epochInterval(),
+		DposContext: dposContext,
+		statedb:     statedb,
+		config:      api.dpos.config,
+		record:      new(ElectionRecord),
+	}
+	if err := epochContext.tryElect(api.chain.GetHeaderByNumber(0), head); err != nil {
+		return nil, err
+	}
+	return epochContext.record, nil
+}
+
+// GetEpochChange retrieves the canonical epoch transition record committed in
+// the epoch trie at the given block. Unlike GetElectionRecord it needs no
+// historical state or replay, and the answer is provable against the block's
+// DposContext root.
+func (api *API) GetEpochChange(number *rpc.BlockNumber) (*types.EpochChange, error) {
+	header := api.headerByNumber(number)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	dposContext, err := api.dposContextAt(header)
+	if err != nil {
+		return nil, err
+	}
+	return dposContext.GetEpochChange()
+}
+
+// GetEpochSummary retrieves the persisted summary of the given epoch's
+// transition. Unlike GetElectionRecord it needs no historical state, but only
+// covers elections this node executed itself.
+func (api *API) GetEpochSummary(epoch uint64) (*rawdb.EpochSummary, error) {
+	summary := rawdb.ReadEpochSummary(api.dpos.db, epoch)
+	if summary == nil {
+		return nil, fmt.Errorf("no summary recorded for epoch %d", epoch)
+	}
+	return summary, nil
+}
+
+// GetIssuance returns the cumulative net issuance in wei - rewards minted
+// minus slashed amounts burned - as recorded when the given epoch began. For
+// the current epoch, the live counter at the chain head is returned instead;
+// snapshots only exist for epochs that began after issuance tracking was
+// introduced.
+func (api *API) GetIssuance(epoch uint64) (*big.Int, error) {
+	if issuance := rawdb.ReadIssuanceSnapshot(api.dpos.db, epoch); issuance != nil {
+		return issuance, nil
+	}
+	head := api.chain.CurrentHeader()
+	if head != nil && uint64(head.Time.Int64()/api.dpos.epochInterval()) == epoch {
+		dposContext, err := api.dposContextAt(head)
+		if err != nil {
+			return nil, err
+		}
+		return dposContext.Issuance(), nil
+	}
+	return nil, fmt.Errorf("no issuance snapshot for epoch %d", epoch)
+}
+
+// maxRewardEpochs bounds the epoch range a single reward history query may
+// cover, keeping one request from scanning years of records.
+const maxRewardEpochs = 1000
+
+// GetRewards retrieves the persisted reward records of the given account over
+// the given epoch range, both ends inclusive: block rewards, commission and
+// fee income earned as a validator plus the payouts received as a delegator.
+// Epochs in which the account earned nothing are omitted. Only distributions
+// this node executed itself are covered.
+func (api *API) GetRewards(account common.Address, fromEpoch, toEpoch uint64) ([]*rawdb.RewardRecord, error) {
+	if toEpoch < fromEpoch {
+		return nil, ErrInvalidEpochRange
+	}
+	if toEpoch-fromEpoch >= maxRewardEpochs {
+		return nil, fmt.Errorf("epoch range exceeds %d epochs", maxRewardEpochs)
+	}
+	var records []*rawdb.RewardRecord
+	for epoch := fromEpoch; epoch <= toEpoch; epoch++ {
+		if record := rawdb.ReadRewardRecord(api.dpos.db, epoch, account); record != nil {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// ValidatorPerformance is the locally observed sealing performance of one
+// validator over one epoch, aggregated from the blocks this node finalized.
+type ValidatorPerformance struct {
+	Address     common.Address `json:"address"`
+	Blocks      uint64         `json:"blocks"`      // Blocks the validator sealed in the epoch
+	GasUsed     uint64         `json:"gasUsed"`     // Total gas those blocks consumed
+	AvgFullness float64        `json:"avgFullness"` // Consumed fraction of the gas limit across the blocks
+	AvgDelay    float64        `json:"avgDelay"`    // Average local propagation delay in milliseconds, zero without live samples
+}
+
+// GetValidatorPerformance reports how each validator of the given epoch
+// actually sealed: blocks produced, gas included, average block fullness and
+// the propagation delay measured against the local clock, so delegators can
+// tell validators that include transactions from ones sealing empty blocks.
+// Only blocks this node finalized itself are covered; validators the node
+// never saw seal report zeros.
+func (api *API) GetValidatorPerformance(epoch uint64) ([]ValidatorPerformance, error) {
+	// Resolve the epoch's validator set, from the persisted summary for past
+	// epochs and from the head context for the running one
+	var validators []common.Address
+	if summary := rawdb.ReadEpochSummary(api.dpos.db, epoch); summary != nil {
+		validators = summary.Validators
+	} else {
+		head := api.chain.CurrentHeader()
+		if head == nil || uint64(head.Time.Int64()/api.dpos.epochInterval()) != epoch {
+			return nil, fmt.Errorf("no validator set known for epoch %d", epoch)
+		}
+		dposContext, err := api.dposContextAt(head)
+		if err != nil {
+			return nil, err
+		}
+		if validators, err = dposContext.GetValidators(); err != nil {
+			return nil, err
+		}
+	}
+	performances := make([]ValidatorPerformance, 0, len(validators))
+	for _, validator := range validators {
+		performance := ValidatorPerformance{Address: validator}
+		if record := rawdb.ReadPerformanceRecord(api.dpos.db, epoch, validator); record != nil {
+			performance.Blocks = record.Blocks
+			performance.GasUsed = record.GasUsed
+			if record.GasLimit > 0 {
+				performance.AvgFullness = float64(record.GasUsed) / float64(record.GasLimit)
+			}
+			if record.DelaySamples > 0 {
+				performance.AvgDelay = float64(record.Delay) / float64(record.DelaySamples)
+			}
+		}
+		performances = append(performances, performance)
+	}
+	return performances, nil
+}
+
+// Candidate reputation parameters. The score is integral arithmetic over
+// records every full node derives identically from block execution — epoch
+// summaries, performance records and candidate registration blocks — so
+// nodes that processed the same chain agree on it.
+const (
+	// reputationWindow is the number of finished epochs the uptime and
+	// discipline inputs look back over.
+	reputationWindow = 8
+
+	// reputationAgeTarget is the number of epochs of uninterrupted
+	// registration after which a candidate earns full age credit.
+	reputationAgeTarget = 30
+
+	// Relative weights of the three reputation inputs, summing to 10.
+	reputationUptimeWeight     = 6
+	reputationAgeWeight        = 2
+	reputationDisciplineWeight = 2
+)
+
+// CandidateReputation is the reputation score of one candidate along with the
+// inputs it was computed from, all in 0-10000 basis points.
+type CandidateReputation struct {
+	Address       common.Address `json:"address"`
+	Score         uint64         `json:"score"`
+	UptimeBps     uint64         `json:"uptimeBps"`     // Average share of the fair block count sealed over the window
+	AgeBps        uint64         `json:"ageBps"`        // Registration age credit
+	DisciplineBps uint64         `json:"disciplineBps"` // Credit remaining after inactivity kickouts in the window
+	SinceBlock    uint64         `json:"sinceBlock"`    // Block the registration was first seen at, zero if unknown
+	Kicked        uint64         `json:"kicked"`        // Inactivity kickouts within the window
+	Slashed       *big.Int       `json:"slashed"`       // Lifetime slashed stake from the staking stats mirror, nil when disabled
+}
+
+// GetCandidateReputation scores every registered candidate at the current
+// head, so wallets can sort candidate lists by something better than raw
+// stake. The score combines three inputs over the last reputationWindow
+// finished epochs: sealing uptime — the sealed block count of each validator
+// duty against its fair share — registration age with linear credit up to
+// reputationAgeTarget epochs, and discipline, where each inactivity kickout
+// in the window costs half the credit. Candidates without validator duty in
+// the window take full uptime credit, since there is no evidence against
+// them; candidates whose registration block is unknown take no age credit.
+func (api *API) GetCandidateReputation() ([]CandidateReputation, error) {
+	head := api.chain.CurrentHeader()
+	if head == nil {
+		return nil, errUnknownBlock
+	}
+	dposContext, err := api.dposContextAt(head)
+	if err != nil {
+		return nil, err
+	}
+	budget := newWalkBudget()
+	var candidates []common.Address
+	iter := trie.NewIterator(dposContext.CandidateTrie().NodeIterator(nil))
+	for iter.Next() {
+		if err := budget.spend(); err != nil {
+			return nil, err
+		}
+		// Skip auxiliary entries such as kickout cooldowns
+		if len(iter.Key) != common.AddressLength {
+			continue
+		}
+		candidates = append(candidates, common.BytesToAddress(iter.Value))
+	}
+	// Collect the epoch summaries and total sealed block counts of the window
+	type epochStats struct {
+		summary *rawdb.EpochSummary
+		total   uint64
+	}
+	epoch := uint64(head.Time.Int64() / api.dpos.epochInterval())
+	first := uint64(0)
+	if epoch > reputationWindow {
+		first = epoch - reputationWindow
+	}
+	var window []epochStats
+	for e := first; e < epoch; e++ {
+		summary := rawdb.ReadEpochSummary(api.dpos.db, e)
+		if summary == nil {
+			continue
+		}
+		stats := epochStats{summary: summary}
+		for _, validator := range summary.Validators {
+			if record := rawdb.ReadPerformanceRecord(api.dpos.db, e, validator); record != nil {
+				stats.total += record.Blocks
+			}
+		}
+		window = append(window, stats)
+	}
+	// The staking stats mirror contributes the lifetime slashed amounts when
+	// the chain runs with it
+	var statedb *state.StateDB
+	if api.dpos.config != nil && api.dpos.config.MirrorStakingStats && api.dpos.stateFn != nil {
+		statedb, _ = api.dpos.stateFn(head.Root)
+	}
+	reputations := make([]CandidateReputation, 0, len(candidates))
+	for _, candidate := range candidates {
+		reputation := CandidateReputation{Address: candidate}
+		var uptimeAcc, duty uint64
+		for _, stats := range window {
+			member := false
+			for _, validator := range stats.summary.Validators {
+				if validator == candidate {
+					member = true
+					break
+				}
+			}
+			if member && stats.total > 0 {
+				var sealed uint64
+				if record := rawdb.ReadPerformanceRecord(api.dpos.db, stats.summary.Epoch, candidate); record != nil {
+					sealed = record.Blocks
+				}
+				bps := sealed * uint64(len(stats.summary.Validators)) * 10000 / stats.total
+				if bps > 10000 {
+					bps = 10000
+				}
+				uptimeAcc += bps
+				duty++
+			}
+			for _, kicked := range stats.summary.Kicked {
+				if kicked == candidate {
+					reputation.Kicked++
+				}
+			}
+		}
+		reputation.UptimeBps = 10000
+		if duty > 0 {
+			reputation.UptimeBps = uptimeAcc / duty
+		}
+		reputation.DisciplineBps = 10000
+		if penalty := reputation.Kicked * 5000; penalty < 10000 {
+			reputation.DisciplineBps = 10000 - penalty
+		} else {
+			reputation.DisciplineBps = 0
+		}
+		if reputation.SinceBlock = rawdb.ReadCandidateSince(api.dpos.db, candidate); reputation.SinceBlock > 0 {
+			ageBlocks := head.Number.Uint64() - reputation.SinceBlock
+			targetBlocks := reputationAgeTarget * uint64(api.dpos.epochInterval()/api.dpos.slotInterval())
+			if ageBlocks >= targetBlocks {
+				reputation.AgeBps = 10000
+			} else {
+				reputation.AgeBps = ageBlocks * 10000 / targetBlocks
+			}
+		}
+		reputation.Score = (reputation.UptimeBps*reputationUptimeWeight +
+			reputation.AgeBps*reputationAgeWeight +
+			reputation.DisciplineBps*reputationDisciplineWeight) / 10
+		if statedb != nil {
+			reputation.Slashed = statedb.GetState(types.StakingStatsAddr, types.StakingStatSlot(candidate, types.StakingStatsSlashesSlot)).Big()
+		}
+		reputations = append(reputations, reputation)
+	}
+	// Order by score, ties by address, so the listing is deterministic
+	sort.Slice(reputations, func(i, j int) bool {
+		if reputations[i].Score != reputations[j].Score {
+			return reputations[i].Score > reputations[j].Score
+		}
+		return bytes.Compare(reputations[i].Address[:], reputations[j].Address[:]) < 0
+	})
+	return reputations, nil
+}
+
+// GetSignedHeader returns the epoch boundary header at the given number
+// together with the validator co-signatures aggregated for it so far, in the
+// form a bridge relay submits to a light client contract on another chain.
+func (api *API) GetSignedHeader(number *rpc.BlockNumber) (*SignedHeader, error) {
+	header := api.headerByNumber(number)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	if !api.dpos.IsEpochBoundary(api.chain.GetHeaderByHash(header.ParentHash), header) {
+		return nil, ErrNotEpochBoundary
+	}
+	return api.dpos.SignedHeaderOf(header), nil
+}
+
+// GetVotes retrieves the candidate the given delegator voted for at the
+// specified block, or the zero address if the delegator has no active vote.
+func (api *API) GetVotes(delegator common.Address, number *rpc.BlockNumber) (common.Address, error) {
+	dposContext, err := api.dposContextAt(api.headerByNumber(number))
+	if err != nil {
+		return common.Address{}, err
+	}
+	candidate, err := dposContext.VoteTrie().TryGet(delegator.Bytes())
+	if err != nil || candidate == nil {
+		return common.Address{}, nil
+	}
+	return common.BytesToAddress(candidate), nil
+}
+
+// GetBlacklist retrieves the unix time until which the given address is
+// blacklisted from standing for election at the specified block, or zero if
+// the address is not blacklisted. Entries whose expiry passed the block's
+// timestamp are reported as zero.
+func (api *API) GetBlacklist(address common.Address, number *rpc.BlockNumber) (int64, error) {
+	header := api.headerByNumber(number)
+	dposContext, err := api.dposContextAt(header)
+	if err != nil {
+		return 0, err
+	}
+	expiry := dposContext.BlacklistExpiry(address)
+	if expiry <= header.Time.Int64() {
+		return 0, nil
+	}
+	return expiry, nil
+}
+
+// GetActiveSigner retrieves the sealing key active for the given candidate at
+// the specified block, which is the candidate address itself unless a signer
+// rotation has taken effect.
+func (api *API) GetActiveSigner(candidate common.Address, number *rpc.BlockNumber) (common.Address, error) {
+	header := api.headerByNumber(number)
+	dposContext, err := api.dposContextAt(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return dposContext.ActiveSigner(candidate, header.Time.Int64()/api.dpos.epochInterval()), nil
+}
+
+// delegationChunkSize is the number of delegations a dump request returns per
+// chunk by default; a chunk bounds the memory a dump holds at once, whatever
+// the size of the delegate trie.
+const delegationChunkSize = 1024
+
+// Delegation is the RPC representation of a single delegate trie entry: one
+// delegator backing one candidate.
+type Delegation struct {
+	Candidate common.Address `json:"candidate"`
+	Delegator common.Address `json:"delegator"`
+}
+
+// DelegationChunk is one page of a delegation dump. Next is the continuation
+// token resuming the dump after the chunk, or empty once the trie is
+// exhausted.
+type DelegationChunk struct {
+	Delegations []Delegation  `json:"delegations"`
+	Next        hexutil.Bytes `json:"next,omitempty"`
+}
+
+// GetDelegations dumps the delegations at the specified block one chunk at a
+// time: at most limit entries are returned per call, starting at the given
+// continuation token, along with the token resuming the dump. Mainnet-scale
+// delegate tries are dumped in bounded memory by following the tokens.
+func (api *API) GetDelegations(number *rpc.BlockNumber, start hexutil.Bytes, limit int) (*DelegationChunk, error) {
+	dposContext, err := api.dposContextAt(api.headerByNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 10*delegationChunkSize {
+		limit = api.defaultChunk(delegationChunkSize)
+	}
+	chunk := &DelegationChunk{Delegations: make([]Delegation, 0, limit)}
+	iter := trie.NewIterator(dposContext.DelegateTrie().NodeIterator(start))
+	for iter.Next() {
+		if len(iter.Key) != 2*common.AddressLength {
+			continue
+		}
+		if len(chunk.Delegations) == limit {
+			chunk.Next = common.CopyBytes(iter.Key)
+			break
+		}
+		chunk.Delegations = append(chunk.Delegations, Delegation{
+			Candidate: common.BytesToAddress(iter.Key[:common.AddressLength]),
+			Delegator: common.BytesToAddress(iter.Key[common.AddressLength:]),
+		})
+	}
+	return chunk, nil
+}
+
+// StreamDelegations creates a subscription streaming the delegations at the
+// specified block in chunks. The walk advances one chunk per notification, so
+// a slow consumer throttles the trie iteration instead of buffering the whole
+// dump in memory.
+func (api *API) StreamDelegations(ctx context.Context, number *rpc.BlockNumber) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	dposContext, err := api.dposContextAt(api.headerByNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		chunk := make([]Delegation, 0, delegationChunkSize)
+		iter := trie.NewIterator(dposContext.DelegateTrie().NodeIterator(nil))
+		for iter.Next() {
+			if len(iter.Key) != 2*common.AddressLength {
+				continue
+			}
+			chunk = append(chunk, Delegation{
+				Candidate: common.BytesToAddress(iter.Key[:common.AddressLength]),
+				Delegator: common.BytesToAddress(iter.Key[common.AddressLength:]),
+			})
+			if len(chunk) == delegationChunkSize {
+				select {
+				case <-sub.Err():
+					return
+				case <-notifier.Closed():
+					return
+				default:
+				}
+				if err := notifier.Notify(sub.ID, DelegationChunk{Delegations: chunk}); err != nil {
+					return
+				}
+				chunk = make([]Delegation, 0, delegationChunkSize)
+			}
+		}
+		// Flush the tail chunk; its missing continuation marks the end
+		if len(chunk) > 0 {
+			notifier.Notify(sub.ID, DelegationChunk{Delegations: chunk})
+		}
+	}()
+	return sub, nil
+}
+
+// AccountInfo is the RPC representation of an account's role in the dpos
+// system: the candidate it votes for, the weight that vote carries and
+// whether the account itself stands for election.
+type AccountInfo struct {
+	VotedCandidate  common.Address `json:"votedCandidate"`  // Zero if the account has no active vote
+	DelegatedWeight *big.Int       `json:"delegatedWeight"` // Effective election weight of the account's vote
+	IsCandidate     bool           `json:"isCandidate"`
+	Compounding     bool           `json:"compounding"`  // Whether reward cuts auto-compound into bonded stake
+	BondedReward    *big.Int       `json:"bondedReward"` // Rewards compounded so far, paid out at undelegation
+}
+
+// GetDelegators returns the delegators voting for the given candidate at the
+// current head, answered from the delegation index maintained alongside block
+// import instead of a delegate trie walk. The forward direction — what a
+// delegator votes for — is GetVotes. Errors while the index has not caught
+// up with the head, e.g. right after a fast sync.
+func (api *API) GetDelegators(candidate common.Address) ([]common.Address, error) {
+	head := api.chain.CurrentHeader()
+	if head == nil {
+		return nil, errUnknownBlock
+	}
+	if rawdb.ReadDelegationIndexHead(api.dpos.db) != head.Hash() {
+		return nil, ErrIndexNotSynced
+	}
+	return rawdb.ReadIndexedDelegators(api.dpos.db, candidate), nil
+}
+
+// VoteChange is the RPC representation of one entry of an account's recorded
+// delegation history.
+type VoteChange struct {
+	Block     uint64         `json:"block"`
+	Candidate common.Address `json:"candidate"`
+	Weight    *big.Int       `json:"weight"` // The account's stake when the change landed
+	Revoked   bool           `json:"revoked"`
+}
+
+// GetVoteHistory returns the recorded delegation changes of the given account
+// within the block range, oldest first. The log is written alongside block
+// import, so it only covers blocks processed by this node: history before a
+// fast sync pivot or an index rebuild is absent. Nil range bounds default to
+// the genesis and the current head respectively.
+func (api *API) GetVoteHistory(account common.Address, fromBlock, toBlock *rpc.BlockNumber) ([]VoteChange, error) {
+	from, to := uint64(0), uint64(math.MaxUint64)
+	if fromBlock != nil && *fromBlock > 0 {
+		from = uint64(*fromBlock)
+	}
+	if toBlock != nil && *toBlock >= 0 {
+		to = uint64(*toBlock)
+	}
+	if from > to {
+		return nil, fmt.Errorf("invalid block range [%d, %d]", from, to)
+	}
+	history := make([]VoteChange, 0)
+	for _, change := range rawdb.ReadVoteHistory(api.dpos.db, account) {
+		if change.Block < from || change.Block > to {
+			continue
+		}
+		history = append(history, VoteChange{
+			Block:     change.Block,
+			Candidate: change.Candidate,
+			Weight:    change.Weight,
+			Revoked:   change.Revoked,
+		})
+	}
+	return history, nil
+}
+
+// KickoutRecord is the RPC representation of one recorded removal of a
+// candidate from the validator set.
+type KickoutRecord struct {
+	Epoch     uint64 `json:"epoch"`     // Epoch the candidate was kicked after
+	MintCnt   uint64 `json:"mintCnt"`   // Blocks produced over the kickout window
+	Threshold uint64 `json:"threshold"` // Production that would have kept the candidate in
+}
+
+// KickoutChunk is one page of a candidate's kickout history. Next is the
+// continuation epoch to pass as start for the following page, nil on the
+// last page.
+type KickoutChunk struct {
+	Kickouts []KickoutRecord `json:"kickouts"`
+	Next     *uint64         `json:"next,omitempty"`
+}
+
+// kickoutChunkSize is the default number of entries per kickout history page.
+const kickoutChunkSize = 64
+
+// GetKickoutHistory returns the recorded kickouts of the given candidate,
+// oldest first, one page at a time: at most limit entries are returned per
+// call, starting at the given epoch. The log is written alongside epoch
+// processing on this node, so elections it never executed — e.g. before a
+// fast sync pivot — are absent.
+func (api *API) GetKickoutHistory(candidate common.Address, start *uint64, limit int) (*KickoutChunk, error) {
+	if limit <= 0 || limit > 10*kickoutChunkSize {
+		limit = api.defaultChunk(kickoutChunkSize)
+	}
+	from := uint64(0)
+	if start != nil {
+		from = *start
+	}
+	chunk := &KickoutChunk{Kickouts: make([]KickoutRecord, 0)}
+	for _, entry := range rawdb.ReadKickoutHistory(api.dpos.db, candidate) {
+		if entry.Epoch < from {
+			continue
+		}
+		if len(chunk.Kickouts) == limit {
+			next := entry.Epoch
+			chunk.Next = &next
+			break
+		}
+		chunk.Kickouts = append(chunk.Kickouts, KickoutRecord{
+			Epoch:     entry.Epoch,
+			MintCnt:   entry.MintCnt,
+			Threshold: entry.Threshold,
+		})
+	}
+	return chunk, nil
+}
+
+// GetAccountInfo retrieves the dpos view of the given account at the
+// specified block, serving explorer frontends in a single query.
+func (api *API) GetAccountInfo(address common.Address, number *rpc.BlockNumber) (*AccountInfo, error) {
+	header := api.headerByNumber(number)
+	dposContext, err := api.dposContextAt(header)
+	if err != nil {
+		return nil, err
+	}
+	info := &AccountInfo{DelegatedWeight: new(big.Int), BondedReward: new(big.Int)}
+	if candidate, err := dposContext.CandidateTrie().TryGet(address.Bytes()); err == nil && candidate != nil {
+		info.IsCandidate = true
+	}
+	if voted, err := dposContext.VoteTrie().TryGet(address.Bytes()); err == nil && voted != nil {
+		info.VotedCandidate = common.BytesToAddress(voted)
+		info.Compounding = dposContext.Compounding(address)
+		info.BondedReward = dposContext.BondedReward(address)
+		if api.dpos.stateFn != nil {
+			if statedb, err := api.dpos.stateFn(header.Root); err == nil {
+				epochContext := &EpochContext{statedb: statedb, config: api.dpos.config}
+				info.DelegatedWeight = epochContext.voteWeight(statedb.GetBalance(address))
+			}
+		}
+	}
+	return info, nil
+}
+
+// ProxyVoteInfo is the RPC representation of a custodian's proxy vote: the
+// merkle root committing to the individual user preferences and the
+// per-candidate aggregates the chain tallies.
+type ProxyVoteInfo struct {
+	Root        common.Hash             `json:"root"`
+	Allocations []types.ProxyAllocation `json:"allocations"`
+}
+
+// GetProxyVote retrieves the proxy vote of the given custodian at the
+// specified block, or nil if the custodian has none.
+func (api *API) GetProxyVote(custodian common.Address, number *rpc.BlockNumber) (*ProxyVoteInfo, error) {
+	dposContext, err := api.dposContextAt(api.headerByNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	allocations, err := dposContext.ProxyAllocations(custodian)
+	if err != nil {
+		return nil, err
+	}
+	if len(allocations) == 0 {
+		return nil, nil
+	}
+	return &ProxyVoteInfo{Root: dposContext.ProxyRoot(custodian), Allocations: allocations}, nil
+}
+
+// StakingStats is the RPC representation of the network-wide staking state:
+// how much stake votes, at what effective weight, spread over how many
+// accounts, and which fraction of the declared total supply participates.
+type StakingStats struct {
+	TotalStake        *big.Int `json:"totalStake"`        // Raw stake backing all votes, in wei
+	TotalWeight       *big.Int `json:"totalWeight"`       // Effective election weight after the weight curve
+	Delegators        uint64   `json:"delegators"`        // Number of accounts with an active vote
+	Candidates        uint64   `json:"candidates"`        // Number of registered candidates
+	ParticipationRate float64  `json:"participationRate"` // TotalStake over the configured total supply, zero if unconfigured
+}
+
+// GetStakingStats tallies the staking participation of the network at the
+// specified block, serving dashboards and reward curve parameterization in a
+// single query.
+func (api *API) GetStakingStats(number *rpc.BlockNumber) (*StakingStats, error) {
+	header := api.headerByNumber(number)
+	dposContext, err := api.dposContextAt(header)
+	if err != nil {
+		return nil, err
+	}
+	if api.dpos.stateFn == nil {
+		return nil, ErrNoStateAccess
+	}
+	statedb, err := api.dpos.stateFn(header.Root)
+	if err != nil {
+		return nil, err
+	}
+	budget := newWalkBudget()
+	epochContext := &EpochContext{
+		TimeStamp:   header.Time.Int64(),
+		DposContext: dposContext,
+		statedb:     statedb,
+		config:      api.dpos.config,
+		budget:      budget,
+	}
+	stats := &StakingStats{TotalStake: new(big.Int), TotalWeight: new(big.Int)}
+	raw, votes, err := epochContext.tallyVotes()
+	if err != nil {
+		return nil, err
+	}
+	stats.Candidates = uint64(len(raw))
+	for _, stake := range raw {
+		stats.TotalStake.Add(stats.TotalStake, stake)
+	}
+	for _, weight := range votes {
+		stats.TotalWeight.Add(stats.TotalWeight, weight)
+	}
+	iter := trie.NewIterator(dposContext.VoteTrie().NodeIterator(nil))
+	for iter.Next() {
+		if err := budget.spend(); err != nil {
+			return nil, err
+		}
+		// Auxiliary entries such as nonces and vote stamps are longer than
+		// an address and skipped
+		if len(iter.Key) == common.AddressLength {
+			stats.Delegators++
+		}
+	}
+	if supply := api.dpos.config.TotalSupply; supply != nil && supply.Sign() > 0 {
+		rate, _ := new(big.Rat).SetFrac(stats.TotalStake, supply).Float64()
+		stats.ParticipationRate = rate
+	}
+	return stats, nil
+}
+
+// BlockInfo is the RPC representation of a block's consensus context: the
+// validator that proposed it, the epoch it belongs to and the validator set
+// it was verified against.
+type BlockInfo struct {
+	Number     uint64           `json:"number"`
+	Proposer   common.Address   `json:"proposer"`
+	Epoch      int64            `json:"epoch"`
+	Validators []common.Address `json:"validators"`
+}
+
+// GetBlockInfo retrieves the consensus context of the specified block.
+func (api *API) GetBlockInfo(number *rpc.BlockNumber) (*BlockInfo, error) {
+	header := api.headerByNumber(number)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	dposContext, err := api.dposContextAt(header)
+	if err != nil {
+		return nil, err
+	}
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		return nil, err
+	}
+	return &BlockInfo{
+		Number:     header.Number.Uint64(),
+		Proposer:   header.Validator,
+		Epoch:      header.Time.Int64() / api.dpos.epochInterval(),
+		Validators: validators,
+	}, nil
+}
+
+// DelegationChange describes a delegator whose vote differs between the two
+// ends of a context diff.
+type DelegationChange struct {
+	Delegator common.Address  `json:"delegator"`
+	From      *common.Address `json:"from,omitempty"` // Previous candidate, omitted for new votes
+	To        *common.Address `json:"to,omitempty"`   // Current candidate, omitted for retracted votes
+}
+
+// ContextDiff summarises how the consensus state changed between two blocks:
+// candidates that entered or left the race, delegators whose vote moved and
+// changes to the validator set.
+type ContextDiff struct {
+	FromBlock         uint64             `json:"fromBlock"`
+	ToBlock           uint64             `json:"toBlock"`
+	AddedCandidates   []common.Address   `json:"addedCandidates"`
+	RemovedCandidates []common.Address   `json:"removedCandidates"`
+	Delegations       []DelegationChange `json:"delegations"`
+	AddedValidators   []common.Address   `json:"addedValidators"`
+	RemovedValidators []common.Address   `json:"removedValidators"`
+}
+
+// GetContextDiff computes the dpos context changes between the two specified
+// blocks by diffing their consensus tries, so indexers and monitoring bots
+// don't have to re-implement trie traversal out-of-process.
+func (api *API) GetContextDiff(fromNumber, toNumber *rpc.BlockNumber) (*ContextDiff, error) {
+	fromHeader, toHeader := api.headerByNumber(fromNumber), api.headerByNumber(toNumber)
+	if fromHeader == nil || toHeader == nil {
+		return nil, errUnknownBlock
+	}
+	fromContext, err := api.dposContextAt(fromHeader)
+	if err != nil {
+		return nil, err
+	}
+	toContext, err := api.dposContextAt(toHeader)
+	if err != nil {
+		return nil, err
+	}
+	diff := &ContextDiff{
+		FromBlock: fromHeader.Number.Uint64(),
+		ToBlock:   toHeader.Number.Uint64(),
+	}
+	// One budget covers all trie walks the diff needs
+	budget := newWalkBudget()
+	// Diff the candidate sets, unless the trie roots already match
+	if fromContext.CandidateTrie().Hash() != toContext.CandidateTrie().Hash() {
+		before, err := candidateSet(fromContext, budget)
+		if err != nil {
+			return nil, err
+		}
+		after, err := candidateSet(toContext, budget)
+		if err != nil {
+			return nil, err
+		}
+		diff.AddedCandidates = setDifference(after, before)
+		diff.RemovedCandidates = setDifference(before, after)
+	}
+	// Diff the votes of individual delegators
+	if fromContext.VoteTrie().Hash() != toContext.VoteTrie().Hash() {
+		before, err := voteMap(fromContext, budget)
+		if err != nil {
+			return nil, err
+		}
+		after, err := voteMap(toContext, budget)
+		if err != nil {
+			return nil, err
+		}
+		for delegator, candidate := range before {
+			if after[delegator] != candidate {
+				diff.Delegations = append(diff.Delegations, delegationChange(delegator, before, after))
+			}
+		}
+		for delegator := range after {
+			if _, ok := before[delegator]; !ok {
+				diff.Delegations = append(diff.Delegations, delegationChange(delegator, before, after))
+			}
+		}
+		sort.Slice(diff.Delegations, func(i, j int) bool {
+			return bytes.Compare(diff.Delegations[i].Delegator[:], diff.Delegations[j].Delegator[:]) < 0
+		})
+	}
+	// Diff the validator sets
+	if fromContext.EpochTrie().Hash() != toContext.EpochTrie().Hash() {
+		fromValidators, err := fromContext.GetValidators()
+		if err != nil {
+			return nil, err
+		}
+		toValidators, err := toContext.GetValidators()
+		if err != nil {
+			return nil, err
+		}
+		before, after := addressSet(fromValidators), addressSet(toValidators)
+		diff.AddedValidators = setDifference(after, before)
+		diff.RemovedValidators = setDifference(before, after)
+	}
+	return diff, nil
+}
+
+// candidateSet collects the registered candidates of a dpos context, charging
+// the visited entries against the given walk budget.
+func candidateSet(dposContext *types.DposContext, budget *walkBudget) (map[common.Address]struct{}, error) {
+	candidates := make(map[common.Address]struct{})
+	iter := trie.NewIterator(dposContext.CandidateTrie().NodeIterator(nil))
+	for iter.Next() {
+		if err := budget.spend(); err != nil {
+			return nil, err
+		}
+		if len(iter.Key) != common.AddressLength {
+			continue
+		}
+		candidates[common.BytesToAddress(iter.Value)] = struct{}{}
+	}
+	return candidates, nil
+}
+
+// voteMap collects the active votes of a dpos context, keyed by delegator,
+// charging the visited entries against the given walk budget.
+func voteMap(dposContext *types.DposContext, budget *walkBudget) (map[common.Address]common.Address, error) {
+	votes := make(map[common.Address]common.Address)
+	iter := trie.NewIterator(dposContext.VoteTrie().NodeIterator(nil))
+	for iter.Next() {
+		if err := budget.spend(); err != nil {
+			return nil, err
+		}
+		if len(iter.Key) != common.AddressLength {
+			continue
+		}
+		votes[common.BytesToAddress(iter.Key)] = common.BytesToAddress(iter.Value)
+	}
+	return votes, nil
+}
+
+// addressSet converts an address list into a membership set.
+func addressSet(addresses []common.Address) map[common.Address]struct{} {
+	set := make(map[common.Address]struct{}, len(addresses))
+	for _, address := range addresses {
+		set[address] = struct{}{}
+	}
+	return set
+}
+
+// setDifference lists the addresses present in a but not in b, sorted.
+func setDifference(a, b map[common.Address]struct{}) []common.Address {
+	var diff []common.Address
+	for address := range a {
+		if _, ok := b[address]; !ok {
+			diff = append(diff, address)
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool {
+		return bytes.Compare(diff[i][:], diff[j][:]) < 0
+	})
+	return diff
+}
+
+// delegationChange builds the change record of a single delegator from the
+// vote maps of the two ends of a diff.
+func delegationChange(delegator common.Address, before, after map[common.Address]common.Address) DelegationChange {
+	change := DelegationChange{Delegator: delegator}
+	if candidate, ok := before[delegator]; ok {
+		from := candidate
+		change.From = &from
+	}
+	if candidate, ok := after[delegator]; ok {
+		to := candidate
+		change.To = &to
+	}
+	return change
+}
+
+// ValidatorStatus describes the liveness of an active validator as observed
+// through the heartbeats gossiped on the network.
+type ValidatorStatus struct {
+	Address       common.Address `json:"address"`
+	LastHeartbeat uint64         `json:"lastHeartbeat"` // Unix time of the last heartbeat, zero if never heard
+	Online        bool           `json:"online"`
+}
+
+// ValidatorStatus reports which validators of the current epoch are online,
+// based on the heartbeats received from the network.
+func (api *API) ValidatorStatus() ([]ValidatorStatus, error) {
+	dposContext, err := api.dposContextAt(api.chain.CurrentHeader())
+	if err != nil {
+		return nil, err
+	}
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	statuses := make([]ValidatorStatus, 0, len(validators))
+	for _, validator := range validators {
+		last := api.dpos.LastHeartbeat(validator)
+		statuses = append(statuses, ValidatorStatus{
+			Address:       validator,
+			LastHeartbeat: last,
+			Online:        last != 0 && now-int64(last) <= api.dpos.heartbeatTimeout(),
+		})
+	}
+	return statuses, nil
+}
+
+// SelfStatus is the self-diagnostics report of the local node's validator
+// duties, bundling what operators otherwise have to infer from logs.
+type SelfStatus struct {
+	Signer        common.Address `json:"signer"`        // Authorized signing address, zero when not authorized
+	SignerReady   bool           `json:"signerReady"`   // Whether a signing key is authorized and available
+	Validator     bool           `json:"validator"`     // Whether the signer is in the active validator set
+	NextSlot      int64          `json:"nextSlot"`      // Unix time of the signer's next slot, zero if none
+	Synced        bool           `json:"synced"`        // Whether the chain head is fresh enough to seal on
+	ClockSkew     int64          `json:"clockSkew"`     // Estimated local clock skew in seconds
+	MintedInEpoch int64          `json:"mintedInEpoch"` // Blocks the signer produced in the current epoch
+	KickoutRisk   bool           `json:"kickoutRisk"`   // Whether production trails the pro-rated kickout threshold
+}
+
+// GetSelfStatus reports whether this node is currently able and expected to
+// seal: signer availability, validator set membership, the next assigned
+// slot, sync freshness, clock skew and kickout risk.
+func (api *API) GetSelfStatus() (*SelfStatus, error) {
+	header := api.chain.CurrentHeader()
+	dposContext, err := api.dposContextAt(header)
+	if err != nil {
+		return nil, err
+	}
+	api.dpos.mu.RLock()
+	signer, signFn := api.dpos.signer, api.dpos.signFn
+	api.dpos.mu.RUnlock()
+
+	now := time.Now().Unix()
+	health, err := api.dpos.Health(dposContext, now)
+	if err != nil {
+		return nil, err
+	}
+	return &SelfStatus{
+		Signer:        signer,
+		SignerReady:   signFn != nil && signer != (common.Address{}),
+		Validator:     health.Validator,
+		NextSlot:      health.NextSlot,
+		Synced:        now-header.Time.Int64() <= 3*api.dpos.slotInterval(),
+		ClockSkew:     api.dpos.ClockSkew(),
+		MintedInEpoch: health.MintedInEpoch,
+		KickoutRisk:   health.KickoutRisk,
+	}, nil
+}
+
+// NewSealFailure creates a subscription streaming the sealing failures of
+// the local validator, so orchestration can react to a broken signer without
+// tailing logs.
+func (api *API) NewSealFailure(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		failures := make(chan SealFailure, 8)
+		feedSub := api.dpos.SubscribeSealFailures(failures)
+		defer feedSub.Unsubscribe()
+
+		for {
+			select {
+			case failure := <-failures:
+				notifier.Notify(sub.ID, failure)
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// SlotInfo describes the producer slot containing a point in time.
+type SlotInfo struct {
+	Timestamp int64          `json:"timestamp"` // Queried point in time
+	Epoch     int64          `json:"epoch"`     // Epoch containing the slot
+	Slot      int64          `json:"slot"`      // Index of the slot within its epoch
+	Validator common.Address `json:"validator"` // Validator the slot is assigned to
+	SlotStart int64          `json:"slotStart"` // Unix time the slot opens at
+	SlotEnd   int64          `json:"slotEnd"`   // Unix time the slot closes at, exclusive
+}
+
+// GetSlotInfo maps a timestamp onto its producer slot: the epoch and slot
+// index containing it, the validator assigned under the current head's
+// schedule and the slot boundaries. A nil timestamp queries the current
+// time. The mapping reuses the schedule lookup of the sealer, so monitoring
+// and explorers need not re-implement the slot arithmetic with its interval
+// voting and layout edge cases.
+func (api *API) GetSlotInfo(timestamp *int64) (*SlotInfo, error) {
+	now := time.Now().Unix()
+	if timestamp != nil {
+		now = *timestamp
+	}
+	if now < 0 {
+		return nil, fmt.Errorf("timestamp %d before the unix epoch", now)
+	}
+	dposContext, err := api.dposContextAt(api.chain.CurrentHeader())
+	if err != nil {
+		return nil, err
+	}
+	epochContext := &EpochContext{DposContext: dposContext, config: api.dpos.config}
+	interval := epochContext.blockInterval()
+	start := now - now%interval
+	validator, err := epochContext.lookupValidator(start)
+	if err != nil {
+		return nil, err
+	}
+	return &SlotInfo{
+		Timestamp: now,
+		Epoch:     now / api.dpos.epochInterval(),
+		Slot:      (now % api.dpos.epochInterval()) / interval,
+		Validator: validator,
+		SlotStart: start,
+		SlotEnd:   start + interval,
+	}, nil
+}
+
+// MissedSlot reports a producer slot that passed without a block arriving.
+type MissedSlot struct {
+	Validator common.Address `json:"validator"` // Validator the slot was assigned to
+	Slot      int64          `json:"slot"`      // Unix time of the missed slot
+	Number    uint64         `json:"number"`    // Head block number when the miss was observed
+}
+
+// MissedSlots creates a subscription firing whenever a slot assigned to the
+// given validator passes without a block, detected from the local clock and
+// the producer schedule. The zero address subscribes to the misses of every
+// validator. Alerting on a validator's own slots this way needs no explorer
+// polling.
+func (api *API) MissedSlots(ctx context.Context, validator common.Address) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		for {
+			// Wake just after the next slot boundary, leaving the network a
+			// second of propagation slack before calling the slot missed
+			now := time.Now().Unix()
+			wait := api.dpos.nextSlot(now) - now + 1
+			select {
+			case <-time.After(time.Duration(wait) * time.Second):
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+			slot := api.dpos.prevSlot(time.Now().Unix())
+			head := api.chain.CurrentHeader()
+			if head.Time.Int64() >= slot {
+				// A block covering the slot arrived in time
+				continue
+			}
+			dposContext, err := api.dposContextAt(head)
+			if err != nil {
+				continue
+			}
+			epochContext := &EpochContext{DposContext: dposContext, config: api.dpos.config}
+			scheduled, err := epochContext.lookupValidator(slot)
+			if err != nil {
+				continue
+			}
+			if validator != (common.Address{}) && scheduled != validator {
+				continue
+			}
+			notifier.Notify(sub.ID, MissedSlot{
+				Validator: scheduled,
+				Slot:      slot,
+				Number:    head.Number.Uint64(),
+			})
+		}
+	}()
+	return sub, nil
+}
+
+// MintCntDiff reports one mint counter whose stored value diverges from the
+// block production recomputed from the chain's headers.
+type MintCntDiff struct {
+	Epoch      uint64         `json:"epoch"`
+	Validator  common.Address `json:"validator"`
+	Stored     int64          `json:"stored"`
+	Recomputed int64          `json:"recomputed"`
+}
+
+// VerifyMintCnt recomputes the per-validator block production of the epochs
+// the given block range covers from the canonical headers, applying the
+// attribution rule active at each block, and compares the result with the
+// counters stored in the head's mint counter trie. Only epochs the range
+// fully encloses and the retention still holds are compared; an end block of
+// zero verifies up to the head. Mismatches point at the legacy boundary
+// misattribution or at counter corruption.
+func (api *API) VerifyMintCnt(from, to uint64) ([]MintCntDiff, error) {
+	head := api.chain.CurrentHeader()
+	if to == 0 || to > head.Number.Uint64() {
+		to = head.Number.Uint64()
+	}
+	if from > to {
+		return nil, fmt.Errorf("start block %d beyond end block %d", from, to)
+	}
+	dposContext, err := api.dposContextAt(head)
+	if err != nil {
+		return nil, err
+	}
+	fixBlock := uint64(0)
+	if cfg := api.chain.Config().Dpos; cfg != nil {
+		fixBlock = cfg.MintCntFixBlock
+	}
+	counts := make(map[int64]map[common.Address]int64)
+	firstEpoch, lastEpoch := int64(0), int64(0)
+	for number := from; number <= to; number++ {
+		header := api.chain.GetHeaderByNumber(number)
+		if header == nil {
+			return nil, fmt.Errorf("missing canonical header %d", number)
+		}
+		t := header.Time.Int64()
+		if number == from {
+			firstEpoch = t / api.dpos.epochInterval()
+		}
+		if number == to {
+			lastEpoch = t / api.dpos.epochInterval()
+		}
+		epoch := t / api.dpos.epochInterval()
+		if fixBlock > 0 && number >= fixBlock && t%api.dpos.epochInterval() == 0 {
+			epoch--
+		}
+		if counts[epoch] == nil {
+			counts[epoch] = make(map[common.Address]int64)
+		}
+		counts[epoch][header.Validator]++
+	}
+	// Clamp the comparison to the epochs the range fully encloses and the
+	// counter retention still holds at the head
+	minEpoch, maxEpoch := firstEpoch+1, lastEpoch-1
+	if floor := head.Time.Int64()/api.dpos.epochInterval() - mintCntRetention + 1; minEpoch < floor {
+		minEpoch = floor
+	}
+	var diffs []MintCntDiff
+	for epoch := minEpoch; epoch <= maxEpoch; epoch++ {
+		recomputed := counts[epoch]
+		prefix := make([]byte, 8)
+		binary.BigEndian.PutUint64(prefix, uint64(epoch))
+		seen := make(map[common.Address]bool)
+		iter := trie.NewIterator(dposContext.MintCntTrie().NodeIterator(prefix))
+		for iter.Next() && bytes.HasPrefix(iter.Key, prefix) {
+			if len(iter.Key) != 8+common.AddressLength {
+				continue
+			}
+			validator := common.BytesToAddress(iter.Key[8:])
+			seen[validator] = true
+			stored := int64(binary.BigEndian.Uint64(iter.Value))
+			if stored != recomputed[validator] {
+				diffs = append(diffs, MintCntDiff{Epoch: uint64(epoch), Validator: validator, Stored: stored, Recomputed: recomputed[validator]})
+			}
+		}
+		// Produced blocks whose counter is missing entirely
+		missing := make([]common.Address, 0, len(recomputed))
+		for validator := range recomputed {
+			if !seen[validator] {
+				missing = append(missing, validator)
+			}
+		}
+		sort.Slice(missing, func(i, j int) bool { return bytes.Compare(missing[i][:], missing[j][:]) < 0 })
+		for _, validator := range missing {
+			diffs = append(diffs, MintCntDiff{Epoch: uint64(epoch), Validator: validator, Stored: 0, Recomputed: recomputed[validator]})
+		}
+	}
+	return diffs, nil
+}
+
+// PendingEvidence returns the double-sign proofs the malicious-fork monitor
+// has detected in peer announcements, ready to be wrapped into
+// ReportEvidence transactions.
+func (api *API) PendingEvidence() []*types.HeaderEvidence {
+	return api.dpos.PendingEvidence()
+}
+
+// ForkAlerts creates a subscription streaming the alerts of the
+// malicious-fork monitor: validator double-signs and non-canonical forks
+// outgrowing the configured length.
+func (api *API) ForkAlerts(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		alerts := make(chan ForkAlert, 8)
+		feedSub := api.dpos.SubscribeForkAlerts(alerts)
+		defer feedSub.Unsubscribe()
+
+		for {
+			select {
+			case alert := <-alerts:
+				notifier.Notify(sub.ID, alert)
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// ResumeSealing lifts a sealing auto-pause after the operator fixed the
+// underlying signer problem.
+func (api *API) ResumeSealing() {
+	api.dpos.ResumeSealing()
+}
+
+// GetConfirmedBlockNumber retrieves the latest irreversible block number.
+func (api *API) GetConfirmedBlockNumber() (*big.Int, error) {
+	var err error
+	header := api.dpos.confirmedBlockHeader
+	if header == nil {
+		header, err = api.dpos.loadConfirmedBlockHeader(api.chain)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return header.Number, nil
+}
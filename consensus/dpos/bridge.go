@@ -0,0 +1,190 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Light bridge support: validators co-sign epoch boundary headers, and the
+// aggregated signatures let a contract on another chain verify this chain's
+// headers against the validator set it already knows. The signatures are
+// collected over the heartbeat gossip channel and kept for a bounded number
+// of epochs.
+
+// bridgeRetentionEpochs is the number of past epochs whose boundary header
+// signatures are kept. A bridge relay that fell further behind has to resync
+// from a node that archived the aggregates externally.
+const bridgeRetentionEpochs = int64(3)
+
+var (
+	errBridgeBadSig       = errors.New("bridge signature does not match validator")
+	errBridgeUnknownBlock = errors.New("bridge signature for unknown block")
+	errBridgeNotBoundary  = errors.New("bridge signature for a non boundary block")
+	errBridgeNotValidator = errors.New("bridge signer not a validator of the epoch")
+)
+
+// HeaderSignature is one validator's co-signature of an epoch boundary
+// header, gossiped between peers for aggregation.
+type HeaderSignature struct {
+	Hash      common.Hash    // Hash of the co-signed header
+	Number    uint64         // Number of the co-signed header
+	Validator common.Address // Validator the signature belongs to
+	Sig       []byte         // Signature of the validator over the content
+}
+
+// SigHash returns the hash covered by the co-signature. It also serves as the
+// gossip identity of the signature for deduplication.
+func (hs *HeaderSignature) SigHash() (hash common.Hash) {
+	hasher := sha3.NewKeccak256()
+	rlp.Encode(hasher, []interface{}{hs.Hash, hs.Number, hs.Validator})
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// Verify checks that the co-signature was produced by the validator it
+// announces.
+func (hs *HeaderSignature) Verify() error {
+	pubkey, err := crypto.Ecrecover(hs.SigHash().Bytes(), hs.Sig)
+	if err != nil {
+		return err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	if signer != hs.Validator {
+		return errBridgeBadSig
+	}
+	return nil
+}
+
+// SignedHeader is an epoch boundary header together with the validator
+// co-signatures aggregated so far, in the form a bridge contract consumes.
+type SignedHeader struct {
+	Header     *types.Header    `json:"header"`
+	Validators []common.Address `json:"validators"` // Validators whose signatures were collected, sorted
+	Signatures [][]byte         `json:"signatures"` // Signatures in the order of the validators
+}
+
+// IsEpochBoundary reports whether the header starts a new epoch relative to
+// its parent, i.e. whether it ran an election and is worth co-signing for
+// bridges.
+func (d *Dpos) IsEpochBoundary(parent, header *types.Header) bool {
+	if parent == nil || header == nil {
+		return false
+	}
+	return parent.Time.Int64()/d.epochInterval() != header.Time.Int64()/d.epochInterval()
+}
+
+// SignBridgeHeader co-signs an epoch boundary header with the engine's
+// authorized signer. It returns nil without error when no signer is
+// authorized, e.g. on non validating nodes.
+func (d *Dpos) SignBridgeHeader(header *types.Header) (*HeaderSignature, error) {
+	d.mu.RLock()
+	signer, signFn := d.signer, d.signFn
+	d.mu.RUnlock()
+	if signFn == nil {
+		return nil, nil
+	}
+	hs := &HeaderSignature{Hash: header.Hash(), Number: header.Number.Uint64(), Validator: signer}
+	sig, err := signFn(accounts.Account{Address: signer}, hs.SigHash().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	hs.Sig = sig
+	return hs, nil
+}
+
+// RecordHeaderSignature aggregates a verified co-signature, after checking
+// against the chain that it covers a known epoch boundary header and that the
+// signer belongs to the validator set sealed into that header. Aggregates
+// older than the retention window are pruned on the way.
+func (d *Dpos) RecordHeaderSignature(chain consensus.ChainReader, hs *HeaderSignature) error {
+	header := chain.GetHeader(hs.Hash, hs.Number)
+	if header == nil {
+		return errBridgeUnknownBlock
+	}
+	if !d.IsEpochBoundary(chain.GetHeaderByHash(header.ParentHash), header) {
+		return errBridgeNotBoundary
+	}
+	dposContext, err := types.NewDposContextFromProto(d.triedb, header.DposContext)
+	if err != nil {
+		return err
+	}
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		return err
+	}
+	isValidator := false
+	for _, validator := range validators {
+		if validator == hs.Validator {
+			isValidator = true
+			break
+		}
+	}
+	if !isValidator {
+		return errBridgeNotValidator
+	}
+
+	d.bridgeMu.Lock()
+	defer d.bridgeMu.Unlock()
+	sigs, ok := d.bridgeSigs[hs.Hash]
+	if !ok {
+		sigs = make(map[common.Address][]byte)
+		d.bridgeSigs[hs.Hash] = sigs
+		d.bridgeNumbers[hs.Hash] = hs.Number
+	}
+	sigs[hs.Validator] = hs.Sig
+
+	// Prune aggregates that fell out of the retention window
+	horizon := uint64(bridgeRetentionEpochs * d.epochInterval() / d.slotInterval())
+	for hash, number := range d.bridgeNumbers {
+		if number+horizon < hs.Number {
+			delete(d.bridgeSigs, hash)
+			delete(d.bridgeNumbers, hash)
+		}
+	}
+	return nil
+}
+
+// SignedHeaderOf returns the given epoch boundary header together with the
+// co-signatures aggregated for it so far, sorted by validator address so the
+// output is deterministic.
+func (d *Dpos) SignedHeaderOf(header *types.Header) *SignedHeader {
+	d.bridgeMu.RLock()
+	sigs := d.bridgeSigs[header.Hash()]
+	signed := &SignedHeader{Header: header}
+	for validator := range sigs {
+		signed.Validators = append(signed.Validators, validator)
+	}
+	sort.Slice(signed.Validators, func(i, j int) bool {
+		return signed.Validators[i].String() < signed.Validators[j].String()
+	})
+	for _, validator := range signed.Validators {
+		signed.Signatures = append(signed.Signatures, sigs[validator])
+	}
+	d.bridgeMu.RUnlock()
+	return signed
+}
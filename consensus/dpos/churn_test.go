@@ -0,0 +1,217 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/simulations"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// The churn scenario scripts validator joins, crashes and voluntary exits
+// across epochs on an in-memory simulation network. Each simulation node
+// operates one candidate; a candidate only mints the blocks of an epoch while
+// its node is up, and the real election and kickout logic run at every epoch
+// boundary. The assertions cover chain liveness (a full validator set at all
+// times) and the resulting validator-set evolution.
+
+// churnService is the no-op service the simulation nodes run; the scenario
+// only scripts node lifecycle, the consensus state lives in the test harness.
+type churnService struct{}
+
+func newChurnService(ctx *adapters.ServiceContext) (node.Service, error) {
+	return &churnService{}, nil
+}
+
+func (s *churnService) Protocols() []p2p.Protocol   { return nil }
+func (s *churnService) APIs() []rpc.API             { return nil }
+func (s *churnService) Start(srv *p2p.Server) error { return nil }
+func (s *churnService) Stop() error                 { return nil }
+
+// churnEnv couples the election environment with the simulation network and
+// the node operating each candidate.
+type churnEnv struct {
+	*electionEnv
+	network *simulations.Network
+	nodes   map[common.Address]discover.NodeID
+	genesis *types.Header
+}
+
+func newChurnEnv(t *testing.T) *churnEnv {
+	adapter := adapters.NewSimAdapter(adapters.Services{
+		"dpos-churn": newChurnService,
+	})
+	network := simulations.NewNetwork(adapter, &simulations.NetworkConfig{
+		DefaultService: "dpos-churn",
+	})
+	return &churnEnv{
+		electionEnv: newElectionEnv(t),
+		network:     network,
+		nodes:       make(map[common.Address]discover.NodeID),
+		genesis:     testHeader(0, 0),
+	}
+}
+
+// join spins up a simulation node operating the given candidate and registers
+// the self-voting candidacy backed by the given balance.
+func (env *churnEnv) join(t *testing.T, candidate common.Address, balance int64) {
+	conf := adapters.RandomNodeConfig()
+	simNode, err := env.network.NewNodeWithConfig(conf)
+	if err != nil {
+		t.Fatalf("failed to create simulation node: %v", err)
+	}
+	if err := env.network.Start(simNode.ID()); err != nil {
+		t.Fatalf("failed to start simulation node: %v", err)
+	}
+	env.nodes[candidate] = simNode.ID()
+	env.addCandidate(t, candidate, balance)
+}
+
+// crash stops the node operating the given candidate without any cleanup of
+// its consensus state, like a validator host dying mid-epoch.
+func (env *churnEnv) crash(t *testing.T, candidate common.Address) {
+	if err := env.network.Stop(env.nodes[candidate]); err != nil {
+		t.Fatalf("failed to stop simulation node: %v", err)
+	}
+}
+
+// up reports whether the node operating the given candidate is running.
+func (env *churnEnv) up(candidate common.Address) bool {
+	simNode := env.network.GetNode(env.nodes[candidate])
+	return simNode != nil && simNode.Up
+}
+
+// mintEpoch credits every current validator whose node is up with its full
+// block production share of the given epoch; validators whose node is down
+// mint nothing, exactly what the kickout logic keys on.
+func (env *churnEnv) mintEpoch(t *testing.T, epoch int64) {
+	validators, err := env.dposContext.GetValidators()
+	if err != nil {
+		t.Fatalf("failed to get validators: %v", err)
+	}
+	share := epochInterval / blockInterval / int64(len(validators))
+	epochBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(epochBytes, uint64(epoch))
+	for _, validator := range validators {
+		if !env.up(validator) {
+			continue
+		}
+		cntBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(cntBytes, uint64(share))
+		if err := env.dposContext.MintCntTrie().TryUpdate(append(epochBytes, validator.Bytes()...), cntBytes); err != nil {
+			t.Fatalf("failed to record mint count: %v", err)
+		}
+	}
+}
+
+// crossEpoch runs the election at the boundary closing the given epoch and
+// returns the elected validator set.
+func (env *churnEnv) crossEpoch(t *testing.T, epoch int64) map[common.Address]bool {
+	boundary := (epoch + 1) * epochInterval
+	ec := env.epochContext(boundary)
+	parent := testHeader(epoch*epochInterval/blockInterval, boundary-blockInterval)
+	if err := ec.tryElect(env.genesis, parent); err != nil {
+		t.Fatalf("election at epoch %d failed: %v", epoch, err)
+	}
+	validators, err := env.dposContext.GetValidators()
+	if err != nil {
+		t.Fatalf("failed to get validators: %v", err)
+	}
+	if len(validators) < safeSizeOf(nil) {
+		t.Fatalf("liveness lost after epoch %d: %d validators, want at least %d", epoch, len(validators), safeSizeOf(nil))
+	}
+	elected := make(map[common.Address]bool)
+	for _, validator := range validators {
+		elected[validator] = true
+	}
+	return elected
+}
+
+// Tests that the consensus stays live and the validator set evolves correctly
+// while validators join, crash and leave across epochs.
+func TestValidatorChurnSimulation(t *testing.T) {
+	defer func(prev int64) { timeOfFirstBlock = prev }(timeOfFirstBlock)
+	timeOfFirstBlock = blockInterval
+
+	env := newChurnEnv(t)
+	defer env.network.Shutdown()
+
+	// Boot the network with a few more candidates than validator slots; the
+	// lowest staked ones stay on standby
+	nodeCount := maxValidatorSize + 4
+	for i := 0; i < nodeCount; i++ {
+		env.join(t, testAddr(i), int64(1000+i))
+	}
+	elected := env.crossEpoch(t, 0)
+	if len(elected) != maxValidatorSize {
+		t.Fatalf("initial validator count mismatch: have %d, want %d", len(elected), maxValidatorSize)
+	}
+	for i := 0; i < 4; i++ {
+		if elected[testAddr(i)] {
+			t.Errorf("standby candidate %d elected over higher staked ones", i)
+		}
+	}
+	// Epoch 1: three validator hosts crash mid-epoch and stop minting; the
+	// boundary must kick them out and promote the standby candidates
+	crashed := []common.Address{testAddr(nodeCount - 1), testAddr(nodeCount - 2), testAddr(nodeCount - 3)}
+	for _, validator := range crashed {
+		env.crash(t, validator)
+	}
+	env.mintEpoch(t, 1)
+	elected = env.crossEpoch(t, 1)
+	for _, validator := range crashed {
+		if elected[validator] {
+			t.Errorf("crashed validator %x survived the kickout", validator)
+		}
+	}
+	if !elected[testAddr(1)] || !elected[testAddr(2)] || !elected[testAddr(3)] {
+		t.Errorf("standby candidates not promoted after kickout")
+	}
+	// Epoch 2: a well backed newcomer joins; the kicked candidates stay out
+	// on their cooldown even though their nodes may come back
+	newcomer := testAddr(nodeCount)
+	env.join(t, newcomer, 100000)
+	env.mintEpoch(t, 2)
+	elected = env.crossEpoch(t, 2)
+	if !elected[newcomer] {
+		t.Errorf("newly joined candidate %x not elected", newcomer)
+	}
+	for _, validator := range crashed {
+		if elected[validator] {
+			t.Errorf("kicked validator %x re-elected during cooldown", validator)
+		}
+	}
+	// Epoch 3: a validator retires voluntarily, removing its candidacy; the
+	// next boundary must elect the set without it while staying live
+	departed := testAddr(10)
+	if err := env.dposContext.KickoutCandidate(departed); err != nil {
+		t.Fatalf("failed to retire candidate: %v", err)
+	}
+	env.mintEpoch(t, 3)
+	elected = env.crossEpoch(t, 3)
+	if elected[departed] {
+		t.Errorf("retired validator %x still elected", departed)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	// clockSkewSamples is the number of recent observations the skew
+	// estimate averages over, smoothing out network latency jitter.
+	clockSkewSamples = 8
+
+	// clockSkewWarnDivisor and clockSkewDangerDivisor express the skew
+	// thresholds as fractions of the block interval: above a quarter the
+	// operator is warned, above half sealing becomes dangerous since the
+	// node starts hitting neighbouring slots.
+	clockSkewWarnDivisor   = 4
+	clockSkewDangerDivisor = 2
+
+	// clockSkewWarnInterval throttles repeated skew warnings.
+	clockSkewWarnInterval = time.Minute
+)
+
+// clockSkewGauge exports the current skew estimate in seconds; positive means
+// the local clock runs behind the network.
+var clockSkewGauge = metrics.NewRegisteredGauge("dpos/clock/skew", nil)
+
+// clockMonitor estimates the local clock skew against the network. Freshly
+// minted blocks are sealed exactly on a slot boundary and heartbeats are
+// stamped at signing time, so for a well synchronised clock both should match
+// the local time of arrival to within network latency. Most missed slots in
+// practice are clock problems the node could have detected this way.
+type clockMonitor struct {
+	mu      sync.Mutex
+	samples []int64 // Ring of recent skew observations in seconds
+	pos     int     // Next ring slot to overwrite once the ring is full
+	warned  time.Time
+}
+
+// observe records one skew sample from a block or heartbeat stamped at the
+// given unix time, measured against the engine's current block interval.
+// Timestamps far from local time are historical sync traffic rather than
+// clock evidence and are ignored.
+func (cm *clockMonitor) observe(stampedAt, now, interval int64) {
+	skew := now - stampedAt
+	if skew > 10*interval || skew < -10*interval {
+		return
+	}
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if len(cm.samples) < clockSkewSamples {
+		cm.samples = append(cm.samples, skew)
+	} else {
+		cm.samples[cm.pos] = skew
+		cm.pos = (cm.pos + 1) % clockSkewSamples
+	}
+	estimate := cm.estimate()
+	clockSkewGauge.Update(estimate)
+
+	if warn := interval / clockSkewWarnDivisor; (estimate >= warn || estimate <= -warn) && time.Since(cm.warned) > clockSkewWarnInterval {
+		log.Warn("System clock seems off, check NTP", "skew", time.Duration(estimate)*time.Second, "blockInterval", time.Duration(interval)*time.Second)
+		cm.warned = time.Now()
+	}
+}
+
+// estimate returns the mean of the recorded skew samples. The caller must
+// hold the monitor lock.
+func (cm *clockMonitor) estimate() int64 {
+	if len(cm.samples) == 0 {
+		return 0
+	}
+	sum := int64(0)
+	for _, sample := range cm.samples {
+		sum += sample
+	}
+	return sum / int64(len(cm.samples))
+}
+
+// dangerous reports whether the estimated skew is large enough that sealing
+// at the given block interval would likely produce blocks in the wrong slot.
+func (cm *clockMonitor) dangerous(interval int64) bool {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	danger := interval / clockSkewDangerDivisor
+	estimate := cm.estimate()
+	return estimate >= danger || estimate <= -danger
+}
+
+// ClockSkew returns the engine's current clock skew estimate in seconds, for
+// the validator status RPC and operator dashboards.
+func (d *Dpos) ClockSkew() int64 {
+	d.clock.mu.Lock()
+	defer d.clock.mu.Unlock()
+	return d.clock.estimate()
+}
@@ -0,0 +1,1885 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dpos implements the delegated proof-of-stake consensus engine.
+package dpos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	extraVanity        = 32   // Fixed number of extra-data prefix bytes reserved for validator vanity
+	extraSeal          = 65   // Fixed number of extra-data suffix bytes reserved for validator seal
+	extraNote          = 16   // Leading vanity bytes free for an operator note, ahead of the gas votes
+	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
+
+	// sealBudgetDivisor is the fraction of the slot reserved for finalizing,
+	// signing and propagating a block: the miner stops filling in
+	// transactions once only 1/sealBudgetDivisor of the slot remains.
+	sealBudgetDivisor = 5
+)
+
+// Default timing and sizing parameters of the engine. Private and developer
+// chains may override them through DposConfig; the config aware helpers below
+// resolve the effective value for a given network, so two engines with
+// different configurations never interfere.
+const (
+	blockInterval    = int64(10)    // Default number of seconds between two consecutive blocks
+	epochInterval    = int64(86400) // Default number of seconds a validator epoch lasts
+	maxValidatorSize = 21           // Default number of validators elected per epoch
+)
+
+// blockIntervalOf returns the block interval the configured network runs at,
+// falling back to the engine default when unset.
+func blockIntervalOf(config *params.DposConfig) int64 {
+	if config == nil {
+		return blockInterval
+	}
+	return config.BlockIntervalSeconds()
+}
+
+// epochIntervalOf returns the epoch length of the configured network in
+// seconds, falling back to the engine default when unset.
+func epochIntervalOf(config *params.DposConfig) int64 {
+	if config == nil {
+		return epochInterval
+	}
+	return config.EpochIntervalSeconds()
+}
+
+// maxValidatorSizeOf returns the number of validators the configured network
+// elects per epoch, falling back to the engine default when unset.
+func maxValidatorSizeOf(config *params.DposConfig) int {
+	if config != nil && config.MaxValidatorSize > 0 {
+		return int(config.MaxValidatorSize)
+	}
+	return maxValidatorSize
+}
+
+// safeSizeOf returns the minimum number of candidates an election needs to
+// fill a validator set on the configured network.
+func safeSizeOf(config *params.DposConfig) int {
+	return maxValidatorSizeOf(config)*2/3 + 1
+}
+
+// consensusSizeOf returns the number of confirming validators that makes a
+// block irreversible on the configured network.
+func consensusSizeOf(config *params.DposConfig) int {
+	return maxValidatorSizeOf(config)*2/3 + 1
+}
+
+// kickoutCooldownOf returns the number of seconds a kicked out validator is
+// barred from re-registering as a candidate on the configured network.
+func kickoutCooldownOf(config *params.DposConfig) int64 {
+	return 2 * epochIntervalOf(config)
+}
+
+// Dpos delegated proof-of-stake protocol constants.
+var (
+	blockReward = big.NewInt(5e+18) // Default block reward in wei for networks without an emission schedule
+
+	uncleHash = types.CalcUncleHash(nil) // Always Keccak256(RLP([])) as uncles are meaningless outside of PoW.
+
+	confirmedBlockHead = []byte("confirmed-block-head")
+
+	timeOfFirstBlock = int64(0)
+)
+
+// Various error messages to mark blocks invalid. These should be private to
+// prevent engine specific errors from being referenced in the remainder of the
+// codebase, inherently breaking if the engine is swapped out. Please put common
+// error types into the consensus package.
+var (
+	// errUnknownBlock is returned when the list of validators is requested for a
+	// block that is not part of the local blockchain.
+	errUnknownBlock = errors.New("unknown block")
+
+	// errMissingVanity is returned if a block's extra-data section is shorter than
+	// 32 bytes, which is required to store the validator vanity.
+	errMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
+
+	// errMissingDposContext is returned when a header that should carry the
+	// dpos context trie roots carries none.
+	errMissingDposContext = errors.New("header is missing its dpos context")
+
+	// errMissingSignature is returned if a block's extra-data section doesn't seem
+	// to contain a 65 byte secp256k1 signature.
+	errMissingSignature = errors.New("extra-data 65 byte suffix signature missing")
+
+	// errExtraDataTooLong is returned if a block's extra-data section exceeds
+	// the fixed vanity plus seal form, preventing extra-data from being
+	// abused as arbitrary block storage.
+	errExtraDataTooLong = errors.New("extra-data exceeds vanity and seal")
+
+	// errInvalidMixDigest is returned if a block's mix digest is non-zero.
+	errInvalidMixDigest = errors.New("non-zero mix digest")
+
+	// errInvalidUncleHash is returned if a block contains an non-empty uncle list.
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is not one.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// ErrInvalidTimestamp is returned if the timestamp of a block is lower than
+	// the previous block's timestamp + the minimum block period.
+	ErrInvalidTimestamp = errors.New("invalid timestamp")
+
+	// ErrWaitForPrevBlock is returned when trying to mint before the parent of
+	// the slot has arrived.
+	ErrWaitForPrevBlock = &Error{ErrCodeWaitForParent, "wait for last block arrived"}
+
+	// ErrMintFutureBlock is returned when trying to mint a block whose slot lies
+	// in the future.
+	ErrMintFutureBlock = &Error{ErrCodeFutureSlot, "mint the future block"}
+
+	// ErrMismatchSignerAndValidator is returned if the block was sealed by a key
+	// that does not belong to the validator recorded in the header.
+	ErrMismatchSignerAndValidator = errors.New("mismatch block signer and validator")
+
+	// ErrInvalidBlockValidator is returned if the block was sealed by a validator
+	// that is not in charge of the block's time slot.
+	ErrInvalidBlockValidator = &Error{ErrCodeNotOwnSlot, "invalid block validator"}
+
+	// ErrInvalidMintBlockTime is returned if the block's timestamp is not aligned
+	// on a slot boundary.
+	ErrInvalidMintBlockTime = errors.New("invalid time to mint the block")
+
+	// ErrNilBlockHeader is returned when walking the chain hits a missing header.
+	ErrNilBlockHeader = errors.New("nil block header returned")
+
+	// ErrStaleParentBlock is returned when the chain head changed while the
+	// sealer was waiting for its time slot, e.g. because of a reorg.
+	ErrStaleParentBlock = errors.New("chain head changed while sealing")
+
+	// ErrDangerousClockSkew is returned when sealing is refused because the
+	// local clock drifted too far off the network to hit the right slot.
+	ErrDangerousClockSkew = &Error{ErrCodeClockSkew, "local clock skew too large to seal safely"}
+
+	// ErrSealingPaused is returned while sealing is auto-paused after
+	// repeated failures, until the operator resumes it.
+	ErrSealingPaused = &Error{ErrCodeSealingPaused, "sealing paused after repeated failures"}
+)
+
+// SignerFn is a signer callback function to request a hash to be signed by a
+// backing account.
+type SignerFn func(accounts.Account, []byte) ([]byte, error)
+
+// StateFn resolves a state root to the account state it commits to. It is
+// injected by the backend so RPC queries can weigh votes by balances.
+type StateFn func(root common.Hash) (*state.StateDB, error)
+
+// Dpos is the delegated proof-of-stake consensus engine.
+type Dpos struct {
+	config *params.DposConfig // Consensus engine configuration parameters
+	db     ethdb.Database     // Database to store and retrieve consensus checkpoints
+	triedb *trie.Database     // Trie database backing the dpos context tries
+
+	signer common.Address // Ethereum address of the signing key
+	signFn SignerFn       // Signer function to authorize hashes with
+
+	signatures *lru.ARCCache // Signatures of recent blocks to speed up mining
+
+	stateFn StateFn // Account state resolver for RPC queries needing balances
+
+	legacy    consensus.Engine // Pre-fork engine of a migrated chain, nil when dpos runs from genesis
+	forkBlock uint64           // First block number governed by dpos on a migrated chain
+
+	confirmedBlockHeader *types.Header
+
+	heartbeats   map[common.Address]uint64 // Last heartbeat times of the validators heard on the network
+	heartbeatsMu sync.RWMutex
+
+	clock clockMonitor // Clock skew estimate fed by block and heartbeat timestamps
+
+	holderID       uint64 // Random id distinguishing processes sharing a validator key
+	standby        bool   // Whether this node yields sealing to a live primary
+	replica        bool   // Whether this node is a read replica that never seals, guarded by mu
+	peerHolderSeen int64  // Unix time of the last heartbeat from another holder of our key, guarded by heartbeatsMu
+
+	sealFails  int        // Consecutive failed sealing attempts, guarded by mu
+	sealPaused bool       // Whether sealing was auto-paused after repeated failures, guarded by mu
+	sealFeed   event.Feed // Publishes SealFailure reports to subscribers
+
+	gasPriceVote uint64 // Minimum gas price the local validator signals in sealed headers, guarded by mu
+
+	heartbeatSlots uint64 // Slots between heartbeat blocks when empty-block suppression is on, zero to disable, guarded by mu
+	lastSealed     int64  // Unix time of the last block the local validator sealed, guarded by mu
+
+	adoptedInterval int64 // Block interval the current epoch adopted by vote, zero without one, guarded by mu
+
+	faults *faultInjection // Testing-only failure injector, nil in production, guarded by mu
+
+	haltReported bool // Whether the unusable validator set halt was already reported, guarded by mu
+
+	bridgeSigs    map[common.Hash]map[common.Address][]byte // Aggregated bridge co-signatures per boundary header
+	bridgeNumbers map[common.Hash]uint64                    // Block numbers of the aggregated headers, for pruning
+	bridgeMu      sync.RWMutex
+
+	forkWatch       uint64                                // Fork length the malicious-fork monitor alerts at, zero when disabled
+	slotSeen        map[slotKey]*types.Header             // Sealed header remembered per validator slot, guarded by forkMu
+	forkBranches    map[common.Hash]uint64                // Lengths of observed non-canonical branches, guarded by forkMu
+	pendingEvidence map[common.Hash]*types.HeaderEvidence // Detected double-sign proofs awaiting reporting, guarded by forkMu
+	forkFeed        event.Feed                            // Publishes ForkAlerts to subscribers
+	evidenceFeed    event.Feed                            // Publishes detected double-sign evidence to subscribers
+	forkMu          sync.Mutex
+
+	precomputedHead common.Hash // Head the last speculative election replay ran on, guarded by precomputeMu
+	precomputeMu    sync.Mutex
+
+	// Lifecycle feeds publishing typed consensus events to in-process
+	// subscribers, see events.go
+	slotStartedFeed     event.Feed
+	blockSealedFeed     event.Feed
+	slotMissedFeed      event.Feed
+	epochElectedFeed    event.Feed
+	validatorKickedFeed event.Feed
+	evidenceFoundFeed   event.Feed
+
+	mu   sync.RWMutex
+	stop chan bool
+}
+
+// sigHash returns the hash which is used as input for the delegated
+// proof-of-stake signing. It is the hash of the entire header apart from the
+// 65 byte signature contained at the end of the extra data.
+func sigHash(header *types.Header) (hash common.Hash) {
+	hasher := sha3.NewKeccak256()
+
+	rlp.Encode(hasher, []interface{}{
+		header.ParentHash,
+		header.UncleHash,
+		header.Validator,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		header.Extra[:len(header.Extra)-extraSeal],
+		header.MixDigest,
+		header.Nonce,
+		header.DposContext.Root(),
+	})
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// ecrecover extracts the Ethereum account address from a signed header.
+func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+	// If the signature's already cached, return that
+	hash := header.Hash()
+	if address, known := sigcache.Get(hash); known {
+		return address.(common.Address), nil
+	}
+	// Retrieve the signature from the header extra-data
+	if len(header.Extra) < extraSeal {
+		return common.Address{}, errMissingSignature
+	}
+	signature := header.Extra[len(header.Extra)-extraSeal:]
+	// Recover the public key and the Ethereum address
+	pubkey, err := crypto.Ecrecover(sigHash(header).Bytes(), signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+
+	sigcache.Add(hash, signer)
+	return signer, nil
+}
+
+// New creates a delegated proof-of-stake consensus engine.
+func New(config *params.DposConfig, db ethdb.Database) *Dpos {
+	// Timing and sizing overrides of private networks stay on the config and
+	// are resolved through the config aware helpers, never by mutating the
+	// package defaults another engine instance may be reading.
+	if config != nil {
+		// A mode that is neither built in nor registered by an embedding
+		// fork would silently tally with the linear fallback and fork the
+		// chain against correctly configured nodes; refuse to start instead
+		if !builtinWeightMode(config.VoteWeightMode) && lookupVoteWeigher(config.VoteWeightMode) == nil {
+			log.Crit("Unknown vote weight mode, register it with RegisterVoteWeigher", "mode", config.VoteWeightMode)
+		}
+		switch config.ProposerOrdering {
+		case "", ProposerOrderingShuffle, ProposerOrderingStakeWeighted:
+		default:
+			log.Warn("Unknown proposer ordering, falling back to shuffle", "mode", config.ProposerOrdering)
+		}
+	}
+	signatures, _ := lru.NewARC(inmemorySignatures)
+	return &Dpos{
+		config:        config,
+		db:            db,
+		triedb:        trie.NewDatabase(db),
+		signatures:    signatures,
+		heartbeats:    make(map[common.Address]uint64),
+		holderID:      rand.Uint64(),
+		bridgeSigs:    make(map[common.Hash]map[common.Address][]byte),
+		bridgeNumbers: make(map[common.Hash]uint64),
+	}
+}
+
+// Author implements consensus.Engine, returning the validator that sealed the
+// header.
+func (d *Dpos) Author(header *types.Header) (common.Address, error) {
+	if d.isLegacy(header.Number.Uint64()) {
+		return d.legacy.Author(header)
+	}
+	return header.Validator, nil
+}
+
+// RecoverProposer returns the account recovered from the header's seal
+// signature. Unlike Author it does not trust the declared validator field,
+// and unlike a raw ecrecover it is answered from the engine's signature
+// cache for recently touched headers, making it cheap enough for the RPC
+// block marshalling to call on every request.
+func (d *Dpos) RecoverProposer(header *types.Header) (common.Address, error) {
+	if d.isLegacy(header.Number.Uint64()) {
+		return d.legacy.Author(header)
+	}
+	return ecrecover(header, d.signatures)
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules.
+func (d *Dpos) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	if d.isLegacy(header.Number.Uint64()) {
+		return d.legacy.VerifyHeader(chain, header, seal)
+	}
+	return d.verifyHeader(chain, header, nil)
+}
+
+func (d *Dpos) verifyHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+	// Don't waste time checking blocks from the future
+	if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+		return consensus.ErrFutureBlock
+	}
+	// Check that the extra-data contains both the vanity and signature
+	if len(header.Extra) < extraVanity {
+		return errMissingVanity
+	}
+	if len(header.Extra) < extraVanity+extraSeal {
+		return errMissingSignature
+	}
+	// The extra-data is a fixed-form field: operator note and gas votes in
+	// the vanity, followed by the seal. Reject anything beyond that.
+	if len(header.Extra) > extraVanity+extraSeal {
+		return errExtraDataTooLong
+	}
+	// Ensure that the mix digest is zero as we don't have fork protection currently
+	if header.MixDigest != (common.Hash{}) {
+		return errInvalidMixDigest
+	}
+	// Difficulty always 1
+	if number > 0 && header.Difficulty.Cmp(big.NewInt(1)) != 0 {
+		return errInvalidDifficulty
+	}
+	// Ensure that the block doesn't contain any uncles which are meaningless in DPOS
+	if header.UncleHash != uncleHash {
+		return errInvalidUncleHash
+	}
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return consensus.ErrUnknownAncestor
+	}
+	// Under adaptive interval voting any interval down to the governance
+	// floor may be active, so this stateless check enforces the floor; the
+	// slot lookup of the seal verification enforces the exact grid
+	minInterval := uint64(d.slotInterval())
+	if d.config != nil && d.config.MinBlockInterval > 0 {
+		minInterval = d.config.MinBlockInterval
+	}
+	if parent.Time.Uint64()+minInterval > header.Time.Uint64()+d.driftTolerance() {
+		return ErrInvalidTimestamp
+	}
+	// The gas limit may move at most one bounded step per block, however the
+	// validators vote; a single sealer cannot drag it anywhere on its own
+	diff := int64(parent.GasLimit) - int64(header.GasLimit)
+	if diff < 0 {
+		diff *= -1
+	}
+	limit := parent.GasLimit / params.GasLimitBoundDivisor
+	if uint64(diff) >= limit || header.GasLimit < params.MinGasLimit {
+		return fmt.Errorf("invalid gas limit: have %d, want %d += %d", header.GasLimit, parent.GasLimit, limit)
+	}
+	return nil
+}
+
+// driftTolerance returns the number of seconds a block timestamp may drift
+// off its slot boundary on this network.
+func (d *Dpos) driftTolerance() uint64 {
+	if d.config != nil {
+		return d.config.MaxBlockDrift
+	}
+	return 0
+}
+
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers
+// concurrently, including the requested seal checks. The method returns a
+// quit channel to abort the operations and a results channel to retrieve the
+// async verifications.
+func (d *Dpos) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	// On a migrated chain, hand the pre-fork prefix of the batch to the
+	// legacy engine, which knows how to verify its own headers; a batch
+	// usually falls entirely on one side of the fork.
+	if d.legacy != nil && len(headers) > 0 && headers[0].Number.Uint64() < d.forkBlock {
+		split := sort.Search(len(headers), func(i int) bool {
+			return headers[i].Number.Uint64() >= d.forkBlock
+		})
+		if split == len(headers) {
+			return d.legacy.VerifyHeaders(chain, headers, seals)
+		}
+		return d.verifyHeadersAcrossFork(chain, headers, seals, split)
+	}
+	return d.verifyHeaderBatch(chain, headers, seals, 0)
+}
+
+// verifyHeadersAcrossFork verifies a header batch straddling the migration
+// fork: the legacy engine checks the pre-fork prefix, the dpos rules the
+// rest, and the results are forwarded in batch order on a single channel.
+func (d *Dpos) verifyHeadersAcrossFork(chain consensus.ChainReader, headers []*types.Header, seals []bool, split int) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+	go func() {
+		legacyAbort, legacyResults := d.legacy.VerifyHeaders(chain, headers[:split], seals[:split])
+		defer close(legacyAbort)
+		for i := 0; i < split; i++ {
+			select {
+			case err := <-legacyResults:
+				results <- err
+			case <-abort:
+				return
+			}
+		}
+		// The dpos part keeps the full batch in view, so the transition
+		// block finds its clique parent among the batched ancestors
+		dposAbort, dposResults := d.verifyHeaderBatch(chain, headers, seals, split)
+		defer close(dposAbort)
+		for i := split; i < len(headers); i++ {
+			select {
+			case err := <-dposResults:
+				results <- err
+			case <-abort:
+				return
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeaderBatch concurrently verifies the headers from the given offset
+// on against the dpos rules, the preceding batch entries serving as ancestors
+// not yet known to the chain. Requested seal checks run on a fast path made
+// for the fixed slot grid: the slot schedule is resolved once per epoch and
+// the headers are checked against the cached set, instead of each opening the
+// dpos tries of its parent.
+func (d *Dpos) verifyHeaderBatch(chain consensus.ChainReader, headers []*types.Header, seals []bool, offset int) (chan<- struct{}, <-chan error) {
+	// Spawn as many workers as allowed threads; header checks only read the
+	// chain and the batch, so they can run concurrently
+	workers := runtime.GOMAXPROCS(0)
+	if len(headers)-offset < workers {
+		workers = len(headers) - offset
+	}
+	var (
+		inputs = make(chan int)
+		done   = make(chan int, workers)
+		errors = make([]error, len(headers))
+		abort  = make(chan struct{})
+	)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for index := range inputs {
+				errors[index] = d.verifyHeader(chain, headers[index], headers[:index])
+				done <- index
+			}
+		}()
+	}
+	errorsOut := make(chan error, len(headers)-offset)
+	go func() {
+		defer close(inputs)
+		var (
+			in, out = offset, offset
+			checked = make([]bool, len(headers))
+			inputs  = inputs
+			sched   *epochSchedule
+		)
+		for {
+			select {
+			case inputs <- in:
+				if in++; in == len(headers) {
+					// Reached end of headers. Stop sending to workers.
+					inputs = nil
+				}
+			case index := <-done:
+				for checked[index] = true; checked[out]; out++ {
+					// Seal checks run here on the ordered tail, so the epoch
+					// schedule cache walks the batch front to back
+					if errors[out] == nil && seals[out] {
+						errors[out] = d.verifySealFast(chain, headers, out, &sched)
+					}
+					errorsOut <- errors[out]
+					if out == len(headers)-1 {
+						return
+					}
+				}
+			case <-abort:
+				return
+			}
+		}
+	}()
+	return abort, errorsOut
+}
+
+// VerifyUncles implements consensus.Engine, always returning an error for any
+// uncles as this consensus mechanism doesn't permit uncles.
+func (d *Dpos) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errInvalidUncleHash
+	}
+	return nil
+}
+
+// VerifySeal implements consensus.Engine, checking whether the signature
+// contained in the header belongs to the validator in charge of the block's
+// time slot.
+func (d *Dpos) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	if d.isLegacy(header.Number.Uint64()) {
+		return d.legacy.VerifySeal(chain, header)
+	}
+	parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	return d.verifySeal(chain, header, parent)
+}
+
+func (d *Dpos) verifySeal(chain consensus.ChainReader, header *types.Header, parent *types.Header) error {
+	// Verifying the genesis block is not supported
+	if header.Number.Uint64() == 0 {
+		return errUnknownBlock
+	}
+	var (
+		dposContext *types.DposContext
+		err         error
+	)
+	// The transition block of a migrated chain sits on a clique parent that
+	// carries no dpos context; its validator schedule comes from the
+	// migration context built out of the clique signers instead
+	if d.legacy != nil && header.Number.Uint64() == d.forkBlock {
+		dposContext, err = d.MigrationContext(chain, parent)
+	} else {
+		dposContext, err = types.NewDposContextFromProto(d.triedb, parent.DposContext)
+	}
+	if err != nil {
+		return err
+	}
+	d.syncBlockInterval(dposContext)
+	epochContext := &EpochContext{DposContext: dposContext, config: d.config}
+	validator, err := d.lookupValidatorSafe(epochContext, header.Time.Int64())
+	if err != nil {
+		return err
+	}
+	if err := d.verifyBlockSigner(dposContext, validator, header); err != nil {
+		return err
+	}
+	d.reportMissedSlots(epochContext, parent, header)
+	// A verified block was sealed on a slot boundary by someone else's
+	// clock, which makes it a skew sample for ours
+	d.clock.observe(header.Time.Int64(), time.Now().Unix(), d.slotInterval())
+	d.maybePrecomputeElection(chain, header)
+	return d.updateConfirmedBlockHeader(chain)
+}
+
+func (d *Dpos) verifyBlockSigner(dposContext *types.DposContext, validator common.Address, header *types.Header) error {
+	signer, err := ecrecover(header, d.signatures)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(header.Validator.Bytes(), validator.Bytes()) {
+		return ErrInvalidBlockValidator
+	}
+	// The header names the candidate, but the signature must come from the
+	// sealing key currently active for it, which may differ after a rotation
+	if !bytes.Equal(signer.Bytes(), dposContext.ActiveSigner(validator, header.Time.Int64()/d.epochInterval()).Bytes()) {
+		return ErrMismatchSignerAndValidator
+	}
+	return nil
+}
+
+func (d *Dpos) updateConfirmedBlockHeader(chain consensus.ChainReader) error {
+	if d.confirmedBlockHeader == nil {
+		header, err := d.loadConfirmedBlockHeader(chain)
+		if err != nil {
+			header = chain.GetHeaderByNumber(0)
+			if header == nil {
+				return err
+			}
+		}
+		d.confirmedBlockHeader = header
+	}
+	curHeader := chain.CurrentHeader()
+	epoch := int64(-1)
+	validatorMap := make(map[common.Address]bool)
+	for d.confirmedBlockHeader.Hash() != curHeader.Hash() &&
+		d.confirmedBlockHeader.Number.Uint64() < curHeader.Number.Uint64() {
+		// Pre-fork clique headers carry no validator identity, so the
+		// confirmed block cannot reach below the migration fork
+		if d.isLegacy(curHeader.Number.Uint64()) {
+			return nil
+		}
+		curEpoch := curHeader.Time.Int64() / d.epochInterval()
+		if curEpoch != epoch {
+			epoch = curEpoch
+			validatorMap = make(map[common.Address]bool)
+		}
+		// Fast return: if the number of remaining headers is not enough to
+		// collect signatures from consensusSize distinct validators, the
+		// confirmed block cannot advance any further.
+		if curHeader.Number.Int64()-d.confirmedBlockHeader.Number.Int64() < int64(consensusSizeOf(d.config)-len(validatorMap)) {
+			log.Debug("Dpos fast return", "current", curHeader.Number.String(), "confirmed", d.confirmedBlockHeader.Number.String(), "witnessCount", len(validatorMap))
+			return nil
+		}
+		validatorMap[curHeader.Validator] = true
+		if len(validatorMap) >= consensusSizeOf(d.config) {
+			d.confirmedBlockHeader = curHeader
+			if err := d.storeConfirmedBlockHeader(d.db); err != nil {
+				return err
+			}
+			log.Debug("Dpos set confirmed block header success", "currentHeader", curHeader.Number.String())
+			return nil
+		}
+		curHeader = chain.GetHeaderByHash(curHeader.ParentHash)
+		if curHeader == nil {
+			return ErrNilBlockHeader
+		}
+	}
+	return nil
+}
+
+func (d *Dpos) loadConfirmedBlockHeader(chain consensus.ChainReader) (*types.Header, error) {
+	key, err := d.db.Get(confirmedBlockHead)
+	if err != nil {
+		return nil, err
+	}
+	header := chain.GetHeaderByHash(common.BytesToHash(key))
+	if header == nil {
+		return nil, ErrNilBlockHeader
+	}
+	return header, nil
+}
+
+// storeConfirmedBlockHeader saves the hash of the latest confirmed block into
+// the database.
+func (d *Dpos) storeConfirmedBlockHeader(db ethdb.Database) error {
+	return db.Put(confirmedBlockHead, d.confirmedBlockHeader.Hash().Bytes())
+}
+
+// Prepare implements consensus.Engine, initialising the consensus fields of a
+// block header for the local validator to seal on top.
+func (d *Dpos) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	if d.isLegacy(header.Number.Uint64()) {
+		return d.legacy.Prepare(chain, header)
+	}
+	header.Nonce = types.BlockNonce{}
+	number := header.Number.Uint64()
+	// The configured extra-data becomes the operator note at the head of the
+	// vanity; clip it so it cannot collide with the gas votes published in
+	// the vanity tail
+	if len(header.Extra) > extraNote {
+		header.Extra = header.Extra[:extraNote]
+	}
+	if len(header.Extra) < extraVanity {
+		header.Extra = append(header.Extra, bytes.Repeat([]byte{0x00}, extraVanity-len(header.Extra))...)
+	}
+	header.Extra = header.Extra[:extraVanity]
+	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	header.Difficulty = d.CalcDifficulty(chain, header.Time.Uint64(), parent)
+	header.Validator = d.signer
+	// After a key rotation the local signer seals on behalf of a candidate
+	// with a different address; the header must carry the candidate identity,
+	// so map the sealing key back through the active rotation records
+	if dposContext, err := types.NewDposContextFromProto(d.triedb, parent.DposContext); err == nil {
+		if validators, err := dposContext.GetValidators(); err == nil {
+			epoch := header.Time.Int64() / d.epochInterval()
+			for _, validator := range validators {
+				if validator != d.signer && dposContext.ActiveSigner(validator, epoch) == d.signer {
+					header.Validator = validator
+					break
+				}
+			}
+		}
+	}
+	// Publish the local gas limit preference in the tail of the vanity
+	// section; the chain moves its gas limit toward the median of the
+	// preferences the active validators published
+	if target := params.TargetGasLimit; target > 0 {
+		binary.BigEndian.PutUint64(header.Extra[extraVanity-8:extraVanity], target)
+	}
+	// Publish the local minimum gas price preference just ahead of it; the
+	// epoch transition aggregates the votes into the network fee floor
+	d.mu.RLock()
+	gasPriceVote := d.gasPriceVote
+	d.mu.RUnlock()
+	if gasPriceVote > 0 {
+		binary.BigEndian.PutUint64(header.Extra[extraVanity-16:extraVanity-8], gasPriceVote)
+	}
+	return nil
+}
+
+// SealDeadline returns the wall clock instant by which block building on top
+// of the given header must stop, so the block can still be finalized, signed
+// and propagated within its slot instead of overrunning it and being rejected
+// by the validator schedule.
+func (d *Dpos) SealDeadline(header *types.Header) time.Time {
+	interval := d.slotInterval()
+	slotEnd := header.Time.Int64() + interval
+	return time.Unix(slotEnd-interval/sealBudgetDivisor, 0)
+}
+
+// SetGasPriceVote sets the minimum gas price the local validator publishes in
+// the headers it seals, normally the miner's own gas price setting.
+func (d *Dpos) SetGasPriceVote(price *big.Int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if price != nil && price.IsUint64() {
+		d.gasPriceVote = price.Uint64()
+	}
+}
+
+// gasPriceVoteFromExtra extracts the minimum gas price vote a validator
+// published in the header's vanity section, zero if none.
+func gasPriceVoteFromExtra(extra []byte) uint64 {
+	if len(extra) < extraVanity {
+		return 0
+	}
+	return binary.BigEndian.Uint64(extra[extraVanity-16 : extraVanity-8])
+}
+
+// gasVoteFromExtra extracts the gas limit vote a validator published in the
+// tail of the header's vanity section, zero if none.
+func gasVoteFromExtra(extra []byte) uint64 {
+	if len(extra) < extraVanity {
+		return 0
+	}
+	return binary.BigEndian.Uint64(extra[extraVanity-8 : extraVanity])
+}
+
+// NoteFromExtra extracts the operator note a validator embedded at the head
+// of its sealed header's vanity section, with the zero padding stripped. The
+// note is free-form tagging for monitoring and attribution; consensus only
+// bounds its size.
+func NoteFromExtra(extra []byte) []byte {
+	if len(extra) > extraNote {
+		extra = extra[:extraNote]
+	}
+	return bytes.TrimRight(extra, "\x00")
+}
+
+// CalcGasLimit computes the gas limit of a block sealed on top of the given
+// parent, moving the parent's limit toward the median gas limit vote of the
+// active validator set by at most one bounded step. Targeting the on-chain
+// median replaces the miner-local targeting of the ethash chains: raising
+// throughput takes a coordinated majority, not a lone miner flag.
+func (d *Dpos) CalcGasLimit(parent *types.Header, dposContext *types.DposContext) uint64 {
+	limit := parent.GasLimit
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		return limit
+	}
+	votes := make([]uint64, 0, len(validators))
+	for _, validator := range validators {
+		if vote := dposContext.GasTarget(validator); vote > 0 {
+			votes = append(votes, vote)
+		}
+	}
+	if len(votes) == 0 {
+		return limit
+	}
+	sort.Slice(votes, func(i, j int) bool { return votes[i] < votes[j] })
+	target := votes[len(votes)/2]
+
+	step := parent.GasLimit/params.GasLimitBoundDivisor - 1
+	switch {
+	case target > limit && target-limit > step:
+		limit += step
+	case limit > target && limit-target > step:
+		limit -= step
+	default:
+		limit = target
+	}
+	if limit < params.MinGasLimit {
+		limit = params.MinGasLimit
+	}
+	return limit
+}
+
+// accumulateRewards splits the scheduled block reward between the sealing
+// validator and its delegators: the validator keeps its declared commission,
+// the remainder is shared among the delegators proportionally to their stake.
+// Rounding dust goes to the validator.
+func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header, dposContext *types.DposContext, db ethdb.Database) {
+	reward := calcBlockReward(config.Dpos, header.Number.Uint64())
+	if reward.Sign() == 0 {
+		return
+	}
+	// Fold the minted reward into the chain's issuance counter
+	if err := dposContext.AddIssuance(reward); err != nil {
+		log.Error("Failed to update issuance counter", "err", err)
+	}
+	validator := header.Coinbase
+	epoch := uint64(header.Time.Int64() / epochIntervalOf(config.Dpos))
+
+	// The validator's cut goes to its registered reward recipient, if any;
+	// the reward records stay attributed to the validator either way
+	payout := dposContext.RewardRecipient(validator)
+	if payout == (common.Address{}) {
+		payout = validator
+	}
+	delegators, stakes, totalStake := delegatorStakes(dposContext, state, validator)
+	if totalStake.Sign() == 0 {
+		// No delegators with stake, the validator keeps the whole reward
+		state.AddBalance(payout, reward)
+		recordValidatorReward(db, epoch, validator, reward, reward)
+		return
+	}
+	rate := dposContext.Commission(validator)
+	validatorCut := new(big.Int).Div(new(big.Int).Mul(reward, new(big.Int).SetUint64(rate)), big.NewInt(100))
+	pool := new(big.Int).Sub(reward, validatorCut)
+
+	distributed := new(big.Int)
+	for i, delegator := range delegators {
+		share := new(big.Int).Div(new(big.Int).Mul(pool, stakes[i]), totalStake)
+		state.AddBalance(delegator, share)
+		distributed.Add(distributed, share)
+		recordDelegatorReward(db, epoch, delegator, share)
+	}
+	validatorCut.Add(validatorCut, new(big.Int).Sub(pool, distributed))
+	state.AddBalance(payout, validatorCut)
+	recordValidatorReward(db, epoch, validator, reward, validatorCut)
+}
+
+// recordValidatorReward folds one sealed block into the validator's persisted
+// reward record of the epoch: the reward its block minted and the cut the
+// validator kept after its delegators were paid.
+func recordValidatorReward(db ethdb.Database, epoch uint64, validator common.Address, minted, cut *big.Int) {
+	if db == nil {
+		return
+	}
+	record := rawdb.ReadRewardRecord(db, epoch, validator)
+	if record == nil {
+		record = rawdb.NewRewardRecord(epoch)
+	}
+	record.Blocks++
+	record.BlockReward.Add(record.BlockReward, minted)
+	record.Commission.Add(record.Commission, cut)
+	rawdb.WriteRewardRecord(db, validator, record)
+}
+
+// recordDelegatorReward folds one block reward payout into the delegator's
+// persisted reward record of the epoch.
+func recordDelegatorReward(db ethdb.Database, epoch uint64, delegator common.Address, share *big.Int) {
+	if db == nil || share.Sign() == 0 {
+		return
+	}
+	record := rawdb.ReadRewardRecord(db, epoch, delegator)
+	if record == nil {
+		record = rawdb.NewRewardRecord(epoch)
+	}
+	record.DelegatorReward.Add(record.DelegatorReward, share)
+	rawdb.WriteRewardRecord(db, delegator, record)
+}
+
+// recordFeeIncome folds a validator's cut of the epoch fee pool into its
+// persisted reward record.
+func recordFeeIncome(db ethdb.Database, epoch uint64, validator common.Address, share *big.Int) {
+	if db == nil || share.Sign() == 0 {
+		return
+	}
+	record := rawdb.ReadRewardRecord(db, epoch, validator)
+	if record == nil {
+		record = rawdb.NewRewardRecord(epoch)
+	}
+	record.FeeIncome.Add(record.FeeIncome, share)
+	rawdb.WriteRewardRecord(db, validator, record)
+}
+
+// recordPerformance folds one finalized block into the sealing validator's
+// persisted performance record of the block's epoch: blocks sealed, the gas
+// they consumed against the gas they could have, and the propagation delay
+// measured against the local clock. Only blocks finalized within a slot of
+// their timestamp sample the delay — the node's own blocks finalize ahead of
+// their slot and catch-up imports arrive arbitrarily late, and neither says
+// anything about propagation.
+func recordPerformance(db ethdb.Database, header *types.Header, interval, epochLen int64) {
+	if db == nil {
+		return
+	}
+	epoch := uint64(header.Time.Int64() / epochLen)
+	record := rawdb.ReadPerformanceRecord(db, epoch, header.Validator)
+	if record == nil {
+		record = &rawdb.PerformanceRecord{Epoch: epoch}
+	}
+	record.Blocks++
+	record.GasUsed += header.GasUsed
+	record.GasLimit += header.GasLimit
+	delay := time.Now().UnixNano()/int64(time.Millisecond) - header.Time.Int64()*1000
+	if delay > 0 && delay <= interval*1000 {
+		record.Delay += uint64(delay)
+		record.DelaySamples++
+	}
+	rawdb.WritePerformanceRecord(db, header.Validator, record)
+}
+
+// calcBlockReward computes the reward minted by the block at the given number
+// according to the emission schedule of the chain config: the initial reward
+// halves every HalvingInterval blocks and the final block before the hard cap
+// is clamped so the schedule never mints past it. Networks without a schedule
+// fall back to the engine's constant default.
+func calcBlockReward(config *params.DposConfig, number uint64) *big.Int {
+	initial := blockReward
+	if config.BlockReward != nil {
+		initial = config.BlockReward
+	}
+	reward := new(big.Int).Set(initial)
+	if config.HalvingInterval > 0 {
+		reward.Rsh(reward, uint(number/config.HalvingInterval))
+	}
+	if config.HardCap != nil {
+		minted := mintedBefore(initial, config.HalvingInterval, number)
+		if left := new(big.Int).Sub(config.HardCap, minted); left.Cmp(reward) < 0 {
+			if left.Sign() < 0 {
+				return new(big.Int)
+			}
+			reward = left
+		}
+	}
+	return reward
+}
+
+// mintedBefore returns the total reward the emission schedule minted for all
+// blocks preceding the given number. Block zero is the genesis and mints
+// nothing.
+func mintedBefore(initial *big.Int, halvingInterval, number uint64) *big.Int {
+	minted := new(big.Int)
+	if number <= 1 {
+		return minted
+	}
+	blocks := number - 1 // rewarded blocks are 1 .. number-1
+	if halvingInterval == 0 {
+		return minted.Mul(initial, new(big.Int).SetUint64(blocks))
+	}
+	reward := new(big.Int).Set(initial)
+	for era := uint64(0); reward.Sign() > 0; era++ {
+		// Block 1 is halvingInterval-1 blocks into era zero
+		eraBlocks := halvingInterval
+		if era == 0 {
+			eraBlocks = halvingInterval - 1
+		}
+		if remaining := blocks; remaining <= eraBlocks {
+			eraBlocks = remaining
+		}
+		minted.Add(minted, new(big.Int).Mul(reward, new(big.Int).SetUint64(eraBlocks)))
+		if blocks -= eraBlocks; blocks == 0 {
+			break
+		}
+		reward.Rsh(reward, 1)
+	}
+	return minted
+}
+
+// delegatorStakes collects the delegators of the given candidate together
+// with their stake, snapshotted before any reward is paid out.
+func delegatorStakes(dposContext *types.DposContext, state *state.StateDB, candidateAddr common.Address) (delegators []common.Address, stakes []*big.Int, totalStake *big.Int) {
+	totalStake = new(big.Int)
+	candidate := candidateAddr.Bytes()
+	iter := trie.NewIterator(dposContext.DelegateTrie().NodeIterator(candidate))
+	for iter.Next() {
+		if !bytes.HasPrefix(iter.Key, candidate) {
+			break
+		}
+		delegator := common.BytesToAddress(iter.Value)
+		stake := state.GetBalance(delegator)
+		delegators = append(delegators, delegator)
+		stakes = append(stakes, stake)
+		totalStake.Add(totalStake, stake)
+	}
+	return delegators, stakes, totalStake
+}
+
+// SystemTransactions returns the maintenance transactions the block at the
+// given header must carry: a reward distribution for every block that mints a
+// reward and an epoch maintenance transaction whenever the block crosses an
+// epoch boundary. Both are derived deterministically, so the producer and
+// every verifier synthesize the same set.
+func (d *Dpos) SystemTransactions(chain consensus.ChainReader, header *types.Header) ([]*types.Transaction, error) {
+	// Pre-fork blocks of a migrated chain carry no dpos maintenance
+	if d.isLegacy(header.Number.Uint64()) {
+		return nil, nil
+	}
+	parent := chain.GetHeaderByHash(header.ParentHash)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	var txs []*types.Transaction
+	if calcBlockReward(chain.Config().Dpos, header.Number.Uint64()).Sign() > 0 {
+		txs = append(txs, types.NewSystemTransaction(types.RewardDistributionAddr, header.Number.Uint64()))
+	}
+	if parent.Time.Int64()/d.epochInterval() < header.Time.Int64()/d.epochInterval() {
+		txs = append(txs, types.NewSystemTransaction(types.EpochMaintenanceAddr, header.Number.Uint64()))
+	}
+	return txs, nil
+}
+
+// ApplySystemTransaction executes a single engine-synthesized maintenance
+// transaction: reward distribution pays out the block reward, epoch
+// maintenance runs the fee pool payout, kickouts, downtime slashing and the
+// election of the epoch transition.
+func (d *Dpos) ApplySystemTransaction(chain consensus.ChainReader, header *types.Header, state *state.StateDB, dposContext *types.DposContext, tx *types.Transaction) error {
+	switch tx.Type() {
+	case types.RewardDistribution:
+		accumulateRewards(chain.Config(), state, header, dposContext, d.db)
+		return nil
+	case types.EpochMaintenance:
+		parent := chain.GetHeaderByHash(header.ParentHash)
+		if parent == nil {
+			return consensus.ErrUnknownAncestor
+		}
+		genesis := chain.GetHeaderByNumber(0)
+		epochContext := &EpochContext{
+			statedb:     state,
+			DposContext: dposContext,
+			TimeStamp:   header.Time.Int64(),
+			config:      d.config,
+			record:      new(ElectionRecord),
+			db:          d.db,
+		}
+		if err := epochContext.tryElect(genesis, parent); err != nil {
+			return fmt.Errorf("got error when elect next epoch, err: %s", err)
+		}
+		// The election may have adopted a new block interval; realign the
+		// engine's slot timing with it
+		d.syncBlockInterval(dposContext)
+		if len(epochContext.record.Validators) > 0 {
+			d.writeEpochSummary(epochContext.record)
+		}
+		d.reportEpochTransition(epochContext.record)
+		// Snapshot the cumulative issuance at the epoch boundary, keyed by
+		// the epoch being entered, so economic monitoring can read the
+		// supply per epoch without summing rewards across blocks
+		rawdb.WriteIssuanceSnapshot(d.db, uint64(header.Time.Int64()/d.epochInterval()), dposContext.Issuance())
+		return nil
+	default:
+		return fmt.Errorf("unknown system transaction type %v", tx.Type())
+	}
+}
+
+// Finalize implements consensus.Engine, computing the final state root and
+// updating the mint counter of the sealing validator. Block rewards and the
+// epoch election run as explicit system transactions before Finalize, so the
+// maintenance operations show up in the block's receipts.
+func (d *Dpos) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction,
+	uncles []*types.Header, receipts []*types.Receipt, dposContext *types.DposContext) (*types.Block, error) {
+	if d.isLegacy(header.Number.Uint64()) {
+		return d.legacy.Finalize(chain, header, state, txs, uncles, receipts, dposContext)
+	}
+	parent := chain.GetHeaderByHash(header.ParentHash)
+	if timeOfFirstBlock == 0 {
+		if firstBlockHeader := chain.GetHeaderByNumber(1); firstBlockHeader != nil {
+			timeOfFirstBlock = firstBlockHeader.Time.Int64()
+		}
+	}
+	// Sample delegator balances on a fixed slot cadence for the
+	// average-balance vote weighting. The cadence follows the block interval
+	// the context itself adopted, so every node grids the samples identically
+	// whatever interval its engine currently runs at.
+	if d.config != nil && d.config.VoteWeightMode == VoteWeightAverage {
+		epochContext := &EpochContext{
+			statedb:     state,
+			DposContext: dposContext,
+			TimeStamp:   header.Time.Int64(),
+			config:      d.config,
+		}
+		if header.Time.Int64()/epochContext.blockInterval()%balanceSampleSlots == 0 {
+			if err := epochContext.sampleDelegatorBalances(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+
+	// Record the gas limit and gas price votes the sealer published in the
+	// header, feeding the aggregates the chain targets
+	if vote := gasVoteFromExtra(header.Extra); vote > 0 {
+		if err := dposContext.SetGasTarget(header.Validator, vote); err != nil {
+			return nil, err
+		}
+	}
+	if vote := gasPriceVoteFromExtra(header.Extra); vote > 0 {
+		if err := dposContext.SetGasPriceVote(header.Validator, vote); err != nil {
+			return nil, err
+		}
+	}
+	// Update the mint count of the sealing validator
+	fixed := false
+	if cfg := chain.Config().Dpos; cfg != nil && cfg.MintCntFixBlock > 0 && header.Number.Uint64() >= cfg.MintCntFixBlock {
+		// The first block under the fork cleans up the boundary blocks the
+		// legacy accounting credited to the wrong epoch
+		if header.Number.Uint64() == cfg.MintCntFixBlock {
+			migrateMintCnt(chain, parent, dposContext, d.epochInterval())
+		}
+		fixed = true
+	}
+	updateMintCnt(parent.Time.Int64(), header.Time.Int64(), header.Validator, dposContext, fixed, d.epochInterval())
+	// Fold the block into the validator's local performance record
+	recordPerformance(d.db, header, d.slotInterval(), d.epochInterval())
+	commitStart := time.Now()
+	var err error
+	header.DposContext, err = dposContext.Commit()
+	if err != nil {
+		return nil, err
+	}
+	// Attribute the trie commit to the election when one ran, completing the
+	// latency breakdown of epoch-boundary imports
+	if parent.Time.Int64()/d.epochInterval() < header.Time.Int64()/d.epochInterval() {
+		electionCommitTimer.UpdateSince(commitStart)
+	}
+	block := types.NewBlock(header, txs, uncles, receipts)
+	block.DposContext = dposContext
+	return block, nil
+}
+
+// writeEpochSummary persists a compact account of the epoch transition the
+// record describes, so historical queries don't have to replay the election.
+func (d *Dpos) writeEpochSummary(record *ElectionRecord) {
+	tallies, err := rlp.EncodeToBytes(record.Tallies)
+	if err != nil {
+		log.Error("Failed to encode election tallies", "epoch", record.Epoch, "err", err)
+		return
+	}
+	kicked := make([]common.Address, 0, len(record.Kicked))
+	for _, validator := range record.Kicked {
+		kicked = append(kicked, validator.Address)
+		// Append the removal to the candidate's kickout log, so delegators can
+		// audit a candidate's record without replaying past elections. Imports
+		// may revisit an epoch boundary; only record the epoch once.
+		history := rawdb.ReadKickoutHistory(d.db, validator.Address)
+		if len(history) > 0 && history[len(history)-1].Epoch >= uint64(record.Epoch) {
+			continue
+		}
+		history = append(history, rawdb.KickoutEntry{
+			Epoch:     uint64(record.Epoch),
+			MintCnt:   uint64(validator.MintCnt),
+			Threshold: uint64(validator.Threshold),
+		})
+		rawdb.WriteKickoutHistory(d.db, validator.Address, history)
+	}
+	rawdb.WriteEpochSummary(d.db, &rawdb.EpochSummary{
+		Epoch:       uint64(record.Epoch),
+		Validators:  record.Validators,
+		TalliesHash: common.BytesToHash(crypto.Keccak256(tallies)),
+		Kicked:      kicked,
+		Seed:        uint64(record.Seed),
+		Shuffle:     record.Shuffle,
+	})
+}
+
+// emptyValidatorsMeter counts the slots hitting an empty or corrupt validator
+// set, a condition that should never occur on a healthy node.
+var emptyValidatorsMeter = metrics.NewRegisteredMeter("dpos/validators/empty", nil)
+
+// epochSummaryRecoveryDepth is the number of past epochs searched for a
+// persisted summary when rebuilding a lost validator set.
+const epochSummaryRecoveryDepth = 8
+
+// lookupValidatorSafe maps a point in time onto the validator in charge of
+// its slot, adding defenses against an empty or corrupt validator set: the
+// condition is metered, reported once as a halt state instead of flooding the
+// log every slot, and the epoch trie of the given context is rebuilt from the
+// last persisted epoch summary when one is available.
+func (d *Dpos) lookupValidatorSafe(epochContext *EpochContext, now int64) (common.Address, error) {
+	validators, err := epochContext.DposContext.GetValidators()
+	if err != nil || len(validators) == 0 {
+		emptyValidatorsMeter.Mark(1)
+		if rerr := d.recoverValidators(epochContext.DposContext, now); rerr != nil {
+			d.mu.Lock()
+			reported := d.haltReported
+			d.haltReported = true
+			d.mu.Unlock()
+			if !reported {
+				log.Error("Validator set unusable and no epoch summary to rebuild from, consensus halted until resync", "err", err)
+			}
+			if err == nil {
+				err = ErrEmptyValidatorSet
+			}
+			return common.Address{}, err
+		}
+		d.mu.Lock()
+		d.haltReported = false
+		d.mu.Unlock()
+	}
+	return epochContext.lookupValidator(now)
+}
+
+// recoverValidators rebuilds the validator set of the given context from the
+// most recent persisted epoch summary, searching backwards from the epoch the
+// given time falls into. The repair only touches the in-memory context: the
+// repaired trie is used for slot lookups and never committed.
+func (d *Dpos) recoverValidators(dposContext *types.DposContext, now int64) error {
+	if d.db == nil {
+		return ErrEmptyValidatorSet
+	}
+	epoch := now / d.epochInterval()
+	for back := int64(0); back <= epochSummaryRecoveryDepth && epoch-back >= 0; back++ {
+		summary := rawdb.ReadEpochSummary(d.db, uint64(epoch-back))
+		if summary == nil || len(summary.Validators) == 0 {
+			continue
+		}
+		if err := dposContext.SetValidators(summary.Validators); err != nil {
+			return err
+		}
+		log.Warn("Rebuilt validator set from epoch summary", "epoch", epoch-back, "validators", len(summary.Validators))
+		return nil
+	}
+	return ErrEmptyValidatorSet
+}
+
+// ValidatorHealth summarizes the consensus duty status of the local signer
+// for monitoring dashboards.
+type ValidatorHealth struct {
+	Validator     bool  // Whether the signer is part of the active validator set
+	NextSlot      int64 // Unix time of the next slot assigned to the signer, zero if none
+	MintedInEpoch int64 // Blocks the signer produced in the current epoch
+	KickoutRisk   bool  // Whether the production trails the pro-rated kickout threshold
+}
+
+// Health reports the duty status of the authorized signer against the given
+// dpos context, normally the one of the chain head.
+func (d *Dpos) Health(dposContext *types.DposContext, now int64) (*ValidatorHealth, error) {
+	d.mu.RLock()
+	signer := d.signer
+	d.mu.RUnlock()
+
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		return nil, err
+	}
+	health := new(ValidatorHealth)
+	for _, validator := range validators {
+		if validator == signer {
+			health.Validator = true
+			break
+		}
+	}
+	if !health.Validator {
+		return health, nil
+	}
+	// Locate the next slot assigned to the signer. Slots close to the epoch
+	// boundary may be re-assigned by the election, which is fine for a
+	// monitoring estimate.
+	interval := d.slotInterval()
+	start := d.nextSlot(now)
+	for i := 0; i < len(validators); i++ {
+		t := start + int64(i)*interval
+		offset := t % d.epochInterval() / interval % int64(len(validators))
+		if validators[offset] == signer {
+			health.NextSlot = t
+			break
+		}
+	}
+	epoch := now / d.epochInterval()
+	health.MintedInEpoch = dposContext.GetMintCnt(epoch, signer)
+
+	// Compare the production against the kickout threshold pro-rated to the
+	// elapsed part of the epoch
+	threshold := now % d.epochInterval() / interval / int64(maxValidatorSizeOf(d.config)) / 2
+	health.KickoutRisk = health.MintedInEpoch < threshold
+	return health, nil
+}
+
+// syncBlockInterval realigns the engine's slot timing with the block interval
+// the current epoch adopted through validator voting. The adopted interval is
+// kept on the engine rather than in the package level defaults, so concurrent
+// readers never race a verification in progress and a side chain cannot flip
+// the timing of the canonical one. Networks without adaptive interval voting
+// never store one and stay on the configured default.
+func (d *Dpos) syncBlockInterval(dposContext *types.DposContext) {
+	adopted := dposContext.BlockInterval()
+	if adopted <= 0 {
+		return
+	}
+	d.mu.Lock()
+	previous := d.adoptedInterval
+	if adopted != previous {
+		d.adoptedInterval = adopted
+	}
+	d.mu.Unlock()
+	if adopted != previous {
+		log.Info("Block interval changed by validator vote", "previous", d.slotIntervalFrom(previous), "interval", adopted)
+	}
+}
+
+// slotInterval returns the block interval the engine currently seals and
+// verifies at: the one the current epoch adopted by vote if any, otherwise
+// the configured default.
+func (d *Dpos) slotInterval() int64 {
+	d.mu.RLock()
+	adopted := d.adoptedInterval
+	d.mu.RUnlock()
+	return d.slotIntervalFrom(adopted)
+}
+
+// slotIntervalFrom maps a recorded adopted interval to the effective one,
+// falling back to the configured default when none was adopted.
+func (d *Dpos) slotIntervalFrom(adopted int64) int64 {
+	if adopted > 0 {
+		return adopted
+	}
+	return blockIntervalOf(d.config)
+}
+
+// epochInterval returns the epoch length of the engine's network in seconds.
+func (d *Dpos) epochInterval() int64 {
+	return epochIntervalOf(d.config)
+}
+
+// prevSlot and nextSlot are the engine aware counterparts of PrevSlot and
+// NextSlot, aligned to the slot grid of the adopted block interval.
+func (d *Dpos) prevSlot(now int64) int64 {
+	interval := d.slotInterval()
+	return (now - 1) / interval * interval
+}
+
+func (d *Dpos) nextSlot(now int64) int64 {
+	interval := d.slotInterval()
+	return (now + interval - 1) / interval * interval
+}
+
+// HeartbeatPeriod returns the cadence at which the local validator broadcasts
+// liveness heartbeats, following the adopted block interval.
+func (d *Dpos) HeartbeatPeriod() time.Duration {
+	return time.Duration(d.slotInterval()) * time.Second
+}
+
+// heartbeatTimeout returns the number of seconds of heartbeat silence after
+// which a validator is considered offline.
+func (d *Dpos) heartbeatTimeout() int64 {
+	return 3 * d.slotInterval()
+}
+
+func (d *Dpos) checkDeadline(lastBlock *types.Block, now int64) error {
+	prevSlot := d.prevSlot(now)
+	nextSlot := d.nextSlot(now)
+	if lastBlock.Time().Int64() >= nextSlot {
+		return ErrMintFutureBlock
+	}
+	// The last block arrived, and this is the moment to mint
+	if lastBlock.Time().Int64() == prevSlot || nextSlot-now <= 1 {
+		return nil
+	}
+	return ErrWaitForPrevBlock
+}
+
+// CheckValidator reports whether the local signer is the validator in charge
+// of the slot at the given time on top of lastBlock.
+func (d *Dpos) CheckValidator(lastBlock *types.Block, now int64) error {
+	if err := d.checkDeadline(lastBlock, now); err != nil {
+		return err
+	}
+	dposContext, err := types.NewDposContextFromProto(d.triedb, lastBlock.Header().DposContext)
+	if err != nil {
+		return err
+	}
+	d.syncBlockInterval(dposContext)
+	epochContext := &EpochContext{DposContext: dposContext, config: d.config}
+	slotTime := d.prevSlot(now) + d.slotInterval()
+	validator, err := d.lookupValidatorSafe(epochContext, slotTime)
+	if err != nil {
+		return err
+	}
+	// The slot belongs to the candidate, but the key entitled to seal it is
+	// the candidate's active signer, which may differ after a rotation
+	if (validator == common.Address{}) || dposContext.ActiveSigner(validator, slotTime/d.epochInterval()) != d.signer {
+		return ErrInvalidBlockValidator
+	}
+	return nil
+}
+
+// ownsImminentSlot reports whether the slot following now belongs to the
+// local signer and has not been sealed on top of head yet.
+func (d *Dpos) ownsImminentSlot(head *types.Header, now int64) bool {
+	next := d.nextSlot(now)
+	if head.Time.Int64() >= next {
+		return false
+	}
+	dposContext, err := types.NewDposContextFromProto(d.triedb, head.DposContext)
+	if err != nil {
+		return false
+	}
+	epochContext := &EpochContext{DposContext: dposContext, config: d.config}
+	validator, err := d.lookupValidatorSafe(epochContext, next)
+	if err != nil {
+		return false
+	}
+	return validator != (common.Address{}) && dposContext.ActiveSigner(validator, next/d.epochInterval()) == d.signer
+}
+
+// AwaitSlotIdle holds the caller back until the local validator has nothing
+// at stake in the imminent slot anymore: the slot belongs to another
+// validator, the local block for it was sealed, or the slot boundary passed
+// unsealed. It exists so a shutdown ordered right before the local slot does
+// not turn a routine restart into a missed block. The wait is bounded by
+// timeout, so a stuck chain can never hang the teardown.
+func (d *Dpos) AwaitSlotIdle(chain consensus.ChainReader, timeout time.Duration) {
+	d.mu.RLock()
+	signer := d.signer
+	d.mu.RUnlock()
+	if signer == (common.Address{}) || d.IsReplica() {
+		return
+	}
+	deadline := time.Now().Add(timeout)
+	logged := false
+	for time.Now().Before(deadline) {
+		head := chain.CurrentHeader()
+		now := time.Now().Unix()
+		if head == nil || head.DposContext == nil || !d.ownsImminentSlot(head, now) {
+			return
+		}
+		if !logged {
+			log.Info("Holding shutdown for the local slot", "slot", d.nextSlot(now), "timeout", common.PrettyDuration(timeout))
+			logged = true
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	log.Warn("Shutdown grace elapsed with the local slot still pending", "timeout", common.PrettyDuration(timeout))
+}
+
+// sealCriticalWindow is the tail of a slot, in seconds, during which the
+// local validator is about to seal and non-urgent background work should
+// step aside.
+const sealCriticalWindow = 1
+
+// SealCriticalWait returns how long the caller should defer non-urgent work
+// because the local validator is inside the final second of its own slot on
+// top of lastBlock, zero when sealing is not imminent. The cheap clock check
+// runs first, so the slot ownership lookup only happens inside the window
+// itself.
+func (d *Dpos) SealCriticalWait(lastBlock *types.Block, now int64) time.Duration {
+	if d.signer == (common.Address{}) || d.IsReplica() {
+		return 0
+	}
+	next := d.nextSlot(now)
+	if remaining := next - now; remaining <= 0 || remaining > sealCriticalWindow {
+		return 0
+	}
+	if d.CheckValidator(lastBlock, now) != nil {
+		return 0
+	}
+	return time.Duration(next-now) * time.Second
+}
+
+// Seal implements consensus.Engine, waiting for the local validator's time
+// slot and attaching the validator's signature on top of the block.
+func (d *Dpos) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	if d.isLegacy(block.NumberU64()) {
+		return d.legacy.Seal(chain, block, stop)
+	}
+	header := block.Header()
+	number := header.Number.Uint64()
+	// Sealing the genesis block is not supported
+	if number == 0 {
+		return nil, errUnknownBlock
+	}
+	// A read replica serves queries only; it never takes a slot, whatever
+	// validator keys the node happens to hold
+	if d.IsReplica() {
+		return nil, ErrReplicaMode
+	}
+	// Sit out slots while sealing is auto-paused after repeated failures;
+	// the operator resumes once the signer problem is fixed
+	if d.SealingPaused() {
+		return nil, ErrSealingPaused
+	}
+	// The testing-only fault injector may want the slot sat out entirely
+	if d.injectSkip() {
+		log.Warn("Fault injection sitting out owned slot", "number", number)
+		return nil, nil
+	}
+	// A skewed clock would seal into the wrong slot and the block would be
+	// discarded by everyone else; better to sit the slot out when asked to
+	if d.config != nil && d.config.RefuseSealOnSkew && d.clock.dangerous(d.slotInterval()) {
+		log.Warn("Refusing to seal on dangerous clock skew", "skew", time.Duration(d.ClockSkew())*time.Second)
+		return nil, ErrDangerousClockSkew
+	}
+	now := time.Now().Unix()
+	// In hot-standby mode, yield the slot while the primary holder of the
+	// validator key is alive on the network
+	if d.shouldStandAside(now) {
+		return nil, nil
+	}
+	// With empty-block suppression on, sit out the slot when the block
+	// carries no user transactions, unless a heartbeat block is due to keep
+	// the mint count clear of the kickout threshold
+	if d.suppressEmptyBlock(block, now) {
+		log.Debug("Suppressing empty block", "number", number)
+		return nil, nil
+	}
+	delay := d.nextSlot(now) - now
+	if delay > 0 {
+		select {
+		case <-stop:
+			return nil, nil
+		case <-time.After(time.Duration(delay) * time.Second):
+		}
+	}
+	// A reorg may have replaced the chain head while we were waiting for the
+	// slot. If it crossed an epoch boundary the validator set the block was
+	// built against is stale, so abandon it; the miner picks up the new head
+	// through its chain head subscription and rebuilds.
+	if chain.CurrentHeader().Hash() != header.ParentHash {
+		log.Warn("Abandoning stale sealing work", "number", number, "parent", header.ParentHash)
+		return nil, ErrStaleParentBlock
+	}
+	// Align the sealed timestamp onto its slot boundary: verifiers key the
+	// slot lookup on the header time and the wall clock may be slightly off
+	// after the wait
+	header.Time.SetInt64(d.nextSlot(now))
+	d.slotStartedFeed.Send(SlotStartedEvent{Slot: header.Time.Int64(), Validator: header.Validator})
+
+	// Time's up, sign the block with the authorized key
+	d.mu.RLock()
+	signer, signFn := d.signer, d.signFn
+	d.mu.RUnlock()
+	if signer == (common.Address{}) || signFn == nil {
+		return nil, ErrNoAvailableSigner
+	}
+	sighash, err := signFn(accounts.Account{Address: signer}, sigHash(header).Bytes())
+	if err != nil {
+		d.reportSealFailure(number, err)
+		return nil, err
+	}
+	d.reportSealSuccess()
+	d.mu.Lock()
+	d.lastSealed = header.Time.Int64()
+	d.mu.Unlock()
+	copy(header.Extra[len(header.Extra)-extraSeal:], sighash)
+	// The testing-only fault injector may double-sign the slot or hold the
+	// sealed block back past its deadline
+	d.injectDoubleSign(header, accounts.Account{Address: signer}, signFn)
+	if delay := d.injectDelay(); delay > 0 {
+		log.Warn("Fault injection delaying sealed block", "number", number, "delay", delay)
+		select {
+		case <-stop:
+			return nil, nil
+		case <-time.After(delay):
+		}
+	}
+	d.blockSealedFeed.Send(BlockSealedEvent{Number: number, Hash: header.Hash(), Slot: header.Time.Int64(), Validator: header.Validator})
+	// The local validator never verifies its own block, so kick off the
+	// speculative election replay for the next slot from here as well
+	d.maybePrecomputeElection(chain, header)
+	return block.WithSeal(header), nil
+}
+
+// SetEmptyBlockSuppression makes the local validator sit out its slot when
+// there are no user transactions to include, sealing a heartbeat block every
+// given number of slots instead so the mint count based kickout isn't
+// triggered. Zero slots disable suppression. Meant for low-traffic private
+// networks that don't want an ever-growing chain of empty blocks.
+func (d *Dpos) SetEmptyBlockSuppression(slots uint64) {
+	d.mu.Lock()
+	d.heartbeatSlots = slots
+	d.mu.Unlock()
+	if slots > 0 {
+		log.Info("Dpos empty-block suppression enabled", "heartbeatSlots", slots)
+	}
+}
+
+// SetNodeCacheSize installs a dedicated LRU of roughly the given number of
+// megabytes in front of the database the dpos context tries read from, so
+// elections and delegate lookups don't thrash the main state cache. A
+// non-positive size leaves the tries on the uncached database. Must be called
+// during setup, before the engine starts verifying blocks.
+func (d *Dpos) SetNodeCacheSize(sizeMB int) {
+	if sizeMB <= 0 {
+		return
+	}
+	d.mu.Lock()
+	d.triedb = trie.NewDatabase(newNodeCacheDB(d.db, sizeMB))
+	d.mu.Unlock()
+	log.Info("Dpos trie node cache enabled", "size", fmt.Sprintf("%dMB", sizeMB))
+}
+
+// SetReplica switches the engine in or out of read replica mode. A replica
+// serves dpos queries but never seals, so an RPC provider can hold validator
+// keys for convenience without risking double-signed slots.
+func (d *Dpos) SetReplica(replica bool) {
+	d.mu.Lock()
+	d.replica = replica
+	d.mu.Unlock()
+	if replica {
+		log.Info("Dpos engine in read replica mode, sealing disabled")
+	}
+}
+
+// IsReplica reports whether the engine runs as a read replica.
+func (d *Dpos) IsReplica() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.replica
+}
+
+// suppressEmptyBlock reports whether the local validator should sit out the
+// slot instead of sealing the given block: suppression is enabled, the block
+// carries no user transactions and a heartbeat block is not yet due.
+func (d *Dpos) suppressEmptyBlock(block *types.Block, now int64) bool {
+	d.mu.RLock()
+	slots := d.heartbeatSlots
+	lastSealed := d.lastSealed
+	d.mu.RUnlock()
+	if slots == 0 {
+		return false
+	}
+	for _, tx := range block.Transactions() {
+		if !tx.Type().IsSystem() {
+			return false
+		}
+	}
+	return now-lastSealed < int64(slots)*d.slotInterval()
+}
+
+// CalcDifficulty implements consensus.Engine. With DPOS the difficulty of all
+// blocks is one, chain weight equals chain length.
+func (d *Dpos) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	if d.isLegacy(parent.Number.Uint64() + 1) {
+		return d.legacy.CalcDifficulty(chain, time, parent)
+	}
+	return big.NewInt(1)
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC API to
+// query the delegated proof-of-stake state.
+func (d *Dpos) APIs(chain consensus.ChainReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "dpos",
+		Version:   "1.0",
+		Service:   &API{chain: chain, dpos: d},
+		Public:    true,
+	}}
+}
+
+// Authorize injects a private key into the consensus engine to mint new
+// blocks with.
+func (d *Dpos) Authorize(signer common.Address, signFn SignerFn) {
+	d.mu.Lock()
+	d.signer = signer
+	d.signFn = signFn
+	d.mu.Unlock()
+}
+
+// SetStateFn injects the account state resolver used by RPC queries that need
+// to weigh votes by delegator balances.
+func (d *Dpos) SetStateFn(stateFn StateFn) {
+	d.mu.Lock()
+	d.stateFn = stateFn
+	d.mu.Unlock()
+}
+
+// Sign authorizes an arbitrary hash with the engine's signer, e.g. to prove
+// validator identity on the network layer. It returns a zero address without
+// error when no signer is authorized.
+func (d *Dpos) Sign(hash common.Hash) (common.Address, []byte, error) {
+	d.mu.RLock()
+	signer, signFn := d.signer, d.signFn
+	d.mu.RUnlock()
+	if signFn == nil {
+		return common.Address{}, nil, nil
+	}
+	sig, err := signFn(accounts.Account{Address: signer}, hash.Bytes())
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return signer, sig, nil
+}
+
+// SigHash returns the hash a validator signs when sealing the given header.
+// It is exported for tools and tests that assemble blocks outside the mining
+// loop.
+func SigHash(header *types.Header) common.Hash {
+	return sigHash(header)
+}
+
+// LookupValidator returns the validator in charge of the slot containing the
+// given unix time, based on the supplied dpos context. It applies the strict
+// slot alignment; callers with a chain config should query the engine so the
+// configured drift tolerance is honoured.
+func LookupValidator(dposContext *types.DposContext, now int64) (common.Address, error) {
+	epochContext := &EpochContext{DposContext: dposContext}
+	return epochContext.lookupValidator(now)
+}
+
+// PrevSlot returns the last slot boundary at or before now, on the grid of
+// the default block interval. The engine's unexported counterparts follow the
+// interval the current epoch adopted by vote.
+func PrevSlot(now int64) int64 {
+	return (now - 1) / blockInterval * blockInterval
+}
+
+// NextSlot returns the first slot boundary after now, on the grid of the
+// default block interval.
+func NextSlot(now int64) int64 {
+	return (now + blockInterval - 1) / blockInterval * blockInterval
+}
+
+// updateMintCnt increments the number of blocks the validator minted in the
+// epoch the new block belongs to. The legacy accounting credited a block
+// sealed exactly on an epoch boundary to the epoch it opens, although the
+// closing epoch's validator set scheduled and produced it; from
+// MintCntFixBlock on such blocks are credited to the closing epoch and the
+// stored counter is bounds-checked, so a corrupted value restarts the count
+// instead of being carried forward.
+func updateMintCnt(parentBlockTime, currentBlockTime int64, validator common.Address, dposContext *types.DposContext, fixed bool, epochLen int64) {
+	currentMintCntTrie := dposContext.MintCntTrie()
+	currentEpoch := parentBlockTime / epochLen
+	newEpoch := currentBlockTime / epochLen
+	if fixed && currentBlockTime%epochLen == 0 {
+		newEpoch--
+	}
+	currentEpochBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(currentEpochBytes, uint64(currentEpoch))
+
+	cnt := int64(1)
+	// Still in the same epoch as the parent, continue its counter
+	if currentEpoch == newEpoch {
+		iter := trie.NewIterator(currentMintCntTrie.NodeIterator(currentEpochBytes))
+		// When the current epoch has counters already, read the previous value
+		if iter.Next() {
+			cntBytes := currentMintCntTrie.Get(append(currentEpochBytes, validator.Bytes()...))
+			if cntBytes != nil {
+				cnt = int64(binary.BigEndian.Uint64(cntBytes)) + 1
+			}
+		}
+		// An epoch holds at most one slot per second, so a larger counter can
+		// only come from corruption
+		if fixed && (cnt < 1 || cnt > epochLen) {
+			log.Warn("Mint counter out of bounds, restarting", "validator", validator, "epoch", newEpoch, "cnt", cnt)
+			cnt = 1
+		}
+	}
+	newCntBytes := make([]byte, 8)
+	newEpochBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(newEpochBytes, uint64(newEpoch))
+	binary.BigEndian.PutUint64(newCntBytes, uint64(cnt))
+	dposContext.MintCntTrie().TryUpdate(append(newEpochBytes, validator.Bytes()...), newCntBytes)
+}
+
+// migrateMintCnt re-derives the retained mint counters from the chain's
+// headers, run once on the first block the MintCntFixBlock fork covers. Only
+// boundary blocks were misattributed, so the walk moves the unit each of
+// them contributed from the epoch it opens to the epoch it closes; credits
+// towards epochs that already fell out of retention are dropped with the
+// decrement. The walk is deterministic over canonical ancestors, making the
+// migration part of the fork block's state transition.
+func migrateMintCnt(chain consensus.ChainReader, parent *types.Header, dposContext *types.DposContext, epochLen int64) {
+	start := time.Now()
+	currentEpoch := parent.Time.Int64() / epochLen
+	oldest := currentEpoch - mintCntRetention + 1
+	moved := 0
+	for header := parent; header != nil && header.Number.Uint64() > 0 && header.Time.Int64() >= oldest*epochLen; header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1) {
+		if header.Time.Int64()%epochLen != 0 {
+			continue
+		}
+		epoch := header.Time.Int64() / epochLen
+		shiftMintCnt(dposContext, epoch, epoch-1, header.Validator, oldest)
+		moved++
+	}
+	log.Info("Migrated mint counters to fixed attribution", "epoch", currentEpoch, "moved", moved, "elapsed", common.PrettyDuration(time.Since(start)))
+}
+
+// shiftMintCnt moves one minted block of the validator from the counter of
+// one epoch to another's, dropping the credit when the receiving epoch fell
+// below the retention floor.
+func shiftMintCnt(dposContext *types.DposContext, fromEpoch, toEpoch int64, validator common.Address, oldest int64) {
+	mintCntTrie := dposContext.MintCntTrie()
+	fromKey := make([]byte, 8)
+	binary.BigEndian.PutUint64(fromKey, uint64(fromEpoch))
+	fromKey = append(fromKey, validator.Bytes()...)
+	if cntBytes := mintCntTrie.Get(fromKey); cntBytes != nil {
+		if cnt := binary.BigEndian.Uint64(cntBytes); cnt > 1 {
+			newBytes := make([]byte, 8)
+			binary.BigEndian.PutUint64(newBytes, cnt-1)
+			mintCntTrie.TryUpdate(fromKey, newBytes)
+		} else {
+			mintCntTrie.Delete(fromKey)
+		}
+	}
+	if toEpoch < oldest {
+		return
+	}
+	toKey := make([]byte, 8)
+	binary.BigEndian.PutUint64(toKey, uint64(toEpoch))
+	toKey = append(toKey, validator.Bytes()...)
+	cnt := uint64(1)
+	if cntBytes := mintCntTrie.Get(toKey); cntBytes != nil {
+		cnt = binary.BigEndian.Uint64(cntBytes) + 1
+	}
+	cntBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(cntBytes, cnt)
+	mintCntTrie.TryUpdate(toKey, cntBytes)
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Timing and sizing overrides of a private network must stay scoped to the
+// engine configured with them instead of leaking into the package defaults
+// or other engine instances.
+func TestConfigScopedParameters(t *testing.T) {
+	custom := &params.DposConfig{BlockInterval: 3, EpochInterval: 600, MaxValidatorSize: 5}
+	db := ethdb.NewMemDatabase()
+	engine := New(custom, db)
+
+	if got := engine.slotInterval(); got != 3 {
+		t.Errorf("configured slot interval: got %d, want 3", got)
+	}
+	if got := engine.epochInterval(); got != 600 {
+		t.Errorf("configured epoch interval: got %d, want 600", got)
+	}
+	if got := maxValidatorSizeOf(custom); got != 5 {
+		t.Errorf("configured validator size: got %d, want 5", got)
+	}
+	if got := safeSizeOf(custom); got != 5*2/3+1 {
+		t.Errorf("configured safe size: got %d, want %d", got, 5*2/3+1)
+	}
+	if got := kickoutCooldownOf(custom); got != 1200 {
+		t.Errorf("configured kickout cooldown: got %d, want 1200", got)
+	}
+
+	// A default engine constructed afterwards keeps the defaults
+	def := New(nil, db)
+	if got := def.slotInterval(); got != blockInterval {
+		t.Errorf("default slot interval: got %d, want %d", got, blockInterval)
+	}
+	if got := def.epochInterval(); got != epochInterval {
+		t.Errorf("default epoch interval: got %d, want %d", got, epochInterval)
+	}
+	if got := maxValidatorSizeOf(nil); got != maxValidatorSize {
+		t.Errorf("default validator size: got %d, want %d", got, maxValidatorSize)
+	}
+}
+
+// A block interval adopted by vote must be picked up by the engine that
+// processed it without leaking into the package defaults or other engines.
+func TestAdoptedBlockInterval(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	engine := New(nil, db)
+	if got := engine.slotInterval(); got != blockInterval {
+		t.Fatalf("fresh engine slot interval: got %d, want the default %d", got, blockInterval)
+	}
+
+	dposContext, err := types.NewDposContext(trie.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to create dpos context: %v", err)
+	}
+	if err := dposContext.SetBlockInterval(5); err != nil {
+		t.Fatalf("failed to set block interval: %v", err)
+	}
+	engine.syncBlockInterval(dposContext)
+
+	if got := engine.slotInterval(); got != 5 {
+		t.Errorf("adopted slot interval: got %d, want 5", got)
+	}
+	if blockInterval != 10 {
+		t.Errorf("package default mutated to %d", blockInterval)
+	}
+	if got := New(nil, db).slotInterval(); got != blockInterval {
+		t.Errorf("second engine slot interval: got %d, want the default %d", got, blockInterval)
+	}
+
+	// The slot grid and heartbeat timings follow the adopted interval
+	if got := engine.prevSlot(11); got != 10 {
+		t.Errorf("prevSlot(11): got %d, want 10", got)
+	}
+	if got := engine.nextSlot(11); got != 15 {
+		t.Errorf("nextSlot(11): got %d, want 15", got)
+	}
+	if got := engine.heartbeatTimeout(); got != 15 {
+		t.Errorf("heartbeat timeout: got %d, want 15", got)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package election implements the pure election algorithm of the dpos engine
+// over plain Go values: vote weighting, candidate ranking and the seeded
+// shuffle, free of any state or trie dependency. Explorers, auditors and
+// alternative clients can recompute an election by feeding it the flattened
+// chain data the engine derives from its tries; the engine itself runs on
+// this package, so the two cannot drift apart.
+package election
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"math/rand"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Vote weight modes. Linear weighting is the default; the other curves reduce
+// the influence large stakes can buy. The average-balance mode of the engine
+// is not a curve of its own: it weighs linearly over averaged stake inputs.
+const (
+	WeightLinear  = "linear"
+	WeightSqrt    = "sqrt"
+	WeightQuadCap = "quadratic-capped"
+)
+
+// ErrTooFewCandidates is returned when fewer candidates stand than the safe
+// size the election needs.
+var ErrTooFewCandidates = errors.New("too few candidates to elect")
+
+// Candidate pairs a candidate with the vote weight it gathered.
+type Candidate struct {
+	Address common.Address
+	Weight  *big.Int
+}
+
+// byRank sorts candidates by descending weight, ties broken by ascending
+// address, matching the canonical ordering of the on-chain election.
+type byRank []Candidate
+
+func (p byRank) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byRank) Len() int      { return len(p) }
+func (p byRank) Less(i, j int) bool {
+	if p[i].Weight.Cmp(p[j].Weight) != 0 {
+		return p[i].Weight.Cmp(p[j].Weight) > 0
+	}
+	return p[i].Address.String() < p[j].Address.String()
+}
+
+// Weight translates a single delegator's stake into election weight under the
+// given curve, capped per delegator if a cap is set. Unknown modes weigh
+// linearly.
+func Weight(mode string, cap, stake *big.Int) *big.Int {
+	switch mode {
+	case WeightSqrt:
+		// Weight grows with the square root of the stake
+		weight := new(big.Int).Sqrt(stake)
+		if cap != nil && weight.Cmp(cap) > 0 {
+			weight = cap
+		}
+		return weight
+	case WeightQuadCap:
+		// Stake counts linearly up to the cap and the excess only with its
+		// square root, diminishing returns instead of a hard cutoff
+		if cap == nil || stake.Cmp(cap) <= 0 {
+			return stake
+		}
+		excess := new(big.Int).Sub(stake, cap)
+		return excess.Sqrt(excess).Add(excess, cap)
+	default:
+		// Linear weighting, capped if a cap is set
+		if cap != nil && stake.Cmp(cap) > 0 {
+			return cap
+		}
+		return stake
+	}
+}
+
+// Tally aggregates per candidate both the raw stake of its delegators and the
+// effective vote weight under the given curve. Candidates without delegations
+// tally zero; delegations to addresses outside the candidate list are
+// dropped, delegators without a stake entry count as zero. Proxy aggregates
+// the engine folds in can be supplied as additional delegations.
+func Tally(candidates []common.Address, delegations map[common.Address]common.Address, stakes map[common.Address]*big.Int, mode string, cap *big.Int) (raw, weights map[common.Address]*big.Int) {
+	raw = make(map[common.Address]*big.Int, len(candidates))
+	weights = make(map[common.Address]*big.Int, len(candidates))
+	for _, candidate := range candidates {
+		raw[candidate] = new(big.Int)
+		weights[candidate] = new(big.Int)
+	}
+	for delegator, candidate := range delegations {
+		if _, ok := raw[candidate]; !ok {
+			continue
+		}
+		stake := stakes[delegator]
+		if stake == nil {
+			continue
+		}
+		raw[candidate].Add(raw[candidate], stake)
+		weights[candidate].Add(weights[candidate], Weight(mode, cap, stake))
+	}
+	return raw, weights
+}
+
+// SafeSize returns the minimum candidate count an election for a validator
+// set of the given size needs to stay healthy.
+func SafeSize(size int) int {
+	return size*2/3 + 1
+}
+
+// Seed derives the shuffle seed of the epoch entered after the given parent
+// block from the parent's hash and the epoch being left.
+func Seed(parentHash common.Hash, prevEpoch int64) int64 {
+	return int64(binary.LittleEndian.Uint64(crypto.Keccak512(parentHash.Bytes()))) + prevEpoch
+}
+
+// Rank sorts the candidates into the canonical election order and applies the
+// cutoffs: candidates below the vote threshold are dropped from the tail, but
+// never below the safe size, and the list is clipped to the validator set
+// size. The input is left untouched; ErrTooFewCandidates is returned when
+// fewer candidates stand than the safe size.
+func Rank(candidates []Candidate, threshold *big.Int, size, safe int) ([]Candidate, error) {
+	if len(candidates) < safe {
+		return nil, ErrTooFewCandidates
+	}
+	ranked := make([]Candidate, len(candidates))
+	copy(ranked, candidates)
+	sort.Sort(byRank(ranked))
+	if threshold != nil {
+		for len(ranked) > safe && ranked[len(ranked)-1].Weight.Cmp(threshold) < 0 {
+			ranked = ranked[:len(ranked)-1]
+		}
+	}
+	if len(ranked) > size {
+		ranked = ranked[:size]
+	}
+	return ranked, nil
+}
+
+// ShuffleVersion identifies the exact shuffle algorithm in use: the Fisher-
+// Yates walk below over the math/rand source. It is bumped whenever a change
+// makes Shuffle produce a different order for the same seed, so a recorded
+// validator order can always be checked against the algorithm that laid it
+// out and drift between node versions shows up as a version mismatch instead
+// of a silent reordering.
+const ShuffleVersion = 1
+
+// Shuffle permutes the candidates in place with the deterministic shuffle of
+// the on-chain election, spreading the slot assignment by the given seed.
+func Shuffle(candidates []Candidate, seed int64) {
+	r := rand.New(rand.NewSource(seed))
+	for i := len(candidates) - 1; i > 0; i-- {
+		j := int(r.Int31n(int32(i + 1)))
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	}
+}
+
+// Elect runs the full pure election over the given candidates: rank, apply
+// the cutoffs and shuffle by the seed. The returned slice is freshly
+// allocated and ordered as the validators would be laid over the epoch's
+// slots.
+func Elect(candidates []Candidate, threshold *big.Int, size, safe int, seed int64) ([]Candidate, error) {
+	elected, err := Rank(candidates, threshold, size, safe)
+	if err != nil {
+		return nil, err
+	}
+	Shuffle(elected, seed)
+	return elected, nil
+}
+
+// Addresses flattens a candidate list into its addresses, in order.
+func Addresses(candidates []Candidate) []common.Address {
+	addresses := make([]common.Address, 0, len(candidates))
+	for _, candidate := range candidates {
+		addresses = append(addresses, candidate.Address)
+	}
+	return addresses
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// update regenerates the expected validator sets of the election vectors from
+// the current implementation:
+//
+//	go test ./consensus/dpos -run TestElectionVectors -update
+//
+// Committing regenerated vectors is a consensus change and needs the same
+// scrutiny as the code change that made them necessary.
+var update = flag.Bool("update", false, "rewrite the election vector outputs")
+
+// electionVector is one canonical election scenario: the chain state feeding
+// tryElect and the ordered validator set it must produce. The vectors pin the
+// election down byte for byte, so alternative implementations and refactors
+// can prove compatibility.
+type electionVector struct {
+	Name               string           `json:"name"`
+	Timestamp          int64            `json:"timestamp"`
+	GenesisTime        int64            `json:"genesisTime"`
+	ParentNumber       int64            `json:"parentNumber"`
+	ParentTime         int64            `json:"parentTime"`
+	PreviousValidators []common.Address `json:"previousValidators"`
+	Candidates         []struct {
+		Address common.Address `json:"address"`
+		Balance *big.Int       `json:"balance"`
+	} `json:"candidates"`
+	Delegations []struct {
+		Delegator common.Address `json:"delegator"`
+		Candidate common.Address `json:"candidate"`
+		Balance   *big.Int       `json:"balance"`
+	} `json:"delegations"`
+	MintCnts []struct {
+		Epoch     uint64         `json:"epoch"`
+		Validator common.Address `json:"validator"`
+		Count     uint64         `json:"count"`
+	} `json:"mintCnts"`
+	Validators []common.Address `json:"validators"`
+}
+
+// runVector replays the scenario of the vector and returns the elected
+// validator set.
+func runVector(t *testing.T, vector *electionVector) []common.Address {
+	env := newElectionEnv(t)
+	if len(vector.PreviousValidators) > 0 {
+		if err := env.dposContext.SetValidators(vector.PreviousValidators); err != nil {
+			t.Fatalf("failed to set previous validators: %v", err)
+		}
+	}
+	for _, candidate := range vector.Candidates {
+		env.statedb.AddBalance(candidate.Address, candidate.Balance)
+		if err := env.dposContext.BecomeCandidate(candidate.Address); err != nil {
+			t.Fatalf("failed to register candidate: %v", err)
+		}
+		if err := env.dposContext.Delegate(candidate.Address, candidate.Address); err != nil {
+			t.Fatalf("failed to self vote: %v", err)
+		}
+	}
+	for _, delegation := range vector.Delegations {
+		env.statedb.AddBalance(delegation.Delegator, delegation.Balance)
+		if err := env.dposContext.Delegate(delegation.Delegator, delegation.Candidate); err != nil {
+			t.Fatalf("failed to delegate: %v", err)
+		}
+	}
+	for _, mint := range vector.MintCnts {
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, mint.Epoch)
+		cnt := make([]byte, 8)
+		binary.BigEndian.PutUint64(cnt, mint.Count)
+		if err := env.dposContext.MintCntTrie().TryUpdate(append(key, mint.Validator.Bytes()...), cnt); err != nil {
+			t.Fatalf("failed to set mint count: %v", err)
+		}
+	}
+	ec := env.epochContext(vector.Timestamp)
+	genesis := testHeader(0, vector.GenesisTime)
+	parent := testHeader(vector.ParentNumber, vector.ParentTime)
+	if err := ec.tryElect(genesis, parent); err != nil {
+		t.Fatalf("election failed: %v", err)
+	}
+	validators, err := env.dposContext.GetValidators()
+	if err != nil {
+		t.Fatalf("failed to get validators: %v", err)
+	}
+	return validators
+}
+
+// Tests the election against the canonical vectors in testdata, so refactors
+// of tryElect and alternative implementations can prove byte-for-byte
+// compatible validator sets.
+func TestElectionVectors(t *testing.T) {
+	path := filepath.Join("testdata", "election_vectors.json")
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read election vectors: %v", err)
+	}
+	var vectors []*electionVector
+	if err := json.Unmarshal(blob, &vectors); err != nil {
+		t.Fatalf("failed to parse election vectors: %v", err)
+	}
+	dirty := false
+	for _, vector := range vectors {
+		validators := runVector(t, vector)
+		if *update {
+			vector.Validators = validators
+			dirty = true
+			continue
+		}
+		if len(validators) != len(vector.Validators) {
+			t.Errorf("vector %s: validator count mismatch: have %d, want %d", vector.Name, len(validators), len(vector.Validators))
+			continue
+		}
+		for i := range validators {
+			if validators[i] != vector.Validators[i] {
+				t.Errorf("vector %s: validator %d mismatch: have %x, want %x", vector.Name, i, validators[i], vector.Validators[i])
+			}
+		}
+	}
+	if dirty {
+		blob, err := json.MarshalIndent(vectors, "", "  ")
+		if err != nil {
+			t.Fatalf("failed to encode election vectors: %v", err)
+		}
+		if err := ioutil.WriteFile(path, append(blob, '\n'), 0644); err != nil {
+			t.Fatalf("failed to write election vectors: %v", err)
+		}
+	}
+}
@@ -0,0 +1,1266 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/dpos/election"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// electionFallbackMeter counts epoch transitions that could not elect a fresh
+// validator set and kept the previous one instead.
+var electionFallbackMeter = metrics.NewRegisteredMeter("dpos/election/fallback", nil)
+
+// Timers attributing the import latency of epoch-boundary blocks to the
+// phases of the epoch transition, so operators can explain the periodic
+// import spikes these blocks cause.
+var (
+	electionElectTimer   = metrics.NewRegisteredTimer("dpos/election/elect", nil)
+	electionKickoutTimer = metrics.NewRegisteredTimer("dpos/election/kickout", nil)
+	electionTallyTimer   = metrics.NewRegisteredTimer("dpos/election/tally", nil)
+	electionApplyTimer   = metrics.NewRegisteredTimer("dpos/election/apply", nil)
+	electionCommitTimer  = metrics.NewRegisteredTimer("dpos/election/commit", nil)
+)
+
+// EpochContext wraps the pieces of state the election works on: the dpos
+// context of the block being processed, the account state for vote weights
+// and the timestamp deciding which epoch is current.
+//
+// An EpochContext is not safe for concurrent use. Parallel verifiers must
+// each construct their own over an independent DposContext view and statedb;
+// views opened from the same trie database may be read concurrently.
+type EpochContext struct {
+	TimeStamp   int64
+	DposContext *types.DposContext
+	statedb     *state.StateDB
+	config      *params.DposConfig
+
+	// record, when non-nil, collects a machine readable account of the
+	// election for the RPC export API.
+	record *ElectionRecord
+
+	// db, when non-nil, receives the per-epoch reward records of the fee
+	// pool payout for the reward history API.
+	db ethdb.Database
+
+	// budget, when non-nil, meters the trie entries the context visits on
+	// behalf of an RPC query. Consensus leaves it nil and walks unmetered.
+	budget *walkBudget
+}
+
+// Vote weight modes selectable through DposConfig. Linear weighting is the
+// default; the other curves reduce the influence large stakes can buy. The
+// curves themselves live in the side-effect-free election package.
+const (
+	VoteWeightLinear  = election.WeightLinear
+	VoteWeightSqrt    = election.WeightSqrt
+	VoteWeightQuadCap = election.WeightQuadCap
+
+	// VoteWeightAverage weighs votes linearly, but by the time-weighted
+	// average balance sampled over the previous epoch instead of a single
+	// snapshot, making flash-loan style vote inflation useless.
+	VoteWeightAverage = "average-balance"
+)
+
+// Proposer ordering modes selectable through DposConfig. The uniform shuffle
+// is the default; stake weighting makes block production share track stake.
+const (
+	ProposerOrderingShuffle       = "shuffle"
+	ProposerOrderingStakeWeighted = "stake-weighted"
+)
+
+// stakeWeightedCycleFactor scales the slot layout cycle of a stake-weighted
+// epoch: the cycle holds factor slots per elected validator, bounding both
+// the proportionality rounding error and the size of the stored layout.
+const stakeWeightedCycleFactor = 10
+
+// balanceSampleSlots is the number of slots between two balance samples of
+// the average-balance weighting mode. Sampling every slot would make every
+// block iterate the vote trie; one sample per a handful of slots averages
+// just as well.
+const balanceSampleSlots = int64(10)
+
+// voteWeight translates a single delegator's stake into election weight,
+// applying the curve and per-delegator cap the network configures.
+func (ec *EpochContext) voteWeight(stake *big.Int) *big.Int {
+	if ec.config == nil {
+		return stake
+	}
+	// A mode registered by an embedding fork takes precedence, see
+	// RegisterVoteWeigher; built-in modes never resolve there
+	if weigher := lookupVoteWeigher(ec.config.VoteWeightMode); weigher != nil {
+		return weigher.Weight(stake)
+	}
+	return election.Weight(ec.config.VoteWeightMode, ec.config.MaxVoteWeight, stake)
+}
+
+// tallyVotes walks the candidate and delegate tries, accumulating per
+// candidate both the raw stake of its delegators and the effective vote
+// weight after the configured per-delegator adjustments.
+func (ec *EpochContext) tallyVotes() (raw, votes map[common.Address]*big.Int, err error) {
+	raw = map[common.Address]*big.Int{}
+	votes = map[common.Address]*big.Int{}
+	delegateTrie := ec.DposContext.DelegateTrie()
+	candidateTrie := ec.DposContext.CandidateTrie()
+
+	iterCandidate := trie.NewIterator(candidateTrie.NodeIterator(nil))
+	existCandidate := iterCandidate.Next()
+	if !existCandidate {
+		return raw, votes, ErrNoCandidates
+	}
+	for existCandidate {
+		if err := ec.budget.spend(); err != nil {
+			return nil, nil, err
+		}
+		// Skip auxiliary entries such as kickout cooldowns. Addresses under
+		// an active cooldown cannot re-register, so plain candidate entries
+		// never belong to cooled down validators.
+		if len(iterCandidate.Key) != common.AddressLength {
+			existCandidate = iterCandidate.Next()
+			continue
+		}
+		candidate := iterCandidate.Value
+		candidateAddr := common.BytesToAddress(candidate)
+		// Governance-blacklisted candidates keep their registration and
+		// delegations, but receive no votes while the entry lasts
+		if expiry := ec.DposContext.BlacklistExpiry(candidateAddr); expiry > ec.TimeStamp {
+			existCandidate = iterCandidate.Next()
+			continue
+		}
+		stakeSum := new(big.Int)
+		score := new(big.Int)
+		raw[candidateAddr] = stakeSum
+		votes[candidateAddr] = score
+
+		// Custodial proxy votes count toward the candidate like any other
+		// stake, with the weight curve applied to the aggregate
+		if proxy := ec.DposContext.ProxyWeight(candidateAddr); proxy.Sign() > 0 {
+			stakeSum.Add(stakeSum, proxy)
+			score.Add(score, ec.voteWeight(proxy))
+		}
+		delegateIterator := trie.NewIterator(delegateTrie.NodeIterator(candidate))
+
+		existDelegator := delegateIterator.Next()
+		for existDelegator {
+			if err := ec.budget.spend(); err != nil {
+				return nil, nil, err
+			}
+			if !bytes.HasPrefix(delegateIterator.Key, candidate) {
+				break
+			}
+			delegator := delegateIterator.Value
+			delegatorAddr := common.BytesToAddress(delegator)
+			// Expired votes are skipped lazily; the expired entries are
+			// garbage collected at the epoch transition
+			if ec.voteExpired(delegatorAddr) {
+				existDelegator = delegateIterator.Next()
+				continue
+			}
+			stake := ec.delegatorStake(delegatorAddr)
+			stakeSum.Add(stakeSum, stake)
+			score.Add(score, ec.voteWeight(stake))
+			existDelegator = delegateIterator.Next()
+		}
+		existCandidate = iterCandidate.Next()
+	}
+	return raw, votes, nil
+}
+
+// voteExpired reports whether a delegator's vote aged past the configured
+// expiry without re-confirmation. Votes without a confirmation stamp predate
+// the expiry mode and never expire.
+func (ec *EpochContext) voteExpired(delegator common.Address) bool {
+	if ec.config == nil || ec.config.VoteExpiryEpochs == 0 {
+		return false
+	}
+	voted := ec.DposContext.VoteTime(delegator)
+	if voted == 0 {
+		return false
+	}
+	return ec.TimeStamp/ec.epochInterval()-voted/ec.epochInterval() > int64(ec.config.VoteExpiryEpochs)
+}
+
+// pruneExpiredVotes garbage collects expired delegations from the vote and
+// delegate tries at the epoch transition. The tally already skips them
+// lazily; pruning keeps the tries from accumulating dead entries.
+func (ec *EpochContext) pruneExpiredVotes() {
+	if ec.config == nil || ec.config.VoteExpiryEpochs == 0 {
+		return
+	}
+	// Collect expired delegations first, the tries must not change under
+	// their iterators
+	type delegation struct {
+		delegator, candidate common.Address
+	}
+	var expired []delegation
+	iter := trie.NewIterator(ec.DposContext.VoteTrie().NodeIterator(nil))
+	for iter.Next() {
+		if len(iter.Key) != common.AddressLength {
+			continue
+		}
+		delegator := common.BytesToAddress(iter.Key)
+		if ec.voteExpired(delegator) {
+			expired = append(expired, delegation{delegator, common.BytesToAddress(iter.Value)})
+		}
+	}
+	for _, entry := range expired {
+		if err := ec.DposContext.UnDelegate(entry.delegator, entry.candidate); err != nil {
+			log.Warn("Failed to prune expired vote", "delegator", entry.delegator, "err", err)
+			continue
+		}
+		if err := ec.DposContext.ClearVoteTime(entry.delegator); err != nil {
+			log.Warn("Failed to clear expired vote stamp", "delegator", entry.delegator, "err", err)
+		}
+	}
+	if len(expired) > 0 {
+		log.Debug("Pruned expired votes", "entries", len(expired), "expiry", ec.config.VoteExpiryEpochs)
+	}
+}
+
+// selfStake returns the raw stake a candidate delegates to itself, or zero
+// when the candidate's own vote goes to another candidate or expired.
+func (ec *EpochContext) selfStake(candidate common.Address) *big.Int {
+	voted, err := ec.DposContext.VoteTrie().TryGet(candidate.Bytes())
+	if err != nil || common.BytesToAddress(voted) != candidate || ec.voteExpired(candidate) {
+		return new(big.Int)
+	}
+	return ec.delegatorStake(candidate)
+}
+
+// selfStakeShortfall reports whether the candidate's self-delegation covers
+// less than the configured share of its total raw stake, which bars it from
+// the election until the self-stake recovers. Candidates without any stake
+// pass trivially; they cannot be elected on zero votes anyway.
+func (ec *EpochContext) selfStakeShortfall(candidate common.Address, total *big.Int) bool {
+	if ec.config == nil || ec.config.MinSelfStakePercent == 0 {
+		return false
+	}
+	if total == nil || total.Sign() == 0 {
+		return false
+	}
+	floor := new(big.Int).Mul(total, new(big.Int).SetUint64(ec.config.MinSelfStakePercent))
+	return new(big.Int).Mul(ec.selfStake(candidate), big.NewInt(100)).Cmp(floor) < 0
+}
+
+// delegatorStake returns the stake a delegator's vote counts with: the
+// current account balance, or the average balance sampled over the running
+// epoch when the average-balance weighting mode is active. A delegator that
+// voted after the last sample falls back to its current balance.
+func (ec *EpochContext) delegatorStake(delegator common.Address) *big.Int {
+	stake := ec.statedb.GetBalance(delegator)
+	if ec.config != nil && ec.config.VoteWeightMode == VoteWeightAverage {
+		if avg := ec.DposContext.AverageBalance(delegator); avg != nil {
+			stake = avg
+		}
+	}
+	// Compounded rewards are bonded stake and weigh like balance
+	if bonded := ec.DposContext.BondedReward(delegator); bonded.Sign() > 0 {
+		return new(big.Int).Add(stake, bonded)
+	}
+	return stake
+}
+
+// sampleDelegatorBalances accumulates one balance sample per active delegator
+// into the vote trie, feeding the average-balance weighting of the next
+// election. It runs every balanceSampleSlots slots as part of block
+// finalization, so all nodes derive identical accumulators.
+func (ec *EpochContext) sampleDelegatorBalances() error {
+	iter := trie.NewIterator(ec.DposContext.VoteTrie().NodeIterator(nil))
+	for iter.Next() {
+		// Skip auxiliary entries such as meta-vote nonces and the
+		// accumulators themselves
+		if len(iter.Key) != common.AddressLength {
+			continue
+		}
+		delegator := common.BytesToAddress(iter.Key)
+		if err := ec.DposContext.AddBalanceSample(delegator, ec.statedb.GetBalance(delegator)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearBalanceSamples drops all balance accumulators at the epoch transition,
+// opening a fresh sampling window for the entering epoch.
+func (ec *EpochContext) clearBalanceSamples() error {
+	// Collect the delegators first, the trie must not change under its
+	// iterator
+	var delegators []common.Address
+	iter := trie.NewIterator(ec.DposContext.VoteTrie().NodeIterator(nil))
+	for iter.Next() {
+		if len(iter.Key) < common.AddressLength {
+			continue
+		}
+		if types.IsBalanceSampleKey(iter.Key) {
+			delegators = append(delegators, common.BytesToAddress(iter.Key[:common.AddressLength]))
+		}
+	}
+	for _, delegator := range delegators {
+		if err := ec.DposContext.ClearBalanceSamples(delegator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatorSizeAt returns the validator set size and the derived safe
+// candidate count active in the given epoch, following the growth schedule if
+// the network configures one. Headers need no separate check: verification
+// looks validators up in the set elected for their epoch, which already has
+// the scheduled size.
+func validatorSizeAt(config *params.DposConfig, epoch int64) (size, safe int) {
+	size = maxValidatorSizeOf(config)
+	if config != nil {
+		for _, step := range config.ValidatorSizeSchedule {
+			if int64(step.FromEpoch) <= epoch && step.Size > 0 {
+				size = int(step.Size)
+			}
+		}
+	}
+	return size, election.SafeSize(size)
+}
+
+// kickoutValidator removes validators that failed to produce enough blocks in
+// the given epoch from the candidate list, as long as enough candidates stay
+// behind to keep the election safe.
+func (ec *EpochContext) kickoutValidator(epoch int64) error {
+	validators, err := ec.DposContext.GetValidators()
+	if err != nil {
+		return fmt.Errorf("failed to get validator: %s", err)
+	}
+	if len(validators) == 0 {
+		return ErrEmptyValidatorSet
+	}
+
+	epochDuration := ec.epochInterval()
+	// The first epoch rarely starts on an epoch boundary, so derive its
+	// duration from the time of the first block instead. Otherwise freshly
+	// started chains would kick out perfectly healthy validators.
+	if ec.TimeStamp-timeOfFirstBlock < ec.epochInterval() {
+		epochDuration = ec.TimeStamp - timeOfFirstBlock
+	}
+
+	_, safe := validatorSizeAt(ec.config, epoch)
+	// Aggregate block production over a rolling window of past epochs if the
+	// network configures one, so a validator cannot dodge the check by
+	// alternating a barely active epoch with a dead one. The window cannot
+	// reach beyond the mint counter retention.
+	window := int64(1)
+	if ec.config != nil && ec.config.KickoutWindow > 1 {
+		window = int64(ec.config.KickoutWindow)
+		if window > mintCntRetention {
+			window = mintCntRetention
+		}
+	}
+	needKickoutValidators := sortableAddresses{}
+	thresholds := make(map[common.Address]int64)
+	for _, validator := range validators {
+		// A validator winding down a scheduled departure is leaving by plan;
+		// its missed slots are no liveness failure and earn no kickout record
+		if exit := ec.DposContext.ExitEpoch(validator); exit > 0 && exit <= epoch+1 {
+			continue
+		}
+		cnt := int64(0)
+		expected := int64(0)
+		for e := epoch - window + 1; e <= epoch; e++ {
+			// Skip epochs that predate the chain or fell out of retention;
+			// they must not inflate the expected production
+			if e != epoch && !ec.epochMinted(e) {
+				continue
+			}
+			cnt += ec.DposContext.GetMintCnt(e, validator)
+			if e == epoch {
+				// Scale the closing epoch by the validator's slot share, so
+				// a stake-weighted layout does not kick small validators for
+				// slots they never held
+				held, cycle := ec.expectedShare(validators, validator)
+				expected += epochDuration / ec.blockInterval() * held / cycle
+				continue
+			}
+			// The layouts of older window epochs are gone with their epoch
+			// tries; fall back to the uniform share
+			sizeAt, _ := validatorSizeAt(ec.config, e)
+			expected += ec.epochInterval() / ec.blockInterval() / int64(sizeAt)
+		}
+		if cnt < expected/2 {
+			// Validator was not active enough across the window
+			needKickoutValidators = append(needKickoutValidators, &sortableAddress{validator, big.NewInt(cnt)})
+			thresholds[validator] = expected / 2
+		}
+	}
+	// Short circuit if no validators need to be kicked out
+	needKickoutValidatorCnt := len(needKickoutValidators)
+	if needKickoutValidatorCnt <= 0 {
+		return nil
+	}
+	sort.Sort(sort.Reverse(needKickoutValidators))
+
+	// Count simultaneous failures per declared operator group, so correlated
+	// downtime across one operator's machines draws a longer cooldown
+	var groupCounts map[common.Hash]uint64
+	if ec.config != nil && ec.config.CorrelationPenalty {
+		failed := make([]common.Address, 0, len(needKickoutValidators))
+		for _, validator := range needKickoutValidators {
+			failed = append(failed, validator.address)
+		}
+		groupCounts = ec.failingGroupCounts(failed)
+	}
+
+	candidateCount := 0
+	iter := trie.NewIterator(ec.DposContext.CandidateTrie().NodeIterator(nil))
+	for iter.Next() {
+		// Skip auxiliary entries such as kickout cooldowns
+		if len(iter.Key) != common.AddressLength {
+			continue
+		}
+		candidateCount++
+		if candidateCount >= needKickoutValidatorCnt+safe {
+			break
+		}
+	}
+
+	for i, validator := range needKickoutValidators {
+		// Ensure the candidate count never drops below the safe size
+		if candidateCount <= safe {
+			log.Info("No more candidate can be kickout", "prevEpochID", epoch, "candidateCount", candidateCount, "needKickoutCount", len(needKickoutValidators)-i)
+			return nil
+		}
+		if err := ec.DposContext.KickoutCandidate(validator.address); err != nil {
+			return err
+		}
+		// Bar the validator from re-registering right away, otherwise it
+		// could stand for re-election in the very epoch it was kicked from.
+		// Correlated failures within one operator group stretch the cooldown
+		// by the number of group members kicked alongside.
+		cooldown := kickoutCooldownOf(ec.config)
+		if group := ec.DposContext.OperatorGroup(validator.address); group != (common.Hash{}) && groupCounts[group] > 1 {
+			cooldown *= int64(groupCounts[group])
+		}
+		if err := ec.DposContext.SetKickoutCooldown(validator.address, ec.TimeStamp+cooldown); err != nil {
+			return err
+		}
+		candidateCount--
+		if ec.record != nil {
+			ec.record.Kicked = append(ec.record.Kicked, KickedValidator{Address: validator.address, MintCnt: validator.weight.Int64(), Threshold: thresholds[validator.address]})
+		}
+		log.Info("Kickout candidate", "prevEpochID", epoch, "candidate", validator.address.String(), "mintCnt", validator.weight.String())
+	}
+	return nil
+}
+
+// failingGroupCounts counts, per declared operator group, how many of the
+// given validators failed their epoch duties. Validators without a declared
+// group are not counted; they cannot correlate through one.
+func (ec *EpochContext) failingGroupCounts(failed []common.Address) map[common.Hash]uint64 {
+	counts := make(map[common.Hash]uint64)
+	for _, validator := range failed {
+		if group := ec.DposContext.OperatorGroup(validator); group != (common.Hash{}) {
+			counts[group]++
+		}
+	}
+	return counts
+}
+
+// slashInactiveValidators applies the economic downtime penalty of the given
+// epoch: validators that missed more than the configured share of their slots
+// lose a fraction of their balance, which is split across the validators that
+// stayed active or burned, depending on configuration. Kickout removes a
+// validator from future elections; slashing makes the downtime itself costly.
+func (ec *EpochContext) slashInactiveValidators(epoch int64) error {
+	if ec.config == nil || ec.config.SlashMissedPercent == 0 || ec.config.SlashPenaltyPercent == 0 {
+		return nil
+	}
+	validators, err := ec.DposContext.GetValidators()
+	if err != nil {
+		return err
+	}
+	epochDuration := ec.epochInterval()
+	if ec.TimeStamp-timeOfFirstBlock < ec.epochInterval() {
+		epochDuration = ec.TimeStamp - timeOfFirstBlock
+	}
+	slots := epochDuration / ec.blockInterval()
+	if slots == 0 {
+		return nil
+	}
+
+	// First pass: sort the validators into the active and the failing, so
+	// correlated failures within one operator group are known before any
+	// penalty is sized
+	type downtime struct {
+		validator        common.Address
+		missed, expected int64
+	}
+	var (
+		active  []common.Address
+		failing []downtime
+	)
+	for _, validator := range validators {
+		// Scale the expectation by the validator's slot share, so a
+		// stake-weighted layout does not slash small validators for slots
+		// they never held
+		held, cycle := ec.expectedShare(validators, validator)
+		expected := slots * held / cycle
+		if expected == 0 {
+			active = append(active, validator)
+			continue
+		}
+		missed := expected - ec.DposContext.GetMintCnt(epoch, validator)
+		if missed*100 <= expected*int64(ec.config.SlashMissedPercent) {
+			active = append(active, validator)
+			continue
+		}
+		failing = append(failing, downtime{validator, missed, expected})
+	}
+	var groupCounts map[common.Hash]uint64
+	if ec.config.CorrelationPenalty {
+		failed := make([]common.Address, 0, len(failing))
+		for _, entry := range failing {
+			failed = append(failed, entry.validator)
+		}
+		groupCounts = ec.failingGroupCounts(failed)
+	}
+	slashedTotal := new(big.Int)
+	for _, entry := range failing {
+		// Amplify the penalty by the number of same-group validators that
+		// failed alongside, capped at confiscation
+		percent := ec.config.SlashPenaltyPercent
+		if group := ec.DposContext.OperatorGroup(entry.validator); group != (common.Hash{}) && groupCounts[group] > 1 {
+			percent *= groupCounts[group]
+			if percent > 100 {
+				percent = 100
+			}
+		}
+		penalty := new(big.Int).Mul(ec.statedb.GetBalance(entry.validator), new(big.Int).SetUint64(percent))
+		penalty.Div(penalty, big.NewInt(100))
+		if penalty.Sign() == 0 {
+			continue
+		}
+		ec.statedb.SubBalance(entry.validator, penalty)
+		slashedTotal.Add(slashedTotal, penalty)
+		if ec.config.MirrorStakingStats {
+			ec.bumpStakingStat(entry.validator, types.StakingStatsSlashesSlot, penalty)
+		}
+		if ec.record != nil {
+			ec.record.Slashed = append(ec.record.Slashed, SlashedValidator{Address: entry.validator, Penalty: penalty})
+		}
+		log.Info("Slashed validator for downtime", "prevEpochID", epoch, "validator", entry.validator.String(), "missed", entry.missed, "expected", entry.expected, "penalty", penalty.String())
+	}
+	if slashedTotal.Sign() == 0 {
+		return nil
+	}
+	// Burn the penalties if configured, or when nobody stayed active enough
+	// to receive them; the balances were already subtracted above
+	if ec.config.BurnSlashed || len(active) == 0 {
+		log.Info("Burned downtime penalties", "prevEpochID", epoch, "amount", slashedTotal.String())
+		return ec.DposContext.SubIssuance(slashedTotal)
+	}
+	// Split the penalties across the active validators, rounding dust burns
+	share := new(big.Int).Div(slashedTotal, big.NewInt(int64(len(active))))
+	for _, validator := range active {
+		ec.statedb.AddBalance(validator, share)
+	}
+	dust := new(big.Int).Sub(slashedTotal, new(big.Int).Mul(share, big.NewInt(int64(len(active)))))
+	return ec.DposContext.SubIssuance(dust)
+}
+
+// depositRefundEpochs is the number of fully produced epochs over which a
+// campaign deposit is earned back: a validator producing all of its expected
+// blocks recovers one such slice per epoch.
+const depositRefundEpochs = 4
+
+// refundCampaignDeposits pays back a slice of each validator's campaign
+// deposit proportional to the blocks it produced during the closed epoch, so
+// the deposit is earned through actual service instead of being a one-time
+// fee. The remainder stays locked and is forfeited should the candidate
+// leave the list before earning it back.
+func (ec *EpochContext) refundCampaignDeposits(epoch int64) error {
+	validators, err := ec.DposContext.GetValidators()
+	if err != nil {
+		return err
+	}
+	epochDuration := ec.epochInterval()
+	// Size the first epoch like the kickout check does, so a freshly started
+	// chain does not short-change its validators
+	if ec.TimeStamp-timeOfFirstBlock < ec.epochInterval() {
+		epochDuration = ec.TimeStamp - timeOfFirstBlock
+	}
+	for _, validator := range validators {
+		remaining := ec.DposContext.Deposit(validator)
+		if remaining.Sign() == 0 {
+			continue
+		}
+		held, cycle := ec.expectedShare(validators, validator)
+		expected := epochDuration / ec.blockInterval() * held / cycle
+		if expected <= 0 {
+			continue
+		}
+		minted := ec.DposContext.GetMintCnt(epoch, validator)
+		if minted > expected {
+			minted = expected
+		}
+		refund := new(big.Int).Mul(ec.config.CandidateDeposit, big.NewInt(minted))
+		refund.Div(refund, big.NewInt(expected*depositRefundEpochs))
+		if refund.Cmp(remaining) > 0 {
+			refund = remaining
+		}
+		if refund.Sign() == 0 {
+			continue
+		}
+		if err := ec.DposContext.SetDeposit(validator, new(big.Int).Sub(remaining, refund)); err != nil {
+			return err
+		}
+		ec.statedb.AddBalance(validator, refund)
+	}
+	return nil
+}
+
+// mirrorStakingStats folds the closed epoch's block production into the
+// lifetime counters mirrored in the staking statistics system account, so
+// on-chain contracts can read them. The minted rewards are valued at the
+// reward rate of the boundary block; only the single epoch containing a
+// halving boundary deviates from the exact sum.
+func (ec *EpochContext) mirrorStakingStats(epoch int64, number uint64) error {
+	validators, err := ec.DposContext.GetValidators()
+	if err != nil {
+		return err
+	}
+	reward := calcBlockReward(ec.config, number)
+	for _, validator := range validators {
+		minted := ec.DposContext.GetMintCnt(epoch, validator)
+		if minted == 0 {
+			continue
+		}
+		ec.bumpStakingStat(validator, types.StakingStatsBlocksSlot, big.NewInt(minted))
+		if reward.Sign() > 0 {
+			ec.bumpStakingStat(validator, types.StakingStatsRewardsSlot, new(big.Int).Mul(reward, big.NewInt(minted)))
+		}
+	}
+	return nil
+}
+
+// bumpStakingStat adds an amount to one mirrored counter of a validator in
+// the staking statistics account.
+func (ec *EpochContext) bumpStakingStat(validator common.Address, mappingSlot uint64, amount *big.Int) {
+	key := types.StakingStatSlot(validator, mappingSlot)
+	counter := ec.statedb.GetState(types.StakingStatsAddr, key).Big()
+	counter.Add(counter, amount)
+	ec.statedb.SetState(types.StakingStatsAddr, key, common.BigToHash(counter))
+}
+
+// epochMinted reports whether the mint counter trie holds any entries of the
+// given epoch, i.e. whether the chain produced blocks in it and the counters
+// are still within retention.
+func (ec *EpochContext) epochMinted(epoch int64) bool {
+	prefix := make([]byte, 8)
+	binary.BigEndian.PutUint64(prefix, uint64(epoch))
+	iter := trie.NewIterator(ec.DposContext.MintCntTrie().NodeIterator(prefix))
+	return iter.Next() && bytes.HasPrefix(iter.Key, prefix)
+}
+
+// blockInterval returns the block interval the current epoch runs at: the
+// interval its election adopted on adaptive-interval networks, or the network
+// default.
+func (ec *EpochContext) blockInterval() int64 {
+	if ec.DposContext != nil {
+		if interval := ec.DposContext.BlockInterval(); interval > 0 {
+			return interval
+		}
+	}
+	return blockIntervalOf(ec.config)
+}
+
+// epochInterval returns the epoch length of the context's network in seconds.
+func (ec *EpochContext) epochInterval() int64 {
+	return epochIntervalOf(ec.config)
+}
+
+// lookupValidator maps a point in time onto the validator in charge of the
+// slot containing it.
+func (ec *EpochContext) lookupValidator(now int64) (validator common.Address, err error) {
+	validators, err := ec.DposContext.GetValidators()
+	if err != nil {
+		return common.Address{}, err
+	}
+	drift := int64(0)
+	if ec.config != nil {
+		drift = int64(ec.config.MaxBlockDrift)
+	}
+	return slotValidator(validators, ec.DposContext.GetEpochLayout(), ec.blockInterval(), ec.epochInterval(), drift, now)
+}
+
+// slotValidator maps a point in time onto the validator owning the slot
+// containing it, given the decoded schedule of the slot's epoch: the elected
+// validator set, the optional stake-weighted slot layout, the block interval
+// and the epoch length the epoch runs at.
+func slotValidator(validators []common.Address, layout []byte, interval, epochLen, drift, now int64) (common.Address, error) {
+	offset := now % epochLen
+	// Snap timestamps within the configured drift tolerance onto their slot
+	// boundary, so mild clock skew does not invalidate an otherwise good slot
+	if rem := offset % interval; rem != 0 {
+		switch {
+		case rem <= drift:
+			offset -= rem
+		case interval-rem <= drift:
+			offset += interval - rem
+		default:
+			return common.Address{}, ErrInvalidMintBlockTime
+		}
+	}
+	offset /= interval
+
+	validatorSize := len(validators)
+	if validatorSize == 0 {
+		return common.Address{}, ErrEmptyValidatorSet
+	}
+	// A stake-weighted election leaves a slot layout cycle next to the
+	// validator set; it replaces the uniform round robin
+	if len(layout) > 0 {
+		if index := layout[offset%int64(len(layout))]; int(index) < validatorSize {
+			return validators[index], nil
+		}
+	}
+	offset %= int64(validatorSize)
+	return validators[offset], nil
+}
+
+// stakeWeightedLayout lays the given validators out over a slot cycle, giving
+// each a number of slots proportional to its vote weight. The layout follows
+// the smooth weighted round robin, which interleaves validators instead of
+// handing each a contiguous run, and is fully deterministic: ties fall to the
+// lower index. With no weight anywhere the cycle degenerates to the uniform
+// round robin.
+func stakeWeightedLayout(validators []election.Candidate) []byte {
+	cycle := stakeWeightedCycleFactor * len(validators)
+	layout := make([]byte, 0, cycle)
+	total := new(big.Int)
+	for _, validator := range validators {
+		total.Add(total, validator.Weight)
+	}
+	if total.Sign() == 0 {
+		for slot := 0; slot < cycle; slot++ {
+			layout = append(layout, byte(slot%len(validators)))
+		}
+		return layout
+	}
+	current := make([]*big.Int, len(validators))
+	for i := range current {
+		current[i] = new(big.Int)
+	}
+	for slot := 0; slot < cycle; slot++ {
+		best := 0
+		for i, validator := range validators {
+			current[i].Add(current[i], validator.Weight)
+			if current[i].Cmp(current[best]) > 0 {
+				best = i
+			}
+		}
+		current[best].Sub(current[best], total)
+		layout = append(layout, byte(best))
+	}
+	return layout
+}
+
+// expectedShare returns the share of the current epoch's slots assigned to
+// the given validator, as held slots out of a cycle. A uniform round robin
+// yields one slot per validator count; a stake-weighted layout yields the
+// validator's slice of the stored cycle.
+func (ec *EpochContext) expectedShare(validators []common.Address, validator common.Address) (held, cycle int64) {
+	layout := ec.DposContext.GetEpochLayout()
+	if len(layout) == 0 {
+		return 1, int64(len(validators))
+	}
+	for i, candidate := range validators {
+		if candidate == validator {
+			for _, index := range layout {
+				if int(index) == i {
+					held++
+				}
+			}
+			break
+		}
+	}
+	return held, int64(len(layout))
+}
+
+// tryElect runs an election whenever the block being processed crosses an
+// epoch boundary: underperforming validators of the previous epoch are kicked
+// out, votes are re-counted and the top candidates, shuffled by a seed
+// derived from the parent hash, become the new validator set.
+func (ec *EpochContext) tryElect(genesis, parent *types.Header) error {
+	genesisEpoch := genesis.Time.Int64() / ec.epochInterval()
+	prevEpoch := parent.Time.Int64() / ec.epochInterval()
+	currentEpoch := ec.TimeStamp / ec.epochInterval()
+
+	prevEpochIsGenesis := prevEpoch == genesisEpoch
+	if prevEpochIsGenesis && prevEpoch < currentEpoch {
+		prevEpoch = currentEpoch - 1
+	}
+	if prevEpoch >= currentEpoch {
+		// Not an epoch boundary, nothing to elect
+		return nil
+	}
+	// Run the election on top of revertible snapshots: it touches several
+	// tries and the fee pool balance, and a trie error halfway through must
+	// not leave a partially transitioned context behind.
+	dposSnap := ec.DposContext.Snapshot()
+	stateSnap := ec.statedb.Snapshot()
+	start := time.Now()
+	err := ec.elect(parent, prevEpoch, currentEpoch, prevEpochIsGenesis)
+	electionElectTimer.UpdateSince(start)
+	if err != nil {
+		ec.DposContext.RevertToSnapShot(dposSnap)
+		ec.statedb.RevertToSnapshot(stateSnap)
+		return err
+	}
+	return nil
+}
+
+// elect performs the epoch transitions between prevEpoch and currentEpoch.
+// It is the failable inner part of tryElect, which wraps it in snapshots so
+// transitions are applied all-or-nothing.
+func (ec *EpochContext) elect(parent *types.Header, prevEpoch, currentEpoch int64, prevEpochIsGenesis bool) error {
+	prevEpochBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(prevEpochBytes, uint64(prevEpoch))
+	iter := trie.NewIterator(ec.DposContext.MintCntTrie().NodeIterator(prevEpochBytes))
+	for i := prevEpoch; i < currentEpoch; i++ {
+		// Pay the fees pooled during the closed epoch out to its validators
+		if err := ec.distributeFeePool(i); err != nil {
+			return err
+		}
+		// A scheduled governance override wins over the regular election: it
+		// exists to recover a chain whose validators can no longer sign, so
+		// the overriding set need not be candidates or have votes
+		if override, err := ec.DposContext.PendingValidatorOverride(); err != nil {
+			return err
+		} else if len(override) > 0 {
+			epochTrie, err := types.NewEpochTrie(common.Hash{}, ec.DposContext.DB())
+			if err != nil {
+				return fmt.Errorf("failed to create epoch trie: %s", err)
+			}
+			ec.DposContext.SetEpoch(epochTrie)
+			if err := ec.DposContext.SetValidators(override); err != nil {
+				return err
+			}
+			if err := ec.DposContext.ClearPendingValidatorOverride(); err != nil {
+				return err
+			}
+			if err := ec.commitEpochChange(i+1, override, nil, nil); err != nil {
+				return err
+			}
+			ec.pruneMintCnt(i + 1)
+			ec.pruneExpiredVotes()
+			if ec.record != nil {
+				ec.record.Epoch = i + 1
+				ec.record.Validators = override
+			}
+			log.Warn("Governance validator override applied", "epoch", i+1, "validators", len(override))
+			continue
+		}
+		// Refund campaign deposits for the blocks the closing epoch's
+		// validators actually produced, before kickouts forfeit whatever a
+		// failing validator has not earned back yet
+		if ec.config != nil && ec.config.CandidateDeposit != nil && ec.config.CandidateDeposit.Sign() > 0 {
+			if err := ec.refundCampaignDeposits(i); err != nil {
+				return err
+			}
+		}
+		// If the previous epoch is not genesis, kickout validators that did
+		// not produce enough blocks
+		if !prevEpochIsGenesis && iter.Next() {
+			start := time.Now()
+			if err := ec.kickoutValidator(prevEpoch); err != nil {
+				return err
+			}
+			// Slash validators with prolonged downtime before the votes are
+			// tallied, so the reduced balances already weigh the election
+			if err := ec.slashInactiveValidators(prevEpoch); err != nil {
+				return err
+			}
+			electionKickoutTimer.UpdateSince(start)
+		}
+		// Mirror the closed epoch's production into the staking statistics
+		// account before the epoch trie is replaced
+		if ec.config != nil && ec.config.MirrorStakingStats {
+			if err := ec.mirrorStakingStats(i, parent.Number.Uint64()+1); err != nil {
+				return err
+			}
+		}
+		start := time.Now()
+		raw, votes, err := ec.tallyVotes()
+		if err != nil {
+			return err
+		}
+		electionTallyTimer.UpdateSince(start)
+		// The tally consumed the balance samples of the closed epoch; start a
+		// fresh sampling window for the epoch being entered
+		if ec.config != nil && ec.config.VoteWeightMode == VoteWeightAverage {
+			if err := ec.clearBalanceSamples(); err != nil {
+				return err
+			}
+		}
+		// Elect with the validator set size scheduled for the epoch being
+		// entered
+		size, safe := validatorSizeAt(ec.config, i+1)
+
+		candidates := make([]election.Candidate, 0, len(votes))
+		for candidate, cnt := range votes {
+			// Candidates whose self-delegation dropped below the configured
+			// floor sit the election out
+			if ec.selfStakeShortfall(candidate, raw[candidate]) {
+				continue
+			}
+			// Candidates that announced a scheduled departure sit out
+			// elections from their effective epoch on; the ranking promotes
+			// the next candidates into the freed seats
+			if exit := ec.DposContext.ExitEpoch(candidate); exit > 0 && exit <= i+1 {
+				continue
+			}
+			candidates = append(candidates, election.Candidate{Address: candidate, Weight: cnt})
+		}
+		if len(candidates) < safe {
+			// Refusing to elect would halt the chain for good: every later
+			// boundary re-runs the same failing election. Keep the previous
+			// validator set alive instead, so a mass resignation cannot brick
+			// the network and it recovers once new candidates register.
+			prevValidators, err := ec.DposContext.GetValidators()
+			if err != nil {
+				return err
+			}
+			log.Warn("Too few candidates to elect, keeping previous validators", "candidates", len(candidates), "safe", safe, "epoch", i+1)
+			electionFallbackMeter.Mark(1)
+			if err := ec.commitEpochChange(i+1, prevValidators, raw, votes); err != nil {
+				return err
+			}
+			ec.pruneMintCnt(i + 1)
+			ec.pruneExpiredVotes()
+			if ec.record != nil {
+				ec.record.Epoch = i + 1
+				ec.record.Tallies = talliesOf(raw, votes)
+				ec.record.Validators = prevValidators
+			}
+			continue
+		}
+		// Rank the candidates, apply the vote threshold and size cutoffs and
+		// shuffle with a seed derived from the parent hash and the epoch
+		// number to spread the slot assignment. The algorithm lives in the
+		// pure election package, shared with offline consumers.
+		var threshold *big.Int
+		if ec.config != nil {
+			threshold = ec.config.MinVoteThreshold
+		}
+		seed := election.Seed(parent.Hash(), i)
+		elected, err := election.Elect(candidates, threshold, size, safe, seed)
+		if err != nil {
+			return err
+		}
+		sortedValidators := election.Addresses(elected)
+		// Settle the block interval of the entering epoch before the closing
+		// epoch's trie, which holds the current one, is replaced
+		nextInterval := ec.nextBlockInterval(sortedValidators)
+
+		start = time.Now()
+		epochTrie, err := types.NewEpochTrie(common.Hash{}, ec.DposContext.DB())
+		if err != nil {
+			return fmt.Errorf("failed to create epoch trie: %s", err)
+		}
+		ec.DposContext.SetEpoch(epochTrie)
+		if err := ec.DposContext.SetValidators(sortedValidators); err != nil {
+			return err
+		}
+		// Lay the validators out over the epoch's slots proportionally to
+		// their vote weight if the network orders proposers by stake
+		if ec.config != nil && ec.config.ProposerOrdering == ProposerOrderingStakeWeighted {
+			ec.DposContext.SetEpochLayout(stakeWeightedLayout(elected))
+		}
+		if adaptiveIntervalEnabled(ec.config) {
+			if err := ec.DposContext.SetBlockInterval(nextInterval); err != nil {
+				return err
+			}
+		}
+		electionApplyTimer.UpdateSince(start)
+		// Recompute the network gas price floor as the median of the votes
+		// the incoming validators published in their sealed headers
+		if err := ec.updateMinGasPrice(sortedValidators); err != nil {
+			return err
+		}
+		if err := ec.commitEpochChange(i+1, sortedValidators, raw, votes); err != nil {
+			return err
+		}
+		ec.pruneMintCnt(i + 1)
+		ec.pruneExpiredVotes()
+		if ec.record != nil {
+			ec.record.Epoch = i + 1
+			ec.record.Seed = seed
+			ec.record.Shuffle = election.ShuffleVersion
+			ec.record.Tallies = talliesOf(raw, votes)
+			ec.record.Validators = sortedValidators
+		}
+		log.Info("Come to new epoch", "prevEpoch", i, "nextEpoch", i+1)
+	}
+	return nil
+}
+
+// adaptiveIntervalEnabled reports whether the network votes on its block
+// interval: both governance bounds must be configured and consistent.
+func adaptiveIntervalEnabled(config *params.DposConfig) bool {
+	return config != nil && config.MinBlockInterval > 0 && config.MaxBlockInterval >= config.MinBlockInterval
+}
+
+// nextBlockInterval returns the block interval the entering epoch should run
+// at: the interval a supermajority of the incoming validators voted for, or
+// the current one carried forward when no such agreement exists. Out-of-bound
+// votes are ignored; only one value can ever reach the two-thirds quorum.
+func (ec *EpochContext) nextBlockInterval(validators []common.Address) int64 {
+	current := ec.blockInterval()
+	if !adaptiveIntervalEnabled(ec.config) {
+		return current
+	}
+	counts := make(map[uint64]int)
+	for _, validator := range validators {
+		vote := ec.DposContext.BlockIntervalVote(validator)
+		if vote < ec.config.MinBlockInterval || vote > ec.config.MaxBlockInterval {
+			continue
+		}
+		counts[vote]++
+	}
+	quorum := len(validators)*2/3 + 1
+	for vote, count := range counts {
+		if count >= quorum && int64(vote) != current {
+			log.Info("Validators voted to change the block interval", "previous", current, "interval", vote, "votes", count, "quorum", quorum)
+			return int64(vote)
+		}
+	}
+	return current
+}
+
+// commitEpochChange writes the canonical record of an epoch transition into
+// the epoch trie, where it is committed to by the block's DposContext root.
+// The kickouts are taken from the election record accumulated so far.
+func (ec *EpochContext) commitEpochChange(epoch int64, validators []common.Address, raw, votes map[common.Address]*big.Int) error {
+	tallies, err := rlp.EncodeToBytes(talliesOf(raw, votes))
+	if err != nil {
+		return err
+	}
+	kicked := make([]common.Address, 0)
+	if ec.record != nil {
+		for _, validator := range ec.record.Kicked {
+			kicked = append(kicked, validator.Address)
+		}
+	}
+	return ec.DposContext.SetEpochChange(&types.EpochChange{
+		Epoch:       uint64(epoch),
+		Validators:  validators,
+		Kicked:      kicked,
+		TalliesHash: common.BytesToHash(crypto.Keccak256(tallies)),
+	})
+}
+
+// updateMinGasPrice recomputes the network minimum gas price as the median of
+// the votes the given validators published in their sealed headers. The
+// median is robust against a minority undercutting the market or voting the
+// floor sky-high. Validators without a vote don't count; with no votes at all
+// the floor is lifted.
+func (ec *EpochContext) updateMinGasPrice(validators []common.Address) error {
+	votes := make([]uint64, 0, len(validators))
+	for _, validator := range validators {
+		if vote := ec.DposContext.GasPriceVote(validator); vote > 0 {
+			votes = append(votes, vote)
+		}
+	}
+	if len(votes) == 0 {
+		return ec.DposContext.SetMinGasPrice(0)
+	}
+	sort.Slice(votes, func(i, j int) bool { return votes[i] < votes[j] })
+	return ec.DposContext.SetMinGasPrice(votes[len(votes)/2])
+}
+
+// distributeFeePool pays the transaction fees pooled during the given epoch
+// out to its validators, proportionally to the number of blocks each of them
+// produced, and onwards to their delegators after commission. Rounding dust
+// stays in the pool for the next epoch. On networks that do not pool fees the
+// pool account is empty and this is a no-op.
+func (ec *EpochContext) distributeFeePool(epoch int64) error {
+	pool := new(big.Int).Set(ec.statedb.GetBalance(types.FeePoolAddr))
+	if pool.Sign() == 0 {
+		return nil
+	}
+	validators, err := ec.DposContext.GetValidators()
+	if err != nil {
+		return err
+	}
+	counts := make([]int64, len(validators))
+	totalMinted := int64(0)
+	for i, validator := range validators {
+		counts[i] = ec.DposContext.GetMintCnt(epoch, validator)
+		totalMinted += counts[i]
+	}
+	if totalMinted == 0 {
+		return nil
+	}
+	for i, validator := range validators {
+		if counts[i] == 0 {
+			continue
+		}
+		share := new(big.Int).Div(new(big.Int).Mul(pool, big.NewInt(counts[i])), big.NewInt(totalMinted))
+		if err := ec.distributeValidatorShare(epoch, validator, share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// distributeValidatorShare pays out one validator's cut of the epoch fee
+// pool: the validator keeps its declared commission, the remainder is split
+// across its delegators proportionally to their stake. Delegators that opted
+// into auto-compounding accrue their cut as bonded stake instead of spendable
+// balance. A validator without delegators keeps the whole share; rounding
+// dust stays in the pool.
+func (ec *EpochContext) distributeValidatorShare(epoch int64, validator common.Address, share *big.Int) error {
+	candidate := validator.Bytes()
+	var delegators []common.Address
+	stakes := make(map[common.Address]*big.Int)
+	totalStake := new(big.Int)
+	iter := trie.NewIterator(ec.DposContext.DelegateTrie().NodeIterator(candidate))
+	for iter.Next() {
+		if !bytes.HasPrefix(iter.Key, candidate) {
+			break
+		}
+		delegator := common.BytesToAddress(iter.Value)
+		stake := ec.delegatorStake(delegator)
+		delegators = append(delegators, delegator)
+		stakes[delegator] = stake
+		totalStake.Add(totalStake, stake)
+	}
+	// The validator's cut goes to its registered reward recipient, if any;
+	// the fee income records stay attributed to the validator either way
+	payout := ec.DposContext.RewardRecipient(validator)
+	if payout == (common.Address{}) {
+		payout = validator
+	}
+	if totalStake.Sign() == 0 {
+		ec.statedb.AddBalance(payout, share)
+		ec.statedb.SubBalance(types.FeePoolAddr, share)
+		recordFeeIncome(ec.db, uint64(epoch), validator, share)
+		return nil
+	}
+	// The validator's commission comes off the top
+	commission := new(big.Int).Mul(share, new(big.Int).SetUint64(ec.DposContext.Commission(validator)))
+	commission.Div(commission, big.NewInt(100))
+	ec.statedb.AddBalance(payout, commission)
+	paid := new(big.Int).Set(commission)
+	recordFeeIncome(ec.db, uint64(epoch), validator, commission)
+
+	rest := new(big.Int).Sub(share, commission)
+	for _, delegator := range delegators {
+		cut := new(big.Int).Div(new(big.Int).Mul(rest, stakes[delegator]), totalStake)
+		if cut.Sign() == 0 {
+			continue
+		}
+		if ec.DposContext.Compounding(delegator) {
+			if err := ec.DposContext.AddBondedReward(delegator, cut); err != nil {
+				return err
+			}
+		} else {
+			ec.statedb.AddBalance(delegator, cut)
+		}
+		paid.Add(paid, cut)
+		recordDelegatorReward(ec.db, uint64(epoch), delegator, cut)
+	}
+	ec.statedb.SubBalance(types.FeePoolAddr, paid)
+	return nil
+}
+
+// mintCntRetention is the number of past epochs whose block production
+// counters are kept in the mint counter trie. Counters older than the window
+// have no influence on kickout decisions anymore and are dropped at the epoch
+// transition. The pruning runs identically on every node, so it is part of
+// the consensus rules and keeps the trie from growing without bound.
+const mintCntRetention = int64(7)
+
+// pruneMintCnt removes mint counters of epochs that fell out of the
+// retention window, measured from the epoch being entered.
+func (ec *EpochContext) pruneMintCnt(currentEpoch int64) {
+	mintCntTrie := ec.DposContext.MintCntTrie()
+
+	// Collect stale keys first, the trie must not change under its iterator
+	var stale [][]byte
+	iter := trie.NewIterator(mintCntTrie.NodeIterator(nil))
+	for iter.Next() {
+		if len(iter.Key) < 8 {
+			continue
+		}
+		epoch := int64(binary.BigEndian.Uint64(iter.Key[:8]))
+		if epoch <= currentEpoch-mintCntRetention {
+			stale = append(stale, common.CopyBytes(iter.Key))
+		}
+	}
+	for _, key := range stale {
+		mintCntTrie.Delete(key)
+	}
+	if len(stale) > 0 {
+		log.Debug("Pruned historical mint counters", "entries", len(stale), "epoch", currentEpoch, "retention", mintCntRetention)
+	}
+}
+
+// ReplayElection recomputes the election a block at the given timestamp would
+// have run on top of parent, returning the validator set it elects. The dpos
+// context and state must be the ones the parent block committed; they are
+// mutated during the replay, so callers should pass throwaway copies.
+func ReplayElection(config *params.DposConfig, dposContext *types.DposContext, statedb *state.StateDB, genesis, parent *types.Header, timestamp int64) ([]common.Address, error) {
+	epochContext := &EpochContext{
+		TimeStamp:   timestamp,
+		DposContext: dposContext,
+		statedb:     statedb,
+		config:      config,
+	}
+	if err := epochContext.tryElect(genesis, parent); err != nil {
+		return nil, err
+	}
+	return epochContext.DposContext.GetValidators()
+}
+
+type sortableAddress struct {
+	address common.Address
+	weight  *big.Int
+}
+
+type sortableAddresses []*sortableAddress
+
+func (p sortableAddresses) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p sortableAddresses) Len() int      { return len(p) }
+func (p sortableAddresses) Less(i, j int) bool {
+	if p[i].weight.Cmp(p[j].weight) != 0 {
+		return p[i].weight.Cmp(p[j].weight) > 0
+	}
+	return p[i].address.String() < p[j].address.String()
+}
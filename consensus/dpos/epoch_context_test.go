@@ -0,0 +1,338 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"bytes"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/dpos/election"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// electionEnv is a scripted election scenario: an in-memory account state and
+// dpos context the tests populate with candidates, votes and balances before
+// driving an EpochContext against them.
+type electionEnv struct {
+	statedb     *state.StateDB
+	dposContext *types.DposContext
+}
+
+func newElectionEnv(t *testing.T) *electionEnv {
+	db := ethdb.NewMemDatabase()
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	dposContext, err := types.NewDposContext(trie.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to create dpos context: %v", err)
+	}
+	return &electionEnv{statedb: statedb, dposContext: dposContext}
+}
+
+// addCandidate registers a self-voting candidate backed by the given balance.
+func (env *electionEnv) addCandidate(t *testing.T, candidate common.Address, balance int64) {
+	env.statedb.AddBalance(candidate, big.NewInt(balance))
+	if err := env.dposContext.BecomeCandidate(candidate); err != nil {
+		t.Fatalf("failed to register candidate: %v", err)
+	}
+	if err := env.dposContext.Delegate(candidate, candidate); err != nil {
+		t.Fatalf("failed to self vote: %v", err)
+	}
+}
+
+// delegate casts a vote of the given delegator, backed by the given balance.
+func (env *electionEnv) delegate(t *testing.T, delegator, candidate common.Address, balance int64) {
+	env.statedb.AddBalance(delegator, big.NewInt(balance))
+	if err := env.dposContext.Delegate(delegator, candidate); err != nil {
+		t.Fatalf("failed to delegate: %v", err)
+	}
+}
+
+func (env *electionEnv) epochContext(timestamp int64) *EpochContext {
+	return &EpochContext{
+		TimeStamp:   timestamp,
+		DposContext: env.dposContext,
+		statedb:     env.statedb,
+	}
+}
+
+func testAddr(i int) common.Address {
+	return common.BigToAddress(big.NewInt(int64(i + 1)))
+}
+
+func testHeader(number, timestamp int64) *types.Header {
+	return &types.Header{
+		Number:      big.NewInt(number),
+		Time:        big.NewInt(timestamp),
+		Difficulty:  big.NewInt(1),
+		DposContext: &types.DposContextProto{},
+	}
+}
+
+// Tests that crossing an epoch boundary elects the top candidates: the full
+// configured set size, without duplicates and only from the registered
+// candidates.
+func TestElectNewEpoch(t *testing.T) {
+	env := newElectionEnv(t)
+	candidates := make(map[common.Address]bool)
+	for i := 0; i < maxValidatorSize+5; i++ {
+		env.addCandidate(t, testAddr(i), int64(1000+i))
+		candidates[testAddr(i)] = true
+	}
+	ec := env.epochContext(epochInterval)
+	if err := ec.tryElect(testHeader(0, 0), testHeader(1, epochInterval-blockInterval)); err != nil {
+		t.Fatalf("election failed: %v", err)
+	}
+	validators, err := env.dposContext.GetValidators()
+	if err != nil {
+		t.Fatalf("failed to get validators: %v", err)
+	}
+	if len(validators) != maxValidatorSize {
+		t.Errorf("validator count mismatch: have %d, want %d", len(validators), maxValidatorSize)
+	}
+	seen := make(map[common.Address]bool)
+	for _, validator := range validators {
+		if seen[validator] {
+			t.Errorf("duplicate validator %x", validator)
+		}
+		seen[validator] = true
+		if !candidates[validator] {
+			t.Errorf("validator %x was never a candidate", validator)
+		}
+	}
+}
+
+// Tests that the election is a pure function of its inputs: the same votes,
+// balances and parent hash produce the same ordered validator set.
+func TestElectDeterminism(t *testing.T) {
+	run := func() []common.Address {
+		env := newElectionEnv(t)
+		for i := 0; i < maxValidatorSize+5; i++ {
+			env.addCandidate(t, testAddr(i), int64(1000+i))
+			env.delegate(t, testAddr(100+i), testAddr(i), int64(50*i))
+		}
+		ec := env.epochContext(epochInterval)
+		if err := ec.tryElect(testHeader(0, 0), testHeader(1, epochInterval-blockInterval)); err != nil {
+			t.Fatalf("election failed: %v", err)
+		}
+		validators, err := env.dposContext.GetValidators()
+		if err != nil {
+			t.Fatalf("failed to get validators: %v", err)
+		}
+		return validators
+	}
+	first, second := run(), run()
+	if len(first) != len(second) {
+		t.Fatalf("validator count mismatch between runs: %d != %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("validator %d mismatch between runs: %x != %x", i, first[i], second[i])
+		}
+	}
+}
+
+// Tests that an election with fewer candidates than the safe size keeps the
+// previous validator set instead of halting the chain.
+func TestElectTooFewCandidates(t *testing.T) {
+	env := newElectionEnv(t)
+	previous := make([]common.Address, 0, maxValidatorSize)
+	for i := 0; i < maxValidatorSize; i++ {
+		previous = append(previous, testAddr(200+i))
+	}
+	if err := env.dposContext.SetValidators(previous); err != nil {
+		t.Fatalf("failed to set previous validators: %v", err)
+	}
+	for i := 0; i < safeSizeOf(nil)-1; i++ {
+		env.addCandidate(t, testAddr(i), 1000)
+	}
+	ec := env.epochContext(epochInterval)
+	if err := ec.tryElect(testHeader(0, 0), testHeader(1, epochInterval-blockInterval)); err != nil {
+		t.Fatalf("election failed: %v", err)
+	}
+	validators, err := env.dposContext.GetValidators()
+	if err != nil {
+		t.Fatalf("failed to get validators: %v", err)
+	}
+	if len(validators) != len(previous) {
+		t.Fatalf("validator count changed: have %d, want %d", len(validators), len(previous))
+	}
+	for i := range validators {
+		if validators[i] != previous[i] {
+			t.Errorf("validator %d changed: have %x, want %x", i, validators[i], previous[i])
+		}
+	}
+}
+
+// Tests that independent DposContext views over a shared trie database can
+// serve parallel verifiers: concurrent epoch contexts resolve slot validators
+// and tally votes for a batch of epoch-boundary timestamps without racing and
+// without diverging from the sequential answer. Run with the race detector.
+func TestParallelEpochBoundaryVerification(t *testing.T) {
+	env := newElectionEnv(t)
+	for i := 0; i < maxValidatorSize+5; i++ {
+		env.addCandidate(t, testAddr(i), int64(1000+i))
+		env.delegate(t, testAddr(100+i), testAddr(i), int64(50*i))
+	}
+	ec := env.epochContext(epochInterval)
+	if err := ec.tryElect(testHeader(0, 0), testHeader(1, epochInterval-blockInterval)); err != nil {
+		t.Fatalf("election failed: %v", err)
+	}
+	proto, err := env.dposContext.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit dpos context: %v", err)
+	}
+	// Sequential reference answers for a batch of epoch-boundary slots
+	slots := make([]int64, 16)
+	want := make([]common.Address, len(slots))
+	for i := range slots {
+		slots[i] = epochInterval + int64(i)*blockInterval
+		if want[i], err = ec.lookupValidator(slots[i]); err != nil {
+			t.Fatalf("failed to lookup validator: %v", err)
+		}
+	}
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each verifier opens its own immutable view of the block
+			dposContext, err := types.NewDposContextFromProto(env.dposContext.DB(), proto)
+			if err != nil {
+				t.Errorf("failed to open dpos context view: %v", err)
+				return
+			}
+			parallel := &EpochContext{DposContext: dposContext}
+			for i, slot := range slots {
+				validator, err := parallel.lookupValidator(slot)
+				if err != nil {
+					t.Errorf("failed to lookup validator: %v", err)
+					return
+				}
+				if validator != want[i] {
+					t.Errorf("validator mismatch at slot %d: have %x, want %x", slot, validator, want[i])
+				}
+			}
+			if _, err := dposContext.GetValidators(); err != nil {
+				t.Errorf("failed to get validators: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Tests that a block within the running epoch does not touch the validator
+// set.
+func TestElectNotOnBoundary(t *testing.T) {
+	env := newElectionEnv(t)
+	previous := []common.Address{testAddr(0), testAddr(1), testAddr(2)}
+	if err := env.dposContext.SetValidators(previous); err != nil {
+		t.Fatalf("failed to set previous validators: %v", err)
+	}
+	ec := env.epochContext(epochInterval + 2*blockInterval)
+	if err := ec.tryElect(testHeader(0, 0), testHeader(1, epochInterval+blockInterval)); err != nil {
+		t.Fatalf("election failed: %v", err)
+	}
+	validators, err := env.dposContext.GetValidators()
+	if err != nil {
+		t.Fatalf("failed to get validators: %v", err)
+	}
+	if len(validators) != len(previous) {
+		t.Fatalf("validator count changed: have %d, want %d", len(validators), len(previous))
+	}
+	for i := range validators {
+		if validators[i] != previous[i] {
+			t.Errorf("validator %d changed: have %x, want %x", i, validators[i], previous[i])
+		}
+	}
+}
+
+func TestStakeWeightedLayout(t *testing.T) {
+	validators := []election.Candidate{
+		{Address: common.HexToAddress("0x1"), Weight: big.NewInt(5)},
+		{Address: common.HexToAddress("0x2"), Weight: big.NewInt(3)},
+		{Address: common.HexToAddress("0x3"), Weight: big.NewInt(2)},
+	}
+	layout := stakeWeightedLayout(validators)
+	if len(layout) != stakeWeightedCycleFactor*len(validators) {
+		t.Fatalf("layout length mismatch: have %d, want %d", len(layout), stakeWeightedCycleFactor*len(validators))
+	}
+	// Slot counts must be proportional to the weights over the cycle
+	counts := make([]int, len(validators))
+	for _, index := range layout {
+		counts[index]++
+	}
+	for i, want := range []int{15, 9, 6} {
+		if counts[i] != want {
+			t.Errorf("validator %d slot count mismatch: have %d, want %d", i, counts[i], want)
+		}
+	}
+	// The layout must be deterministic
+	again := stakeWeightedLayout(validators)
+	if !bytes.Equal(layout, again) {
+		t.Errorf("layout not deterministic: have %x, want %x", again, layout)
+	}
+	// Without any weight the cycle degenerates to the uniform round robin
+	for i := range validators {
+		validators[i].Weight = new(big.Int)
+	}
+	uniform := stakeWeightedLayout(validators)
+	for slot, index := range uniform {
+		if int(index) != slot%len(validators) {
+			t.Fatalf("slot %d not uniform: have validator %d, want %d", slot, index, slot%len(validators))
+		}
+	}
+}
+
+// Tests that a candidate whose self-delegation covers less than the
+// configured share of its stake is excluded from the election, even when its
+// total stake would top the tally.
+func TestElectSelfStakeFloor(t *testing.T) {
+	env := newElectionEnv(t)
+	for i := 0; i < maxValidatorSize+1; i++ {
+		env.addCandidate(t, testAddr(i), 1000)
+	}
+	// Dwarf the last candidate's own stake with a delegator vote
+	diluted := testAddr(maxValidatorSize)
+	env.delegate(t, testAddr(500), diluted, 1000000)
+	ec := env.epochContext(epochInterval)
+	ec.config = &params.DposConfig{MinSelfStakePercent: 50}
+	if err := ec.tryElect(testHeader(0, 0), testHeader(1, epochInterval-blockInterval)); err != nil {
+		t.Fatalf("election failed: %v", err)
+	}
+	validators, err := env.dposContext.GetValidators()
+	if err != nil {
+		t.Fatalf("failed to get validators: %v", err)
+	}
+	if len(validators) != maxValidatorSize {
+		t.Errorf("validator count mismatch: have %d, want %d", len(validators), maxValidatorSize)
+	}
+	for _, validator := range validators {
+		if validator == diluted {
+			t.Errorf("diluted candidate %x was elected despite the self-stake floor", diluted)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+// JSON-RPC error codes of the dpos failure classes. They sit in a dedicated
+// range so operator tooling and the console can react to a class of failure
+// without parsing error strings.
+const (
+	ErrCodeEngine           = -32800 // Unclassified consensus engine failure
+	ErrCodeNotOwnSlot       = -32801 // The local signer does not own the block's time slot
+	ErrCodeNoSigner         = -32802 // No authorized signer is available for sealing
+	ErrCodeEmptyValidators  = -32803 // The validator set is empty
+	ErrCodeNoCandidates     = -32804 // No registered candidates to elect from
+	ErrCodeNotEpochBoundary = -32805 // The queried block is not an epoch boundary
+	ErrCodeNoStateAccess    = -32806 // The engine has no account state access configured
+	ErrCodeIndexNotSynced   = -32807 // The delegation index lags the chain head
+	ErrCodeInvalidEpoch     = -32808 // Malformed epoch or block range parameters
+	ErrCodeSealingPaused    = -32809 // Sealing is paused after repeated failures
+	ErrCodeClockSkew        = -32810 // The local clock is too skewed to act safely
+	ErrCodeWaitForParent    = -32811 // The parent of the slot's block has not arrived yet
+	ErrCodeFutureSlot       = -32812 // The block's time slot lies in the future
+	ErrCodeWalkBudget       = -32813 // An RPC trie walk exhausted its execution budget
+	ErrCodeReplicaMode      = -32814 // The node runs as a read replica and never seals
+)
+
+// Error couples a dpos failure with a stable machine readable code. It
+// implements the rpc package's extended error interface, so the code reaches
+// JSON-RPC clients unchanged and errors can still be compared by identity
+// inside the node.
+type Error struct {
+	Code    int
+	Message string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string { return e.Message }
+
+// ErrorCode returns the JSON-RPC error code classifying the failure.
+func (e *Error) ErrorCode() int { return e.Code }
+
+// Typed failures shared between the engine internals and the RPC API.
+var (
+	// ErrEmptyValidatorSet is returned when an operation needs an elected
+	// validator set and the epoch trie holds none.
+	ErrEmptyValidatorSet = &Error{ErrCodeEmptyValidators, "validator set is empty"}
+
+	// ErrNoCandidates is returned by the vote tally when the candidate trie
+	// holds no registered candidates.
+	ErrNoCandidates = &Error{ErrCodeNoCandidates, "no candidates"}
+
+	// ErrNoAvailableSigner is returned when sealing is attempted without an
+	// authorized signer, e.g. before the validator account was unlocked.
+	ErrNoAvailableSigner = &Error{ErrCodeNoSigner, "sealing without authorized validator"}
+
+	// ErrNoStateAccess is returned by API methods needing account balances
+	// when the engine has no state resolver configured.
+	ErrNoStateAccess = &Error{ErrCodeNoStateAccess, "state access not configured"}
+
+	// ErrNotEpochBoundary is returned when an epoch query names a block that
+	// does not close an epoch.
+	ErrNotEpochBoundary = &Error{ErrCodeNotEpochBoundary, "block is not an epoch boundary"}
+
+	// ErrIndexNotSynced is returned while the delegation index still lags
+	// the chain head and cannot answer queries consistently.
+	ErrIndexNotSynced = &Error{ErrCodeIndexNotSynced, "delegation index not synced to head"}
+
+	// ErrInvalidEpochRange is returned for malformed epoch range parameters.
+	ErrInvalidEpochRange = &Error{ErrCodeInvalidEpoch, "invalid epoch range"}
+
+	// ErrWalkBudgetExceeded is returned when an RPC query walking the dpos
+	// tries exhausts its execution budget before finishing; the chunked
+	// endpoints resume such walks with a continuation token.
+	ErrWalkBudgetExceeded = &Error{ErrCodeWalkBudget, "trie walk budget exceeded, use a chunked query"}
+
+	// ErrReplicaMode is returned when sealing is attempted on a node running
+	// as a read replica.
+	ErrReplicaMode = &Error{ErrCodeReplicaMode, "node runs as a read replica"}
+)
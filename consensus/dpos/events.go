@@ -0,0 +1,141 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// The engine publishes its consensus lifecycle as typed events on dedicated
+// feeds, so in-process subsystems — metrics exporters, ethstats feeders,
+// custom plugins — can react to consensus activity without scraping the log.
+// The monitor feeds established elsewhere (seal failures, fork alerts,
+// detected evidence proofs) remain; the events here cover the everyday
+// lifecycle.
+
+// SlotStartedEvent is published when a slot owned by the local validator
+// opens and sealing begins. Slots of other validators pass without an event,
+// since the engine only observes them through the blocks they produce.
+type SlotStartedEvent struct {
+	Slot      int64          // Unix time the slot begins at
+	Validator common.Address // Local validator the slot belongs to
+}
+
+// BlockSealedEvent is published after the local validator signed a block.
+type BlockSealedEvent struct {
+	Number    uint64
+	Hash      common.Hash
+	Slot      int64 // Unix time of the slot the block was sealed into
+	Validator common.Address
+}
+
+// SlotMissedEvent is published for a slot that produced no block, derived
+// from the timestamp gap a verified block leaves to its parent.
+type SlotMissedEvent struct {
+	Slot      int64          // Unix time of the missed slot
+	Validator common.Address // Validator that failed to seal it
+}
+
+// EpochElectedEvent is published when an epoch transition executed by this
+// node installed a new validator set.
+type EpochElectedEvent struct {
+	Epoch      uint64
+	Validators []common.Address // Final ordered validator set
+}
+
+// ValidatorKickedEvent is published for each validator an epoch transition
+// removed for inactivity.
+type ValidatorKickedEvent struct {
+	Epoch     uint64
+	Validator common.Address
+	MintCnt   int64 // Blocks the validator minted in the closed epoch
+}
+
+// EvidenceFoundEvent is published when a double-sign proof against a
+// validator is assembled.
+type EvidenceFoundEvent struct {
+	Evidence *types.HeaderEvidence
+}
+
+// SubscribeSlotStarted subscribes to the openings of the local validator's
+// sealing slots.
+func (d *Dpos) SubscribeSlotStarted(ch chan<- SlotStartedEvent) event.Subscription {
+	return d.slotStartedFeed.Subscribe(ch)
+}
+
+// SubscribeBlockSealed subscribes to the blocks the local validator seals.
+func (d *Dpos) SubscribeBlockSealed(ch chan<- BlockSealedEvent) event.Subscription {
+	return d.blockSealedFeed.Subscribe(ch)
+}
+
+// SubscribeSlotMissed subscribes to the slots the network let pass without a
+// block.
+func (d *Dpos) SubscribeSlotMissed(ch chan<- SlotMissedEvent) event.Subscription {
+	return d.slotMissedFeed.Subscribe(ch)
+}
+
+// SubscribeEpochElected subscribes to the validator sets installed by epoch
+// transitions.
+func (d *Dpos) SubscribeEpochElected(ch chan<- EpochElectedEvent) event.Subscription {
+	return d.epochElectedFeed.Subscribe(ch)
+}
+
+// SubscribeValidatorKicked subscribes to the inactivity kickouts of epoch
+// transitions.
+func (d *Dpos) SubscribeValidatorKicked(ch chan<- ValidatorKickedEvent) event.Subscription {
+	return d.validatorKickedFeed.Subscribe(ch)
+}
+
+// SubscribeEvidenceFound subscribes to assembled double-sign proofs.
+func (d *Dpos) SubscribeEvidenceFound(ch chan<- EvidenceFoundEvent) event.Subscription {
+	return d.evidenceFoundFeed.Subscribe(ch)
+}
+
+// maxMissedSlotReports caps the SlotMissed events derived from a single
+// block, so a node catching up after downtime does not flood its subscribers
+// with the whole gap.
+const maxMissedSlotReports = 16
+
+// reportMissedSlots publishes a SlotMissed event for each slot between the
+// parent and the verified header that produced no block, newest first and
+// capped at maxMissedSlotReports.
+func (d *Dpos) reportMissedSlots(epochContext *EpochContext, parent, header *types.Header) {
+	count := 0
+	interval := epochContext.blockInterval()
+	for slot := header.Time.Int64() - interval; slot > parent.Time.Int64() && count < maxMissedSlotReports; slot -= interval {
+		validator, err := epochContext.lookupValidator(slot)
+		if err != nil {
+			return
+		}
+		d.slotMissedFeed.Send(SlotMissedEvent{Slot: slot, Validator: validator})
+		count++
+	}
+}
+
+// reportEpochTransition publishes the outcome of an executed epoch
+// transition: the elected validator set and each kicked validator.
+func (d *Dpos) reportEpochTransition(record *ElectionRecord) {
+	if record == nil || len(record.Validators) == 0 {
+		return
+	}
+	d.epochElectedFeed.Send(EpochElectedEvent{Epoch: uint64(record.Epoch), Validators: record.Validators})
+	for _, kicked := range record.Kicked {
+		d.validatorKickedFeed.Send(ValidatorKickedEvent{Epoch: uint64(record.Epoch), Validator: kicked.Address, MintCnt: kicked.MintCnt})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Hot-standby failover lets two nodes share one validator identity without
+// double-signing: both hold the key and gossip heartbeats, but each process
+// stamps its heartbeats with a random holder id. A node in standby mode
+// stands aside as long as it hears fresh heartbeats for its own validator
+// from another holder, and takes sealing over once the primary falls silent
+// for a lease timeout. When the primary recovers, its heartbeats resume and
+// the standby yields again.
+
+// leaseTimeout is the number of seconds of heartbeat silence from the other
+// holder of the validator key after which a standby takes sealing over. It
+// deliberately matches the interval after which the network at large would
+// report the validator offline.
+func (d *Dpos) leaseTimeout() int64 {
+	return d.heartbeatTimeout()
+}
+
+// SetStandby switches the engine in or out of hot-standby mode. In standby
+// mode the node seals only while no other holder of the validator key is
+// heard on the network.
+func (d *Dpos) SetStandby(standby bool) {
+	d.mu.Lock()
+	d.standby = standby
+	d.mu.Unlock()
+	if standby {
+		log.Info("Dpos sealing in hot-standby mode", "holder", d.holderID)
+	}
+}
+
+// shouldStandAside reports whether a standby node has to yield the current
+// slot because the primary holder of the validator key is still alive.
+func (d *Dpos) shouldStandAside(now int64) bool {
+	d.mu.RLock()
+	standby := d.standby
+	d.mu.RUnlock()
+	if !standby {
+		return false
+	}
+	d.heartbeatsMu.RLock()
+	lease := d.peerHolderSeen
+	d.heartbeatsMu.RUnlock()
+	if lease == 0 {
+		// No other holder heard yet, nothing to yield to
+		return false
+	}
+	if now-lease > d.leaseTimeout() {
+		// The primary fell silent for a full lease, take over
+		log.Warn("Primary validator silent, standby taking over sealing", "silence", time.Duration(now-lease)*time.Second)
+		return false
+	}
+	return true
+}
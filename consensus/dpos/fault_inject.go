@@ -0,0 +1,146 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Fault injection makes the local validator misbehave on purpose: sit out
+// owned slots, seal late or sign conflicting headers. It exists so kickout,
+// slashing and failover can be exercised end to end on private test networks;
+// it must never be enabled on a network whose stake matters.
+
+// faultInjection holds the armed failure directives of the local validator.
+type faultInjection struct {
+	skipEvery  uint64        // Sit out every Nth owned slot, zero to never skip
+	delay      time.Duration // Hold the sealed block back this long before returning it
+	doubleSign bool          // Sign a conflicting header alongside every sealed block
+	sealed     uint64        // Owned slots counted since injection was armed
+}
+
+// SetFaultInjection arms the engine's testing-only failure injector from a
+// comma separated spec: "skip=N" sits out every Nth owned slot, "delay=D"
+// holds each sealed block back for the duration D, and "doublesign" signs a
+// conflicting header alongside every sealed block, surfacing the resulting
+// evidence through the same pipeline the fork monitor feeds. An empty spec
+// disarms the injector.
+func (d *Dpos) SetFaultInjection(spec string) error {
+	if strings.TrimSpace(spec) == "" {
+		d.mu.Lock()
+		d.faults = nil
+		d.mu.Unlock()
+		return nil
+	}
+	inject := &faultInjection{}
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		switch {
+		case field == "doublesign":
+			inject.doubleSign = true
+		case strings.HasPrefix(field, "skip="):
+			n, err := strconv.ParseUint(field[len("skip="):], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid skip count in fault injection spec: %v", err)
+			}
+			inject.skipEvery = n
+		case strings.HasPrefix(field, "delay="):
+			duration, err := time.ParseDuration(field[len("delay="):])
+			if err != nil {
+				return fmt.Errorf("invalid delay in fault injection spec: %v", err)
+			}
+			inject.delay = duration
+		default:
+			return fmt.Errorf("unknown fault injection directive %q", field)
+		}
+	}
+	d.mu.Lock()
+	d.faults = inject
+	d.mu.Unlock()
+	log.Warn("DPOS FAULT INJECTION ARMED, NEVER USE OUTSIDE TESTING", "spec", spec)
+	return nil
+}
+
+// injectSkip counts an owned slot and reports whether the injector wants it
+// sat out.
+func (d *Dpos) injectSkip() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.faults == nil || d.faults.skipEvery == 0 {
+		return false
+	}
+	d.faults.sealed++
+	return d.faults.sealed%d.faults.skipEvery == 0
+}
+
+// injectDelay returns how long the injector wants the sealed block held back,
+// zero when no delay is armed.
+func (d *Dpos) injectDelay() time.Duration {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.faults == nil {
+		return 0
+	}
+	return d.faults.delay
+}
+
+// injectDoubleSign signs a conflicting variant of the just-sealed header when
+// the injector asks for one and pushes the resulting evidence into the
+// pending evidence store, where integration tests pick it up for reporting
+// just like proofs the fork monitor detects on live networks.
+func (d *Dpos) injectDoubleSign(header *types.Header, signer accounts.Account, signFn SignerFn) {
+	d.mu.RLock()
+	armed := d.faults != nil && d.faults.doubleSign
+	d.mu.RUnlock()
+	if !armed {
+		return
+	}
+	// Flip a vanity byte so the conflict shares the slot but not the hash
+	conflict := types.CopyHeader(header)
+	conflict.Extra[extraVanity-1] ^= 0xff
+
+	sighash, err := signFn(signer, sigHash(conflict).Bytes())
+	if err != nil {
+		log.Warn("Fault injection failed to sign conflicting header", "err", err)
+		return
+	}
+	copy(conflict.Extra[len(conflict.Extra)-extraSeal:], sighash)
+
+	evidence := &types.HeaderEvidence{Header1: types.CopyHeader(header), Header2: conflict}
+	id := evidence.ID()
+
+	d.forkMu.Lock()
+	if d.pendingEvidence == nil {
+		d.pendingEvidence = make(map[common.Hash]*types.HeaderEvidence)
+	}
+	if len(d.pendingEvidence) < maxPendingEvidence {
+		d.pendingEvidence[id] = evidence
+	}
+	d.forkMu.Unlock()
+
+	d.evidenceFeed.Send(evidence)
+	d.evidenceFoundFeed.Send(EvidenceFoundEvent{Evidence: evidence})
+	log.Warn("Fault injection signed conflicting header", "number", header.Number, "hashes", []common.Hash{header.Hash(), conflict.Hash()})
+}
@@ -0,0 +1,215 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Malicious-fork monitor. Headers announced by peers pass through the
+// monitor before import: a validator signing two distinct headers for the
+// same slot is caught as ready-to-report double-sign evidence, and a growing
+// chain of non-canonical validator blocks is flagged once it exceeds the
+// configured length. Both raise events and metrics, so operators and tooling
+// learn about an attack from the announcements themselves instead of from
+// the damage.
+
+// Bounds on the monitor state, so a peer flooding crafted announcements
+// cannot grow it without limit.
+const (
+	maxWatchedSlots    = 4096 // Sealed headers remembered per validator slot
+	maxWatchedBranches = 1024 // Non-canonical branch heads tracked
+	maxPendingEvidence = 64   // Detected double-sign proofs retained for reporting
+)
+
+var (
+	doubleSignCounter = metrics.NewRegisteredCounter("dpos/forkwatch/doublesigns", nil)
+	forkLengthGauge   = metrics.NewRegisteredGauge("dpos/forkwatch/length", nil)
+)
+
+// ForkAlert describes a suspicious observation of the fork monitor: either a
+// validator double-signing its slot or a non-canonical branch outgrowing the
+// configured length. Alerts are published to subscribers and logged.
+type ForkAlert struct {
+	DoubleSign bool           `json:"doubleSign"` // Whether the alert is a double-sign, a long fork otherwise
+	Validator  common.Address `json:"validator"`  // Offending validator for a double-sign, sealer of the branch head otherwise
+	Number     uint64         `json:"number"`     // Block number the alert concerns
+	Hashes     []common.Hash  `json:"hashes"`     // Conflicting header hashes, or the branch head
+	Length     uint64         `json:"length"`     // Length of the non-canonical branch, zero for double-signs
+}
+
+// SetForkMonitor enables the malicious-fork monitor, alerting once a
+// non-canonical branch grows to the given number of blocks. Zero disables the
+// monitor entirely.
+func (d *Dpos) SetForkMonitor(forkLength uint64) {
+	d.forkMu.Lock()
+	defer d.forkMu.Unlock()
+
+	d.forkWatch = forkLength
+	if forkLength > 0 && d.slotSeen == nil {
+		d.slotSeen = make(map[slotKey]*types.Header)
+		d.forkBranches = make(map[common.Hash]uint64)
+		d.pendingEvidence = make(map[common.Hash]*types.HeaderEvidence)
+	}
+}
+
+// slotKey identifies the sealing slot of a validator; two distinct headers
+// under the same key are a double-sign.
+type slotKey struct {
+	validator common.Address
+	time      int64
+}
+
+// ObserveRemoteHeader feeds a header announced by a peer into the fork
+// monitor. The header has not been verified yet, so nothing here may trust
+// it beyond the signature checks evidence verification performs itself.
+func (d *Dpos) ObserveRemoteHeader(chain consensus.ChainReader, header *types.Header) {
+	if header == nil || header.Number == nil || header.Validator == (common.Address{}) {
+		return
+	}
+	d.forkMu.Lock()
+	defer d.forkMu.Unlock()
+	if d.forkWatch == 0 {
+		return
+	}
+	d.observeDoubleSign(header)
+	d.observeBranch(chain, header)
+}
+
+// observeDoubleSign remembers which header each validator sealed for its slot
+// and turns a second, conflicting header into double-sign evidence. The
+// caller holds forkMu.
+func (d *Dpos) observeDoubleSign(header *types.Header) {
+	key := slotKey{validator: header.Validator, time: header.Time.Int64()}
+	prev, seen := d.slotSeen[key]
+	if !seen {
+		if len(d.slotSeen) >= maxWatchedSlots {
+			d.pruneWatchedSlots(header.Time.Int64())
+		}
+		d.slotSeen[key] = types.CopyHeader(header)
+		return
+	}
+	if prev.Hash() == header.Hash() {
+		return
+	}
+	evidence := &types.HeaderEvidence{Header1: prev, Header2: types.CopyHeader(header)}
+	offender, err := evidence.Verify()
+	if err != nil {
+		// A conflicting header that fails evidence verification carries a
+		// bogus seal; the announcing peer is lying, not the validator
+		log.Debug("Discarding unverifiable double-sign candidate", "validator", header.Validator, "err", err)
+		return
+	}
+	id := evidence.ID()
+	if _, known := d.pendingEvidence[id]; known {
+		return
+	}
+	if len(d.pendingEvidence) < maxPendingEvidence {
+		d.pendingEvidence[id] = evidence
+	}
+	doubleSignCounter.Inc(1)
+	log.Warn("Validator double-sign detected", "validator", offender, "number", header.Number.Uint64(),
+		"hash1", prev.Hash(), "hash2", header.Hash())
+	d.evidenceFeed.Send(evidence)
+	d.evidenceFoundFeed.Send(EvidenceFoundEvent{Evidence: evidence})
+	d.forkFeed.Send(ForkAlert{
+		DoubleSign: true,
+		Validator:  offender,
+		Number:     header.Number.Uint64(),
+		Hashes:     []common.Hash{prev.Hash(), header.Hash()},
+	})
+}
+
+// observeBranch tracks chains of announced headers that are not part of the
+// canonical chain and alerts when one outgrows the configured length. The
+// caller holds forkMu.
+func (d *Dpos) observeBranch(chain consensus.ChainReader, header *types.Header) {
+	number := header.Number.Uint64()
+	// Headers ahead of the local head are ordinary sync traffic, and so are
+	// canonical ones; only a header competing with a canonical block at its
+	// height extends a fork
+	current := chain.CurrentHeader()
+	if current == nil || number > current.Number.Uint64() {
+		return
+	}
+	if canonical := chain.GetHeaderByNumber(number); canonical != nil && canonical.Hash() == header.Hash() {
+		delete(d.forkBranches, header.Hash())
+		return
+	}
+	if len(d.forkBranches) >= maxWatchedBranches {
+		d.forkBranches = make(map[common.Hash]uint64)
+	}
+	length := d.forkBranches[header.ParentHash] + 1
+	d.forkBranches[header.Hash()] = length
+	forkLengthGauge.Update(int64(length))
+	if length == d.forkWatch {
+		log.Warn("Long non-canonical fork observed", "length", length, "head", header.Hash(),
+			"number", number, "sealer", header.Validator)
+		d.forkFeed.Send(ForkAlert{
+			Validator: header.Validator,
+			Number:    number,
+			Hashes:    []common.Hash{header.Hash()},
+			Length:    length,
+		})
+	}
+}
+
+// pruneWatchedSlots drops remembered headers whose slot lies more than an
+// epoch behind the given time; a conflicting header that old could no longer
+// be reported anyway. The caller holds forkMu.
+func (d *Dpos) pruneWatchedSlots(now int64) {
+	for key := range d.slotSeen {
+		if key.time < now-d.epochInterval() {
+			delete(d.slotSeen, key)
+		}
+	}
+	// Under a flood of future-dated slots nothing may be prunable; start
+	// over rather than grow without bound
+	if len(d.slotSeen) >= maxWatchedSlots {
+		d.slotSeen = make(map[slotKey]*types.Header)
+	}
+}
+
+// PendingEvidence returns the double-sign proofs the monitor has detected
+// but that have not been consumed yet, ready to be wrapped into
+// ReportEvidence transactions.
+func (d *Dpos) PendingEvidence() []*types.HeaderEvidence {
+	d.forkMu.Lock()
+	defer d.forkMu.Unlock()
+
+	pending := make([]*types.HeaderEvidence, 0, len(d.pendingEvidence))
+	for _, evidence := range d.pendingEvidence {
+		pending = append(pending, evidence)
+	}
+	return pending
+}
+
+// SubscribeForkAlerts subscribes to the alerts of the malicious-fork monitor.
+func (d *Dpos) SubscribeForkAlerts(ch chan<- ForkAlert) event.Subscription {
+	return d.forkFeed.Subscribe(ch)
+}
+
+// SubscribeDetectedEvidence subscribes to the double-sign proofs the monitor
+// detects, so tooling can report them on chain automatically.
+func (d *Dpos) SubscribeDetectedEvidence(ch chan<- *types.HeaderEvidence) event.Subscription {
+	return d.evidenceFeed.Subscribe(ch)
+}
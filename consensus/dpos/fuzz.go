@@ -0,0 +1,116 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build gofuzz
+
+package dpos
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Fuzz is the go-fuzz entry point for the election logic. The input is read
+// as a candidate set with balances; the election runs twice over it and the
+// invariants the rest of the consensus relies on are checked: the validator
+// count never exceeds the configured size, no validator appears twice, every
+// validator was a candidate and the same input yields the same ordered output.
+func Fuzz(data []byte) int {
+	if len(data) < 2 {
+		return 0
+	}
+	count := int(data[0])%64 + 1
+	balances := make([]int64, count)
+	for i := 0; i < count; i++ {
+		balances[i] = int64(data[1+i%(len(data)-1)]) + 1
+	}
+	first, err := fuzzElect(balances)
+	if err != nil {
+		// Elections below the safe size legitimately fail without a
+		// previous validator set to fall back to
+		if count < safeSizeOf(nil) {
+			return 0
+		}
+		panic(fmt.Sprintf("election failed with %d candidates: %v", count, err))
+	}
+	if len(first) > maxValidatorSize {
+		panic(fmt.Sprintf("elected %d validators, limit %d", len(first), maxValidatorSize))
+	}
+	seen := make(map[common.Address]bool)
+	for _, validator := range first {
+		if seen[validator] {
+			panic(fmt.Sprintf("duplicate validator %x", validator))
+		}
+		seen[validator] = true
+		if validator.Big().Int64() > int64(count) {
+			panic(fmt.Sprintf("validator %x was never a candidate", validator))
+		}
+	}
+	second, err := fuzzElect(balances)
+	if err != nil {
+		panic(fmt.Sprintf("election not deterministic, second run failed: %v", err))
+	}
+	if len(first) != len(second) {
+		panic(fmt.Sprintf("election not deterministic: %d != %d validators", len(first), len(second)))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			panic(fmt.Sprintf("election not deterministic at %d: %x != %x", i, first[i], second[i]))
+		}
+	}
+	return 1
+}
+
+// fuzzElect runs one election over self-voting candidates with the given
+// balances, on a fresh in-memory state and dpos context.
+func fuzzElect(balances []int64) ([]common.Address, error) {
+	db := ethdb.NewMemDatabase()
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(db))
+	if err != nil {
+		return nil, err
+	}
+	dposContext, err := types.NewDposContext(trie.NewDatabase(db))
+	if err != nil {
+		return nil, err
+	}
+	for i, balance := range balances {
+		candidate := common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.AddBalance(candidate, big.NewInt(balance))
+		if err := dposContext.BecomeCandidate(candidate); err != nil {
+			return nil, err
+		}
+		if err := dposContext.Delegate(candidate, candidate); err != nil {
+			return nil, err
+		}
+	}
+	ec := &EpochContext{
+		TimeStamp:   epochInterval,
+		DposContext: dposContext,
+		statedb:     statedb,
+	}
+	genesis := &types.Header{Number: big.NewInt(0), Time: big.NewInt(0), Difficulty: big.NewInt(1), DposContext: &types.DposContextProto{}}
+	parent := &types.Header{Number: big.NewInt(1), Time: big.NewInt(epochInterval - blockInterval), Difficulty: big.NewInt(1), DposContext: &types.DposContextProto{}}
+	if err := ec.tryElect(genesis, parent); err != nil {
+		return nil, err
+	}
+	return dposContext.GetValidators()
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	// HeartbeatInterval is the default cadence at which an authorized
+	// validator broadcasts liveness heartbeats to its peers. Engines follow
+	// their network's block interval through HeartbeatPeriod instead.
+	HeartbeatInterval = time.Duration(blockInterval) * time.Second
+
+	// HeartbeatTimeout is the default number of seconds of silence after
+	// which a validator is reported as offline; heartbeatTimeout resolves
+	// the effective value for an engine.
+	HeartbeatTimeout = int64(3 * blockInterval)
+)
+
+const (
+	// heartbeatDrift is the maximum clock difference, in seconds, between
+	// the local node and the timestamp of an accepted heartbeat.
+	heartbeatDrift = int64(60)
+)
+
+var (
+	errHeartbeatExpired = errors.New("heartbeat outside the accepted time window")
+	errHeartbeatBadSig  = errors.New("heartbeat signature does not match validator")
+)
+
+// Heartbeat is a signed liveness announcement gossiped between peers, letting
+// operators notice an offline validator before its slot comes up instead of
+// discovering the failure through missed blocks.
+type Heartbeat struct {
+	Validator common.Address // Validator announcing its liveness
+	Time      uint64         // Unix time the heartbeat was signed at
+	Holder    uint64         // Random id of the process holding the key, for hot-standby failover
+	Sig       []byte         // Signature of the validator over the content
+}
+
+// SigHash returns the hash covered by the heartbeat signature. It also serves
+// as the gossip identity of the heartbeat for deduplication.
+func (hb *Heartbeat) SigHash() (hash common.Hash) {
+	hasher := sha3.NewKeccak256()
+	rlp.Encode(hasher, []interface{}{hb.Validator, hb.Time, hb.Holder})
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// Verify checks that the heartbeat was signed by the validator it announces
+// and that its timestamp is within the accepted clock drift.
+func (hb *Heartbeat) Verify(now int64) error {
+	if delta := now - int64(hb.Time); delta > heartbeatDrift || delta < -heartbeatDrift {
+		return errHeartbeatExpired
+	}
+	pubkey, err := crypto.Ecrecover(hb.SigHash().Bytes(), hb.Sig)
+	if err != nil {
+		return err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	if signer != hb.Validator {
+		return errHeartbeatBadSig
+	}
+	return nil
+}
+
+// SignHeartbeat creates a heartbeat signed by the engine's authorized signer.
+// It returns nil without error when no signer is authorized, e.g. on non
+// validating nodes.
+func (d *Dpos) SignHeartbeat(now int64) (*Heartbeat, error) {
+	d.mu.RLock()
+	signer, signFn := d.signer, d.signFn
+	d.mu.RUnlock()
+	if signFn == nil {
+		return nil, nil
+	}
+	hb := &Heartbeat{Validator: signer, Time: uint64(now), Holder: d.holderID}
+	sig, err := signFn(accounts.Account{Address: signer}, hb.SigHash().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	hb.Sig = sig
+	return hb, nil
+}
+
+// RecordHeartbeat stores the announcement time of a verified heartbeat,
+// keeping only the most recent one per validator.
+func (d *Dpos) RecordHeartbeat(hb *Heartbeat) {
+	d.mu.RLock()
+	signer := d.signer
+	d.mu.RUnlock()
+
+	d.heartbeatsMu.Lock()
+	if hb.Time > d.heartbeats[hb.Validator] {
+		d.heartbeats[hb.Validator] = hb.Time
+	}
+	// A heartbeat for our own validator from another process means the other
+	// holder of the key is alive, renewing its hot-standby lease
+	if hb.Validator == signer && hb.Holder != d.holderID && int64(hb.Time) > d.peerHolderSeen {
+		d.peerHolderSeen = int64(hb.Time)
+	}
+	d.heartbeatsMu.Unlock()
+	// Heartbeats are stamped at signing time on the validator's clock,
+	// which makes them skew samples for ours
+	d.clock.observe(int64(hb.Time), time.Now().Unix(), d.slotInterval())
+}
+
+// LastHeartbeat returns the unix time of the most recent heartbeat heard from
+// the given validator, or zero if none was ever received.
+func (d *Dpos) LastHeartbeat(validator common.Address) uint64 {
+	d.heartbeatsMu.RLock()
+	defer d.heartbeatsMu.RUnlock()
+	return d.heartbeats[validator]
+}
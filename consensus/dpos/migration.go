@@ -0,0 +1,130 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Clique-to-dpos migration. A chain that started out under proof-of-authority
+// switches engines at the configured fork block: everything below the fork is
+// verified and sealed by the wrapped clique engine, everything from the fork
+// on by dpos. The transition block builds its dpos context in-place from the
+// signers authorized in the latest clique checkpoint below the fork - each
+// signer becomes a self-voting candidate and the group forms the first
+// validator set - so the chain enters dpos with the exact committee that ran
+// it under clique and the first election replaces it organically.
+
+// defaultCliqueEpoch is the clique checkpoint interval assumed when the
+// clique configuration leaves its epoch length unset.
+const defaultCliqueEpoch = 30000
+
+// errNoLegacyEngine is returned when a migration context is requested on a
+// chain that runs dpos from genesis.
+var errNoLegacyEngine = errors.New("no legacy engine configured")
+
+// SetLegacyEngine wires the consensus engine that governed the chain before
+// the dpos fork block into the dpos engine. Calls concerning pre-fork headers
+// are delegated to it wholesale.
+func (d *Dpos) SetLegacyEngine(engine consensus.Engine, forkBlock uint64) {
+	d.mu.Lock()
+	d.legacy = engine
+	d.forkBlock = forkBlock
+	d.mu.Unlock()
+}
+
+// LegacyEngine returns the pre-fork consensus engine, or nil when the chain
+// runs dpos from genesis.
+func (d *Dpos) LegacyEngine() consensus.Engine {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.legacy
+}
+
+// isLegacy reports whether the block at the given number is governed by the
+// pre-fork engine rather than by dpos.
+func (d *Dpos) isLegacy(number uint64) bool {
+	return d.legacy != nil && number < d.forkBlock
+}
+
+// MigrationContext builds the dpos context the chain starts from at the
+// clique-to-dpos fork. The signers authorized in the latest clique checkpoint
+// at or below the given parent become the initial candidates, each delegating
+// to itself, and together form the first validator set. The context is
+// deterministic, so the producer of the transition block and every verifier
+// derive the same roots.
+func (d *Dpos) MigrationContext(chain consensus.ChainReader, parent *types.Header) (*types.DposContext, error) {
+	if d.legacy == nil {
+		return nil, errNoLegacyEngine
+	}
+	signers, err := cliqueCheckpointSigners(chain, parent)
+	if err != nil {
+		return nil, err
+	}
+	dposContext, err := types.NewDposContext(d.triedb)
+	if err != nil {
+		return nil, err
+	}
+	for _, signer := range signers {
+		if err := dposContext.BecomeCandidate(signer); err != nil {
+			return nil, err
+		}
+		if err := dposContext.Delegate(signer, signer); err != nil {
+			return nil, err
+		}
+	}
+	if err := dposContext.SetValidators(signers); err != nil {
+		return nil, err
+	}
+	log.Info("Migrating clique signers to dpos validators", "fork", d.forkBlock, "validators", len(signers))
+	return dposContext, nil
+}
+
+// cliqueCheckpointSigners extracts the authorized signer list from the latest
+// clique checkpoint header at or below the given header, walking parent
+// hashes so the lookup stays on the right chain during a reorg.
+func cliqueCheckpointSigners(chain consensus.ChainReader, header *types.Header) ([]common.Address, error) {
+	epoch := uint64(defaultCliqueEpoch)
+	if config := chain.Config().Clique; config != nil && config.Epoch > 0 {
+		epoch = config.Epoch
+	}
+	checkpoint := header
+	for target := header.Number.Uint64() - header.Number.Uint64()%epoch; checkpoint.Number.Uint64() > target; {
+		checkpoint = chain.GetHeader(checkpoint.ParentHash, checkpoint.Number.Uint64()-1)
+		if checkpoint == nil {
+			return nil, consensus.ErrUnknownAncestor
+		}
+	}
+	if len(checkpoint.Extra) < extraVanity+extraSeal {
+		return nil, fmt.Errorf("clique checkpoint %d extra-data too short for a signer list", checkpoint.Number.Uint64())
+	}
+	list := checkpoint.Extra[extraVanity : len(checkpoint.Extra)-extraSeal]
+	if len(list) == 0 || len(list)%common.AddressLength != 0 {
+		return nil, fmt.Errorf("clique checkpoint %d carries a malformed signer list", checkpoint.Number.Uint64())
+	}
+	signers := make([]common.Address, len(list)/common.AddressLength)
+	for i := range signers {
+		copy(signers[i][:], list[i*common.AddressLength:])
+	}
+	return signers, nil
+}
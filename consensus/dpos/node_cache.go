@@ -0,0 +1,93 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	lru "github.com/hashicorp/golang-lru"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// approxNodeSize is the assumed average size of a dpos trie node, used to
+// convert the configured cache budget in megabytes into an entry count for
+// the LRU.
+const approxNodeSize = 384
+
+// Hit statistics of the dpos trie node cache, so the configured size can be
+// judged against the actual working set.
+var (
+	nodeCacheHitMeter  = metrics.NewRegisteredMeter("dpos/nodecache/hit", nil)
+	nodeCacheMissMeter = metrics.NewRegisteredMeter("dpos/nodecache/miss", nil)
+)
+
+// nodeCacheDB is a read-through LRU in front of the database backing the dpos
+// context tries. Vote-heavy blocks and elections walk the same delegate and
+// candidate nodes over and over; keeping the hot set here spares both the
+// disk and the main state cache. Trie nodes are keyed by their hash, so
+// cached entries never go stale and writes may be cached eagerly.
+type nodeCacheDB struct {
+	ethdb.Database
+	cache *lru.Cache
+}
+
+// newNodeCacheDB wraps the given database with an LRU sized to roughly the
+// given number of megabytes.
+func newNodeCacheDB(db ethdb.Database, sizeMB int) *nodeCacheDB {
+	cache, _ := lru.New(sizeMB * 1024 * 1024 / approxNodeSize)
+	return &nodeCacheDB{Database: db, cache: cache}
+}
+
+// Get retrieves the node blob for the given key, preferring the cache over
+// the backing database.
+func (db *nodeCacheDB) Get(key []byte) ([]byte, error) {
+	if blob, ok := db.cache.Get(string(key)); ok {
+		nodeCacheHitMeter.Mark(1)
+		return blob.([]byte), nil
+	}
+	blob, err := db.Database.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	nodeCacheMissMeter.Mark(1)
+	db.cache.Add(string(key), blob)
+	return blob, nil
+}
+
+// Has reports whether the key exists, answering from the cache when possible.
+func (db *nodeCacheDB) Has(key []byte) (bool, error) {
+	if db.cache.Contains(string(key)) {
+		return true, nil
+	}
+	return db.Database.Has(key)
+}
+
+// Put writes the node blob through to the backing database and caches it,
+// since hash-keyed nodes written now are the ones read back next.
+func (db *nodeCacheDB) Put(key []byte, value []byte) error {
+	if err := db.Database.Put(key, value); err != nil {
+		return err
+	}
+	db.cache.Add(string(key), value)
+	return nil
+}
+
+// Delete evicts the key from the cache and forwards the deletion.
+func (db *nodeCacheDB) Delete(key []byte) error {
+	db.cache.Remove(string(key))
+	return db.Database.Delete(key)
+}
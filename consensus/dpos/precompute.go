@@ -0,0 +1,87 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// electionPrecomputeTimer measures the speculative election replays run ahead
+// of epoch boundaries, for comparison against the real election timers.
+var electionPrecomputeTimer = metrics.NewRegisteredTimer("dpos/election/precompute", nil)
+
+// maybePrecomputeElection speculatively replays the upcoming epoch election
+// on throwaway copies of the head's context and state when the next slot
+// crosses an epoch boundary. The replay's outcome is discarded — the real
+// election still runs inside the boundary block — but it pulls the candidate,
+// delegate and vote nodes the tally walks into the node cache during the idle
+// part of the previous slot, so the boundary block does not risk blowing its
+// slot deadline on a cold cache on large networks. New votes can only arrive
+// with a new head block, which triggers a fresh replay of its own; nothing
+// stale is ever consumed.
+func (d *Dpos) maybePrecomputeElection(chain consensus.ChainReader, head *types.Header) {
+	if d.stateFn == nil || head.DposContext == nil {
+		return
+	}
+	// Only the slot ahead of an epoch boundary warrants the work
+	next := d.nextSlot(time.Now().Unix())
+	if head.Time.Int64()/d.epochInterval() == next/d.epochInterval() {
+		return
+	}
+	// One replay per head is enough, however often the trigger fires
+	hash := head.Hash()
+	d.precomputeMu.Lock()
+	done := d.precomputedHead == hash
+	d.precomputedHead = hash
+	d.precomputeMu.Unlock()
+	if done {
+		return
+	}
+	genesis := chain.GetHeaderByNumber(0)
+	if genesis == nil {
+		return
+	}
+	go func() {
+		start := time.Now()
+		dposContext, err := types.NewDposContextFromProto(d.triedb, head.DposContext)
+		if err != nil {
+			return
+		}
+		statedb, err := d.stateFn(head.Root)
+		if err != nil {
+			return
+		}
+		epochContext := &EpochContext{
+			TimeStamp:   next,
+			DposContext: dposContext,
+			statedb:     statedb,
+			config:      d.config,
+		}
+		if err := epochContext.tryElect(genesis, head); err != nil {
+			log.Debug("Speculative election replay failed", "head", hash, "err", err)
+			return
+		}
+		electionPrecomputeTimer.UpdateSince(start)
+		log.Debug("Precomputed epoch election", "head", hash, "elapsed", common.PrettyDuration(time.Since(start)))
+	}()
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests the emission schedule: the default reward, halving eras and the hard
+// cap cutting the tail of the emission.
+func TestCalcBlockReward(t *testing.T) {
+	// Without a schedule every block mints the default reward
+	plain := &params.DposConfig{}
+	if got := calcBlockReward(plain, 1); got.Cmp(blockReward) != 0 {
+		t.Errorf("default reward: got %v, want %v", got, blockReward)
+	}
+	if got := calcBlockReward(plain, 1000000); got.Cmp(blockReward) != 0 {
+		t.Errorf("default reward at height 1000000: got %v, want %v", got, blockReward)
+	}
+
+	// Halving shifts the reward right once per era
+	halving := &params.DposConfig{BlockReward: big.NewInt(1000), HalvingInterval: 10}
+	for _, tt := range []struct {
+		number uint64
+		want   int64
+	}{
+		{1, 1000}, {9, 1000}, {10, 500}, {19, 500}, {20, 250}, {100, 0},
+	} {
+		if got := calcBlockReward(halving, tt.number); got.Int64() != tt.want {
+			t.Errorf("halving reward at %d: got %v, want %d", tt.number, got, tt.want)
+		}
+	}
+
+	// The hard cap truncates the last rewarded block and zeroes everything
+	// after it
+	capped := &params.DposConfig{BlockReward: big.NewInt(100), HardCap: big.NewInt(250)}
+	for _, tt := range []struct {
+		number uint64
+		want   int64
+	}{
+		{1, 100}, {2, 100}, {3, 50}, {4, 0}, {5, 0},
+	} {
+		if got := calcBlockReward(capped, tt.number); got.Int64() != tt.want {
+			t.Errorf("capped reward at %d: got %v, want %d", tt.number, got, tt.want)
+		}
+	}
+}
+
+// Tests that the block reward splits between the validator and its delegators
+// along the declared commission and the delegators' stake, and that the mint
+// is folded into the issuance counter.
+func TestAccumulateRewardsSplit(t *testing.T) {
+	env := newElectionEnv(t)
+	validator := testAddr(0)
+	delegator := testAddr(1)
+	env.addCandidate(t, validator, 100)
+	env.delegate(t, delegator, validator, 300)
+	if err := env.dposContext.SetCommission(validator, 1, 0); err != nil {
+		t.Fatalf("failed to set commission: %v", err)
+	}
+
+	header := testHeader(1, 0)
+	header.Coinbase = validator
+	config := &params.ChainConfig{Dpos: &params.DposConfig{BlockReward: big.NewInt(4000)}}
+	accumulateRewards(config, env.statedb, header, env.dposContext, nil)
+
+	// Commission keeps 1% of 4000 = 40 with the validator; the pool of 3960
+	// splits 1:3 between the self stake of 100 and the delegation of 300, so
+	// the validator collects 100+40+990, the delegator 300+2970.
+	if got := env.statedb.GetBalance(validator); got.Int64() != 1130 {
+		t.Errorf("validator balance: got %v, want 1130", got)
+	}
+	if got := env.statedb.GetBalance(delegator); got.Int64() != 3270 {
+		t.Errorf("delegator balance: got %v, want 3270", got)
+	}
+	if got := env.dposContext.Issuance(); got.Int64() != 4000 {
+		t.Errorf("issuance: got %v, want 4000", got)
+	}
+}
+
+// Tests that a registered reward recipient collects the validator's cut while
+// the delegator payouts stay untouched.
+func TestAccumulateRewardsRecipient(t *testing.T) {
+	env := newElectionEnv(t)
+	validator := testAddr(0)
+	recipient := testAddr(7)
+	env.addCandidate(t, validator, 0)
+	if err := env.dposContext.SetRewardRecipient(validator, recipient); err != nil {
+		t.Fatalf("failed to set reward recipient: %v", err)
+	}
+
+	header := testHeader(1, 0)
+	header.Coinbase = validator
+	config := &params.ChainConfig{Dpos: &params.DposConfig{BlockReward: big.NewInt(4000)}}
+	accumulateRewards(config, env.statedb, header, env.dposContext, nil)
+
+	// Without staked delegators the whole reward is the validator's cut and
+	// lands with the recipient
+	if got := env.statedb.GetBalance(recipient); got.Int64() != 4000 {
+		t.Errorf("recipient balance: got %v, want 4000", got)
+	}
+	if got := env.statedb.GetBalance(validator); got.Sign() != 0 {
+		t.Errorf("validator balance: got %v, want 0", got)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// epochSchedule caches the decoded slot schedule of one epoch for batch seal
+// verification: the elected validator set, the optional stake-weighted slot
+// layout, the block interval the epoch runs at and the sealing keys active
+// for its validators. Resolving one costs a handful of trie lookups; checking
+// a header against it costs none, which is what makes verifying thousands of
+// sequential headers of a fixed-slot chain cheap.
+type epochSchedule struct {
+	epoch      int64 // Epoch the schedule covers, boundary slot of the next one included
+	epochLen   int64 // Epoch length of the network in seconds
+	interval   int64
+	drift      int64
+	validators []common.Address
+	layout     []byte
+	context    *types.DposContext                // Backing context for the sealing key lookups
+	signers    map[common.Address]common.Address // Sealing keys resolved so far
+}
+
+// covers reports whether the schedule answers slot lookups for the given
+// timestamp. The boundary slot of the following epoch is included: its block
+// is sealed by the outgoing set, the election it carries only taking effect
+// for the slots after it.
+func (s *epochSchedule) covers(now int64) bool {
+	switch epoch := now / s.epochLen; {
+	case epoch == s.epoch:
+		return true
+	case epoch == s.epoch+1 && now%s.epochLen == 0:
+		return true
+	}
+	return false
+}
+
+// signer returns the sealing key active for the given validator in the given
+// epoch, resolving it from the backing context on first use. Only the covered
+// epoch is cached; the boundary slot of the following one asks the context
+// directly.
+func (s *epochSchedule) signer(validator common.Address, epoch int64) common.Address {
+	if epoch != s.epoch {
+		return s.context.ActiveSigner(validator, epoch)
+	}
+	if signer, ok := s.signers[validator]; ok {
+		return signer
+	}
+	signer := s.context.ActiveSigner(validator, epoch)
+	s.signers[validator] = signer
+	return signer
+}
+
+// resolveSchedule decodes the slot schedule in force after the given parent
+// header, anchored on the parent's epoch.
+func (d *Dpos) resolveSchedule(parent *types.Header) (*epochSchedule, error) {
+	if parent.DposContext == nil {
+		return nil, errMissingDposContext
+	}
+	dposContext, err := types.NewDposContextFromProto(d.triedb, parent.DposContext)
+	if err != nil {
+		return nil, err
+	}
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		return nil, err
+	}
+	if len(validators) == 0 {
+		return nil, ErrEmptyValidatorSet
+	}
+	interval := blockIntervalOf(d.config)
+	if adopted := dposContext.BlockInterval(); adopted > 0 {
+		interval = adopted
+	}
+	drift := int64(0)
+	if d.config != nil {
+		drift = int64(d.config.MaxBlockDrift)
+	}
+	return &epochSchedule{
+		epoch:      parent.Time.Int64() / d.epochInterval(),
+		epochLen:   d.epochInterval(),
+		interval:   interval,
+		drift:      drift,
+		validators: validators,
+		layout:     dposContext.GetEpochLayout(),
+		context:    dposContext,
+		signers:    make(map[common.Address]common.Address),
+	}, nil
+}
+
+// verifySealFast checks the seal of the index'th batched header against the
+// cached epoch schedule, resolving a fresh schedule from the header's parent
+// when the cached one does not cover the header's slot. The exhaustive
+// per-header path settles what the schedule cannot: the migration transition
+// block, whose set comes from the clique signers, and any disagreement, since
+// a key rotation recorded after the schedule's anchor legitimately moves the
+// expected sealing key mid-epoch. Headers whose schedule cannot be resolved
+// at all pass with only the header checks — below a fast-sync pivot the dpos
+// tries are not present, and past an epoch boundary inside the batch the
+// electing block has not executed yet; both get their context vetted against
+// the sealed roots when the blocks execute.
+func (d *Dpos) verifySealFast(chain consensus.ChainReader, headers []*types.Header, index int, sched **epochSchedule) error {
+	header := headers[index]
+	if header.Number.Uint64() == 0 {
+		return errUnknownBlock
+	}
+	var parent *types.Header
+	if index > 0 {
+		parent = headers[index-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	if d.legacy != nil && header.Number.Uint64() == d.forkBlock {
+		*sched = nil
+		return d.verifySeal(chain, header, parent)
+	}
+	now := header.Time.Int64()
+	if *sched == nil || !(*sched).covers(now) {
+		resolved, err := d.resolveSchedule(parent)
+		if err != nil {
+			*sched = nil
+			log.Debug("Batch seal check skipped, schedule unresolvable", "number", header.Number, "err", err)
+			return nil
+		}
+		*sched = resolved
+	}
+	s := *sched
+	validator, err := slotValidator(s.validators, s.layout, s.interval, s.epochLen, s.drift, now)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(header.Validator.Bytes(), validator.Bytes()) {
+		return d.arbitrateSeal(chain, header, parent, ErrInvalidBlockValidator)
+	}
+	signer, err := ecrecover(header, d.signatures)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(signer.Bytes(), s.signer(validator, now/s.epochLen).Bytes()) {
+		return d.arbitrateSeal(chain, header, parent, ErrMismatchSignerAndValidator)
+	}
+	return nil
+}
+
+// arbitrateSeal settles a fast-path rejection with the exhaustive per-header
+// check when the parent's context is reachable, since the cached schedule can
+// trail legitimate mid-epoch mutations of it. An unreachable context leaves
+// the fast-path verdict standing.
+func (d *Dpos) arbitrateSeal(chain consensus.ChainReader, header, parent *types.Header, verdict error) error {
+	if parent.DposContext == nil {
+		return verdict
+	}
+	if _, err := types.NewDposContextFromProto(d.triedb, parent.DposContext); err != nil {
+		return verdict
+	}
+	return d.verifySeal(chain, header, parent)
+}
@@ -0,0 +1,44 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import "testing"
+
+// TestEpochScheduleCovers exercises the validity window of a cached epoch
+// schedule: all slots of the covered epoch plus the boundary slot of the
+// following one, which is still sealed by the outgoing set.
+func TestEpochScheduleCovers(t *testing.T) {
+	sched := &epochSchedule{epoch: 5, epochLen: epochInterval}
+	tests := []struct {
+		now  int64
+		want bool
+	}{
+		{5 * epochInterval, true},                  // First slot of the covered epoch
+		{5*epochInterval + blockInterval, true},    // Interior slot
+		{6*epochInterval - blockInterval, true},    // Last slot of the covered epoch
+		{6 * epochInterval, true},                  // Boundary slot, sealed by the outgoing set
+		{6*epochInterval + blockInterval, false},   // First post-election slot of the next epoch
+		{7 * epochInterval, false},                 // Boundary of an epoch further out
+		{5*epochInterval - blockInterval, false},   // Slot of the previous epoch
+		{4 * epochInterval, false},                 // Boundary into the covered epoch, sealed by its predecessor
+	}
+	for _, tt := range tests {
+		if got := sched.covers(tt.now); got != tt.want {
+			t.Errorf("covers(%d): got %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// sealFailEscalation is the number of consecutive failed slots after which
+// repeated warnings escalate to errors.
+const sealFailEscalation = 3
+
+// sealFailuresGauge exports the current streak of consecutive failed slots.
+var sealFailuresGauge = metrics.NewRegisteredGauge("dpos/seal/failures", nil)
+
+// SealFailure describes one failed sealing attempt of the local validator,
+// published to subscribers so orchestration can react to a signer that broke
+// instead of tailing logs.
+type SealFailure struct {
+	Number      uint64 `json:"number"`      // Block number the seal attempt was for
+	Error       string `json:"error"`       // Failure reported by the signer
+	Consecutive int    `json:"consecutive"` // Length of the current failure streak
+	Paused      bool   `json:"paused"`      // Whether this failure auto-paused sealing
+}
+
+// reportSealFailure records a failed sealing attempt: the failure streak and
+// its metric grow, alerts escalate from warning to error and, when the
+// configured pause threshold is crossed, sealing is suspended until an
+// operator resumes it. The failure is published to subscribers either way.
+func (d *Dpos) reportSealFailure(number uint64, err error) {
+	d.mu.Lock()
+	d.sealFails++
+	fails := d.sealFails
+	pause := d.config != nil && d.config.SealFailurePause > 0 && uint64(fails) >= d.config.SealFailurePause && !d.sealPaused
+	if pause {
+		d.sealPaused = true
+	}
+	d.mu.Unlock()
+	sealFailuresGauge.Update(int64(fails))
+
+	switch {
+	case pause:
+		log.Error("Sealing auto-paused after repeated failures", "number", number, "consecutive", fails, "err", err)
+	case fails >= sealFailEscalation:
+		log.Error("Block sealing keeps failing", "number", number, "consecutive", fails, "err", err)
+	default:
+		log.Warn("Block sealing failed", "number", number, "consecutive", fails, "err", err)
+	}
+	d.sealFeed.Send(SealFailure{Number: number, Error: err.Error(), Consecutive: fails, Paused: pause})
+}
+
+// reportSealSuccess resets the failure streak after a successful seal. An
+// auto-pause is deliberately not lifted here: it can only trigger again after
+// an operator resumed sealing, so a success while paused cannot happen.
+func (d *Dpos) reportSealSuccess() {
+	d.mu.Lock()
+	d.sealFails = 0
+	d.mu.Unlock()
+	sealFailuresGauge.Update(0)
+}
+
+// PauseSealing suspends sealing until an operator resumes it, for subsystems
+// that detect a condition under which producing blocks would be unsafe, such
+// as inconsistent consensus state after a sync.
+func (d *Dpos) PauseSealing(reason string) {
+	d.mu.Lock()
+	paused := !d.sealPaused
+	d.sealPaused = true
+	d.mu.Unlock()
+	if paused {
+		log.Error("Sealing paused", "reason", reason)
+	}
+}
+
+// SealingPaused reports whether sealing is suspended, either auto-paused
+// after repeated failures or paused by a subsystem.
+func (d *Dpos) SealingPaused() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.sealPaused
+}
+
+// ResumeSealing lifts a pause and resets the failure streak, to be called by
+// the operator once the underlying problem is fixed.
+func (d *Dpos) ResumeSealing() {
+	d.mu.Lock()
+	resumed := d.sealPaused
+	d.sealPaused = false
+	d.sealFails = 0
+	d.mu.Unlock()
+	sealFailuresGauge.Update(0)
+	if resumed {
+		log.Info("Sealing resumed by operator")
+	}
+}
+
+// SubscribeSealFailures subscribes to sealing failure reports of the local
+// validator.
+func (d *Dpos) SubscribeSealFailures(ch chan<- SealFailure) event.Subscription {
+	return d.sealFeed.Subscribe(ch)
+}
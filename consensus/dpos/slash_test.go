@@ -0,0 +1,170 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// setMintCnt records the number of blocks a validator minted in an epoch,
+// bypassing block processing the way the churn simulation does.
+func (env *electionEnv) setMintCnt(t *testing.T, epoch int64, validator common.Address, count int64) {
+	epochBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(epochBytes, uint64(epoch))
+	cntBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(cntBytes, uint64(count))
+	if err := env.dposContext.MintCntTrie().TryUpdate(append(epochBytes, validator.Bytes()...), cntBytes); err != nil {
+		t.Fatalf("failed to record mint count: %v", err)
+	}
+}
+
+// slashEnv builds an epoch worth of history: the given validators form the
+// active set, each backed by a balance of 1000, and each minted the given
+// number of blocks during epoch zero.
+func slashEnv(t *testing.T, config *params.DposConfig, minted []int64) (*electionEnv, *EpochContext, []common.Address) {
+	env := newElectionEnv(t)
+	validators := make([]common.Address, len(minted))
+	for i := range minted {
+		validators[i] = testAddr(i)
+		env.addCandidate(t, validators[i], 1000)
+		env.setMintCnt(t, 0, validators[i], minted[i])
+	}
+	if err := env.dposContext.SetValidators(validators); err != nil {
+		t.Fatalf("failed to set validators: %v", err)
+	}
+	ec := env.epochContext(epochInterval)
+	ec.config = config
+	return env, ec, validators
+}
+
+// Tests that a validator missing more than the configured share of its slots
+// loses the configured balance fraction, while the penalty is split across
+// the validators that stayed active.
+func TestSlashInactiveValidators(t *testing.T) {
+	defer func(prev int64) { timeOfFirstBlock = prev }(timeOfFirstBlock)
+	timeOfFirstBlock = 0
+
+	config := &params.DposConfig{SlashMissedPercent: 50, SlashPenaltyPercent: 10}
+	// Each of the three validators is expected to produce a third of the
+	// epoch's slots; two deliver in full, the last stays dark
+	share := epochInterval / blockInterval / 3
+	env, ec, validators := slashEnv(t, config, []int64{share, share, 0})
+
+	if err := ec.slashInactiveValidators(0); err != nil {
+		t.Fatalf("slashing failed: %v", err)
+	}
+	// The dark validator loses 10% of its 1000 balance, split evenly across
+	// the two active ones
+	if got := env.statedb.GetBalance(validators[2]); got.Int64() != 900 {
+		t.Errorf("slashed balance: got %v, want 900", got)
+	}
+	for _, validator := range validators[:2] {
+		if got := env.statedb.GetBalance(validator); got.Int64() != 1050 {
+			t.Errorf("active balance of %x: got %v, want 1050", validator, got)
+		}
+	}
+}
+
+// Tests that a validator staying above the missed-slot threshold is left
+// alone, so ordinary hiccups do not cost stake.
+func TestSlashThreshold(t *testing.T) {
+	defer func(prev int64) { timeOfFirstBlock = prev }(timeOfFirstBlock)
+	timeOfFirstBlock = 0
+
+	config := &params.DposConfig{SlashMissedPercent: 50, SlashPenaltyPercent: 10}
+	// The last validator misses exactly half of its slots, which is the
+	// threshold itself and thus still tolerated
+	share := epochInterval / blockInterval / 3
+	env, ec, validators := slashEnv(t, config, []int64{share, share, share / 2})
+
+	if err := ec.slashInactiveValidators(0); err != nil {
+		t.Fatalf("slashing failed: %v", err)
+	}
+	for _, validator := range validators {
+		if got := env.statedb.GetBalance(validator); got.Int64() != 1000 {
+			t.Errorf("balance of %x: got %v, want untouched 1000", validator, got)
+		}
+	}
+}
+
+// Tests that penalties are burned out of the issuance counter instead of
+// being redistributed when the chain is configured to do so.
+func TestSlashBurn(t *testing.T) {
+	defer func(prev int64) { timeOfFirstBlock = prev }(timeOfFirstBlock)
+	timeOfFirstBlock = 0
+
+	config := &params.DposConfig{SlashMissedPercent: 50, SlashPenaltyPercent: 10, BurnSlashed: true}
+	share := epochInterval / blockInterval / 3
+	env, ec, validators := slashEnv(t, config, []int64{share, share, 0})
+	if err := env.dposContext.AddIssuance(big.NewInt(3000)); err != nil {
+		t.Fatalf("failed to seed issuance: %v", err)
+	}
+
+	if err := ec.slashInactiveValidators(0); err != nil {
+		t.Fatalf("slashing failed: %v", err)
+	}
+	if got := env.statedb.GetBalance(validators[2]); got.Int64() != 900 {
+		t.Errorf("slashed balance: got %v, want 900", got)
+	}
+	for _, validator := range validators[:2] {
+		if got := env.statedb.GetBalance(validator); got.Int64() != 1000 {
+			t.Errorf("active balance of %x: got %v, want untouched 1000", validator, got)
+		}
+	}
+	if got := env.dposContext.Issuance(); got.Int64() != 2900 {
+		t.Errorf("issuance after burn: got %v, want 2900", got)
+	}
+}
+
+// Tests that correlated failures within one operator group amplify the
+// penalty of every failing member of the group.
+func TestSlashCorrelationPenalty(t *testing.T) {
+	defer func(prev int64) { timeOfFirstBlock = prev }(timeOfFirstBlock)
+	timeOfFirstBlock = 0
+
+	config := &params.DposConfig{SlashMissedPercent: 50, SlashPenaltyPercent: 10, CorrelationPenalty: true}
+	share := epochInterval / blockInterval / 4
+	env, ec, validators := slashEnv(t, config, []int64{share, share, 0, 0})
+	// The two failing validators declare the same operator, so their shared
+	// infrastructure failing doubles both penalties
+	group := common.BytesToHash([]byte("operator"))
+	for _, validator := range validators[2:] {
+		if err := env.dposContext.SetOperatorGroup(validator, group); err != nil {
+			t.Fatalf("failed to set operator group: %v", err)
+		}
+	}
+
+	if err := ec.slashInactiveValidators(0); err != nil {
+		t.Fatalf("slashing failed: %v", err)
+	}
+	for _, validator := range validators[2:] {
+		if got := env.statedb.GetBalance(validator); got.Int64() != 800 {
+			t.Errorf("slashed balance of %x: got %v, want 800", validator, got)
+		}
+	}
+	// The combined 400 splits evenly across the two active validators
+	for _, validator := range validators[:2] {
+		if got := env.statedb.GetBalance(validator); got.Int64() != 1200 {
+			t.Errorf("active balance of %x: got %v, want 1200", validator, got)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/consensus/dpos/election"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the per-delegator weight cap clips each individual vote rather
+// than the candidate's aggregate: several capped delegators still outweigh a
+// single whale.
+func TestVoteWeightCap(t *testing.T) {
+	env := newElectionEnv(t)
+	whale := testAddr(10)
+	crowd := testAddr(11)
+
+	// The whale backs one candidate with a single huge stake; three small
+	// delegators back the other with 200 each
+	env.addCandidate(t, whale, 0)
+	env.addCandidate(t, crowd, 0)
+	env.delegate(t, testAddr(0), whale, 10000)
+	for i := 1; i < 4; i++ {
+		env.delegate(t, testAddr(i), crowd, 200)
+	}
+
+	ec := env.epochContext(0)
+	ec.config = &params.DposConfig{MaxVoteWeight: big.NewInt(150)}
+	raw, votes, err := ec.tallyVotes()
+	if err != nil {
+		t.Fatalf("tally failed: %v", err)
+	}
+	// Raw stake is tracked uncapped, the effective weight per delegator is
+	// clipped at 150
+	if got := raw[whale]; got.Int64() != 10000 {
+		t.Errorf("whale raw stake: got %v, want 10000", got)
+	}
+	if got := votes[whale]; got.Int64() != 150 {
+		t.Errorf("whale votes: got %v, want capped 150", got)
+	}
+	if got := raw[crowd]; got.Int64() != 600 {
+		t.Errorf("crowd raw stake: got %v, want 600", got)
+	}
+	if got := votes[crowd]; got.Int64() != 450 {
+		t.Errorf("crowd votes: got %v, want 3x150", got)
+	}
+}
+
+// Tests the stake-to-weight curves of the election package: sqrt, capped
+// quadratic and the linear default, with and without the per-delegator cap.
+func TestVoteWeightCurves(t *testing.T) {
+	for _, tt := range []struct {
+		mode  string
+		cap   int64 // zero means no cap
+		stake int64
+		want  int64
+	}{
+		// Linear is the default and an unknown mode falls back to it
+		{"", 0, 400, 400},
+		{"no-such-mode", 0, 400, 400},
+		{election.WeightLinear, 100, 400, 100},
+		// Sqrt weighs with the square root, still subject to the cap
+		{election.WeightSqrt, 0, 400, 20},
+		{election.WeightSqrt, 0, 401, 20},
+		{election.WeightSqrt, 15, 400, 15},
+		// Quadratic-capped counts stake linearly up to the cap and the
+		// excess only with its square root
+		{election.WeightQuadCap, 100, 80, 80},
+		{election.WeightQuadCap, 100, 100, 100},
+		{election.WeightQuadCap, 100, 200, 110},
+		{election.WeightQuadCap, 0, 200, 200},
+	} {
+		var cap *big.Int
+		if tt.cap != 0 {
+			cap = big.NewInt(tt.cap)
+		}
+		got := election.Weight(tt.mode, cap, big.NewInt(tt.stake))
+		if got.Int64() != tt.want {
+			t.Errorf("weight(%q, cap %d, stake %d): got %v, want %d", tt.mode, tt.cap, tt.stake, got, tt.want)
+		}
+	}
+}
+
+// Tests that the configured curve is applied per delegator during the tally,
+// so sqrt weighting rewards a spread of backers over a concentrated stake.
+func TestVoteWeightSqrtTally(t *testing.T) {
+	env := newElectionEnv(t)
+	whale := testAddr(10)
+	crowd := testAddr(11)
+
+	env.addCandidate(t, whale, 0)
+	env.addCandidate(t, crowd, 0)
+	env.delegate(t, testAddr(0), whale, 900)
+	for i := 1; i < 4; i++ {
+		env.delegate(t, testAddr(i), crowd, 300)
+	}
+
+	ec := env.epochContext(0)
+	ec.config = &params.DposConfig{VoteWeightMode: election.WeightSqrt}
+	_, votes, err := ec.tallyVotes()
+	if err != nil {
+		t.Fatalf("tally failed: %v", err)
+	}
+	// sqrt(900) = 30 for the whale, 3*sqrt(300) = 3*17 for the crowd
+	if got := votes[whale]; got.Int64() != 30 {
+		t.Errorf("whale votes: got %v, want 30", got)
+	}
+	if got := votes[crowd]; got.Int64() != 51 {
+		t.Errorf("crowd votes: got %v, want 51", got)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import "time"
+
+// Limits of a single RPC-triggered trie walk. A public provider exposing the
+// dpos namespace must not let one query pin a CPU on a mainnet-scale trie, so
+// walks started over RPC are cut off at whichever limit trips first and the
+// caller is pointed at the chunked endpoints. Walks run by consensus itself
+// are never metered.
+const (
+	// walkBudgetTimeout bounds the wall time of one metered walk.
+	walkBudgetTimeout = 5 * time.Second
+
+	// walkBudgetEntries bounds the number of trie entries one metered walk
+	// may visit across all tries it touches.
+	walkBudgetEntries = 1000000
+
+	// walkBudgetStride is the number of entries between two clock checks;
+	// reading the clock on every entry would dominate the iteration itself.
+	walkBudgetStride = 1024
+)
+
+// walkBudget meters a trie walk triggered over RPC against a wall time and an
+// entry count limit. A nil budget meters nothing, so consensus code paths
+// share the walking code without ever being cut off.
+type walkBudget struct {
+	deadline time.Time
+	left     int
+}
+
+// newWalkBudget starts a budget covering one RPC request.
+func newWalkBudget() *walkBudget {
+	return &walkBudget{deadline: time.Now().Add(walkBudgetTimeout), left: walkBudgetEntries}
+}
+
+// spend charges one visited trie entry against the budget, erroring once
+// either the entry or the time limit is exhausted.
+func (b *walkBudget) spend() error {
+	if b == nil {
+		return nil
+	}
+	b.left--
+	if b.left <= 0 {
+		return ErrWalkBudgetExceeded
+	}
+	if b.left%walkBudgetStride == 0 && time.Now().After(b.deadline) {
+		return ErrWalkBudgetExceeded
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dpos
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// VoteWeigher is the plugin interface for custom vote weight calculations.
+// Forks of this codebase register an implementation under a mode name of
+// their own and select it through VoteWeightMode in the chain config, giving
+// them NFT-based, KYC-gated or otherwise bespoke weighting without patching
+// the vote tally itself. The weight computed from a stake flows straight
+// into the election, so every node of such a network must register the same
+// implementation under the same name — the engine refuses to construct when
+// the configured mode resolves to nothing, rather than silently falling back
+// and forking the chain.
+type VoteWeigher interface {
+	// Weight translates a single delegator's stake into election weight. It
+	// must be deterministic and must not retain or mutate the stake.
+	Weight(stake *big.Int) *big.Int
+}
+
+var (
+	voteWeighersMu sync.RWMutex
+	voteWeighers   = make(map[string]VoteWeigher)
+)
+
+// RegisterVoteWeigher makes a custom vote weight implementation selectable
+// through the given VoteWeightMode value. It is meant to be called from an
+// init function, before any engine is constructed, and panics when the mode
+// collides with a built-in curve or an earlier registration: both are
+// programming errors no node should start with.
+func RegisterVoteWeigher(mode string, weigher VoteWeigher) {
+	if weigher == nil {
+		panic("dpos: nil vote weigher registered")
+	}
+	if builtinWeightMode(mode) {
+		panic(fmt.Sprintf("dpos: vote weight mode %q is built in", mode))
+	}
+	voteWeighersMu.Lock()
+	defer voteWeighersMu.Unlock()
+	if _, dup := voteWeighers[mode]; dup {
+		panic(fmt.Sprintf("dpos: vote weight mode %q registered twice", mode))
+	}
+	voteWeighers[mode] = weigher
+}
+
+// lookupVoteWeigher resolves a mode name to its registered implementation,
+// or nil for built-in and unknown modes.
+func lookupVoteWeigher(mode string) VoteWeigher {
+	voteWeighersMu.RLock()
+	defer voteWeighersMu.RUnlock()
+	return voteWeighers[mode]
+}
+
+// builtinWeightMode reports whether the mode names one of the weight curves
+// shipped with the engine.
+func builtinWeightMode(mode string) bool {
+	switch mode {
+	case "", VoteWeightLinear, VoteWeightSqrt, VoteWeightQuadCap, VoteWeightAverage:
+		return true
+	}
+	return false
+}
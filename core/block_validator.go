@@ -101,6 +101,17 @@ func (v *BlockValidator) ValidateState(block, parent *types.Block, statedb *stat
 	return nil
 }
 
+// ValidateDposState validates that the dpos context mutated while processing
+// the block matches the trie roots sealed into the block header.
+func (v *BlockValidator) ValidateDposState(block *types.Block) error {
+	header := block.Header()
+	localProto := block.DposCtx().ToProto()
+	if localProto.Root() != header.DposContext.Root() {
+		return fmt.Errorf("invalid dpos root (remote: %x local: %x)", header.DposContext.Root(), localProto.Root())
+	}
+	return nil
+}
+
 // CalcGasLimit computes the gas limit of the next block after parent.
 // This is miner strategy, not consensus protocol.
 func CalcGasLimit(parent *types.Block) uint64 {
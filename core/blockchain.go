@@ -70,6 +70,7 @@ type CacheConfig struct {
 	Disabled      bool          // Whether to disable trie write caching (archive node)
 	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
 	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	DposArchive   bool          // Whether to retain every historical dpos trie version for dpos queries at any block
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -92,6 +93,7 @@ type BlockChain struct {
 
 	db     ethdb.Database // Low level persistent database to store final content in
 	triegc *prque.Prque   // Priority queue mapping block numbers to tries to gc
+	dposgc *prque.Prque   // Priority queue mapping block numbers to dpos tries to gc
 	gcproc time.Duration  // Accumulates canonical block processing for trie dumping
 
 	hc            *HeaderChain
@@ -152,6 +154,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		cacheConfig:  cacheConfig,
 		db:           db,
 		triegc:       prque.New(),
+		dposgc:       prque.New(),
 		stateCache:   state.NewDatabase(db),
 		quit:         make(chan struct{}),
 		bodyCache:    bodyCache,
@@ -174,6 +177,11 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if bc.genesisBlock == nil {
 		return nil, ErrNoGenesis
 	}
+	// Refuse to start from a genesis block the validators never attested;
+	// a misconfigured copy would silently form a forked network
+	if err := verifyGenesisAttestations(bc.genesisBlock.Header(), chainConfig); err != nil {
+		return nil, err
+	}
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
@@ -386,6 +394,30 @@ func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
 	return state.New(root, bc.stateCache)
 }
 
+// StateCache returns the caching database underpinning the blockchain instance.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
+}
+
+// DposContextAt opens the dpos context pinned by the given header.
+func (bc *BlockChain) DposContextAt(header *types.Header) (*types.DposContext, error) {
+	return types.NewDposContextFromProto(bc.stateCache.TrieDB(), header.DposContext)
+}
+
+// MinGasPrice returns the validator-voted network gas price floor at the
+// current head, zero on chains whose validators never voted one in.
+func (bc *BlockChain) MinGasPrice() uint64 {
+	header := bc.CurrentBlock().Header()
+	if header.DposContext == nil {
+		return 0
+	}
+	dposContext, err := bc.DposContextAt(header)
+	if err != nil {
+		return 0
+	}
+	return dposContext.MinGasPrice()
+}
+
 // Reset purges the entire blockchain, restoring it to its genesis state.
 func (bc *BlockChain) Reset() error {
 	return bc.ResetWithGenesisBlock(bc.genesisBlock)
@@ -669,11 +701,24 @@ func (bc *BlockChain) Stop() {
 				if err := triedb.Commit(recent.Root(), true); err != nil {
 					log.Error("Failed to commit recent state trie", "err", err)
 				}
+				// The dpos tries of the recent blocks must survive the
+				// restart with their state, or the node cannot validate
+				// blocks on top of them
+				if proto := recent.Header().DposContext; proto != nil && !bc.cacheConfig.DposArchive {
+					for _, root := range proto.Roots() {
+						if err := triedb.Commit(root, false); err != nil {
+							log.Error("Failed to commit recent dpos trie", "err", err)
+						}
+					}
+				}
 			}
 		}
 		for !bc.triegc.Empty() {
 			triedb.Dereference(bc.triegc.PopItem().(common.Hash))
 		}
+		for !bc.dposgc.Empty() {
+			triedb.Dereference(bc.dposgc.PopItem().(common.Hash))
+		}
 		if size, _ := triedb.Size(); size != 0 {
 			log.Error("Dangling trie nodes after full cleanup")
 		}
@@ -905,6 +950,23 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	}
 	triedb := bc.stateCache.TrieDB()
 
+	// Persist or garbage collect the dpos context tries. Archive retention
+	// flushes every version to disk so historical dpos queries work at any
+	// block; otherwise the versions ride along with the state GC and only
+	// the periodically committed ones survive.
+	if block.DposCtx() != nil {
+		if bc.cacheConfig.Disabled || bc.cacheConfig.DposArchive {
+			if err := block.DposCtx().Flush(); err != nil {
+				return NonStatTy, err
+			}
+		} else {
+			for _, root := range block.DposCtx().Roots() {
+				triedb.Reference(root, common.Hash{}) // metadata reference to keep trie alive
+				bc.dposgc.Push(root, -float32(block.NumberU64()))
+			}
+		}
+	}
+
 	// If we're running an archive node, always flush
 	if bc.cacheConfig.Disabled {
 		if err := triedb.Commit(root, false); err != nil {
@@ -937,6 +999,14 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 				}
 				// Flush an entire trie and restart the counters
 				triedb.Commit(header.Root, true)
+				// The dpos tries of the committed block must survive the GC
+				// with it, or a restart could replay the chain onto a state
+				// whose consensus context is gone
+				if header.DposContext != nil {
+					for _, root := range header.DposContext.Roots() {
+						triedb.Commit(root, false)
+					}
+				}
 				lastWrite = chosen
 				bc.gcproc = 0
 			}
@@ -949,6 +1019,14 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 				}
 				triedb.Dereference(root.(common.Hash))
 			}
+			for !bc.dposgc.Empty() {
+				root, number := bc.dposgc.Pop()
+				if uint64(-number) > chosen {
+					bc.dposgc.Push(root, number)
+					break
+				}
+				triedb.Dereference(root.(common.Hash))
+			}
 		}
 	}
 	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
@@ -973,6 +1051,9 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		rawdb.WriteTxLookupEntries(batch, block)
 		rawdb.WritePreimages(batch, block.NumberU64(), state.Preimages())
 
+		// Move the delegation index to the new head in the same batch
+		bc.updateDelegationIndex(batch, currentBlock.Header(), block.Header())
+
 		status = CanonStatTy
 	} else {
 		status = SideStatTy
@@ -1143,8 +1224,30 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
+		// Open the dpos context of the parent for the block to mutate; at
+		// the clique-to-dpos fork the parent carries none and the context
+		// is built from the clique signers instead
+		if bc.chainConfig.IsDpos(block.Number()) {
+			if migrator, ok := bc.engine.(DposMigrator); ok && !bc.chainConfig.IsDpos(new(big.Int).Sub(block.Number(), common.Big1)) {
+				block.DposContext, err = migrator.MigrationContext(bc, parent.Header())
+			} else {
+				block.DposContext, err = types.NewDposContextFromProto(bc.stateCache.TrieDB(), parent.Header().DposContext)
+			}
+			if err != nil {
+				return i, events, coalescedLogs, err
+			}
+		}
+		// Warm the dpos tries the block's vote and candidate transactions
+		// will touch, concurrently with their execution
+		var stopPrefetch func()
+		if bc.chainConfig.IsDpos(block.Number()) {
+			stopPrefetch = bc.prefetchDposContext(block, parent.Header())
+		}
 		// Process block using the parent state as reference point.
 		receipts, logs, usedGas, err := bc.processor.Process(block, state, bc.vmConfig)
+		if stopPrefetch != nil {
+			stopPrefetch()
+		}
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
@@ -1155,6 +1258,13 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
 		}
+		// Validate the dpos context against the roots in the header
+		if bc.chainConfig.IsDpos(block.Number()) {
+			if err = bc.Validator().ValidateDposState(block); err != nil {
+				bc.reportBlock(block, receipts, err)
+				return i, events, coalescedLogs, err
+			}
+		}
 		proctime := time.Since(bstart)
 
 		// Write the block to the chain and get the status.
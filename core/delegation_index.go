@@ -0,0 +1,565 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// The delegation index materializes the reverse delegation mapping — which
+// delegators vote for a given candidate — in the chain database, so the
+// answer needs one read instead of a delegate trie prefix walk. It is
+// maintained in the same batch that switches the canonical head: the entries
+// are derived by diffing the delegate tries of the outgoing and incoming
+// head, which keeps the index correct across reorgs since the diff is taken
+// between the actual chains, not the import order. A head marker records the
+// block the index is synced to; whenever the marker does not match the head
+// being replaced — first start, fast sync, or a crash between batch and head
+// switch — the index is rebuilt from scratch off the new head's trie.
+
+// updateDelegationIndex brings the delegation index forward from oldHeader to
+// newHeader, writing the changed entries into the given batch. Failures only
+// log: the index is auxiliary and a stale head marker triggers a rebuild on
+// the next imported block, so an unreadable trie must not abort the import.
+func (bc *BlockChain) updateDelegationIndex(batch ethdb.Batch, oldHeader, newHeader *types.Header) {
+	if !bc.chainConfig.IsDpos(newHeader.Number) || newHeader.DposContext == nil {
+		return
+	}
+	if rawdb.ReadDelegationIndexHead(bc.db) != oldHeader.Hash() {
+		bc.rebuildDelegationIndex(batch, newHeader)
+		return
+	}
+	oldContext, err := bc.DposContextAt(oldHeader)
+	if err != nil {
+		log.Warn("Failed to open old head for delegation index", "hash", oldHeader.Hash(), "err", err)
+		return
+	}
+	newContext, err := bc.DposContextAt(newHeader)
+	if err != nil {
+		log.Warn("Failed to open new head for delegation index", "hash", newHeader.Hash(), "err", err)
+		return
+	}
+	added, err := delegationDiff(oldContext, newContext)
+	if err != nil {
+		log.Warn("Failed to diff delegate tries", "err", err)
+		return
+	}
+	removed, err := delegationDiff(newContext, oldContext)
+	if err != nil {
+		log.Warn("Failed to diff delegate tries", "err", err)
+		return
+	}
+	catalog := newDelegationCatalog(rawdb.ReadDelegationCatalog(bc.db))
+	for candidate := range mergedCandidates(added, removed) {
+		delegators := rawdb.ReadIndexedDelegators(bc.db, candidate)
+		set := make(map[common.Address]bool, len(delegators))
+		for _, delegator := range delegators {
+			set[delegator] = true
+		}
+		for _, delegator := range removed[candidate] {
+			delete(set, delegator)
+		}
+		for _, delegator := range added[candidate] {
+			set[delegator] = true
+		}
+		if len(set) == 0 {
+			rawdb.DeleteIndexedDelegators(batch, candidate)
+			catalog.remove(candidate)
+			continue
+		}
+		rawdb.WriteIndexedDelegators(batch, candidate, sortedAddresses(set))
+		catalog.add(candidate)
+	}
+	catalog.store(batch)
+	bc.updateVoteHistory(batch, oldHeader, newHeader, added, removed)
+	bc.updateCandidateAges(batch, oldContext, newContext, newHeader.Number.Uint64())
+	rawdb.WriteDelegationIndexHead(batch, newHeader.Hash())
+}
+
+// updateCandidateAges records the block a candidate registration was first
+// seen at and clears the record when the registration disappears, feeding the
+// age input of the candidate reputation score. A candidate that drops out and
+// re-registers starts its age over.
+func (bc *BlockChain) updateCandidateAges(batch ethdb.Batch, oldContext, newContext *types.DposContext, number uint64) {
+	added, err := candidateDiff(oldContext, newContext)
+	if err != nil {
+		log.Warn("Failed to diff candidate tries", "err", err)
+		return
+	}
+	removed, err := candidateDiff(newContext, oldContext)
+	if err != nil {
+		log.Warn("Failed to diff candidate tries", "err", err)
+		return
+	}
+	for _, candidate := range added {
+		if rawdb.ReadCandidateSince(bc.db, candidate) == 0 {
+			rawdb.WriteCandidateSince(batch, candidate, number)
+		}
+	}
+	for _, candidate := range removed {
+		rawdb.DeleteCandidateSince(batch, candidate)
+	}
+}
+
+// candidateDiff collects the candidate registrations present in b but not in
+// a, skipping the auxiliary entries sharing the trie.
+func candidateDiff(a, b *types.DposContext) ([]common.Address, error) {
+	diffIter, _ := trie.NewDifferenceIterator(a.CandidateTrie().NodeIterator(nil), b.CandidateTrie().NodeIterator(nil))
+	iter := trie.NewIterator(diffIter)
+	var diff []common.Address
+	for iter.Next() {
+		if len(iter.Key) != common.AddressLength {
+			continue
+		}
+		diff = append(diff, common.BytesToAddress(iter.Key))
+	}
+	return diff, iter.Err
+}
+
+// updateVoteHistory folds the delegate trie diffs of a head switch into the
+// per-account vote history log. Revocations are weighed with the account's
+// balance on the outgoing head — the stake the vote carried until it was
+// withdrawn — and new votes with the balance on the incoming one. The log is
+// best-effort bookkeeping: changes are attributed to the incoming head's
+// block even when a reorg switches several blocks at once, and an index
+// rebuild leaves no history behind for the blocks it skips.
+func (bc *BlockChain) updateVoteHistory(batch ethdb.Batch, oldHeader, newHeader *types.Header, added, removed map[common.Address][]common.Address) {
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	oldState, err := bc.StateAt(oldHeader.Root)
+	if err != nil {
+		log.Warn("Failed to open old head state for vote history", "hash", oldHeader.Hash(), "err", err)
+		return
+	}
+	newState, err := bc.StateAt(newHeader.Root)
+	if err != nil {
+		log.Warn("Failed to open new head state for vote history", "hash", newHeader.Hash(), "err", err)
+		return
+	}
+	block := newHeader.Number.Uint64()
+	changes := make(map[common.Address][]rawdb.VoteChange)
+	for candidate, delegators := range removed {
+		for _, delegator := range delegators {
+			changes[delegator] = append(changes[delegator], rawdb.VoteChange{
+				Block:     block,
+				Candidate: candidate,
+				Weight:    oldState.GetBalance(delegator),
+				Revoked:   true,
+			})
+		}
+	}
+	for candidate, delegators := range added {
+		for _, delegator := range delegators {
+			changes[delegator] = append(changes[delegator], rawdb.VoteChange{
+				Block:     block,
+				Candidate: candidate,
+				Weight:    newState.GetBalance(delegator),
+			})
+		}
+	}
+	for account, entries := range changes {
+		// Order the entries of one block deterministically: revocations
+		// first, then by candidate address
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Revoked != entries[j].Revoked {
+				return entries[i].Revoked
+			}
+			return bytes.Compare(entries[i].Candidate[:], entries[j].Candidate[:]) < 0
+		})
+		rawdb.WriteVoteHistory(batch, account, append(rawdb.ReadVoteHistory(bc.db, account), entries...))
+	}
+}
+
+// rebuildDelegationIndex discards the index and rebuilds it with a full walk
+// of the given header's delegate trie.
+func (bc *BlockChain) rebuildDelegationIndex(batch ethdb.Batch, header *types.Header) {
+	dposContext, err := bc.DposContextAt(header)
+	if err != nil {
+		log.Warn("Failed to open head for delegation index rebuild", "hash", header.Hash(), "err", err)
+		return
+	}
+	for _, candidate := range rawdb.ReadDelegationCatalog(bc.db) {
+		rawdb.DeleteIndexedDelegators(batch, candidate)
+	}
+	delegations := make(map[common.Address]map[common.Address]bool)
+	iter := trie.NewIterator(dposContext.DelegateTrie().NodeIterator(nil))
+	for iter.Next() {
+		if len(iter.Key) != 2*common.AddressLength {
+			continue
+		}
+		candidate := common.BytesToAddress(iter.Key[:common.AddressLength])
+		if delegations[candidate] == nil {
+			delegations[candidate] = make(map[common.Address]bool)
+		}
+		delegations[candidate][common.BytesToAddress(iter.Key[common.AddressLength:])] = true
+	}
+	if iter.Err != nil {
+		log.Warn("Failed to walk delegate trie for index rebuild", "err", iter.Err)
+		return
+	}
+	catalog := newDelegationCatalog(nil)
+	for candidate, delegators := range delegations {
+		rawdb.WriteIndexedDelegators(batch, candidate, sortedAddresses(delegators))
+		catalog.add(candidate)
+	}
+	catalog.store(batch)
+	// Candidates found on the new head are aged from the rebuild block, since
+	// their true registration blocks were never observed by this node
+	candIter := trie.NewIterator(dposContext.CandidateTrie().NodeIterator(nil))
+	for candIter.Next() {
+		if len(candIter.Key) != common.AddressLength {
+			continue
+		}
+		if candidate := common.BytesToAddress(candIter.Key); rawdb.ReadCandidateSince(bc.db, candidate) == 0 {
+			rawdb.WriteCandidateSince(batch, candidate, header.Number.Uint64())
+		}
+	}
+	if candIter.Err != nil {
+		log.Warn("Failed to walk candidate trie for registration ages", "err", candIter.Err)
+	}
+	rawdb.WriteDelegationIndexHead(batch, header.Hash())
+	log.Info("Rebuilt delegation index", "block", header.Number, "candidates", len(delegations))
+}
+
+// ReconcileDelegationIndex cross-checks the vote and delegate tries of the
+// current head against each other and rebuilds the delegation index from
+// them. Intended after a fast sync, where the tries arrive as raw state
+// downloads instead of being built block by block: the two tries record every
+// vote redundantly — the vote trie keyed by delegator, the delegate trie by
+// candidate plus delegator — so a pair that disagrees means the sync
+// delivered inconsistent consensus state, reported as an error with the
+// mismatching entries logged. The index is only rebuilt once the tries agree.
+func (bc *BlockChain) ReconcileDelegationIndex() error {
+	head := bc.CurrentHeader()
+	if head == nil || !bc.chainConfig.IsDpos(head.Number) || head.DposContext == nil {
+		return nil
+	}
+	dposContext, err := bc.DposContextAt(head)
+	if err != nil {
+		return fmt.Errorf("cannot open dpos context at block %d: %v", head.Number, err)
+	}
+	entries, mismatches, err := checkVoteDelegations(dposContext)
+	if err != nil {
+		return err
+	}
+	if mismatches > 0 {
+		return fmt.Errorf("vote and delegate tries disagree on %d entries at block %d", mismatches, head.Number)
+	}
+	// The tries agree; rebuild the index from them, since the blocks below
+	// the pivot were never replayed through updateDelegationIndex
+	batch := bc.db.NewBatch()
+	bc.rebuildDelegationIndex(batch, head)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Reconciled dpos vote state", "block", head.Number, "delegations", entries)
+	return nil
+}
+
+// checkVoteDelegations cross-walks the vote and delegate tries of the given
+// context, returning the number of delegation entries found and the number of
+// entries the two tries disagree on. Each mismatch is logged individually.
+func checkVoteDelegations(dposContext *types.DposContext) (entries, mismatches int, err error) {
+	votes := make(map[common.Address]common.Address)
+	voteIter := trie.NewIterator(dposContext.VoteTrie().NodeIterator(nil))
+	for voteIter.Next() {
+		if len(voteIter.Key) != common.AddressLength {
+			continue
+		}
+		votes[common.BytesToAddress(voteIter.Key)] = common.BytesToAddress(voteIter.Value)
+	}
+	if voteIter.Err != nil {
+		return 0, 0, fmt.Errorf("cannot walk vote trie: %v", voteIter.Err)
+	}
+	iter := trie.NewIterator(dposContext.DelegateTrie().NodeIterator(nil))
+	for iter.Next() {
+		if len(iter.Key) != 2*common.AddressLength {
+			continue
+		}
+		candidate := common.BytesToAddress(iter.Key[:common.AddressLength])
+		delegator := common.BytesToAddress(iter.Key[common.AddressLength:])
+		entries++
+		if votes[delegator] != candidate {
+			log.Error("Delegation without matching vote", "candidate", candidate, "delegator", delegator)
+			mismatches++
+			continue
+		}
+		delete(votes, delegator)
+	}
+	if iter.Err != nil {
+		return 0, 0, fmt.Errorf("cannot walk delegate trie: %v", iter.Err)
+	}
+	for delegator, candidate := range votes {
+		log.Error("Vote without matching delegation", "delegator", delegator, "candidate", candidate)
+		mismatches++
+	}
+	return entries, mismatches, nil
+}
+
+// delegationDiff collects the delegate trie entries present in b but not in
+// a, grouped by candidate. The difference iterator only descends into subtries
+// the two contexts do not share, so the cost scales with the change, not the
+// trie size.
+func delegationDiff(a, b *types.DposContext) (map[common.Address][]common.Address, error) {
+	diffIter, _ := trie.NewDifferenceIterator(a.DelegateTrie().NodeIterator(nil), b.DelegateTrie().NodeIterator(nil))
+	iter := trie.NewIterator(diffIter)
+	diff := make(map[common.Address][]common.Address)
+	for iter.Next() {
+		if len(iter.Key) != 2*common.AddressLength {
+			continue
+		}
+		candidate := common.BytesToAddress(iter.Key[:common.AddressLength])
+		diff[candidate] = append(diff[candidate], common.BytesToAddress(iter.Key[common.AddressLength:]))
+	}
+	return diff, iter.Err
+}
+
+// mergedCandidates returns the union of the candidates touched by the two
+// diffs.
+func mergedCandidates(added, removed map[common.Address][]common.Address) map[common.Address]bool {
+	candidates := make(map[common.Address]bool, len(added)+len(removed))
+	for candidate := range added {
+		candidates[candidate] = true
+	}
+	for candidate := range removed {
+		candidates[candidate] = true
+	}
+	return candidates
+}
+
+// sortedAddresses flattens an address set into a slice sorted by address, so
+// the stored lists are deterministic.
+func sortedAddresses(set map[common.Address]bool) []common.Address {
+	addresses := make([]common.Address, 0, len(set))
+	for address := range set {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i][:], addresses[j][:]) < 0
+	})
+	return addresses
+}
+
+// delegationCatalog tracks which candidates the index holds entries for, so a
+// rebuild can clear stale entries without database iteration.
+type delegationCatalog struct {
+	candidates map[common.Address]bool
+	dirty      bool
+}
+
+func newDelegationCatalog(candidates []common.Address) *delegationCatalog {
+	catalog := &delegationCatalog{candidates: make(map[common.Address]bool, len(candidates))}
+	for _, candidate := range candidates {
+		catalog.candidates[candidate] = true
+	}
+	return catalog
+}
+
+func (c *delegationCatalog) add(candidate common.Address) {
+	if !c.candidates[candidate] {
+		c.candidates[candidate] = true
+		c.dirty = true
+	}
+}
+
+func (c *delegationCatalog) remove(candidate common.Address) {
+	if c.candidates[candidate] {
+		delete(c.candidates, candidate)
+		c.dirty = true
+	}
+}
+
+func (c *delegationCatalog) store(db ethdb.Putter) {
+	if c.dirty {
+		rawdb.WriteDelegationCatalog(db, sortedAddresses(c.candidates))
+	}
+}
+
+// backfillFlushInterval is the number of blocks an offline index backfill
+// replays between two database flushes; each flush also moves the resume
+// marker forward.
+const backfillFlushInterval = 4096
+
+// BackfillDelegationIndex constructs the delegation index offline by
+// replaying the delegate trie changes of the canonical chain from the given
+// block up to the current head, so a node upgrading to the index does not
+// need a full resync. The run is resumable: the index head marker records the
+// last flushed block and a rerun continues from it.
+func (bc *BlockChain) BackfillDelegationIndex(from uint64) error {
+	head := bc.CurrentBlock()
+	if head == nil {
+		return errors.New("chain has no head block")
+	}
+	if bc.chainConfig.Dpos == nil {
+		return errors.New("not a dpos chain")
+	}
+	headNumber := head.NumberU64()
+	// The blocks below a clique-to-dpos fork carry no delegate trie
+	if fork := bc.chainConfig.Dpos.ForkBlock; from < fork {
+		from = fork
+	}
+	if from > headNumber {
+		return fmt.Errorf("start block %d beyond head block %d", from, headNumber)
+	}
+	// Resume from the recorded marker when it sits on the canonical chain at
+	// or beyond the requested start; the index already matches that block
+	number, resumed := from, false
+	if marker := rawdb.ReadDelegationIndexHead(bc.db); marker != (common.Hash{}) {
+		if header := bc.GetHeaderByHash(marker); header != nil {
+			markerNumber := header.Number.Uint64()
+			if canonical := bc.GetHeaderByNumber(markerNumber); canonical != nil && canonical.Hash() == marker &&
+				markerNumber >= from && markerNumber <= headNumber {
+				number, resumed = markerNumber, true
+				log.Info("Resuming delegation index backfill", "block", markerNumber)
+			}
+		}
+	}
+	batch := bc.db.NewBatch()
+	if !resumed {
+		// Seed the index with a full delegate trie walk of the start block
+		header := bc.GetHeaderByNumber(number)
+		if header == nil {
+			return fmt.Errorf("missing canonical header %d", number)
+		}
+		bc.rebuildDelegationIndex(batch, header)
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+	}
+	if number == headNumber {
+		log.Info("Delegation index synced to head", "block", headNumber)
+		return nil
+	}
+	// Replay the per-block delegate trie diffs up to the head
+	var (
+		overlay = newDelegationOverlay(bc.db)
+		start   = time.Now()
+		logged  = time.Now()
+		prev    = bc.GetHeaderByNumber(number)
+	)
+	for number++; number <= headNumber; number++ {
+		header := bc.GetHeaderByNumber(number)
+		if header == nil {
+			return fmt.Errorf("missing canonical header %d", number)
+		}
+		oldContext, err := bc.DposContextAt(prev)
+		if err != nil {
+			return fmt.Errorf("block %d: %v", number-1, err)
+		}
+		newContext, err := bc.DposContextAt(header)
+		if err != nil {
+			return fmt.Errorf("block %d: %v", number, err)
+		}
+		added, err := delegationDiff(oldContext, newContext)
+		if err != nil {
+			return fmt.Errorf("block %d: %v", number, err)
+		}
+		removed, err := delegationDiff(newContext, oldContext)
+		if err != nil {
+			return fmt.Errorf("block %d: %v", number, err)
+		}
+		for candidate := range mergedCandidates(added, removed) {
+			set := overlay.get(candidate)
+			for _, delegator := range removed[candidate] {
+				delete(set, delegator)
+			}
+			for _, delegator := range added[candidate] {
+				set[delegator] = true
+			}
+		}
+		prev = header
+		if number%backfillFlushInterval == 0 || number == headNumber {
+			overlay.flush(batch)
+			rawdb.WriteDelegationIndexHead(batch, header.Hash())
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Backfilling delegation index", "block", number, "head", headNumber, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Backfilled delegation index", "head", headNumber, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// delegationOverlay buffers delegation index changes between two flushes of
+// an offline backfill, so replayed blocks observe each other's changes
+// without a database round trip per block.
+type delegationOverlay struct {
+	db      ethdb.Database
+	sets    map[common.Address]map[common.Address]bool
+	dirty   map[common.Address]bool
+	catalog *delegationCatalog
+}
+
+func newDelegationOverlay(db ethdb.Database) *delegationOverlay {
+	return &delegationOverlay{
+		db:      db,
+		sets:    make(map[common.Address]map[common.Address]bool),
+		dirty:   make(map[common.Address]bool),
+		catalog: newDelegationCatalog(rawdb.ReadDelegationCatalog(db)),
+	}
+}
+
+// get returns the buffered delegator set of a candidate, loading it from the
+// database on first touch, and marks it dirty for the next flush.
+func (o *delegationOverlay) get(candidate common.Address) map[common.Address]bool {
+	set, ok := o.sets[candidate]
+	if !ok {
+		delegators := rawdb.ReadIndexedDelegators(o.db, candidate)
+		set = make(map[common.Address]bool, len(delegators))
+		for _, delegator := range delegators {
+			set[delegator] = true
+		}
+		o.sets[candidate] = set
+	}
+	o.dirty[candidate] = true
+	return set
+}
+
+// flush writes the dirty delegator sets and the candidate catalog into the
+// given batch, keeping the sets buffered for subsequent blocks.
+func (o *delegationOverlay) flush(batch ethdb.Batch) {
+	for candidate := range o.dirty {
+		set := o.sets[candidate]
+		if len(set) == 0 {
+			rawdb.DeleteIndexedDelegators(batch, candidate)
+			o.catalog.remove(candidate)
+			continue
+		}
+		rawdb.WriteIndexedDelegators(batch, candidate, sortedAddresses(set))
+		o.catalog.add(candidate)
+	}
+	o.catalog.store(batch)
+	o.dirty = make(map[common.Address]bool)
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func delegationAddr(i int) common.Address {
+	return common.BigToAddress(big.NewInt(int64(i + 1)))
+}
+
+// newDelegationContext creates an in-memory dpos context with the given
+// delegations, keyed candidate to delegators.
+func newDelegationContext(t *testing.T, delegations map[int][]int) *types.DposContext {
+	dposContext, err := types.NewDposContext(trie.NewDatabase(ethdb.NewMemDatabase()))
+	if err != nil {
+		t.Fatalf("failed to create dpos context: %v", err)
+	}
+	for candidate, delegators := range delegations {
+		if err := dposContext.BecomeCandidate(delegationAddr(candidate)); err != nil {
+			t.Fatalf("failed to register candidate: %v", err)
+		}
+		for _, delegator := range delegators {
+			if err := dposContext.Delegate(delegationAddr(delegator), delegationAddr(candidate)); err != nil {
+				t.Fatalf("failed to delegate: %v", err)
+			}
+		}
+	}
+	return dposContext
+}
+
+// Tests that the vote and delegate trie cross-walk accepts state produced
+// through the regular delegation path and reports tampered tries, the way a
+// corrupted snapshot sync would surface after the pivot.
+func TestCheckVoteDelegations(t *testing.T) {
+	dposContext := newDelegationContext(t, map[int][]int{0: {10, 11}, 1: {12}})
+	entries, mismatches, err := checkVoteDelegations(dposContext)
+	if err != nil {
+		t.Fatalf("cross-walk failed: %v", err)
+	}
+	if entries != 3 {
+		t.Errorf("delegation entries: got %d, want 3", entries)
+	}
+	if mismatches != 0 {
+		t.Errorf("mismatches on consistent tries: got %d, want 0", mismatches)
+	}
+
+	// A delegation entry without a backing vote is corruption
+	orphaned := append(delegationAddr(0).Bytes(), delegationAddr(13).Bytes()...)
+	if err := dposContext.DelegateTrie().TryUpdate(orphaned, delegationAddr(13).Bytes()); err != nil {
+		t.Fatalf("failed to tamper with delegate trie: %v", err)
+	}
+	if _, mismatches, err = checkVoteDelegations(dposContext); err != nil || mismatches != 1 {
+		t.Errorf("orphaned delegation: got %d mismatches, error %v, want 1 mismatch", mismatches, err)
+	}
+
+	// A vote pointing at a candidate without the matching delegation entry is
+	// a second, independent corruption
+	if err := dposContext.VoteTrie().TryUpdate(delegationAddr(14).Bytes(), delegationAddr(1).Bytes()); err != nil {
+		t.Fatalf("failed to tamper with vote trie: %v", err)
+	}
+	if _, mismatches, err = checkVoteDelegations(dposContext); err != nil || mismatches != 2 {
+		t.Errorf("dangling vote: got %d mismatches, error %v, want 2 mismatches", mismatches, err)
+	}
+}
+
+// Tests that the delegate trie diff powering the delegation index picks up
+// exactly the entries added between two contexts, grouped by candidate.
+func TestDelegationDiff(t *testing.T) {
+	db := trie.NewDatabase(ethdb.NewMemDatabase())
+	dposContext, err := types.NewDposContext(db)
+	if err != nil {
+		t.Fatalf("failed to create dpos context: %v", err)
+	}
+	if err := dposContext.BecomeCandidate(delegationAddr(0)); err != nil {
+		t.Fatalf("failed to register candidate: %v", err)
+	}
+	if err := dposContext.Delegate(delegationAddr(10), delegationAddr(0)); err != nil {
+		t.Fatalf("failed to delegate: %v", err)
+	}
+	proto, err := dposContext.Commit()
+	if err != nil {
+		t.Fatalf("failed to commit dpos context: %v", err)
+	}
+	old, err := types.NewDposContextFromProto(db, proto)
+	if err != nil {
+		t.Fatalf("failed to reopen dpos context: %v", err)
+	}
+	// Grow the live context past the snapshot: one more delegator on the
+	// existing candidate, one fresh candidate with a backer
+	if err := dposContext.Delegate(delegationAddr(11), delegationAddr(0)); err != nil {
+		t.Fatalf("failed to delegate: %v", err)
+	}
+	if err := dposContext.BecomeCandidate(delegationAddr(1)); err != nil {
+		t.Fatalf("failed to register candidate: %v", err)
+	}
+	if err := dposContext.Delegate(delegationAddr(12), delegationAddr(1)); err != nil {
+		t.Fatalf("failed to delegate: %v", err)
+	}
+
+	added, err := delegationDiff(old, dposContext)
+	if err != nil {
+		t.Fatalf("failed to diff delegate tries: %v", err)
+	}
+	want := map[common.Address][]common.Address{
+		delegationAddr(0): {delegationAddr(11)},
+		delegationAddr(1): {delegationAddr(12)},
+	}
+	if len(added) != len(want) {
+		t.Fatalf("diffed candidates: got %d, want %d", len(added), len(want))
+	}
+	for candidate, delegators := range want {
+		got := added[candidate]
+		if len(got) != len(delegators) || got[0] != delegators[0] {
+			t.Errorf("diff for candidate %x: got %v, want %v", candidate, got, delegators)
+		}
+	}
+	// The reverse diff sees nothing: no entry was removed
+	removed, err := delegationDiff(dposContext, old)
+	if err != nil {
+		t.Fatalf("failed to diff delegate tries: %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("reverse diff: got %d candidates, want none", len(removed))
+	}
+}
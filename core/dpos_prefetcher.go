@@ -0,0 +1,100 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// prefetchDposContext warms the DposContext trie nodes the block's vote and
+// candidate transactions will touch, concurrently with the block executing
+// them. The walk runs on a throwaway context opened off the same parent
+// roots, since the tries themselves are not safe for concurrent use; the
+// nodes it resolves land in the shared trie node cache the executing context
+// reads through. The walk is best effort — lookup failures are simply paid
+// again by the execution — and the returned function halts it once the block
+// is done.
+func (bc *BlockChain) prefetchDposContext(block *types.Block, parent *types.Header) func() {
+	noop := func() {}
+	if parent.DposContext == nil {
+		return noop
+	}
+	// Only spin up a walker for blocks carrying transactions worth warming
+	prefetch := false
+	for _, tx := range block.Transactions() {
+		switch tx.Type() {
+		case types.Delegate, types.UnDelegate, types.Redelegate, types.LoginCandidate, types.LogoutCandidate:
+			prefetch = true
+		}
+		if prefetch {
+			break
+		}
+	}
+	if !prefetch {
+		return noop
+	}
+	dposContext, err := types.NewDposContextFromProto(bc.stateCache.TrieDB(), parent.DposContext)
+	if err != nil {
+		log.Debug("Failed to open dpos context for prefetch", "err", err)
+		return noop
+	}
+	interrupt := new(int32)
+	go func() {
+		signer := types.MakeSigner(bc.chainConfig, block.Number())
+		for _, tx := range block.Transactions() {
+			if atomic.LoadInt32(interrupt) == 1 {
+				return
+			}
+			from, err := types.Sender(signer, tx)
+			if err != nil {
+				continue
+			}
+			data := tx.Data()
+			switch tx.Type() {
+			case types.LoginCandidate, types.LogoutCandidate:
+				dposContext.CandidateTrie().TryGet(from.Bytes())
+			case types.Delegate, types.UnDelegate:
+				if len(data) < common.AddressLength {
+					continue
+				}
+				prefetchVoteMove(dposContext, from, data[:common.AddressLength])
+			case types.Redelegate:
+				if len(data) != 2*common.AddressLength {
+					continue
+				}
+				dposContext.DelegateTrie().TryGet(append(data[:common.AddressLength:common.AddressLength], from.Bytes()...))
+				prefetchVoteMove(dposContext, from, data[common.AddressLength:])
+			}
+		}
+	}()
+	return func() { atomic.StoreInt32(interrupt, 1) }
+}
+
+// prefetchVoteMove touches the trie paths a vote move of delegator to
+// candidate reads: the candidate registration, the delegator's current vote
+// and the delegate trie entries retracted and written by the move.
+func prefetchVoteMove(dposContext *types.DposContext, delegator common.Address, candidate []byte) {
+	dposContext.CandidateTrie().TryGet(candidate)
+	if old, _ := dposContext.VoteTrie().TryGet(delegator.Bytes()); len(old) == common.AddressLength {
+		dposContext.DelegateTrie().TryGet(append(old, delegator.Bytes()...))
+	}
+	dposContext.DelegateTrie().TryGet(append(candidate[:len(candidate):len(candidate)], delegator.Bytes()...))
+}
@@ -31,10 +31,12 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 //go:generate gencodec -type Genesis -field-override genesisSpecMarshaling -out gen_genesis.go
@@ -54,6 +56,7 @@ type Genesis struct {
 	Mixhash    common.Hash         `json:"mixHash"`
 	Coinbase   common.Address      `json:"coinbase"`
 	Alloc      GenesisAlloc        `json:"alloc"      gencodec:"required"`
+	Dpos       *GenesisDpos        `json:"dpos,omitempty"`
 
 	// These fields are used for consensus tests. Please don't use them
 	// in actual genesis blocks.
@@ -77,6 +80,16 @@ func (ga *GenesisAlloc) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// GenesisDpos seeds the dpos context of the genesis block with candidates and
+// delegations beyond the self-voting validators listed in the chain
+// configuration. Candidates maps each initial candidate to the delegators
+// voting for it at block 0; an empty list registers the candidate without
+// votes. Validators from the chain configuration are always registered and
+// self-voting, whether listed here or not.
+type GenesisDpos struct {
+	Candidates map[common.Address][]common.Address `json:"candidates"`
+}
+
 // GenesisAccount is an account in the state of the genesis block.
 type GenesisAccount struct {
 	Code       []byte                      `json:"code,omitempty"`
@@ -234,6 +247,16 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 		}
 	}
 	root := statedb.IntermediateRoot(false)
+
+	// Initialise the dpos context with the genesis validator set
+	dposContext, err := initGenesisDposContext(g, trie.NewDatabase(db))
+	if err != nil {
+		panic(err)
+	}
+	dposContextProto, err := dposContext.Commit()
+	if err != nil {
+		panic(err)
+	}
 	head := &types.Header{
 		Number:     new(big.Int).SetUint64(g.Number),
 		Nonce:      types.EncodeNonce(g.Nonce),
@@ -246,6 +269,8 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 		MixDigest:  g.Mixhash,
 		Coinbase:   g.Coinbase,
 		Root:       root,
+
+		DposContext: dposContextProto,
 	}
 	if g.GasLimit == 0 {
 		head.GasLimit = params.GenesisGasLimit
@@ -255,8 +280,150 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 	}
 	statedb.Commit(false)
 	statedb.Database().TrieDB().Commit(root, true)
+	dposContext.Flush()
+
+	block := types.NewBlock(head, nil, nil, nil)
+	block.DposContext = dposContext
+	return block
+}
+
+// initGenesisDposContext seeds the dpos context with the validators listed in
+// the chain configuration: they form the validator set of the first epoch and
+// are registered as candidates voting for themselves.
+func initGenesisDposContext(g *Genesis, db *trie.Database) (*types.DposContext, error) {
+	dposContext, err := types.NewDposContext(db)
+	if err != nil {
+		return nil, err
+	}
+	if g.Config == nil || g.Config.Dpos == nil {
+		return dposContext, nil
+	}
+	// A chain migrating from clique starts without validators; the context
+	// is built from the clique signers at the fork block instead
+	if g.Config.Dpos.ForkBlock > 0 {
+		return dposContext, nil
+	}
+	if err = dposContext.SetValidators(g.Config.Dpos.Validators); err != nil {
+		return nil, err
+	}
+	for _, validator := range g.Config.Dpos.Validators {
+		if err = dposContext.BecomeCandidate(validator); err != nil {
+			return nil, err
+		}
+		if err = dposContext.Delegate(validator, validator); err != nil {
+			return nil, err
+		}
+	}
+	// Seed any further candidates and delegations requested by the genesis
+	// specification on top of the self-voting validators
+	if g.Dpos != nil {
+		if err := validateGenesisDpos(g); err != nil {
+			return nil, err
+		}
+		for candidate := range g.Dpos.Candidates {
+			if err = dposContext.BecomeCandidate(candidate); err != nil {
+				return nil, err
+			}
+		}
+		for candidate, delegators := range g.Dpos.Candidates {
+			for _, delegator := range delegators {
+				if err = dposContext.Delegate(delegator, candidate); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return dposContext, nil
+}
+
+// validateGenesisDpos sanity checks the initial candidates and delegations of
+// a genesis specification: delegators may vote at most once, validators may
+// not be re-delegated away from themselves and enough candidates must be
+// registered for the first election to succeed.
+func validateGenesisDpos(g *Genesis) error {
+	candidates := make(map[common.Address]bool)
+	for _, validator := range g.Config.Dpos.Validators {
+		candidates[validator] = true
+	}
+	voted := make(map[common.Address]bool)
+	for candidate, delegators := range g.Dpos.Candidates {
+		candidates[candidate] = true
+		for _, delegator := range delegators {
+			if voted[delegator] {
+				return fmt.Errorf("duplicate genesis delegation by %x", delegator)
+			}
+			voted[delegator] = true
+		}
+	}
+	// Validators delegate to themselves to survive the first kickout round;
+	// a genesis delegation moving that vote would undo it
+	for _, validator := range g.Config.Dpos.Validators {
+		if voted[validator] {
+			return fmt.Errorf("genesis validator %x may not delegate away its self-vote", validator)
+		}
+	}
+	// The first election fails outright with fewer candidates than the safe
+	// size, so reject specifications that deadlock their own chain
+	maxValidatorSize := uint64(21)
+	if g.Config.Dpos.MaxValidatorSize > 0 {
+		maxValidatorSize = g.Config.Dpos.MaxValidatorSize
+	}
+	if safeSize := maxValidatorSize*2/3 + 1; uint64(len(candidates)) < safeSize {
+		return fmt.Errorf("genesis dpos spec holds %d candidates, need at least %d for a safe election", len(candidates), safeSize)
+	}
+	return nil
+}
+
+// genesisAttestationLength is the byte length of one genesis attestation, a
+// secp256k1 signature of a genesis validator.
+const genesisAttestationLength = 65
+
+// GenesisAttestationHash returns the digest the genesis validators sign to
+// attest a chain launch: the hash of the genesis header with the attestation
+// section stripped from its extra-data, so that appending the signatures does
+// not invalidate them.
+func GenesisAttestationHash(header *types.Header, validators int) common.Hash {
+	stripped := types.CopyHeader(header)
+	if n := validators * genesisAttestationLength; len(stripped.Extra) >= n {
+		stripped.Extra = stripped.Extra[:len(stripped.Extra)-n]
+	}
+	return stripped.Hash()
+}
 
-	return types.NewBlock(head, nil, nil, nil)
+// verifyGenesisAttestations checks that every genesis validator countersigned
+// the chain launch. The genesis extra-data must end with one signature per
+// validator, in any order, over the attestation hash.
+func verifyGenesisAttestations(header *types.Header, config *params.ChainConfig) error {
+	if config.Dpos == nil || !config.Dpos.RequireGenesisAttestations {
+		return nil
+	}
+	validators := config.Dpos.Validators
+	if len(validators) == 0 {
+		return errors.New("genesis attestations required but no validators configured")
+	}
+	need := len(validators) * genesisAttestationLength
+	digest := GenesisAttestationHash(header, len(validators))
+	if len(header.Extra) < need {
+		return fmt.Errorf("genesis attestations missing: extra-data holds %d bytes, need %d signatures of %#x", len(header.Extra), len(validators), digest)
+	}
+	sigs := header.Extra[len(header.Extra)-need:]
+	pending := make(map[common.Address]bool)
+	for _, validator := range validators {
+		pending[validator] = true
+	}
+	for i := 0; i < len(validators); i++ {
+		pubkey, err := crypto.Ecrecover(digest[:], sigs[i*genesisAttestationLength:(i+1)*genesisAttestationLength])
+		if err != nil {
+			return fmt.Errorf("invalid genesis attestation %d: %v", i, err)
+		}
+		var signer common.Address
+		copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+		if !pending[signer] {
+			return fmt.Errorf("genesis attestation %d signed by %x, not an unattested validator", i, signer)
+		}
+		delete(pending, signer)
+	}
+	return nil
 }
 
 // Commit writes the block and state of a genesis specification to the database.
@@ -360,6 +527,49 @@ func DeveloperGenesisBlock(period uint64, faucet common.Address) *Genesis {
 	}
 }
 
+// DeveloperDposGenesisBlock returns the 'geth --dev.dpos' genesis block: a
+// single-validator dpos chain sealing fast blocks, with epoch boundaries every
+// minute so election and kickout logic can be exercised locally.
+func DeveloperDposGenesisBlock(period uint64, faucet common.Address) *Genesis {
+	// Mine a block a second unless a slower cadence was requested explicitly
+	if period == 0 {
+		period = 1
+	}
+	config := &params.ChainConfig{
+		ChainID:             big.NewInt(1337),
+		HomesteadBlock:      big.NewInt(0),
+		EIP150Block:         big.NewInt(0),
+		EIP155Block:         big.NewInt(0),
+		EIP158Block:         big.NewInt(0),
+		ByzantiumBlock:      big.NewInt(0),
+		ConstantinopleBlock: nil,
+		Dpos: &params.DposConfig{
+			Validators:       []common.Address{faucet},
+			BlockInterval:    period,
+			EpochInterval:    60 * period,
+			MaxValidatorSize: 1,
+		},
+	}
+	// Assemble and return the genesis with the precompiles and faucet pre-funded
+	return &Genesis{
+		Config:     config,
+		ExtraData:  append(append(make([]byte, 32), faucet[:]...), make([]byte, 65)...),
+		GasLimit:   6283185,
+		Difficulty: big.NewInt(1),
+		Alloc: map[common.Address]GenesisAccount{
+			common.BytesToAddress([]byte{1}): {Balance: big.NewInt(1)}, // ECRecover
+			common.BytesToAddress([]byte{2}): {Balance: big.NewInt(1)}, // SHA256
+			common.BytesToAddress([]byte{3}): {Balance: big.NewInt(1)}, // RIPEMD
+			common.BytesToAddress([]byte{4}): {Balance: big.NewInt(1)}, // Identity
+			common.BytesToAddress([]byte{5}): {Balance: big.NewInt(1)}, // ModExp
+			common.BytesToAddress([]byte{6}): {Balance: big.NewInt(1)}, // ECAdd
+			common.BytesToAddress([]byte{7}): {Balance: big.NewInt(1)}, // ECScalarMul
+			common.BytesToAddress([]byte{8}): {Balance: big.NewInt(1)}, // ECPairing
+			faucet: {Balance: new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(9))},
+		},
+	}
+}
+
 func decodePrealloc(data string) GenesisAlloc {
 	var p []struct{ Addr, Balance *big.Int }
 	if err := rlp.NewStream(strings.NewReader(data), 0).Decode(&p); err != nil {
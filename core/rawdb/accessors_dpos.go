@@ -0,0 +1,374 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// EpochSummary is the compact account of one dpos epoch transition persisted
+// in the chain database, serving historical queries without replaying the
+// election and fast-synced nodes that never executed it.
+type EpochSummary struct {
+	Epoch       uint64           `json:"epoch"`       // Epoch the transition elected validators for
+	Validators  []common.Address `json:"validators"`  // Final ordered validator set
+	TalliesHash common.Hash      `json:"talliesHash"` // Hash of the rlp encoded vote tallies
+	Kicked      []common.Address `json:"kicked"`      // Validators removed for inactivity
+	Seed        uint64           `json:"seed"`        // Unsigned bit pattern of the int64 shuffle seed the election consumed
+	Shuffle     uint64           `json:"shuffle"`     // Version of the shuffle algorithm the seed was fed to, zero when no shuffle ran
+}
+
+// legacyEpochSummary is the summary layout persisted before the shuffle seed
+// was recorded, kept around so summaries written by older versions still
+// decode. Their seed reads as unrecorded.
+type legacyEpochSummary struct {
+	Epoch       uint64
+	Validators  []common.Address
+	TalliesHash common.Hash
+	Kicked      []common.Address
+}
+
+// ReadEpochSummary retrieves the summary of the given epoch's transition, or
+// nil if the transition was not executed locally.
+func ReadEpochSummary(db DatabaseReader, epoch uint64) *EpochSummary {
+	data, _ := db.Get(epochSummaryKey(epoch))
+	if len(data) == 0 {
+		return nil
+	}
+	summary := new(EpochSummary)
+	if err := rlp.DecodeBytes(data, summary); err != nil {
+		legacy := new(legacyEpochSummary)
+		if rlp.DecodeBytes(data, legacy) != nil {
+			log.Error("Invalid epoch summary RLP", "epoch", epoch, "err", err)
+			return nil
+		}
+		summary = &EpochSummary{
+			Epoch:       legacy.Epoch,
+			Validators:  legacy.Validators,
+			TalliesHash: legacy.TalliesHash,
+			Kicked:      legacy.Kicked,
+		}
+	}
+	return summary
+}
+
+// WriteEpochSummary stores the summary of an epoch transition.
+func WriteEpochSummary(db DatabaseWriter, summary *EpochSummary) {
+	data, err := rlp.EncodeToBytes(summary)
+	if err != nil {
+		log.Crit("Failed to encode epoch summary", "err", err)
+	}
+	if err := db.Put(epochSummaryKey(summary.Epoch), data); err != nil {
+		log.Crit("Failed to store epoch summary", "err", err)
+	}
+}
+
+// DeleteEpochSummary removes the summary of the given epoch.
+func DeleteEpochSummary(db DatabaseDeleter, epoch uint64) {
+	if err := db.Delete(epochSummaryKey(epoch)); err != nil {
+		log.Crit("Failed to delete epoch summary", "err", err)
+	}
+}
+
+// RewardRecord aggregates the dpos income of one account over one epoch,
+// folded in at every reward distribution the node executes. The records are
+// local accounting for the history API, not consensus state: blocks lost to
+// a reorg are not unwound from them.
+type RewardRecord struct {
+	Epoch           uint64   `json:"epoch"`
+	Blocks          uint64   `json:"blocks"`          // Blocks the account sealed in the epoch
+	BlockReward     *big.Int `json:"blockReward"`     // Total reward minted by those blocks
+	Commission      *big.Int `json:"commission"`      // Share of the minted rewards the account kept as validator
+	FeeIncome       *big.Int `json:"feeIncome"`       // Share of the pooled transaction fees the account kept as validator
+	DelegatorReward *big.Int `json:"delegatorReward"` // Rewards received for votes delegated to others
+}
+
+// NewRewardRecord creates an empty reward record for the given epoch.
+func NewRewardRecord(epoch uint64) *RewardRecord {
+	return &RewardRecord{
+		Epoch:           epoch,
+		BlockReward:     new(big.Int),
+		Commission:      new(big.Int),
+		FeeIncome:       new(big.Int),
+		DelegatorReward: new(big.Int),
+	}
+}
+
+// ReadRewardRecord retrieves the reward record of the given account in the
+// given epoch, or nil if the account earned nothing the node knows of.
+func ReadRewardRecord(db DatabaseReader, epoch uint64, account common.Address) *RewardRecord {
+	data, _ := db.Get(rewardRecordKey(epoch, account))
+	if len(data) == 0 {
+		return nil
+	}
+	record := new(RewardRecord)
+	if err := rlp.DecodeBytes(data, record); err != nil {
+		log.Error("Invalid reward record RLP", "epoch", epoch, "account", account, "err", err)
+		return nil
+	}
+	return record
+}
+
+// WriteRewardRecord stores the reward record of an account.
+func WriteRewardRecord(db DatabaseWriter, account common.Address, record *RewardRecord) {
+	data, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		log.Crit("Failed to encode reward record", "err", err)
+	}
+	if err := db.Put(rewardRecordKey(record.Epoch, account), data); err != nil {
+		log.Crit("Failed to store reward record", "err", err)
+	}
+}
+
+// PerformanceRecord aggregates the locally observed sealing performance of
+// one validator over one epoch, folded in at every block this node finalizes.
+// Like the reward records this is local accounting, not consensus state:
+// blocks lost to a reorg are not unwound from it.
+type PerformanceRecord struct {
+	Epoch        uint64 `json:"epoch"`
+	Blocks       uint64 `json:"blocks"`       // Blocks the validator sealed in the epoch
+	GasUsed      uint64 `json:"gasUsed"`      // Total gas those blocks consumed
+	GasLimit     uint64 `json:"gasLimit"`     // Total gas those blocks could have consumed
+	Delay        uint64 `json:"delay"`        // Summed local propagation delay of the sampled blocks, in milliseconds
+	DelaySamples uint64 `json:"delaySamples"` // Blocks that arrived live enough to sample the delay of
+}
+
+// ReadPerformanceRecord retrieves the performance record of the given
+// validator in the given epoch, or nil if the node saw it seal nothing.
+func ReadPerformanceRecord(db DatabaseReader, epoch uint64, validator common.Address) *PerformanceRecord {
+	data, _ := db.Get(performanceRecordKey(epoch, validator))
+	if len(data) == 0 {
+		return nil
+	}
+	record := new(PerformanceRecord)
+	if err := rlp.DecodeBytes(data, record); err != nil {
+		log.Error("Invalid performance record RLP", "epoch", epoch, "validator", validator, "err", err)
+		return nil
+	}
+	return record
+}
+
+// WritePerformanceRecord stores the performance record of a validator.
+func WritePerformanceRecord(db DatabaseWriter, validator common.Address, record *PerformanceRecord) {
+	data, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		log.Crit("Failed to encode performance record", "err", err)
+	}
+	if err := db.Put(performanceRecordKey(record.Epoch, validator), data); err != nil {
+		log.Crit("Failed to store performance record", "err", err)
+	}
+}
+
+// ReadDelegationIndexHead retrieves the hash of the block the delegation
+// index is synced to, or the empty hash if the index was never built.
+func ReadDelegationIndexHead(db DatabaseReader) common.Hash {
+	data, _ := db.Get(delegationIndexHeadKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteDelegationIndexHead stores the hash of the block the delegation index
+// is synced to.
+func WriteDelegationIndexHead(db DatabaseWriter, hash common.Hash) {
+	if err := db.Put(delegationIndexHeadKey, hash.Bytes()); err != nil {
+		log.Crit("Failed to store delegation index head", "err", err)
+	}
+}
+
+// ReadIndexedDelegators retrieves the delegators the index holds for the
+// given candidate, sorted by address, or nil if the candidate has none.
+func ReadIndexedDelegators(db DatabaseReader, candidate common.Address) []common.Address {
+	data, _ := db.Get(delegationIndexKey(candidate))
+	if len(data) == 0 {
+		return nil
+	}
+	var delegators []common.Address
+	if err := rlp.DecodeBytes(data, &delegators); err != nil {
+		log.Error("Invalid delegation index RLP", "candidate", candidate, "err", err)
+		return nil
+	}
+	return delegators
+}
+
+// WriteIndexedDelegators stores the delegator list of a candidate.
+func WriteIndexedDelegators(db DatabaseWriter, candidate common.Address, delegators []common.Address) {
+	data, err := rlp.EncodeToBytes(delegators)
+	if err != nil {
+		log.Crit("Failed to encode delegation index entry", "err", err)
+	}
+	if err := db.Put(delegationIndexKey(candidate), data); err != nil {
+		log.Crit("Failed to store delegation index entry", "err", err)
+	}
+}
+
+// DeleteIndexedDelegators removes the delegator list of a candidate.
+func DeleteIndexedDelegators(db DatabaseDeleter, candidate common.Address) {
+	if err := db.Delete(delegationIndexKey(candidate)); err != nil {
+		log.Crit("Failed to delete delegation index entry", "err", err)
+	}
+}
+
+// ReadDelegationCatalog retrieves the candidates the delegation index holds
+// entries for.
+func ReadDelegationCatalog(db DatabaseReader) []common.Address {
+	data, _ := db.Get(delegationCatalogKey)
+	if len(data) == 0 {
+		return nil
+	}
+	var candidates []common.Address
+	if err := rlp.DecodeBytes(data, &candidates); err != nil {
+		log.Error("Invalid delegation catalog RLP", "err", err)
+		return nil
+	}
+	return candidates
+}
+
+// WriteDelegationCatalog stores the candidates the delegation index holds
+// entries for.
+func WriteDelegationCatalog(db DatabaseWriter, candidates []common.Address) {
+	data, err := rlp.EncodeToBytes(candidates)
+	if err != nil {
+		log.Crit("Failed to encode delegation catalog", "err", err)
+	}
+	if err := db.Put(delegationCatalogKey, data); err != nil {
+		log.Crit("Failed to store delegation catalog", "err", err)
+	}
+}
+
+// ReadIssuanceSnapshot retrieves the cumulative issuance in wei recorded when
+// the given epoch began, or nil if no snapshot exists for it.
+func ReadIssuanceSnapshot(db DatabaseReader, epoch uint64) *big.Int {
+	data, _ := db.Get(issuanceSnapshotKey(epoch))
+	if len(data) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(data)
+}
+
+// WriteIssuanceSnapshot stores the cumulative issuance recorded when the
+// given epoch began.
+func WriteIssuanceSnapshot(db DatabaseWriter, epoch uint64, issuance *big.Int) {
+	if err := db.Put(issuanceSnapshotKey(epoch), issuance.Bytes()); err != nil {
+		log.Crit("Failed to store issuance snapshot", "err", err)
+	}
+}
+
+// VoteChange is one entry of an account's delegation history: the candidate
+// the account voted for or revoked, the block the change landed in and the
+// account's balance at the time, which is the stake the vote weighed in with.
+// Like the other dpos accounting records this is local bookkeeping, not
+// consensus state: entries of blocks lost to a reorg are not unwound.
+type VoteChange struct {
+	Block     uint64
+	Candidate common.Address
+	Weight    *big.Int
+	Revoked   bool
+}
+
+// ReadVoteHistory retrieves the recorded delegation changes of an account,
+// oldest first, or nil if none were recorded.
+func ReadVoteHistory(db DatabaseReader, account common.Address) []VoteChange {
+	data, _ := db.Get(voteHistoryKey(account))
+	if len(data) == 0 {
+		return nil
+	}
+	var history []VoteChange
+	if err := rlp.DecodeBytes(data, &history); err != nil {
+		log.Error("Invalid vote history RLP", "account", account, "err", err)
+		return nil
+	}
+	return history
+}
+
+// WriteVoteHistory stores the delegation change log of an account.
+func WriteVoteHistory(db DatabaseWriter, account common.Address, history []VoteChange) {
+	data, err := rlp.EncodeToBytes(history)
+	if err != nil {
+		log.Crit("Failed to encode vote history", "err", err)
+	}
+	if err := db.Put(voteHistoryKey(account), data); err != nil {
+		log.Crit("Failed to store vote history", "err", err)
+	}
+}
+
+// KickoutEntry is one recorded removal of a candidate from the validator set:
+// the epoch it was kicked after, the number of blocks it produced over the
+// kickout window and the production threshold that would have kept it in.
+type KickoutEntry struct {
+	Epoch     uint64
+	MintCnt   uint64
+	Threshold uint64
+}
+
+// ReadKickoutHistory retrieves the recorded kickouts of a candidate, oldest
+// first, or nil if none were recorded.
+func ReadKickoutHistory(db DatabaseReader, candidate common.Address) []KickoutEntry {
+	data, _ := db.Get(kickoutHistoryKey(candidate))
+	if len(data) == 0 {
+		return nil
+	}
+	var history []KickoutEntry
+	if err := rlp.DecodeBytes(data, &history); err != nil {
+		log.Error("Invalid kickout history RLP", "candidate", candidate, "err", err)
+		return nil
+	}
+	return history
+}
+
+// WriteKickoutHistory stores the kickout log of a candidate.
+func WriteKickoutHistory(db DatabaseWriter, candidate common.Address, history []KickoutEntry) {
+	data, err := rlp.EncodeToBytes(history)
+	if err != nil {
+		log.Crit("Failed to encode kickout history", "err", err)
+	}
+	if err := db.Put(kickoutHistoryKey(candidate), data); err != nil {
+		log.Crit("Failed to store kickout history", "err", err)
+	}
+}
+
+// ReadCandidateSince retrieves the block number a candidate's registration
+// was first seen at, or zero if the candidate is unknown.
+func ReadCandidateSince(db DatabaseReader, candidate common.Address) uint64 {
+	data, _ := db.Get(candidateSinceKey(candidate))
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteCandidateSince stores the block number a candidate's registration was
+// first seen at.
+func WriteCandidateSince(db DatabaseWriter, candidate common.Address, number uint64) {
+	if err := db.Put(candidateSinceKey(candidate), encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store candidate registration block", "err", err)
+	}
+}
+
+// DeleteCandidateSince removes the recorded registration block of a
+// candidate.
+func DeleteCandidateSince(db DatabaseDeleter, candidate common.Address) {
+	if err := db.Delete(candidateSinceKey(candidate)); err != nil {
+		log.Crit("Failed to delete candidate registration block", "err", err)
+	}
+}
@@ -56,6 +56,24 @@ var (
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
+	epochSummaryPrefix = []byte("dpos-epoch-") // epochSummaryPrefix + epoch (uint64 big endian) -> epoch summary
+
+	rewardRecordPrefix = []byte("dpos-reward-") // rewardRecordPrefix + epoch (uint64 big endian) + address -> reward record
+
+	delegationIndexPrefix  = []byte("dpos-delegations-")        // delegationIndexPrefix + candidate address -> delegator list
+	delegationIndexHeadKey = []byte("dpos-delegations-head")    // block hash the delegation index is synced to
+	delegationCatalogKey   = []byte("dpos-delegations-catalog") // candidates the delegation index holds entries for
+
+	issuanceSnapshotPrefix = []byte("dpos-issuance-") // issuanceSnapshotPrefix + epoch (uint64 big endian) -> cumulative issuance
+
+	performanceRecordPrefix = []byte("dpos-performance-") // performanceRecordPrefix + epoch (uint64 big endian) + address -> performance record
+
+	voteHistoryPrefix = []byte("dpos-votehist-") // voteHistoryPrefix + account address -> delegation change log
+
+	candidateSincePrefix = []byte("dpos-candsince-") // candidateSincePrefix + candidate address -> registration block number
+
+	kickoutHistoryPrefix = []byte("dpos-kickhist-") // kickoutHistoryPrefix + candidate address -> kickout log
+
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
@@ -132,3 +150,43 @@ func preimageKey(hash common.Hash) []byte {
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
 }
+
+// epochSummaryKey = epochSummaryPrefix + epoch (uint64 big endian)
+func epochSummaryKey(epoch uint64) []byte {
+	return append(epochSummaryPrefix, encodeBlockNumber(epoch)...)
+}
+
+// rewardRecordKey = rewardRecordPrefix + epoch (uint64 big endian) + address
+func rewardRecordKey(epoch uint64, account common.Address) []byte {
+	return append(append(rewardRecordPrefix, encodeBlockNumber(epoch)...), account.Bytes()...)
+}
+
+// delegationIndexKey = delegationIndexPrefix + candidate address
+func delegationIndexKey(candidate common.Address) []byte {
+	return append(delegationIndexPrefix, candidate.Bytes()...)
+}
+
+// issuanceSnapshotKey = issuanceSnapshotPrefix + epoch (uint64 big endian)
+func issuanceSnapshotKey(epoch uint64) []byte {
+	return append(issuanceSnapshotPrefix, encodeBlockNumber(epoch)...)
+}
+
+// performanceRecordKey = performanceRecordPrefix + epoch (uint64 big endian) + address
+func performanceRecordKey(epoch uint64, validator common.Address) []byte {
+	return append(append(performanceRecordPrefix, encodeBlockNumber(epoch)...), validator.Bytes()...)
+}
+
+// voteHistoryKey = voteHistoryPrefix + account address
+func voteHistoryKey(account common.Address) []byte {
+	return append(voteHistoryPrefix, account.Bytes()...)
+}
+
+// candidateSinceKey = candidateSincePrefix + candidate address
+func candidateSinceKey(candidate common.Address) []byte {
+	return append(candidateSincePrefix, candidate.Bytes()...)
+}
+
+// kickoutHistoryKey = kickoutHistoryPrefix + candidate address
+func kickoutHistoryKey(candidate common.Address) []byte {
+	return append(kickoutHistoryPrefix, candidate.Bytes()...)
+}
@@ -17,6 +17,10 @@
 package core
 
 import (
+	"errors"
+	"fmt"
+	"math/big"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/misc"
@@ -65,18 +69,62 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
+	// System transactions are synthesized by the engine, not taken from the
+	// pool; derive the set this block must carry so the trailing body
+	// transactions can be verified against it.
+	systemEngine, _ := p.engine.(SystemTxEngine)
+	var expectedSystem []*types.Transaction
+	if systemEngine != nil {
+		var err error
+		if expectedSystem, err = systemEngine.SystemTransactions(p.bc, header); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+	// Resolve the validator-voted gas price floor the block's transactions
+	// must clear
+	var minGasPrice *big.Int
+	if p.config.Dpos != nil && block.DposCtx() != nil {
+		if floor := block.DposCtx().MinGasPrice(); floor > 0 {
+			minGasPrice = new(big.Int).SetUint64(floor)
+		}
+	}
 	// Iterate over and process the individual transactions
+	seenSystem := false
 	for i, tx := range block.Transactions() {
 		statedb.Prepare(tx.Hash(), block.Hash(), i)
-		receipt, _, err := ApplyTransaction(p.config, p.bc, nil, gp, statedb, header, tx, usedGas, cfg)
+		if tx.Type().IsSystem() {
+			if len(expectedSystem) == 0 || tx.Hash() != expectedSystem[0].Hash() {
+				return nil, nil, 0, fmt.Errorf("unexpected system transaction %x", tx.Hash())
+			}
+			expectedSystem = expectedSystem[1:]
+			seenSystem = true
+
+			receipt, err := ApplySystemTransaction(systemEngine, p.bc, p.config, statedb, header, block.DposCtx(), tx, *usedGas)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			receipts = append(receipts, receipt)
+			allLogs = append(allLogs, receipt.Logs...)
+			continue
+		}
+		if seenSystem {
+			return nil, nil, 0, errors.New("user transaction after system transactions")
+		}
+		if minGasPrice != nil && tx.GasPrice().Cmp(minGasPrice) < 0 {
+			return nil, nil, 0, fmt.Errorf("transaction %x below the network minimum gas price", tx.Hash())
+		}
+		receipt, _, err := ApplyTransaction(p.config, p.bc, nil, gp, statedb, header, tx, usedGas, cfg, block.DposCtx())
 		if err != nil {
 			return nil, nil, 0, err
 		}
 		receipts = append(receipts, receipt)
 		allLogs = append(allLogs, receipt.Logs...)
 	}
+	if len(expectedSystem) > 0 {
+		return nil, nil, 0, fmt.Errorf("block misses %d system transactions", len(expectedSystem))
+	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
-	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), receipts)
+	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), receipts, block.DposCtx())
 
 	return receipts, allLogs, *usedGas, nil
 }
@@ -85,13 +133,14 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 // and uses the input parameters for its environment. It returns the receipt
 // for the transaction, gas used and an error if the transaction failed,
 // indicating the block was invalid.
-func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg vm.Config) (*types.Receipt, uint64, error) {
+func ApplyTransaction(config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, cfg vm.Config, dposContext *types.DposContext) (*types.Receipt, uint64, error) {
 	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
 	if err != nil {
 		return nil, 0, err
 	}
 	// Create a new context to be used in the EVM environment
 	context := NewEVMContext(msg, header, bc, author)
+	context.DposContext = dposContext
 	// Create a new environment which holds all relevant information
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(context, statedb, config, cfg)
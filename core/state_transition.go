@@ -17,14 +17,19 @@
 package core
 
 import (
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"math"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 var (
@@ -73,6 +78,7 @@ type Message interface {
 	Nonce() uint64
 	CheckNonce() bool
 	Data() []byte
+	Type() types.TxType
 }
 
 // IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
@@ -108,6 +114,48 @@ func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error)
 	return gas, nil
 }
 
+// DposIntrinsicGas returns the extra intrinsic gas a dpos operation costs on
+// top of a plain transaction, pricing the trie work the operation causes so
+// the consensus tries cannot be bloated cheaply.
+func DposIntrinsicGas(txType types.TxType) uint64 {
+	switch txType {
+	case types.LoginCandidate:
+		return params.LoginCandidateGas
+	case types.LogoutCandidate:
+		return params.LogoutCandidateGas
+	case types.Delegate:
+		return params.DelegateGas
+	case types.UnDelegate:
+		return params.UnDelegateGas
+	case types.MetaVote:
+		return params.MetaVoteGas
+	case types.SetCommission:
+		return params.SetCommissionGas
+	case types.ForceValidators:
+		return params.ForceValidatorsGas
+	case types.ReportEvidence:
+		return params.ReportEvidenceGas
+	case types.Redelegate:
+		return params.RedelegateGas
+	case types.ProxyVoteTx:
+		return params.ProxyVoteGas
+	case types.BlacklistCandidate:
+		return params.BlacklistGas
+	case types.RotateSigner:
+		return params.RotateSignerGas
+	case types.SetCandidateOwners:
+		return params.CandidateOwnersGas
+	case types.SetOperatorGroup:
+		return params.OperatorGroupGas
+	case types.SetRewardRecipient:
+		return params.RewardRecipientGas
+	case types.VoteBlockInterval:
+		return params.BlockIntervalGas
+	default:
+		return 0
+	}
+}
+
 // NewStateTransition initialises and returns a new state transition object.
 func NewStateTransition(evm *vm.EVM, msg Message, gp *GasPool) *StateTransition {
 	return &StateTransition{
@@ -194,6 +242,7 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 	if err != nil {
 		return nil, 0, false, err
 	}
+	gas += DposIntrinsicGas(msg.Type())
 	if err = st.useGas(gas); err != nil {
 		return nil, 0, false, err
 	}
@@ -210,7 +259,13 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 	} else {
 		// Increment the nonce for the next transaction
 		st.state.SetNonce(msg.From(), st.state.GetNonce(sender.Address())+1)
-		ret, st.gas, vmerr = evm.Call(sender, st.to(), st.data, st.gas, st.value)
+		if msg.Type() == types.Binary {
+			ret, st.gas, vmerr = evm.Call(sender, st.to(), st.data, st.gas, st.value)
+		} else {
+			// Dpos transactions bypass the EVM and mutate the dpos context
+			// of the block directly
+			vmerr = st.applyDposMessage(msg)
+		}
 	}
 	if vmerr != nil {
 		log.Debug("VM returned with error", "err", vmerr)
@@ -222,11 +277,696 @@ func (st *StateTransition) TransitionDb() (ret []byte, usedGas uint64, failed bo
 		}
 	}
 	st.refundGas()
-	st.state.AddBalance(st.evm.Coinbase, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice))
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice)
+	if config := st.evm.ChainConfig().Dpos; config != nil && config.PoolFees {
+		// Fees accrue in the pool and are split across the validator set at
+		// the epoch transition
+		st.state.AddBalance(types.FeePoolAddr, fee)
+	} else {
+		st.state.AddBalance(st.evm.Coinbase, fee)
+	}
 
 	return ret, st.gasUsed(), vmerr != nil, err
 }
 
+// applyDposMessage applies a delegated proof-of-stake operation to the dpos
+// context of the block being processed. Errors returned here are treated like
+// vm errors: the transaction stays in the block and consumes its gas, but the
+// operation has no effect.
+func (st *StateTransition) applyDposMessage(msg Message) error {
+	dposContext := st.evm.DposContext
+	if dposContext == nil {
+		return errors.New("dpos transactions are not supported outside of block processing")
+	}
+	// Most operations touch several tries; revert to a pre-operation snapshot
+	// on failure so a half-applied operation can't leak into the block.
+	snapshot := dposContext.Snapshot()
+
+	var err error
+	switch msg.Type() {
+	case types.LoginCandidate:
+		// Kicked out validators have to sit out their cooldown before they
+		// may stand for election again
+		if expiry := dposContext.KickoutCooldown(msg.From()); expiry > st.evm.Time.Int64() {
+			return fmt.Errorf("candidate under kickout cooldown until %d", expiry)
+		}
+		// Blacklisted addresses may not stand for election at all
+		if expiry := dposContext.BlacklistExpiry(msg.From()); expiry > st.evm.Time.Int64() {
+			return fmt.Errorf("candidate blacklisted until %d", expiry)
+		}
+		if min := st.evm.ChainConfig().Dpos.MinCandidateBalance; min != nil && st.state.GetBalance(msg.From()).Cmp(min) < 0 {
+			return errors.New("balance below candidate minimum")
+		}
+		// First-time registrants lock the campaign deposit; it is earned
+		// back through block production at epoch boundaries and forfeited
+		// when the candidate leaves the list
+		deposit := st.evm.ChainConfig().Dpos.CandidateDeposit
+		charge := deposit != nil && deposit.Sign() > 0 && !dposContext.IsCandidate(msg.From())
+		if charge && st.state.GetBalance(msg.From()).Cmp(deposit) < 0 {
+			return errors.New("balance below candidate deposit")
+		}
+		if err = dposContext.BecomeCandidate(msg.From()); err == nil && charge {
+			if err = dposContext.SetDeposit(msg.From(), deposit); err == nil {
+				st.state.SubBalance(msg.From(), deposit)
+			}
+		}
+	case types.LogoutCandidate:
+		if _, err = st.authorizeCandidateOp(msg.From(), types.LogoutCandidate, msg.Data()); err == nil {
+			err = dposContext.KickoutCandidate(msg.From())
+		}
+	case types.Delegate:
+		// The payload carries the candidate, optionally followed by a flag
+		// byte opting the delegation into reward auto-compounding
+		if len(msg.Data()) != common.AddressLength && len(msg.Data()) != common.AddressLength+1 {
+			return errors.New("invalid candidate in delegate payload")
+		}
+		if min := st.evm.ChainConfig().Dpos.MinDelegateBalance; min != nil && st.state.GetBalance(msg.From()).Cmp(min) < 0 {
+			return errors.New("balance below delegate minimum")
+		}
+		if err = st.checkDelegatorCap(msg.From(), common.BytesToAddress(msg.Data()[:common.AddressLength])); err != nil {
+			return err
+		}
+		// Stamp the vote so an expiry mode can tell re-confirmed votes from
+		// abandoned ones
+		if err = dposContext.Delegate(msg.From(), common.BytesToAddress(msg.Data()[:common.AddressLength])); err == nil {
+			err = dposContext.SetVoteTime(msg.From(), st.evm.Time.Int64())
+		}
+		if err == nil {
+			compound := len(msg.Data()) == common.AddressLength+1 && msg.Data()[common.AddressLength] != 0
+			err = dposContext.SetCompounding(msg.From(), compound)
+		}
+	case types.UnDelegate:
+		if len(msg.Data()) != common.AddressLength {
+			return errors.New("invalid candidate in undelegate payload")
+		}
+		if err = dposContext.UnDelegate(msg.From(), common.BytesToAddress(msg.Data())); err == nil {
+			err = dposContext.ClearVoteTime(msg.From())
+		}
+		// Undelegating pays out compounded rewards and ends the compounding
+		if err == nil {
+			if bonded := dposContext.BondedReward(msg.From()); bonded.Sign() > 0 {
+				if err = dposContext.ClearBondedReward(msg.From()); err == nil {
+					st.state.AddBalance(msg.From(), bonded)
+				}
+			}
+		}
+		if err == nil {
+			err = dposContext.SetCompounding(msg.From(), false)
+		}
+	case types.MetaVote:
+		err = st.applyMetaVote(msg)
+	case types.SetCommission:
+		var data []byte
+		if data, err = st.authorizeCandidateOp(msg.From(), types.SetCommission, msg.Data()); err == nil {
+			if len(data) != 1 {
+				return errors.New("invalid rate in set commission payload")
+			}
+			err = dposContext.SetCommission(msg.From(), uint64(data[0]), st.evm.Time.Int64())
+		}
+	case types.ForceValidators:
+		err = st.applyValidatorOverride(msg)
+	case types.ReportEvidence:
+		err = st.applyEvidence(msg)
+	case types.Redelegate:
+		err = st.applyRedelegate(msg)
+	case types.ProxyVoteTx:
+		err = st.applyProxyVote(msg)
+	case types.BlacklistCandidate:
+		err = st.applyBlacklist(msg)
+	case types.RotateSigner:
+		err = st.applyRotateSigner(msg)
+	case types.SetCandidateOwners:
+		err = st.applySetCandidateOwners(msg)
+	case types.SetOperatorGroup:
+		err = st.applySetOperatorGroup(msg)
+	case types.SetRewardRecipient:
+		err = st.applySetRewardRecipient(msg)
+	case types.VoteBlockInterval:
+		err = st.applyBlockIntervalVote(msg)
+	case types.ScheduleExit:
+		err = st.applyScheduleExit(msg)
+	case types.RewardDistribution, types.EpochMaintenance:
+		// System transactions are synthesized and applied by the block
+		// processor directly; a user-submitted copy is invalid
+		err = errors.New("system transactions cannot be submitted by users")
+	default:
+		return errors.New("unknown dpos transaction type")
+	}
+	if err != nil {
+		dposContext.RevertToSnapShot(snapshot)
+	} else {
+		st.notifyWeightHooks(msg)
+	}
+	return err
+}
+
+// Vote weight change kinds delivered to hook subscribers.
+const (
+	voteWeightStaked   = byte(0) // Stake delegated onto the candidate
+	voteWeightUnstaked = byte(1) // Delegation withdrawn from the candidate
+)
+
+// notifyWeightHooks delivers a vote weight change to the contracts opted into
+// the registry precompile, so liquid staking style derivatives can mint and
+// burn in step with the delegations backing them. Each callback runs with a
+// fixed gas stipend and failures are logged and swallowed: a broken
+// subscriber must not be able to block staking operations. Weight changes
+// applied outside transaction processing — downtime slashes and vote expiry
+// pruning at epoch transitions — have no EVM to call into and are observable
+// through the election records instead.
+func (st *StateTransition) notifyWeightHooks(msg Message) {
+	subscribers := st.evm.DposContext.WeightHookSubscribers()
+	if len(subscribers) == 0 {
+		return
+	}
+	notify := func(candidate common.Address, kind byte) {
+		// onVoteWeightChanged(address account, address candidate, uint8 kind)
+		input := make([]byte, 4+3*32)
+		copy(input, vm.VoteWeightHookSelector)
+		copy(input[4+12:], msg.From().Bytes())
+		copy(input[36+12:], candidate.Bytes())
+		input[len(input)-1] = kind
+		for _, subscriber := range subscribers {
+			if _, _, err := st.evm.Call(vm.AccountRef(vm.VoteWeightRegistryAddr), subscriber, input, params.WeightHookCallGas, big.NewInt(0)); err != nil {
+				log.Warn("Vote weight hook failed", "subscriber", subscriber, "err", err)
+			}
+		}
+	}
+	switch msg.Type() {
+	case types.Delegate:
+		notify(common.BytesToAddress(msg.Data()[:common.AddressLength]), voteWeightStaked)
+	case types.UnDelegate:
+		notify(common.BytesToAddress(msg.Data()), voteWeightUnstaked)
+	case types.Redelegate:
+		notify(common.BytesToAddress(msg.Data()[:common.AddressLength]), voteWeightUnstaked)
+		notify(common.BytesToAddress(msg.Data()[common.AddressLength:]), voteWeightStaked)
+	}
+}
+
+// applyMetaVote applies a vote signed off-chain by a delegator and relayed
+// on-chain by an arbitrary sender. The delegation is attributed to the signer
+// of the typed message, not to the relayer, and the per-delegator vote nonce
+// protects against replays.
+func (st *StateTransition) applyMetaVote(msg Message) error {
+	dposContext := st.evm.DposContext
+
+	vote := new(types.MetaVoteMsg)
+	if err := rlp.DecodeBytes(msg.Data(), vote); err != nil {
+		return err
+	}
+	delegator, err := vote.Recover(st.evm.ChainConfig().ChainID)
+	if err != nil {
+		return err
+	}
+	if nonce := dposContext.VoteNonce(delegator); vote.Nonce != nonce {
+		return fmt.Errorf("invalid meta-vote nonce: have %d, want %d", vote.Nonce, nonce)
+	}
+	if min := st.evm.ChainConfig().Dpos.MinDelegateBalance; min != nil && st.state.GetBalance(delegator).Cmp(min) < 0 {
+		return errors.New("balance below delegate minimum")
+	}
+	if err := dposContext.Delegate(delegator, vote.Candidate); err != nil {
+		return err
+	}
+	if err := dposContext.SetVoteTime(delegator, st.evm.Time.Int64()); err != nil {
+		return err
+	}
+	return dposContext.SetVoteNonce(delegator, vote.Nonce+1)
+}
+
+// applyValidatorOverride applies a break-glass governance operation: a quorum
+// of the governance signers configured in genesis schedules a replacement
+// validator set, applied at the next epoch transition. Any account may submit
+// the transaction; authority comes from the signatures in the payload.
+func (st *StateTransition) applyValidatorOverride(msg Message) error {
+	config := st.evm.ChainConfig().Dpos
+	if len(config.GovernanceSigners) == 0 || config.GovernanceThreshold == 0 {
+		return errors.New("validator override disabled by configuration")
+	}
+	dposContext := st.evm.DposContext
+
+	override := new(types.ValidatorOverride)
+	if err := rlp.DecodeBytes(msg.Data(), override); err != nil {
+		return err
+	}
+	if len(override.Validators) == 0 {
+		return errors.New("empty validator override")
+	}
+	if nonce := dposContext.GovernanceNonce(); override.Nonce != nonce {
+		return fmt.Errorf("invalid override nonce: have %d, want %d", override.Nonce, nonce)
+	}
+	signers, err := override.RecoverSigners(st.evm.ChainConfig().ChainID)
+	if err != nil {
+		return err
+	}
+	quorum := make(map[common.Address]struct{})
+	for _, signer := range signers {
+		authorized := false
+		for _, governor := range config.GovernanceSigners {
+			if signer == governor {
+				authorized = true
+				break
+			}
+		}
+		if !authorized {
+			return fmt.Errorf("override signer %x is not a governance signer", signer)
+		}
+		quorum[signer] = struct{}{}
+	}
+	if uint64(len(quorum)) < config.GovernanceThreshold {
+		return fmt.Errorf("override quorum not met: have %d, want %d", len(quorum), config.GovernanceThreshold)
+	}
+	if err := dposContext.SetPendingValidatorOverride(override.Validators); err != nil {
+		return err
+	}
+	if err := dposContext.SetGovernanceNonce(override.Nonce + 1); err != nil {
+		return err
+	}
+	log.Warn("Governance validator override scheduled", "validators", len(override.Validators), "signers", len(quorum), "nonce", override.Nonce)
+	return nil
+}
+
+// applyBlacklist applies a governance blacklist entry: a quorum of the
+// governance signers bars an address from standing for election until the
+// expiry time. An expiry at or before the current block time lifts an
+// earlier entry. Any account may submit the transaction; authority comes
+// from the signatures in the payload.
+func (st *StateTransition) applyBlacklist(msg Message) error {
+	config := st.evm.ChainConfig().Dpos
+	if len(config.GovernanceSigners) == 0 || config.GovernanceThreshold == 0 {
+		return errors.New("governance blacklist disabled by configuration")
+	}
+	dposContext := st.evm.DposContext
+
+	blacklist := new(types.Blacklist)
+	if err := rlp.DecodeBytes(msg.Data(), blacklist); err != nil {
+		return err
+	}
+	if nonce := dposContext.GovernanceNonce(); blacklist.Nonce != nonce {
+		return fmt.Errorf("invalid blacklist nonce: have %d, want %d", blacklist.Nonce, nonce)
+	}
+	signers, err := blacklist.RecoverSigners(st.evm.ChainConfig().ChainID)
+	if err != nil {
+		return err
+	}
+	quorum := make(map[common.Address]struct{})
+	for _, signer := range signers {
+		authorized := false
+		for _, governor := range config.GovernanceSigners {
+			if signer == governor {
+				authorized = true
+				break
+			}
+		}
+		if !authorized {
+			return fmt.Errorf("blacklist signer %x is not a governance signer", signer)
+		}
+		quorum[signer] = struct{}{}
+	}
+	if uint64(len(quorum)) < config.GovernanceThreshold {
+		return fmt.Errorf("blacklist quorum not met: have %d, want %d", len(quorum), config.GovernanceThreshold)
+	}
+	expiry := int64(blacklist.Expiry)
+	if expiry <= st.evm.Time.Int64() {
+		// An expiry in the past lifts an earlier entry
+		expiry = 0
+	}
+	if err := dposContext.SetBlacklist(blacklist.Account, expiry); err != nil {
+		return err
+	}
+	if err := dposContext.SetGovernanceNonce(blacklist.Nonce + 1); err != nil {
+		return err
+	}
+	log.Warn("Governance blacklist updated", "account", blacklist.Account, "expiry", expiry, "signers", len(quorum), "nonce", blacklist.Nonce)
+	return nil
+}
+
+// applyRotateSigner schedules a new sealing key for the sender, an active
+// candidate, effective at the next epoch. Delegations stay untouched: votes
+// bind to the candidate identity, not the key that signs its blocks.
+func (st *StateTransition) applyRotateSigner(msg Message) error {
+	dposContext := st.evm.DposContext
+	data, err := st.authorizeCandidateOp(msg.From(), types.RotateSigner, msg.Data())
+	if err != nil {
+		return err
+	}
+	if len(data) != common.AddressLength {
+		return errors.New("invalid signer in rotation payload")
+	}
+	newSigner := common.BytesToAddress(data)
+	if newSigner == (common.Address{}) {
+		return errors.New("cannot rotate to the zero address")
+	}
+	candidate := msg.From()
+	registered, err := dposContext.CandidateTrie().TryGet(candidate.Bytes())
+	if err != nil {
+		return err
+	}
+	if registered == nil {
+		return errors.New("signer rotation requires a registered candidate")
+	}
+	// Activate at the next epoch boundary so the signer never changes
+	// mid-epoch under the slot schedule
+	epoch := st.evm.Time.Int64() / st.evm.ChainConfig().Dpos.EpochIntervalSeconds()
+	return dposContext.RotateSigner(candidate, newSigner, epoch+1)
+}
+
+// applyScheduleExit records, or with a zero epoch cancels, the sender's
+// planned departure. The effective epoch must lie in the future, so an
+// announcement never touches the duties of the running epoch; elections
+// from the effective epoch on skip the candidate and its wind-down is not
+// judged as a liveness failure.
+func (st *StateTransition) applyScheduleExit(msg Message) error {
+	dposContext := st.evm.DposContext
+	data, err := st.authorizeCandidateOp(msg.From(), types.ScheduleExit, msg.Data())
+	if err != nil {
+		return err
+	}
+	if len(data) != 8 {
+		return errors.New("invalid epoch in exit payload")
+	}
+	if !dposContext.IsCandidate(msg.From()) {
+		return errors.New("exit scheduling requires a registered candidate")
+	}
+	epoch := int64(binary.BigEndian.Uint64(data))
+	if epoch != 0 && epoch <= st.evm.Time.Int64()/st.evm.ChainConfig().Dpos.EpochIntervalSeconds() {
+		return errors.New("exit epoch must lie in the future")
+	}
+	return dposContext.SetExitEpoch(msg.From(), epoch)
+}
+
+// authorizeCandidateOp checks the owner authorization of a candidate
+// management operation and returns its inner payload. Candidates without an
+// owner set are controlled by their own key, so the payload passes through
+// untouched. Candidates under multisig control must wrap the payload in a
+// CandidateAuthorization carrying a threshold of owner signatures; the owner
+// nonce is advanced so the signatures cannot be replayed.
+func (st *StateTransition) authorizeCandidateOp(candidate common.Address, txType types.TxType, data []byte) ([]byte, error) {
+	dposContext := st.evm.DposContext
+	owners, threshold := dposContext.CandidateOwners(candidate)
+	if len(owners) == 0 {
+		return data, nil
+	}
+	auth := new(types.CandidateAuthorization)
+	if err := rlp.DecodeBytes(data, auth); err != nil {
+		return nil, err
+	}
+	if nonce := dposContext.CandidateOwnerNonce(candidate); auth.Nonce != nonce {
+		return nil, fmt.Errorf("invalid owner nonce: have %d, want %d", auth.Nonce, nonce)
+	}
+	signers, err := auth.RecoverSigners(st.evm.ChainConfig().ChainID, candidate, txType)
+	if err != nil {
+		return nil, err
+	}
+	quorum := make(map[common.Address]struct{})
+	for _, signer := range signers {
+		authorized := false
+		for _, owner := range owners {
+			if signer == owner {
+				authorized = true
+				break
+			}
+		}
+		if !authorized {
+			return nil, fmt.Errorf("signer %x is not a candidate owner", signer)
+		}
+		quorum[signer] = struct{}{}
+	}
+	if uint64(len(quorum)) < threshold {
+		return nil, fmt.Errorf("owner quorum not met: have %d, want %d", len(quorum), threshold)
+	}
+	if err := dposContext.SetCandidateOwnerNonce(candidate, auth.Nonce+1); err != nil {
+		return nil, err
+	}
+	return auth.Payload, nil
+}
+
+// applySetOperatorGroup declares the operator group of the sender, an active
+// candidate, feeding the anti-correlation penalties of the epoch transition.
+// The zero group clears the declaration.
+func (st *StateTransition) applySetOperatorGroup(msg Message) error {
+	dposContext := st.evm.DposContext
+	candidate := msg.From()
+	registered, err := dposContext.CandidateTrie().TryGet(candidate.Bytes())
+	if err != nil {
+		return err
+	}
+	if registered == nil {
+		return errors.New("operator group requires a registered candidate")
+	}
+	data, err := st.authorizeCandidateOp(candidate, types.SetOperatorGroup, msg.Data())
+	if err != nil {
+		return err
+	}
+	if len(data) != common.HashLength {
+		return errors.New("invalid operator group payload")
+	}
+	return dposContext.SetOperatorGroup(candidate, common.BytesToHash(data))
+}
+
+// applySetRewardRecipient registers the address the sender's reward payouts
+// are sent to. The sender must be a registered candidate; the zero recipient
+// clears the registration, paying the candidate directly again.
+func (st *StateTransition) applySetRewardRecipient(msg Message) error {
+	dposContext := st.evm.DposContext
+	candidate := msg.From()
+	registered, err := dposContext.CandidateTrie().TryGet(candidate.Bytes())
+	if err != nil {
+		return err
+	}
+	if registered == nil {
+		return errors.New("reward recipient requires a registered candidate")
+	}
+	data, err := st.authorizeCandidateOp(candidate, types.SetRewardRecipient, msg.Data())
+	if err != nil {
+		return err
+	}
+	if len(data) != common.AddressLength {
+		return errors.New("invalid reward recipient payload")
+	}
+	return dposContext.SetRewardRecipient(candidate, common.BytesToAddress(data))
+}
+
+// applyBlockIntervalVote records the block interval the sending candidate
+// prefers the network to run at. The epoch election adopts a new interval
+// when a supermajority of the elected validators voted for the same value;
+// votes must fall within the governance bounds the chain configures.
+func (st *StateTransition) applyBlockIntervalVote(msg Message) error {
+	config := st.evm.ChainConfig().Dpos
+	if config.MinBlockInterval == 0 || config.MaxBlockInterval < config.MinBlockInterval {
+		return errors.New("block interval voting disabled by configuration")
+	}
+	dposContext := st.evm.DposContext
+	candidate := msg.From()
+	registered, err := dposContext.CandidateTrie().TryGet(candidate.Bytes())
+	if err != nil {
+		return err
+	}
+	if registered == nil {
+		return errors.New("block interval vote requires a registered candidate")
+	}
+	data, err := st.authorizeCandidateOp(candidate, types.VoteBlockInterval, msg.Data())
+	if err != nil {
+		return err
+	}
+	if len(data) != 8 {
+		return errors.New("invalid block interval vote payload")
+	}
+	interval := binary.BigEndian.Uint64(data)
+	if interval < config.MinBlockInterval || interval > config.MaxBlockInterval {
+		return fmt.Errorf("block interval vote %d outside bounds [%d, %d]", interval, config.MinBlockInterval, config.MaxBlockInterval)
+	}
+	return dposContext.SetBlockIntervalVote(candidate, interval)
+}
+
+// applySetCandidateOwners records the owner set controlling the sender's
+// candidate-management operations. The first owner set may be recorded by the
+// candidate's own key; changing or removing an existing one requires a
+// threshold of the current owners, like any other management operation.
+func (st *StateTransition) applySetCandidateOwners(msg Message) error {
+	dposContext := st.evm.DposContext
+	candidate := msg.From()
+	registered, err := dposContext.CandidateTrie().TryGet(candidate.Bytes())
+	if err != nil {
+		return err
+	}
+	if registered == nil {
+		return errors.New("owner set requires a registered candidate")
+	}
+	data, err := st.authorizeCandidateOp(candidate, types.SetCandidateOwners, msg.Data())
+	if err != nil {
+		return err
+	}
+	update := new(types.CandidateOwnerSet)
+	if err := rlp.DecodeBytes(data, update); err != nil {
+		return err
+	}
+	if len(update.Owners) == 0 {
+		if update.Threshold != 0 {
+			return errors.New("threshold without owners")
+		}
+		return dposContext.SetCandidateOwners(candidate, nil, 0)
+	}
+	if update.Threshold == 0 || update.Threshold > uint64(len(update.Owners)) {
+		return fmt.Errorf("invalid owner threshold %d for %d owners", update.Threshold, len(update.Owners))
+	}
+	seen := make(map[common.Address]struct{})
+	for _, owner := range update.Owners {
+		if owner == (common.Address{}) {
+			return errors.New("zero address cannot be a candidate owner")
+		}
+		if _, ok := seen[owner]; ok {
+			return fmt.Errorf("duplicate candidate owner %x", owner)
+		}
+		seen[owner] = struct{}{}
+	}
+	return dposContext.SetCandidateOwners(candidate, update.Owners, update.Threshold)
+}
+
+// applyRedelegate moves the sender's vote from one candidate to another in a
+// single atomic operation, subject to the per-epoch redelegation limit that
+// keeps votes from being shuffled rapidly around an election.
+func (st *StateTransition) applyRedelegate(msg Message) error {
+	dposContext := st.evm.DposContext
+	data := msg.Data()
+	if len(data) != 2*common.AddressLength {
+		return errors.New("invalid candidate pair in redelegate payload")
+	}
+	from := common.BytesToAddress(data[:common.AddressLength])
+	to := common.BytesToAddress(data[common.AddressLength:])
+	if err := st.checkDelegatorCap(msg.From(), to); err != nil {
+		return err
+	}
+
+	config := st.evm.ChainConfig().Dpos
+	epoch := st.evm.Time.Int64() / config.EpochIntervalSeconds()
+	if limit := config.MaxRedelegationsPerEpoch; limit > 0 {
+		if cnt := dposContext.RedelegationCount(epoch, msg.From()); cnt >= limit {
+			return errors.New("redelegation limit for the epoch reached")
+		}
+	}
+	if err := dposContext.Redelegate(msg.From(), from, to); err != nil {
+		return err
+	}
+	// A redelegation re-confirms the vote like a fresh delegate operation
+	if err := dposContext.SetVoteTime(msg.From(), st.evm.Time.Int64()); err != nil {
+		return err
+	}
+	return dposContext.IncRedelegationCount(epoch, msg.From())
+}
+
+// delegatorCapTopic identifies the log left behind when a vote is rejected
+// because the candidate reached the configured delegator cap, so wallets can
+// tell the rejection apart from other vote failures in the receipt.
+var delegatorCapTopic = common.BytesToHash(crypto.Keccak256([]byte("DelegatorCapExceeded(address,address)")))
+
+// checkDelegatorCap rejects a delegation that would push the candidate past
+// the configured distinct-delegator cap. Re-confirming or moving a vote the
+// candidate already counts stays allowed. Alongside the failed receipt
+// status, the rejection leaves a log carrying the delegator and candidate;
+// the delegate trie walk behind the count stops at the cap, so the check
+// itself stays bounded.
+func (st *StateTransition) checkDelegatorCap(delegator, candidate common.Address) error {
+	config := st.evm.ChainConfig().Dpos
+	if config == nil || config.MaxDelegatorsPerCandidate == 0 {
+		return nil
+	}
+	dposContext := st.evm.DposContext
+	if current, _ := dposContext.VoteTrie().TryGet(delegator.Bytes()); len(current) == common.AddressLength && common.BytesToAddress(current) == candidate {
+		return nil
+	}
+	if dposContext.DelegatorCount(candidate, config.MaxDelegatorsPerCandidate) < config.MaxDelegatorsPerCandidate {
+		return nil
+	}
+	st.state.AddLog(&types.Log{
+		Address: types.DelegateAddr,
+		Topics:  []common.Hash{delegatorCapTopic, delegator.Hash(), candidate.Hash()},
+	})
+	return fmt.Errorf("candidate %x reached the delegator cap of %d", candidate, config.MaxDelegatorsPerCandidate)
+}
+
+// applyProxyVote replaces the sender's custodial vote allocations with the
+// ones in the payload. The chain only checks the per-candidate aggregates;
+// whether they honestly reflect the user preferences behind the merkle root
+// is between the custodian and its users, who can audit the root against the
+// published allocations.
+func (st *StateTransition) applyProxyVote(msg Message) error {
+	dposContext := st.evm.DposContext
+
+	vote := new(types.ProxyVote)
+	if err := rlp.DecodeBytes(msg.Data(), vote); err != nil {
+		return err
+	}
+	total, err := vote.Validate()
+	if err != nil {
+		return err
+	}
+	// A custodian cannot allocate more weight than the stake it holds
+	if total.Cmp(st.state.GetBalance(msg.From())) > 0 {
+		return errors.New("proxy vote exceeds custodian balance")
+	}
+	return dposContext.SetProxyVote(msg.From(), vote)
+}
+
+// applyEvidence slashes a validator proven to have sealed two conflicting
+// headers for the same slot. The offender loses the configured fraction of
+// its balance and its candidacy; the reporting sender collects a share of the
+// penalty and the remainder is burned.
+func (st *StateTransition) applyEvidence(msg Message) error {
+	config := st.evm.ChainConfig().Dpos
+	if config.EvidencePenaltyPercent == 0 {
+		return errors.New("evidence processing disabled by configuration")
+	}
+	dposContext := st.evm.DposContext
+
+	evidence := new(types.HeaderEvidence)
+	if err := rlp.DecodeBytes(msg.Data(), evidence); err != nil {
+		return err
+	}
+	offender, err := evidence.Verify()
+	if err != nil {
+		return err
+	}
+	if maxAge := config.EvidenceMaxAge; maxAge > 0 {
+		if offense := evidence.Header1.Number.Uint64(); offense+maxAge < st.evm.BlockNumber.Uint64() {
+			return errors.New("evidence expired")
+		}
+	}
+	id := evidence.ID()
+	if used, err := dposContext.EvidenceUsed(id); err != nil {
+		return err
+	} else if used {
+		return errors.New("evidence already reported")
+	}
+	penalty := new(big.Int).Mul(st.state.GetBalance(offender), new(big.Int).SetUint64(config.EvidencePenaltyPercent))
+	penalty.Div(penalty, big.NewInt(100))
+	reward := new(big.Int).Mul(penalty, new(big.Int).SetUint64(config.EvidenceRewardPercent))
+	reward.Div(reward, big.NewInt(100))
+	st.state.SubBalance(offender, penalty)
+	st.state.AddBalance(msg.From(), reward)
+	// Mirror the slash into the staking statistics account if the network
+	// exposes the counters to contracts
+	if config.MirrorStakingStats {
+		key := types.StakingStatSlot(offender, types.StakingStatsSlashesSlot)
+		counter := st.state.GetState(types.StakingStatsAddr, key).Big()
+		counter.Add(counter, penalty)
+		st.state.SetState(types.StakingStatsAddr, key, common.BigToHash(counter))
+	}
+	// The reporter's share changes hands, the remainder leaves the supply
+	if err := dposContext.SubIssuance(new(big.Int).Sub(penalty, reward)); err != nil {
+		return err
+	}
+	// A proven double-signer also forfeits its candidacy; an offender that
+	// never registered has none to lose
+	if err := dposContext.KickoutCandidate(offender); err != nil {
+		return err
+	}
+	if err := dposContext.MarkEvidenceUsed(id); err != nil {
+		return err
+	}
+	log.Warn("Slashed validator for double-signing", "offender", offender, "penalty", penalty, "reporter", msg.From(), "reward", reward)
+	return nil
+}
+
 func (st *StateTransition) refundGas() {
 	// Apply refund counter, capped to half of the used gas.
 	refund := st.gasUsed() / 2
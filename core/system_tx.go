@@ -0,0 +1,72 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// SystemTxEngine is implemented by consensus engines whose per-block
+// maintenance - reward distribution, slashing, epoch transitions - runs as
+// explicit system transactions instead of invisible state mutations inside
+// Finalize. The transactions are derived deterministically from the chain, so
+// the block producer and every verifier synthesize the same set: the producer
+// appends them after the user transactions, verifiers check the block carries
+// exactly the expected ones. Since they are part of the block body and earn
+// regular receipts, the maintenance operations show up in explorers and
+// traces like any other transaction.
+type SystemTxEngine interface {
+	// SystemTransactions returns the system transactions the block described
+	// by the given header must carry, in order.
+	SystemTransactions(chain consensus.ChainReader, header *types.Header) ([]*types.Transaction, error)
+
+	// ApplySystemTransaction executes a single system transaction against the
+	// given state. A failure invalidates the block.
+	ApplySystemTransaction(chain consensus.ChainReader, header *types.Header, state *state.StateDB, dposContext *types.DposContext, tx *types.Transaction) error
+}
+
+// DposMigrator is implemented by a consensus engine that migrates a chain
+// from a legacy engine to dpos at a fork block. MigrationContext builds the
+// dpos context the transition block starts from, derived deterministically
+// from the pre-fork chain so producer and verifiers agree on it.
+type DposMigrator interface {
+	MigrationContext(chain consensus.ChainReader, parent *types.Header) (*types.DposContext, error)
+}
+
+// ApplySystemTransaction executes a system transaction through the engine and
+// wraps the outcome in a receipt, mirroring what ApplyTransaction does for
+// user transactions. System transactions consume no gas, so the cumulative
+// gas of the enclosing block passes through unchanged.
+func ApplySystemTransaction(engine SystemTxEngine, chain consensus.ChainReader, config *params.ChainConfig, statedb *state.StateDB, header *types.Header, dposContext *types.DposContext, tx *types.Transaction, usedGas uint64) (*types.Receipt, error) {
+	if err := engine.ApplySystemTransaction(chain, header, statedb, dposContext, tx); err != nil {
+		return nil, err
+	}
+	var root []byte
+	if config.IsByzantium(header.Number) {
+		statedb.Finalise(true)
+	} else {
+		root = statedb.IntermediateRoot(config.IsEIP158(header.Number)).Bytes()
+	}
+	receipt := types.NewReceipt(root, false, usedGas)
+	receipt.TxHash = tx.Hash()
+	receipt.Logs = statedb.GetLogs(tx.Hash())
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	return receipt, nil
+}
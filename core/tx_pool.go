@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 )
 
@@ -56,6 +57,26 @@ var (
 	// with a different one without the required price bump.
 	ErrReplaceUnderpriced = errors.New("replacement transaction underpriced")
 
+	// ErrDposTxRateLimited is returned if an account submits dpos operations
+	// faster than the configured per account rate limit allows.
+	ErrDposTxRateLimited = errors.New("dpos transaction rate limited")
+
+	// ErrDposNotActivated is returned if a dpos operation is submitted on a
+	// migrating chain before the dpos fork block.
+	ErrDposNotActivated = errors.New("dpos not activated yet")
+
+	// ErrDuplicateEvidence is returned if double-sign evidence with the same
+	// pair of conflicting headers was already accepted by the pool.
+	ErrDuplicateEvidence = errors.New("duplicate double-sign evidence")
+
+	// ErrStaleEvidence is returned if double-sign evidence exceeds the
+	// configured maximum age and could no longer be applied on chain.
+	ErrStaleEvidence = errors.New("stale double-sign evidence")
+
+	// ErrSystemTx is returned if a transaction targets one of the system
+	// maintenance addresses reserved for the consensus engine.
+	ErrSystemTx = errors.New("system transactions cannot be submitted by users")
+
 	// ErrInsufficientFunds is returned if the total cost of executing a transaction
 	// is higher than the balance of the user's account.
 	ErrInsufficientFunds = errors.New("insufficient funds for gas * price + value")
@@ -121,6 +142,12 @@ type blockChain interface {
 	SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription
 }
 
+// minGasPriceReader is implemented by chains whose validators vote a network
+// minimum gas price in; the pool refreshes the floor on every head change.
+type minGasPriceReader interface {
+	MinGasPrice() uint64
+}
+
 // TxPoolConfig are the configuration parameters of the transaction pool.
 type TxPoolConfig struct {
 	NoLocals  bool          // Whether local transaction handling should be disabled
@@ -136,6 +163,8 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	DposTxInterval time.Duration // Minimum delay between two dpos operations of one account, zero disables the limit
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -153,6 +182,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	DposTxInterval: 10 * time.Second,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -196,15 +227,19 @@ type TxPool struct {
 	currentState  *state.StateDB      // Current state in the blockchain head
 	pendingState  *state.ManagedState // Pending state tracking virtual nonces
 	currentMaxGas uint64              // Current gas limit for transaction caps
+	minGasPrice   uint64              // Validator-voted network gas price floor, zero when unset
 
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
 
-	pending map[common.Address]*txList   // All currently processable transactions
-	queue   map[common.Address]*txList   // Queued but non-processable transactions
-	beats   map[common.Address]time.Time // Last heartbeat from each known account
-	all     *txLookup                    // All transactions to allow lookups
-	priced  *txPricedList                // All transactions sorted by price
+	pending       map[common.Address]*txList   // All currently processable transactions
+	queue         map[common.Address]*txList   // Queued but non-processable transactions
+	beats         map[common.Address]time.Time // Last heartbeat from each known account
+	lastDposTx    map[common.Address]time.Time // Last accepted dpos operation per account, for rate limiting
+	knownEvidence map[common.Hash]uint64       // Double-sign evidence ids seen, mapped to the block they arrived at
+	validatorLane map[common.Hash]struct{}     // Locally submitted validator ops shielded from price eviction
+	all           *txLookup                    // All transactions to allow lookups
+	priced        *txPricedList                // All transactions sorted by price
 
 	wg sync.WaitGroup // for shutdown sync
 
@@ -219,16 +254,19 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 
 	// Create the transaction pool with its initial settings
 	pool := &TxPool{
-		config:      config,
-		chainconfig: chainconfig,
-		chain:       chain,
-		signer:      types.NewEIP155Signer(chainconfig.ChainID),
-		pending:     make(map[common.Address]*txList),
-		queue:       make(map[common.Address]*txList),
-		beats:       make(map[common.Address]time.Time),
-		all:         newTxLookup(),
-		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
-		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
+		config:        config,
+		chainconfig:   chainconfig,
+		chain:         chain,
+		signer:        types.NewEIP155Signer(chainconfig.ChainID),
+		pending:       make(map[common.Address]*txList),
+		queue:         make(map[common.Address]*txList),
+		beats:         make(map[common.Address]time.Time),
+		lastDposTx:    make(map[common.Address]time.Time),
+		knownEvidence: make(map[common.Hash]uint64),
+		validatorLane: make(map[common.Hash]struct{}),
+		all:           newTxLookup(),
+		chainHeadCh:   make(chan ChainHeadEvent, chainHeadChanSize),
+		gasPrice:      new(big.Int).SetUint64(config.PriceLimit),
 	}
 	pool.locals = newAccountSet(pool.signer)
 	pool.priced = newTxPricedList(pool.all)
@@ -408,10 +446,16 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.currentState = statedb
 	pool.pendingState = state.ManageState(statedb)
 	pool.currentMaxGas = newHead.GasLimit
+	// Refresh the validator-voted gas price floor of the network
+	if reader, ok := pool.chain.(minGasPriceReader); ok {
+		pool.minGasPrice = reader.MinGasPrice()
+	}
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
+	pool.forgiveDposRateLimits(reinject)
+	pool.pruneKnownEvidence(newHead.Number.Uint64())
 	pool.addTxsLocked(reinject, false)
 
 	// validate the pool of pending transactions, this will remove
@@ -577,6 +621,11 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	//if !local && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
 	//	return ErrUnderpriced
 	//}
+	// The validator-voted network floor binds everyone, locals included:
+	// blocks carrying cheaper transactions are rejected in consensus
+	if pool.minGasPrice > 0 && tx.GasPrice().Cmp(new(big.Int).SetUint64(pool.minGasPrice)) < 0 {
+		return ErrUnderpriced
+	}
 	// Ensure the transaction adheres to nonce ordering
 	if pool.currentState.GetNonce(from) > tx.Nonce() {
 		return ErrNonceTooLow
@@ -590,12 +639,118 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return err
 	}
+	intrGas += DposIntrinsicGas(tx.Type())
 	if tx.Gas() < intrGas {
 		return ErrIntrinsicGas
 	}
+	// Apply the dpos anti-spam rules before the operation enters the pool
+	if pool.chainconfig.Dpos != nil && tx.Type() != types.Binary {
+		// Dpos operations cannot execute until the chain has migrated
+		if next := new(big.Int).Add(pool.chain.CurrentBlock().Number(), common.Big1); !pool.chainconfig.IsDpos(next) {
+			return ErrDposNotActivated
+		}
+		if err := pool.validateDposTx(from, tx, local); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateDposTx enforces the anti-spam rules for dpos operations: minimum
+// balances for candidacy and delegation plus a per account rate limit, so the
+// tries every election has to iterate cannot be bloated cheaply.
+func (pool *TxPool) validateDposTx(from common.Address, tx *types.Transaction, local bool) error {
+	dposConfig := pool.chainconfig.Dpos
+	switch tx.Type() {
+	case types.LoginCandidate:
+		if min := dposConfig.MinCandidateBalance; min != nil && pool.currentState.GetBalance(from).Cmp(min) < 0 {
+			return ErrInsufficientFunds
+		}
+	case types.Delegate:
+		if min := dposConfig.MinDelegateBalance; min != nil && pool.currentState.GetBalance(from).Cmp(min) < 0 {
+			return ErrInsufficientFunds
+		}
+	case types.ReportEvidence:
+		if err := pool.validateEvidence(tx); err != nil {
+			return err
+		}
+	}
+	// System transactions are synthesized by the consensus engine and never
+	// enter via the pool, whatever they are signed with
+	if tx.Type().IsSystem() {
+		return ErrSystemTx
+	}
+	// Locally submitted validator housekeeping is exempt from the rate limit:
+	// an evidence report or key rotation must not queue behind an unrelated
+	// operation the same account sent moments earlier
+	if local && tx.Type().IsValidatorOp() {
+		return nil
+	}
+	if pool.config.DposTxInterval > 0 {
+		if last, ok := pool.lastDposTx[from]; ok && time.Since(last) < pool.config.DposTxInterval {
+			return ErrDposTxRateLimited
+		}
+		pool.lastDposTx[from] = time.Now()
+	}
+	return nil
+}
+
+// validateEvidence checks a double-sign report before it enters the pool: the
+// proof must verify on its own, be recent enough to still apply and not
+// duplicate evidence the pool already accepted.
+func (pool *TxPool) validateEvidence(tx *types.Transaction) error {
+	evidence := new(types.HeaderEvidence)
+	if err := rlp.DecodeBytes(tx.Data(), evidence); err != nil {
+		return err
+	}
+	if _, err := evidence.Verify(); err != nil {
+		return err
+	}
+	current := pool.chain.CurrentBlock().NumberU64()
+	if maxAge := pool.chainconfig.Dpos.EvidenceMaxAge; maxAge > 0 {
+		if offense := evidence.Header1.Number.Uint64(); offense+maxAge <= current {
+			return ErrStaleEvidence
+		}
+	}
+	id := evidence.ID()
+	if _, ok := pool.knownEvidence[id]; ok {
+		return ErrDuplicateEvidence
+	}
+	pool.knownEvidence[id] = current
 	return nil
 }
 
+// pruneKnownEvidence drops evidence ids the chain has moved past the maximum
+// age of, so the deduplication set cannot grow without bound.
+func (pool *TxPool) pruneKnownEvidence(current uint64) {
+	if pool.chainconfig.Dpos == nil || pool.chainconfig.Dpos.EvidenceMaxAge == 0 {
+		return
+	}
+	for id, seen := range pool.knownEvidence {
+		if seen+pool.chainconfig.Dpos.EvidenceMaxAge < current {
+			delete(pool.knownEvidence, id)
+		}
+	}
+}
+
+// forgiveDposRateLimits clears the rate limiter entries of the senders of the
+// given dpos operations. A reorg drops operations that already passed the
+// rate limit once when they first entered the chain; without forgiveness the
+// limiter would reject their reinjection and the votes would be lost.
+func (pool *TxPool) forgiveDposRateLimits(txs types.Transactions) {
+	if pool.chainconfig.Dpos == nil {
+		return
+	}
+	for _, tx := range txs {
+		if tx.Type() == types.Binary {
+			continue
+		}
+		if from, err := types.Sender(pool.signer, tx); err == nil {
+			delete(pool.lastDposTx, from)
+		}
+	}
+}
+
 // add validates a transaction and inserts it into the non-executable queue for
 // later pending promotion and execution. If the transaction is a replacement for
 // an already pending or queued one, it overwrites the previous and returns this
@@ -628,6 +783,13 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		// New transaction is better than our worse ones, make room for it
 		drop := pool.priced.Discard(pool.all.Count()-int(pool.config.GlobalSlots+pool.config.GlobalQueue-1), pool.locals)
 		for _, tx := range drop {
+			// Validator housekeeping in the protected lane stays pooled
+			// whatever its price; put it back and let the price pressure
+			// fall on the remaining candidates
+			if _, protected := pool.validatorLane[tx.Hash()]; protected {
+				pool.priced.Put(tx)
+				continue
+			}
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
 			pool.removeTx(tx.Hash(), false)
@@ -636,8 +798,15 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	// If the transaction is replacing an already pending one, do directly
 	from, _ := types.Sender(pool.signer, tx) // already validated
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
-		// Nonce already pending, check if required price bump is met
-		inserted, old := list.Add(tx, pool.config.PriceBump)
+		// Nonce already pending, check if required price bump is met. The
+		// protected lane has its own replacement rule: a local validator op
+		// replaces its predecessor at any higher price, so correcting an
+		// urgent operation is not held hostage by the bump heuristic
+		bump := pool.config.PriceBump
+		if local && tx.Type().IsValidatorOp() {
+			bump = 0
+		}
+		inserted, old := list.Add(tx, bump)
 		if !inserted {
 			pendingDiscardCounter.Inc(1)
 			return false, ErrReplaceUnderpriced
@@ -646,10 +815,12 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		if old != nil {
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed()
+			delete(pool.validatorLane, old.Hash())
 			pendingReplaceCounter.Inc(1)
 		}
 		pool.all.Add(tx)
 		pool.priced.Put(tx)
+		pool.markValidatorLane(tx, local)
 		pool.journalTx(from, tx)
 
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
@@ -664,6 +835,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	pool.markValidatorLane(tx, local)
 	// Mark local addresses and journal local transactions
 	//if local {
 	//	pool.locals.add(from)
@@ -704,6 +876,32 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 	return old != nil, nil
 }
 
+// validatorLaneSlots bounds the protected lane holding locally submitted
+// validator housekeeping operations. The bound keeps a misbehaving local
+// client from turning the protection into an eviction bypass for bulk spam.
+const validatorLaneSlots = 64
+
+// markValidatorLane shields a locally submitted validator housekeeping
+// operation from price-based eviction. Entries whose transactions left the
+// pool through paths that don't unmark them are swept once the lane fills up.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) markValidatorLane(tx *types.Transaction, local bool) {
+	if !local || !tx.Type().IsValidatorOp() {
+		return
+	}
+	if len(pool.validatorLane) >= validatorLaneSlots {
+		for hash := range pool.validatorLane {
+			if pool.all.Get(hash) == nil {
+				delete(pool.validatorLane, hash)
+			}
+		}
+	}
+	if len(pool.validatorLane) < validatorLaneSlots {
+		pool.validatorLane[tx.Hash()] = struct{}{}
+	}
+}
+
 // journalTx adds the specified transaction to the local disk journal if it is
 // deemed to have been sent from a local account.
 func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
@@ -872,6 +1070,7 @@ func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
 
 	// Remove it from the list of known transactions
 	pool.all.Remove(hash)
+	delete(pool.validatorLane, hash)
 	if outofbound {
 		pool.priced.Removed()
 	}
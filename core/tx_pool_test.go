@@ -77,6 +77,11 @@ func pricedTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ec
 	return tx
 }
 
+func delegateTransaction(nonce uint64, candidate common.Address, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.SignTx(types.NewDelegateTransaction(nonce, candidate, 100000, big.NewInt(1)), types.HomesteadSigner{}, key)
+	return tx
+}
+
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
 	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
@@ -126,7 +131,7 @@ func validateEvents(events chan NewTxsEvent, count int) error {
 		case ev := <-events:
 			received = append(received, ev.Txs...)
 		case <-time.After(time.Second):
-			return fmt.Errorf("event #%d not fired", received)
+			return fmt.Errorf("event #%d not fired", len(received))
 		}
 	}
 	if len(received) > count {
@@ -356,6 +361,54 @@ func TestTransactionChainFork(t *testing.T) {
 	}
 }
 
+// Tests that dpos operations dropped by a reorg are exempt from the dpos rate
+// limit when they are reinjected into the pool, so votes crossing an epoch
+// boundary reorg are not lost.
+func TestTransactionDposReorgReinjection(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	chainconfig := *params.TestChainConfig
+	chainconfig.Dpos = &params.DposConfig{}
+
+	poolconfig := testTxPoolConfig
+	poolconfig.DposTxInterval = time.Hour
+
+	pool := NewTxPool(poolconfig, &chainconfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	account := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(account, big.NewInt(100000000000000))
+
+	candidate := common.HexToAddress("0x01")
+	tx := delegateTransaction(0, candidate, key)
+	if err := pool.AddRemote(tx); err != nil {
+		t.Fatalf("failed to add dpos transaction: %v", err)
+	}
+	// A follow-up operation within the interval is rate limited
+	if err := pool.AddRemote(delegateTransaction(1, candidate, key)); err != ErrDposTxRateLimited {
+		t.Fatalf("follow-up error mismatch: have %v, want %v", err, ErrDposTxRateLimited)
+	}
+	// Drop the operation as a reorg would and reinject it the way reset does
+	pool.removeTx(tx.Hash(), true)
+
+	pool.mu.Lock()
+	pool.forgiveDposRateLimits(types.Transactions{tx})
+	pool.addTxsLocked(types.Transactions{tx}, false)
+	pool.mu.Unlock()
+
+	pending, err := pool.Pending()
+	if err != nil {
+		t.Fatalf("failed to fetch pending transactions: %v", err)
+	}
+	if len(pending[account]) != 1 {
+		t.Fatalf("reinjected dpos transactions: have %d, want 1", len(pending[account]))
+	}
+}
+
 func TestTransactionDoubleNonce(t *testing.T) {
 	t.Parallel()
 
@@ -33,6 +33,10 @@ type Validator interface {
 	// ValidateState validates the given statedb and optionally the receipts and
 	// gas used.
 	ValidateState(block, parent *types.Block, state *state.StateDB, receipts types.Receipts, usedGas uint64) error
+
+	// ValidateDposState validates the dpos context of the processed block
+	// against the trie roots recorded in its header.
+	ValidateDposState(block *types.Block) error
 }
 
 // Processor is an interface for processing blocks using a given initial state.
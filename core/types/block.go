@@ -83,6 +83,10 @@ type Header struct {
 	Extra       []byte         `json:"extraData"        gencodec:"required"`
 	MixDigest   common.Hash    `json:"mixHash"          gencodec:"required"`
 	Nonce       BlockNonce     `json:"nonce"            gencodec:"required"`
+
+	// Consensus fields of the delegated proof-of-stake engine
+	Validator   common.Address    `json:"validator"`
+	DposContext *DposContextProto `json:"dposContext"      rlp:"nil"`
 }
 
 // field type overrides for gencodec
@@ -147,6 +151,11 @@ type Block struct {
 	uncles       []*Header
 	transactions Transactions
 
+	// DposContext carries the open dpos tries of the block while it is being
+	// processed. It is not part of the block encoding; the tries are rooted
+	// in the header through DposContextProto.
+	DposContext *DposContext
+
 	// caches
 	hash atomic.Value
 	size atomic.Value
@@ -253,6 +262,10 @@ func CopyHeader(h *Header) *Header {
 		cpy.Extra = make([]byte, len(h.Extra))
 		copy(cpy.Extra, h.Extra)
 	}
+	if h.DposContext != nil {
+		ctx := *h.DposContext
+		cpy.DposContext = &ctx
+	}
 	return &cpy
 }
 
@@ -312,6 +325,7 @@ func (b *Block) MixDigest() common.Hash   { return b.header.MixDigest }
 func (b *Block) Nonce() uint64            { return binary.BigEndian.Uint64(b.header.Nonce[:]) }
 func (b *Block) Bloom() Bloom             { return b.header.Bloom }
 func (b *Block) Coinbase() common.Address { return b.header.Coinbase }
+func (b *Block) Validator() common.Address { return b.header.Validator }
 func (b *Block) Root() common.Hash        { return b.header.Root }
 func (b *Block) ParentHash() common.Hash  { return b.header.ParentHash }
 func (b *Block) TxHash() common.Hash      { return b.header.TxHash }
@@ -319,6 +333,9 @@ func (b *Block) ReceiptHash() common.Hash { return b.header.ReceiptHash }
 func (b *Block) UncleHash() common.Hash   { return b.header.UncleHash }
 func (b *Block) Extra() []byte            { return common.CopyBytes(b.header.Extra) }
 
+// DposCtx returns the open dpos tries attached to the block during processing.
+func (b *Block) DposCtx() *DposContext { return b.DposContext }
+
 func (b *Block) Header() *Header { return CopyHeader(b.header) }
 
 // Body returns the non-header content of the block.
@@ -360,6 +377,7 @@ func (b *Block) WithSeal(header *Header) *Block {
 		header:       &cpy,
 		transactions: b.transactions,
 		uncles:       b.uncles,
+		DposContext:  b.DposContext,
 	}
 }
 
@@ -0,0 +1,1862 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Keys of the sole entries kept in the epoch trie.
+var (
+	epochValidatorKey = []byte("validator")
+
+	// epochChangeKey is the epoch trie key holding the canonical record of
+	// the transition that installed the current validator set, so light
+	// clients can prove an election outcome with a single trie proof.
+	epochChangeKey = []byte("change")
+
+	// epochLayoutKey is the epoch trie key holding the slot layout cycle of
+	// a stake-weighted epoch: a sequence of indexes into the validator set,
+	// repeated over the epoch's slots. Absent under uniform round robin.
+	epochLayoutKey = []byte("layout")
+
+	// epochIntervalKey is the epoch trie key holding the block interval, in
+	// seconds, the epoch runs at on networks with adaptive interval voting.
+	// Absent, the network default applies.
+	epochIntervalKey = []byte("interval")
+
+	// voteNonceSuffix is appended to a delegator address to form the vote
+	// trie key under which the delegator's meta-vote nonce is stored. The
+	// suffix keeps nonce entries disjoint from the plain 20 byte delegator
+	// keys used for the vote mapping itself.
+	voteNonceSuffix = []byte(":nonce")
+
+	// balanceSumSuffix and balanceCntSuffix are appended to a delegator
+	// address to form the vote trie keys under which the balance samples of
+	// the running epoch accumulate, backing the average-balance vote
+	// weighting mode.
+	balanceSumSuffix = []byte(":balsum")
+	balanceCntSuffix = []byte(":balcnt")
+
+	// voteTimeSuffix is appended to a delegator address to form the vote
+	// trie key under which the unix time of the delegator's last vote
+	// confirmation is stored, backing the vote expiry mode.
+	voteTimeSuffix = []byte(":voted")
+
+	// redelegateSuffix is appended to a delegator address to form the vote
+	// trie key counting the delegator's redelegations within the current
+	// epoch, backing the per-epoch redelegation limit.
+	redelegateSuffix = []byte(":redel")
+
+	// compoundSuffix is appended to a delegator address to form the vote
+	// trie key flagging the delegation as auto-compounding: reward cuts
+	// accrue as bonded stake instead of spendable balance.
+	compoundSuffix = []byte(":compound")
+
+	// bondedSuffix is appended to a delegator address to form the vote trie
+	// key under which the delegator's compounded rewards accumulate. Bonded
+	// rewards count toward vote weight and are paid out on undelegation.
+	bondedSuffix = []byte(":bonded")
+
+	// proxyRootSuffix and proxyAllocSuffix are appended to a custodian
+	// address to form the vote trie keys under which the merkle root and the
+	// allocation list of the custodian's proxy vote are stored.
+	proxyRootSuffix  = []byte(":proot")
+	proxyAllocSuffix = []byte(":palloc")
+
+	// proxyWeightSuffix is appended to a candidate address to form the
+	// candidate trie key under which the candidate's aggregate proxy vote
+	// weight, summed over all custodians, is stored.
+	proxyWeightSuffix = []byte(":proxy")
+
+	// kickoutCooldownSuffix is appended to a candidate address to form the
+	// candidate trie key under which the expiry time of a kickout cooldown is
+	// stored. While the cooldown lasts, the address may not re-register as a
+	// candidate.
+	kickoutCooldownSuffix = []byte(":kickout")
+
+	// commissionSuffix is appended to a candidate address to form the
+	// candidate trie key under which the candidate's commission rate is
+	// stored.
+	commissionSuffix = []byte(":commission")
+
+	// gasTargetSuffix is appended to a validator address to form the
+	// candidate trie key under which the validator's latest gas limit vote
+	// is stored.
+	gasTargetSuffix = []byte(":gastarget")
+
+	// gasPriceVoteSuffix is appended to a validator address to form the
+	// candidate trie key under which the validator's latest minimum gas
+	// price vote is stored.
+	gasPriceVoteSuffix = []byte(":gasprice")
+
+	// intervalVoteSuffix is appended to a candidate address to form the
+	// candidate trie key under which the candidate's preferred block
+	// interval is stored.
+	intervalVoteSuffix = []byte(":interval")
+
+	// gasFloorKey is the candidate trie key holding the network minimum gas
+	// price, recomputed from the validator votes at each epoch transition.
+	gasFloorKey = []byte("governance:gasfloor")
+
+	// signerRotationSuffix is appended to a candidate address to form the
+	// candidate trie key under which a sealing key rotation is stored. The
+	// rotation activates at an epoch boundary, never mid-epoch.
+	signerRotationSuffix = []byte(":rotation")
+
+	// candidateOwnersSuffix is appended to a candidate address to form the
+	// candidate trie key under which the owner set of a multisig-controlled
+	// candidate is stored. While an owner set exists, candidate-management
+	// operations require a threshold of owner signatures.
+	candidateOwnersSuffix = []byte(":owners")
+
+	// operatorGroupSuffix is appended to a candidate address to form the
+	// candidate trie key under which the candidate's declared operator group
+	// is stored. The key is longer than an address, so candidate iterations
+	// skip it.
+	operatorGroupSuffix = []byte(":group")
+
+	// rewardRecipientSuffix is appended to a candidate address to form the
+	// candidate trie key under which the candidate's registered reward
+	// recipient is stored. The key is longer than an address, so candidate
+	// iterations skip it.
+	rewardRecipientSuffix = []byte(":payout")
+
+	// depositSuffix is appended to a candidate address to form the candidate
+	// trie key under which the unrefunded part of the candidate's campaign
+	// deposit is stored. The deposit is earned back through block production
+	// and the remainder is forfeited when the candidate leaves the list.
+	depositSuffix = []byte(":deposit")
+
+	// blacklistSuffix is appended to an address to form the candidate trie
+	// key under which the expiry time of a governance blacklist entry is
+	// stored. While the entry lasts, the address may not register as a
+	// candidate and its votes are ignored by the tally.
+	blacklistSuffix = []byte(":blacklist")
+
+	// exitEpochSuffix is appended to a candidate address to form the
+	// candidate trie key under which the candidate's scheduled exit epoch is
+	// stored. Elections from that epoch on skip the candidate.
+	exitEpochSuffix = []byte(":exit")
+
+	// governanceNonceKey is the candidate trie key holding the replay
+	// protection nonce of governance operations. Its length differs from an
+	// address, so candidate iterations skip it.
+	governanceNonceKey = []byte("governance:nonce")
+
+	// governanceOverrideKey is the candidate trie key holding a pending
+	// validator override, applied and cleared at the next epoch transition.
+	governanceOverrideKey = []byte("governance:override")
+
+	// issuanceKey is the candidate trie key holding the cumulative net
+	// issuance of the chain: rewards minted minus slashed amounts burned.
+	// Its length differs from an address, so candidate iterations skip it.
+	issuanceKey = []byte("governance:issuance")
+
+	// weightHookKey is the candidate trie key holding the contracts opted
+	// into vote weight change callbacks. Its length differs from an address,
+	// so candidate iterations skip it.
+	weightHookKey = []byte("hooks:voteweight")
+
+	// evidenceUsedPrefix prefixes the candidate trie keys marking double-sign
+	// evidence as consumed. The keys are longer than an address, so candidate
+	// iterations skip them.
+	evidenceUsedPrefix = []byte("evidence:")
+)
+
+const (
+	// MaxCommissionRate is the highest commission, in percent, a candidate
+	// may deduct from delegator rewards.
+	MaxCommissionRate = uint64(100)
+
+	// commissionRaiseLimit is the largest step, in percentage points, by
+	// which a candidate may raise its commission at once.
+	commissionRaiseLimit = uint64(1)
+
+	// commissionRaiseWindow is the minimum number of seconds between two
+	// commission raises, matching the epoch length of the dpos engine.
+	// Together with commissionRaiseLimit it caps raises at one percentage
+	// point per epoch, so delegators have time to react.
+	commissionRaiseWindow = int64(86400)
+)
+
+// CandidateKey returns the candidate trie key of a candidate address.
+func CandidateKey(candidate common.Address) []byte {
+	return candidate.Bytes()
+}
+
+// CommissionKey returns the candidate trie key holding the commission rate of
+// the given candidate.
+func CommissionKey(candidate common.Address) []byte {
+	return append(candidate.Bytes(), commissionSuffix...)
+}
+
+// KickoutCooldownKey returns the candidate trie key holding the kickout
+// cooldown expiry of the given address.
+func KickoutCooldownKey(candidate common.Address) []byte {
+	return append(candidate.Bytes(), kickoutCooldownSuffix...)
+}
+
+// VoteKey returns the vote trie key of a delegator.
+func VoteKey(delegator common.Address) []byte {
+	return delegator.Bytes()
+}
+
+// VoteNonceKey returns the vote trie key holding the meta-vote nonce of the
+// given delegator.
+func VoteNonceKey(delegator common.Address) []byte {
+	return append(delegator.Bytes(), voteNonceSuffix...)
+}
+
+// IsBalanceSampleKey reports whether a vote trie key belongs to the balance
+// accumulators of the average-balance weighting mode.
+func IsBalanceSampleKey(key []byte) bool {
+	return bytes.HasSuffix(key, balanceSumSuffix) || bytes.HasSuffix(key, balanceCntSuffix)
+}
+
+// DelegateKey returns the delegate trie key of a candidate/delegator pair.
+func DelegateKey(candidate, delegator common.Address) []byte {
+	return append(candidate.Bytes(), delegator.Bytes()...)
+}
+
+// commissionEntry is the rlp encoded value stored under a candidate's
+// commission key, remembering when the rate was last raised.
+type commissionEntry struct {
+	Rate     uint64
+	RaisedAt uint64
+}
+
+var (
+	// ErrNilDposContextProto is returned when reconstructing a DposContext
+	// from a header that carries no dpos context at all.
+	ErrNilDposContextProto = errors.New("nil dpos context proto")
+)
+
+// DposContext bundles the five consensus tries maintained by the delegated
+// proof-of-stake engine next to the regular account state:
+//
+//	epochTrie:     the validator set of the current epoch
+//	delegateTrie:  candidate -> delegator mappings (one entry per delegator)
+//	voteTrie:      delegator -> candidate mappings and vote nonces
+//	candidateTrie: the set of accounts standing for election
+//	mintCntTrie:   per epoch block production counters of the validators
+//
+// All five tries are rooted in the block header through DposContextProto, so
+// the whole context is covered by the chain's proof-of-integrity.
+type DposContext struct {
+	epochTrie     *trie.Trie
+	delegateTrie  *trie.Trie
+	voteTrie      *trie.Trie
+	candidateTrie *trie.Trie
+	mintCntTrie   *trie.Trie
+
+	db *trie.Database
+
+	// Journal of revisions handed out by Snapshot, allowing changes to be
+	// undone down to a specific revision, like StateDB does for accounts.
+	validRevisions []dposRevision
+	nextRevisionId int
+}
+
+// NewEpochTrie opens the epoch trie rooted at the given hash.
+func NewEpochTrie(root common.Hash, db *trie.Database) (*trie.Trie, error) {
+	return trie.New(root, db)
+}
+
+// NewDelegateTrie opens the delegate trie rooted at the given hash.
+func NewDelegateTrie(root common.Hash, db *trie.Database) (*trie.Trie, error) {
+	return trie.New(root, db)
+}
+
+// NewVoteTrie opens the vote trie rooted at the given hash.
+func NewVoteTrie(root common.Hash, db *trie.Database) (*trie.Trie, error) {
+	return trie.New(root, db)
+}
+
+// NewCandidateTrie opens the candidate trie rooted at the given hash.
+func NewCandidateTrie(root common.Hash, db *trie.Database) (*trie.Trie, error) {
+	return trie.New(root, db)
+}
+
+// NewMintCntTrie opens the mint counter trie rooted at the given hash.
+func NewMintCntTrie(root common.Hash, db *trie.Database) (*trie.Trie, error) {
+	return trie.New(root, db)
+}
+
+// NewDposContext creates an empty dpos context on top of the given trie
+// database.
+func NewDposContext(db *trie.Database) (*DposContext, error) {
+	return NewDposContextFromProto(db, &DposContextProto{})
+}
+
+// NewDposContextFromProto opens the five dpos tries at the roots recorded in
+// the given proto. Every call returns an independent view: views backed by
+// the same trie database may be read concurrently, but a single view must
+// not be shared between goroutines.
+func NewDposContextFromProto(db *trie.Database, ctxProto *DposContextProto) (*DposContext, error) {
+	if ctxProto == nil {
+		return nil, ErrNilDposContextProto
+	}
+	epochTrie, err := NewEpochTrie(ctxProto.EpochHash, db)
+	if err != nil {
+		return nil, err
+	}
+	delegateTrie, err := NewDelegateTrie(ctxProto.DelegateHash, db)
+	if err != nil {
+		return nil, err
+	}
+	voteTrie, err := NewVoteTrie(ctxProto.VoteHash, db)
+	if err != nil {
+		return nil, err
+	}
+	candidateTrie, err := NewCandidateTrie(ctxProto.CandidateHash, db)
+	if err != nil {
+		return nil, err
+	}
+	mintCntTrie, err := NewMintCntTrie(ctxProto.MintCntHash, db)
+	if err != nil {
+		return nil, err
+	}
+	return &DposContext{
+		epochTrie:     epochTrie,
+		delegateTrie:  delegateTrie,
+		voteTrie:      voteTrie,
+		candidateTrie: candidateTrie,
+		mintCntTrie:   mintCntTrie,
+		db:            db,
+	}, nil
+}
+
+// Copy duplicates the dpos context including the in-memory modifications of
+// its tries. Snapshots of the copied context cannot be applied to the copy.
+func (d *DposContext) Copy() *DposContext {
+	epochTrie := *d.epochTrie
+	delegateTrie := *d.delegateTrie
+	voteTrie := *d.voteTrie
+	candidateTrie := *d.candidateTrie
+	mintCntTrie := *d.mintCntTrie
+	return &DposContext{
+		epochTrie:     &epochTrie,
+		delegateTrie:  &delegateTrie,
+		voteTrie:      &voteTrie,
+		candidateTrie: &candidateTrie,
+		mintCntTrie:   &mintCntTrie,
+		db:            d.db,
+	}
+}
+
+// Root returns the combined root of the dpos context, defined as the keccak256
+// hash of the rlp encoded roots of the five tries.
+func (d *DposContext) Root() (h common.Hash) {
+	return rlpHash([]interface{}{
+		d.epochTrie.Hash(),
+		d.delegateTrie.Hash(),
+		d.candidateTrie.Hash(),
+		d.voteTrie.Hash(),
+		d.mintCntTrie.Hash(),
+	})
+}
+
+// dposRevision pins the state of the five tries at the time a snapshot was
+// taken. Trie values are persistent, so a shallow struct copy is enough to
+// freeze a revision.
+type dposRevision struct {
+	id int
+
+	epochTrie     trie.Trie
+	delegateTrie  trie.Trie
+	voteTrie      trie.Trie
+	candidateTrie trie.Trie
+	mintCntTrie   trie.Trie
+}
+
+// Snapshot returns an identifier for the current revision of the context,
+// mirroring the revision semantics of StateDB.
+func (d *DposContext) Snapshot() int {
+	id := d.nextRevisionId
+	d.nextRevisionId++
+	d.validRevisions = append(d.validRevisions, dposRevision{
+		id:            id,
+		epochTrie:     *d.epochTrie,
+		delegateTrie:  *d.delegateTrie,
+		voteTrie:      *d.voteTrie,
+		candidateTrie: *d.candidateTrie,
+		mintCntTrie:   *d.mintCntTrie,
+	})
+	return id
+}
+
+// RevertToSnapShot reverts all context changes made since the given revision.
+// The tries are restored in place, so references handed out through the trie
+// accessors observe the revert too.
+func (d *DposContext) RevertToSnapShot(revid int) {
+	// Find the snapshot in the stack of valid snapshots
+	idx := sort.Search(len(d.validRevisions), func(i int) bool {
+		return d.validRevisions[i].id >= revid
+	})
+	if idx == len(d.validRevisions) || d.validRevisions[idx].id != revid {
+		panic(fmt.Errorf("revision id %v cannot be reverted", revid))
+	}
+	rev := d.validRevisions[idx]
+	*d.epochTrie = rev.epochTrie
+	*d.delegateTrie = rev.delegateTrie
+	*d.voteTrie = rev.voteTrie
+	*d.candidateTrie = rev.candidateTrie
+	*d.mintCntTrie = rev.mintCntTrie
+	d.validRevisions = d.validRevisions[:idx]
+}
+
+// FromProto reopens all tries at the roots recorded in the given proto.
+func (d *DposContext) FromProto(dcp *DposContextProto) error {
+	var err error
+	if d.epochTrie, err = NewEpochTrie(dcp.EpochHash, d.db); err != nil {
+		return err
+	}
+	if d.delegateTrie, err = NewDelegateTrie(dcp.DelegateHash, d.db); err != nil {
+		return err
+	}
+	if d.candidateTrie, err = NewCandidateTrie(dcp.CandidateHash, d.db); err != nil {
+		return err
+	}
+	if d.voteTrie, err = NewVoteTrie(dcp.VoteHash, d.db); err != nil {
+		return err
+	}
+	d.mintCntTrie, err = NewMintCntTrie(dcp.MintCntHash, d.db)
+	return err
+}
+
+// DposContextVersion is the newest header encoding version of the dpos
+// context this client understands. Version zero is the original bare list of
+// the five trie roots and remains the wire form as long as no extension
+// fields are in use, so existing header hashes are unaffected.
+//
+// The upgrade rules for the envelope are:
+//   - new fields append to the tail of the root list, never reorder it
+//   - a field old clients may safely ignore rides in the tail without a
+//     version bump; decoding preserves it verbatim so re-encoding keeps the
+//     header hash intact
+//   - a consensus-affecting field bumps the version, making clients that
+//     predate it reject the header outright instead of silently forking
+const DposContextVersion = 1
+
+// DposContextProto is the header representation of a dpos context, pinning
+// the roots of the five consensus tries.
+type DposContextProto struct {
+	EpochHash     common.Hash `json:"epochRoot"     gencodec:"required"`
+	DelegateHash  common.Hash `json:"delegateRoot"  gencodec:"required"`
+	CandidateHash common.Hash `json:"candidateRoot" gencodec:"required"`
+	VoteHash      common.Hash `json:"voteRoot"      gencodec:"required"`
+	MintCntHash   common.Hash `json:"mintCntRoot"   gencodec:"required"`
+
+	// Envelope bookkeeping, only set on protos decoded from a versioned
+	// encoding: the version the proto arrived with and any extension fields
+	// this client does not know, preserved so the encoding round-trips.
+	version uint64
+	tail    []rlp.RawValue
+}
+
+// EncodingVersion returns the envelope version the proto was decoded from;
+// zero marks the original bare root list.
+func (dcp *DposContextProto) EncodingVersion() uint64 {
+	return dcp.version
+}
+
+// EncodeRLP implements rlp.Encoder. A nil proto encodes as the empty list,
+// matching the encoder's convention for absent struct pointers, so headers
+// without a dpos context keep their pre-dpos hash. A context without envelope
+// bookkeeping encodes as the original bare root list; a version prefix and
+// preserved extension fields are re-emitted unchanged, so any decoded
+// encoding round-trips to its original bytes.
+func (dcp *DposContextProto) EncodeRLP(w io.Writer) error {
+	if dcp == nil {
+		_, err := w.Write(rlp.EmptyList)
+		return err
+	}
+	var out []interface{}
+	if dcp.version != 0 {
+		out = append(out, dcp.version)
+	}
+	out = append(out, dcp.EpochHash, dcp.DelegateHash, dcp.CandidateHash, dcp.VoteHash, dcp.MintCntHash)
+	for _, item := range dcp.tail {
+		out = append(out, item)
+	}
+	return rlp.Encode(w, out)
+}
+
+// DecodeRLP implements rlp.Decoder, accepting both the original bare root
+// list and the versioned envelope. Envelope versions newer than this client
+// understands are rejected.
+func (dcp *DposContextProto) DecodeRLP(s *rlp.Stream) error {
+	listSize, err := s.List()
+	if err != nil {
+		return err
+	}
+	if listSize == 0 {
+		// The nil placeholder of an absent context; decodes as the zero proto
+		// when not behind an rlp:"nil" field.
+		*dcp = DposContextProto{}
+		return s.ListEnd()
+	}
+	kind, size, err := s.Kind()
+	if err != nil {
+		return err
+	}
+	dcp.version, dcp.tail = 0, nil
+	// Trie roots are full hashes, so a shorter leading item marks the
+	// version of an envelope
+	if kind == rlp.Byte || (kind == rlp.String && size < common.HashLength) {
+		if err := s.Decode(&dcp.version); err != nil {
+			return err
+		}
+		if dcp.version == 0 || dcp.version > DposContextVersion {
+			return fmt.Errorf("unsupported dpos context version %d, newest known is %d", dcp.version, DposContextVersion)
+		}
+	}
+	for _, hash := range []*common.Hash{&dcp.EpochHash, &dcp.DelegateHash, &dcp.CandidateHash, &dcp.VoteHash, &dcp.MintCntHash} {
+		if err := s.Decode(hash); err != nil {
+			return err
+		}
+	}
+	// Preserve extension fields this client does not know about, so the
+	// envelope survives a re-encoding with its header hash intact
+	for {
+		var item rlp.RawValue
+		if err := s.Decode(&item); err == rlp.EOL {
+			break
+		} else if err != nil {
+			return err
+		}
+		dcp.tail = append(dcp.tail, item)
+	}
+	return s.ListEnd()
+}
+
+// Equal reports whether two protos pin the same trie roots. Envelope
+// bookkeeping is ignored: contexts agreeing on every root describe the same
+// state regardless of the encoding they arrived in.
+func (dcp *DposContextProto) Equal(other *DposContextProto) bool {
+	if dcp == nil || other == nil {
+		return dcp == other
+	}
+	return dcp.EpochHash == other.EpochHash &&
+		dcp.DelegateHash == other.DelegateHash &&
+		dcp.CandidateHash == other.CandidateHash &&
+		dcp.VoteHash == other.VoteHash &&
+		dcp.MintCntHash == other.MintCntHash
+}
+
+// Roots returns the non-empty root hashes recorded in the proto.
+func (dcp *DposContextProto) Roots() []common.Hash {
+	roots := make([]common.Hash, 0, 5)
+	for _, root := range []common.Hash{
+		dcp.EpochHash,
+		dcp.DelegateHash,
+		dcp.CandidateHash,
+		dcp.VoteHash,
+		dcp.MintCntHash,
+	} {
+		if root == EmptyRootHash || root == (common.Hash{}) {
+			continue
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// HotRoots returns the non-empty roots of the tries a validator needs before
+// it can verify seals and seal itself: the elected set, the candidate
+// metadata and the block production counters.
+func (dcp *DposContextProto) HotRoots() []common.Hash {
+	roots := make([]common.Hash, 0, 3)
+	for _, root := range []common.Hash{
+		dcp.EpochHash,
+		dcp.CandidateHash,
+		dcp.MintCntHash,
+	} {
+		if root == EmptyRootHash || root == (common.Hash{}) {
+			continue
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// ColdRoots returns the non-empty roots of the delegation tries, consulted
+// only by dpos transactions and the next election and hence safe to backfill
+// after the hot tries.
+func (dcp *DposContextProto) ColdRoots() []common.Hash {
+	roots := make([]common.Hash, 0, 2)
+	for _, root := range []common.Hash{
+		dcp.DelegateHash,
+		dcp.VoteHash,
+	} {
+		if root == EmptyRootHash || root == (common.Hash{}) {
+			continue
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// NewDposTrieSync creates a download scheduler for the given consensus tries.
+// Unlike the account trie their leaves are plain values without nested tries,
+// so no leaf callback is needed.
+func NewDposTrieSync(database trie.DatabaseReader, roots ...common.Hash) *trie.Sync {
+	if len(roots) == 0 {
+		return trie.NewSync(EmptyRootHash, database, nil)
+	}
+	syncer := trie.NewSync(roots[0], database, nil)
+	for _, root := range roots[1:] {
+		syncer.AddSubTrie(root, 0, common.Hash{}, nil)
+	}
+	return syncer
+}
+
+// ToProto derives the header representation from the current (uncommitted)
+// trie roots.
+func (d *DposContext) ToProto() *DposContextProto {
+	return &DposContextProto{
+		EpochHash:     d.epochTrie.Hash(),
+		DelegateHash:  d.delegateTrie.Hash(),
+		CandidateHash: d.candidateTrie.Hash(),
+		VoteHash:      d.voteTrie.Hash(),
+		MintCntHash:   d.mintCntTrie.Hash(),
+	}
+}
+
+// Root returns the combined root of the proto, matching DposContext.Root.
+func (p *DposContextProto) Root() (h common.Hash) {
+	return rlpHash([]interface{}{
+		p.EpochHash,
+		p.DelegateHash,
+		p.CandidateHash,
+		p.VoteHash,
+		p.MintCntHash,
+	})
+}
+
+// BecomeCandidate adds the given address to the candidate trie. A stale
+// kickout cooldown entry is removed along the way; rejecting registrations
+// while the cooldown is still active is the caller's responsibility, since
+// only the caller knows the current block time.
+func (d *DposContext) BecomeCandidate(candidateAddr common.Address) error {
+	candidate := candidateAddr.Bytes()
+	if err := d.candidateTrie.TryDelete(append(candidate, kickoutCooldownSuffix...)); err != nil {
+		if _, ok := err.(*trie.MissingNodeError); !ok {
+			return err
+		}
+	}
+	// A re-registration after a completed departure starts without the old
+	// exit schedule
+	if err := d.candidateTrie.TryDelete(append(candidate, exitEpochSuffix...)); err != nil {
+		if _, ok := err.(*trie.MissingNodeError); !ok {
+			return err
+		}
+	}
+	return d.candidateTrie.TryUpdate(candidate, candidate)
+}
+
+// KickoutCooldown returns the unix time until which the given address is
+// barred from re-registering as a candidate, or zero if no cooldown was
+// recorded.
+func (d *DposContext) KickoutCooldown(candidateAddr common.Address) int64 {
+	enc := d.candidateTrie.Get(append(candidateAddr.Bytes(), kickoutCooldownSuffix...))
+	if len(enc) == 0 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(enc))
+}
+
+// SetKickoutCooldown bars the given address from re-registering as a
+// candidate until the given unix time.
+func (d *DposContext) SetKickoutCooldown(candidateAddr common.Address, expiresAt int64) error {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, uint64(expiresAt))
+	return d.candidateTrie.TryUpdate(append(candidateAddr.Bytes(), kickoutCooldownSuffix...), enc)
+}
+
+// ExitEpoch returns the epoch from which the candidate's scheduled departure
+// takes effect, or zero if no exit was announced.
+func (d *DposContext) ExitEpoch(candidateAddr common.Address) int64 {
+	enc := d.candidateTrie.Get(append(candidateAddr.Bytes(), exitEpochSuffix...))
+	if len(enc) == 0 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(enc))
+}
+
+// SetExitEpoch records the epoch from which the candidate's scheduled
+// departure takes effect. Zero clears a pending announcement.
+func (d *DposContext) SetExitEpoch(candidateAddr common.Address, epoch int64) error {
+	key := append(candidateAddr.Bytes(), exitEpochSuffix...)
+	if epoch == 0 {
+		if err := d.candidateTrie.TryDelete(key); err != nil {
+			if _, ok := err.(*trie.MissingNodeError); !ok {
+				return err
+			}
+		}
+		return nil
+	}
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, uint64(epoch))
+	return d.candidateTrie.TryUpdate(key, enc)
+}
+
+// GasTarget returns the latest gas limit vote of the given validator, or
+// zero if the validator never published one.
+func (d *DposContext) GasTarget(validator common.Address) uint64 {
+	enc := d.candidateTrie.Get(append(validator.Bytes(), gasTargetSuffix...))
+	if len(enc) == 0 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(enc)
+}
+
+// SetGasTarget records the gas limit vote of the given validator.
+func (d *DposContext) SetGasTarget(validator common.Address, target uint64) error {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, target)
+	return d.candidateTrie.TryUpdate(append(validator.Bytes(), gasTargetSuffix...), enc)
+}
+
+// BlockIntervalVote returns the block interval, in seconds, the given
+// candidate prefers, or zero if the candidate never voted.
+func (d *DposContext) BlockIntervalVote(candidate common.Address) uint64 {
+	enc := d.candidateTrie.Get(append(candidate.Bytes(), intervalVoteSuffix...))
+	if len(enc) == 0 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(enc)
+}
+
+// SetBlockIntervalVote records the block interval vote of the given
+// candidate.
+func (d *DposContext) SetBlockIntervalVote(candidate common.Address, interval uint64) error {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, interval)
+	return d.candidateTrie.TryUpdate(append(candidate.Bytes(), intervalVoteSuffix...), enc)
+}
+
+// BlockInterval returns the block interval, in seconds, the current epoch
+// adopted through validator voting, or zero when the network default applies.
+func (d *DposContext) BlockInterval() int64 {
+	enc := d.epochTrie.Get(epochIntervalKey)
+	if len(enc) == 0 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(enc))
+}
+
+// SetBlockInterval records the block interval the entered epoch runs at.
+func (d *DposContext) SetBlockInterval(interval int64) error {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, uint64(interval))
+	return d.epochTrie.TryUpdate(epochIntervalKey, enc)
+}
+
+// signerRotation records a sealing key rotation of a candidate: Prev signs
+// until the activation epoch, Next from there on.
+type signerRotation struct {
+	Prev       common.Address
+	Next       common.Address
+	Activation uint64
+}
+
+// ActiveSigner returns the sealing key authorized to sign blocks for the
+// given candidate in the given epoch. Candidates that never rotated their
+// key sign with their own address.
+func (d *DposContext) ActiveSigner(candidate common.Address, epoch int64) common.Address {
+	enc := d.candidateTrie.Get(append(candidate.Bytes(), signerRotationSuffix...))
+	if len(enc) == 0 {
+		return candidate
+	}
+	var rotation signerRotation
+	if err := rlp.DecodeBytes(enc, &rotation); err != nil {
+		return candidate
+	}
+	if epoch >= int64(rotation.Activation) {
+		return rotation.Next
+	}
+	return rotation.Prev
+}
+
+// RotateSigner schedules the sealing key of the given candidate to change to
+// the given signer from the activation epoch on. The key active before the
+// rotation keeps signing until then.
+func (d *DposContext) RotateSigner(candidate, signer common.Address, activation int64) error {
+	rotation := signerRotation{
+		Prev:       d.ActiveSigner(candidate, activation-1),
+		Next:       signer,
+		Activation: uint64(activation),
+	}
+	enc, err := rlp.EncodeToBytes(rotation)
+	if err != nil {
+		return err
+	}
+	return d.candidateTrie.TryUpdate(append(candidate.Bytes(), signerRotationSuffix...), enc)
+}
+
+// candidateOwners records the owner set of a multisig-controlled candidate:
+// Threshold of Owners must co-sign its management operations. The nonce
+// protects the owner signatures against replays.
+type candidateOwners struct {
+	Threshold uint64
+	Owners    []common.Address
+	Nonce     uint64
+}
+
+// CandidateOwners returns the owner set and signature threshold controlling
+// the given candidate's management operations. Candidates without an owner
+// set are controlled by their own key and report an empty set.
+func (d *DposContext) CandidateOwners(candidate common.Address) ([]common.Address, uint64) {
+	enc := d.candidateTrie.Get(append(candidate.Bytes(), candidateOwnersSuffix...))
+	if len(enc) == 0 {
+		return nil, 0
+	}
+	var owners candidateOwners
+	if err := rlp.DecodeBytes(enc, &owners); err != nil {
+		return nil, 0
+	}
+	return owners.Owners, owners.Threshold
+}
+
+// CandidateOwnerNonce returns the replay protection nonce of the given
+// candidate's owner signatures.
+func (d *DposContext) CandidateOwnerNonce(candidate common.Address) uint64 {
+	enc := d.candidateTrie.Get(append(candidate.Bytes(), candidateOwnersSuffix...))
+	if len(enc) == 0 {
+		return 0
+	}
+	var owners candidateOwners
+	if err := rlp.DecodeBytes(enc, &owners); err != nil {
+		return 0
+	}
+	return owners.Nonce
+}
+
+// SetCandidateOwners records the owner set controlling the given candidate's
+// management operations. An empty owner set returns control to the
+// candidate's own key. The replay protection nonce survives owner set updates
+// and removals, so owner signatures from a retired set can never replay even
+// if the same set is re-established later.
+func (d *DposContext) SetCandidateOwners(candidate common.Address, owners []common.Address, threshold uint64) error {
+	nonce := d.CandidateOwnerNonce(candidate)
+	enc, err := rlp.EncodeToBytes(candidateOwners{Threshold: threshold, Owners: owners, Nonce: nonce})
+	if err != nil {
+		return err
+	}
+	return d.candidateTrie.TryUpdate(append(candidate.Bytes(), candidateOwnersSuffix...), enc)
+}
+
+// SetCandidateOwnerNonce advances the replay protection nonce of the given
+// candidate's owner signatures. Candidates without an owner set have no nonce
+// to advance.
+func (d *DposContext) SetCandidateOwnerNonce(candidate common.Address, nonce uint64) error {
+	key := append(candidate.Bytes(), candidateOwnersSuffix...)
+	enc := d.candidateTrie.Get(key)
+	if len(enc) == 0 {
+		return nil
+	}
+	var owners candidateOwners
+	if err := rlp.DecodeBytes(enc, &owners); err != nil {
+		return err
+	}
+	owners.Nonce = nonce
+	enc, err := rlp.EncodeToBytes(owners)
+	if err != nil {
+		return err
+	}
+	return d.candidateTrie.TryUpdate(key, enc)
+}
+
+// GasPriceVote returns the latest minimum gas price vote of the given
+// validator in wei, or zero if the validator never published one.
+func (d *DposContext) GasPriceVote(validator common.Address) uint64 {
+	enc := d.candidateTrie.Get(append(validator.Bytes(), gasPriceVoteSuffix...))
+	if len(enc) == 0 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(enc)
+}
+
+// SetGasPriceVote records the minimum gas price vote of the given validator.
+func (d *DposContext) SetGasPriceVote(validator common.Address, price uint64) error {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, price)
+	return d.candidateTrie.TryUpdate(append(validator.Bytes(), gasPriceVoteSuffix...), enc)
+}
+
+// MinGasPrice returns the network minimum gas price in wei, zero when the
+// validators never voted a floor in.
+func (d *DposContext) MinGasPrice() uint64 {
+	enc := d.candidateTrie.Get(gasFloorKey)
+	if len(enc) == 0 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(enc)
+}
+
+// SetMinGasPrice updates the network minimum gas price. A zero floor removes
+// the entry, lifting the restriction.
+func (d *DposContext) SetMinGasPrice(price uint64) error {
+	if price == 0 {
+		if err := d.candidateTrie.TryDelete(gasFloorKey); err != nil {
+			if _, ok := err.(*trie.MissingNodeError); !ok {
+				return err
+			}
+		}
+		return nil
+	}
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, price)
+	return d.candidateTrie.TryUpdate(gasFloorKey, enc)
+}
+
+// Issuance returns the cumulative net issuance of the chain in wei: the
+// rewards minted over its lifetime minus the slashed amounts burned again.
+func (d *DposContext) Issuance() *big.Int {
+	return new(big.Int).SetBytes(d.candidateTrie.Get(issuanceKey))
+}
+
+// AddIssuance folds newly minted rewards into the issuance counter.
+func (d *DposContext) AddIssuance(minted *big.Int) error {
+	if minted == nil || minted.Sign() == 0 {
+		return nil
+	}
+	return d.candidateTrie.TryUpdate(issuanceKey, new(big.Int).Add(d.Issuance(), minted).Bytes())
+}
+
+// SubIssuance removes burned penalties from the issuance counter, clamping
+// at zero should a burn ever exceed the tracked issuance.
+func (d *DposContext) SubIssuance(burned *big.Int) error {
+	if burned == nil || burned.Sign() == 0 {
+		return nil
+	}
+	total := new(big.Int).Sub(d.Issuance(), burned)
+	if total.Sign() < 0 {
+		total.SetUint64(0)
+	}
+	return d.candidateTrie.TryUpdate(issuanceKey, total.Bytes())
+}
+
+// BlacklistExpiry returns the unix time until which the given address is
+// blacklisted from standing for election, or zero if no entry was recorded.
+// Expired entries are equivalent to absent ones; callers compare against the
+// current block time.
+func (d *DposContext) BlacklistExpiry(addr common.Address) int64 {
+	enc := d.candidateTrie.Get(append(addr.Bytes(), blacklistSuffix...))
+	if len(enc) == 0 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(enc))
+}
+
+// SetBlacklist bars the given address from standing for election until the
+// given unix time. A non-positive expiry removes the entry, lifting an
+// earlier blacklist.
+func (d *DposContext) SetBlacklist(addr common.Address, expiresAt int64) error {
+	key := append(addr.Bytes(), blacklistSuffix...)
+	if expiresAt <= 0 {
+		if err := d.candidateTrie.TryDelete(key); err != nil {
+			if _, ok := err.(*trie.MissingNodeError); !ok {
+				return err
+			}
+		}
+		return nil
+	}
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, uint64(expiresAt))
+	return d.candidateTrie.TryUpdate(key, enc)
+}
+
+// Commission returns the commission rate, in percent, the given candidate
+// deducts from delegator rewards. Candidates that never declared a rate run
+// at zero commission.
+func (d *DposContext) Commission(candidateAddr common.Address) uint64 {
+	enc := d.candidateTrie.Get(append(candidateAddr.Bytes(), commissionSuffix...))
+	if len(enc) == 0 {
+		return 0
+	}
+	var entry commissionEntry
+	if err := rlp.DecodeBytes(enc, &entry); err != nil {
+		return 0
+	}
+	return entry.Rate
+}
+
+// SetCommission declares the commission rate of the given candidate. Lowering
+// the rate is always allowed; raising it is limited to one step per raise
+// window so delegators can withdraw their vote before a raise takes effect.
+func (d *DposContext) SetCommission(candidateAddr common.Address, rate uint64, now int64) error {
+	candidate := candidateAddr.Bytes()
+	if rate > MaxCommissionRate {
+		return fmt.Errorf("commission rate %d exceeds maximum of %d", rate, MaxCommissionRate)
+	}
+	// Only registered candidates carry a commission
+	candidateInTrie, err := d.candidateTrie.TryGet(candidate)
+	if err != nil {
+		return err
+	}
+	if candidateInTrie == nil {
+		return errors.New("invalid candidate to set commission")
+	}
+	var entry commissionEntry
+	if enc := d.candidateTrie.Get(append(candidate, commissionSuffix...)); len(enc) != 0 {
+		if err := rlp.DecodeBytes(enc, &entry); err != nil {
+			return err
+		}
+	}
+	if rate > entry.Rate {
+		if rate-entry.Rate > commissionRaiseLimit {
+			return fmt.Errorf("commission raise from %d to %d exceeds limit of %d points", entry.Rate, rate, commissionRaiseLimit)
+		}
+		if entry.RaisedAt != 0 && now-int64(entry.RaisedAt) < commissionRaiseWindow {
+			return errors.New("commission was already raised within the current window")
+		}
+		entry.RaisedAt = uint64(now)
+	}
+	entry.Rate = rate
+	enc, err := rlp.EncodeToBytes(&entry)
+	if err != nil {
+		return err
+	}
+	return d.candidateTrie.TryUpdate(append(candidate, commissionSuffix...), enc)
+}
+
+// OperatorGroup returns the declared operator group of the given candidate,
+// or the zero hash if the candidate declared none.
+func (d *DposContext) OperatorGroup(candidateAddr common.Address) common.Hash {
+	enc := d.candidateTrie.Get(append(candidateAddr.Bytes(), operatorGroupSuffix...))
+	if len(enc) != common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(enc)
+}
+
+// SetOperatorGroup declares the operator group of the given candidate; the
+// zero group clears the declaration.
+func (d *DposContext) SetOperatorGroup(candidateAddr common.Address, group common.Hash) error {
+	key := append(candidateAddr.Bytes(), operatorGroupSuffix...)
+	if group == (common.Hash{}) {
+		return d.candidateTrie.TryDelete(key)
+	}
+	return d.candidateTrie.TryUpdate(key, group.Bytes())
+}
+
+// RewardRecipient returns the registered reward recipient of the given
+// candidate, or the zero address if the candidate registered none and is
+// paid directly.
+func (d *DposContext) RewardRecipient(candidateAddr common.Address) common.Address {
+	enc := d.candidateTrie.Get(append(candidateAddr.Bytes(), rewardRecipientSuffix...))
+	if len(enc) != common.AddressLength {
+		return common.Address{}
+	}
+	return common.BytesToAddress(enc)
+}
+
+// SetRewardRecipient registers the reward recipient of the given candidate;
+// the zero recipient clears the registration.
+func (d *DposContext) SetRewardRecipient(candidateAddr common.Address, recipient common.Address) error {
+	key := append(candidateAddr.Bytes(), rewardRecipientSuffix...)
+	if recipient == (common.Address{}) {
+		return d.candidateTrie.TryDelete(key)
+	}
+	return d.candidateTrie.TryUpdate(key, recipient.Bytes())
+}
+
+// IsCandidate reports whether the given address is a registered candidate.
+func (d *DposContext) IsCandidate(candidateAddr common.Address) bool {
+	return len(d.candidateTrie.Get(candidateAddr.Bytes())) == common.AddressLength
+}
+
+// Deposit returns the unrefunded part of the given candidate's campaign
+// deposit.
+func (d *DposContext) Deposit(candidateAddr common.Address) *big.Int {
+	return new(big.Int).SetBytes(d.candidateTrie.Get(append(candidateAddr.Bytes(), depositSuffix...)))
+}
+
+// SetDeposit records the unrefunded part of the given candidate's campaign
+// deposit; a non-positive amount removes the entry.
+func (d *DposContext) SetDeposit(candidateAddr common.Address, amount *big.Int) error {
+	key := append(candidateAddr.Bytes(), depositSuffix...)
+	if amount == nil || amount.Sign() <= 0 {
+		if err := d.candidateTrie.TryDelete(key); err != nil {
+			if _, ok := err.(*trie.MissingNodeError); !ok {
+				return err
+			}
+		}
+		return nil
+	}
+	return d.candidateTrie.TryUpdate(key, amount.Bytes())
+}
+
+// WeightHookSubscribers returns the contracts opted into vote weight change
+// callbacks, in registration order.
+func (d *DposContext) WeightHookSubscribers() []common.Address {
+	var subscribers []common.Address
+	if enc := d.candidateTrie.Get(weightHookKey); len(enc) > 0 {
+		rlp.DecodeBytes(enc, &subscribers)
+	}
+	return subscribers
+}
+
+// SetWeightHookSubscribers replaces the vote weight hook registry, clearing
+// the entry once the last subscriber leaves.
+func (d *DposContext) SetWeightHookSubscribers(subscribers []common.Address) error {
+	if len(subscribers) == 0 {
+		return d.candidateTrie.TryDelete(weightHookKey)
+	}
+	enc, err := rlp.EncodeToBytes(subscribers)
+	if err != nil {
+		return fmt.Errorf("failed to encode weight hook subscribers to rlp bytes: %s", err)
+	}
+	return d.candidateTrie.TryUpdate(weightHookKey, enc)
+}
+
+// KickoutCandidate removes a candidate and all delegations pointing at it.
+// Leaving the candidate list forfeits the unrefunded part of the campaign
+// deposit.
+func (d *DposContext) KickoutCandidate(candidateAddr common.Address) error {
+	candidate := candidateAddr.Bytes()
+	err := d.candidateTrie.TryDelete(candidate)
+	if err != nil {
+		if _, ok := err.(*trie.MissingNodeError); !ok {
+			return err
+		}
+	}
+	if err := d.candidateTrie.TryDelete(append(candidate, depositSuffix...)); err != nil {
+		if _, ok := err.(*trie.MissingNodeError); !ok {
+			return err
+		}
+	}
+	iter := trie.NewIterator(d.delegateTrie.NodeIterator(candidate))
+	for iter.Next() {
+		if !bytes.HasPrefix(iter.Key, candidate) {
+			break
+		}
+		delegator := iter.Value
+		key := append(candidate, delegator...)
+		if err = d.delegateTrie.TryDelete(key); err != nil {
+			if _, ok := err.(*trie.MissingNodeError); !ok {
+				return err
+			}
+		}
+		v, err := d.voteTrie.TryGet(delegator)
+		if err != nil {
+			if _, ok := err.(*trie.MissingNodeError); !ok {
+				return err
+			}
+		}
+		if err == nil && bytes.Equal(v, candidate) {
+			if err = d.voteTrie.TryDelete(delegator); err != nil {
+				if _, ok := err.(*trie.MissingNodeError); !ok {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Delegate casts (or moves) the vote of delegator to candidate. The candidate
+// must have registered in the candidate trie beforehand.
+func (d *DposContext) Delegate(delegatorAddr, candidateAddr common.Address) error {
+	delegator, candidate := delegatorAddr.Bytes(), candidateAddr.Bytes()
+
+	// The candidate must be registered before accepting votes
+	candidateInTrie, err := d.candidateTrie.TryGet(candidate)
+	if err != nil {
+		return err
+	}
+	if candidateInTrie == nil {
+		return errors.New("invalid candidate to delegate")
+	}
+	// Retract a previous vote if the delegator voted before
+	oldCandidate, err := d.voteTrie.TryGet(delegator)
+	if err != nil {
+		if _, ok := err.(*trie.MissingNodeError); !ok {
+			return err
+		}
+	}
+	if oldCandidate != nil {
+		d.delegateTrie.Delete(append(oldCandidate, delegator...))
+	}
+	if err = d.delegateTrie.TryUpdate(append(candidate, delegator...), delegator); err != nil {
+		return err
+	}
+	return d.voteTrie.TryUpdate(delegator, candidate)
+}
+
+// UnDelegate retracts the vote of delegator from candidate.
+func (d *DposContext) UnDelegate(delegatorAddr, candidateAddr common.Address) error {
+	delegator, candidate := delegatorAddr.Bytes(), candidateAddr.Bytes()
+
+	// The candidate must be registered before retracting votes
+	candidateInTrie, err := d.candidateTrie.TryGet(candidate)
+	if err != nil {
+		return err
+	}
+	if candidateInTrie == nil {
+		return errors.New("invalid candidate to undelegate")
+	}
+	oldCandidate, err := d.voteTrie.TryGet(delegator)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(candidate, oldCandidate) {
+		return errors.New("mismatch candidate to undelegate")
+	}
+	if err = d.delegateTrie.TryDelete(append(candidate, delegator...)); err != nil {
+		return err
+	}
+	return d.voteTrie.TryDelete(delegator)
+}
+
+// Redelegate atomically moves the vote of delegator from one candidate to
+// another, verifying the current vote matches the expected source.
+func (d *DposContext) Redelegate(delegatorAddr, fromAddr, toAddr common.Address) error {
+	delegator, from, to := delegatorAddr.Bytes(), fromAddr.Bytes(), toAddr.Bytes()
+
+	// The target candidate must be registered before accepting votes
+	candidateInTrie, err := d.candidateTrie.TryGet(to)
+	if err != nil {
+		return err
+	}
+	if candidateInTrie == nil {
+		return errors.New("invalid candidate to redelegate to")
+	}
+	oldCandidate, err := d.voteTrie.TryGet(delegator)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(from, oldCandidate) {
+		return errors.New("mismatch candidate to redelegate from")
+	}
+	if err = d.delegateTrie.TryDelete(append(from, delegator...)); err != nil {
+		return err
+	}
+	if err = d.delegateTrie.TryUpdate(append(to, delegator...), delegator); err != nil {
+		return err
+	}
+	return d.voteTrie.TryUpdate(delegator, to)
+}
+
+// DelegatorCount counts the distinct delegators of the candidate, stopping
+// early at max when positive, so callers enforcing a cap do not pay for a
+// walk over the full delegator set.
+func (d *DposContext) DelegatorCount(candidateAddr common.Address, max uint64) uint64 {
+	prefix := candidateAddr.Bytes()
+	count := uint64(0)
+	iter := trie.NewIterator(d.delegateTrie.NodeIterator(prefix))
+	for iter.Next() && bytes.HasPrefix(iter.Key, prefix) {
+		if len(iter.Key) != 2*common.AddressLength {
+			continue
+		}
+		count++
+		if max > 0 && count >= max {
+			break
+		}
+	}
+	return count
+}
+
+// RedelegationCount returns how often the given delegator redelegated within
+// the given epoch.
+func (d *DposContext) RedelegationCount(epoch int64, delegatorAddr common.Address) uint64 {
+	enc := d.voteTrie.Get(append(delegatorAddr.Bytes(), redelegateSuffix...))
+	if len(enc) != 16 || int64(binary.BigEndian.Uint64(enc[:8])) != epoch {
+		return 0
+	}
+	return binary.BigEndian.Uint64(enc[8:])
+}
+
+// IncRedelegationCount bumps the redelegation counter of the given delegator
+// for the given epoch, implicitly resetting counters of earlier epochs.
+func (d *DposContext) IncRedelegationCount(epoch int64, delegatorAddr common.Address) error {
+	cnt := d.RedelegationCount(epoch, delegatorAddr)
+	enc := make([]byte, 16)
+	binary.BigEndian.PutUint64(enc[:8], uint64(epoch))
+	binary.BigEndian.PutUint64(enc[8:], cnt+1)
+	return d.voteTrie.TryUpdate(append(delegatorAddr.Bytes(), redelegateSuffix...), enc)
+}
+
+// ProxyRoot returns the merkle root of the given custodian's proxy vote, or
+// the zero hash if the custodian has none.
+func (d *DposContext) ProxyRoot(custodianAddr common.Address) common.Hash {
+	return common.BytesToHash(d.voteTrie.Get(append(custodianAddr.Bytes(), proxyRootSuffix...)))
+}
+
+// ProxyAllocations returns the per-candidate allocations of the given
+// custodian's proxy vote.
+func (d *DposContext) ProxyAllocations(custodianAddr common.Address) ([]ProxyAllocation, error) {
+	enc, err := d.voteTrie.TryGet(append(custodianAddr.Bytes(), proxyAllocSuffix...))
+	if err != nil || enc == nil {
+		return nil, err
+	}
+	var allocations []ProxyAllocation
+	if err := rlp.DecodeBytes(enc, &allocations); err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+// ProxyWeight returns the aggregate proxy vote weight assigned to the given
+// candidate, summed over all custodians.
+func (d *DposContext) ProxyWeight(candidateAddr common.Address) *big.Int {
+	return new(big.Int).SetBytes(d.candidateTrie.Get(append(candidateAddr.Bytes(), proxyWeightSuffix...)))
+}
+
+// shiftProxyWeight adds delta, which may be negative, to the aggregate proxy
+// vote weight of the given candidate, deleting the entry when it drops to
+// zero.
+func (d *DposContext) shiftProxyWeight(candidateAddr common.Address, delta *big.Int) error {
+	key := append(candidateAddr.Bytes(), proxyWeightSuffix...)
+	weight := new(big.Int).Add(d.ProxyWeight(candidateAddr), delta)
+	if weight.Sign() <= 0 {
+		if err := d.candidateTrie.TryDelete(key); err != nil {
+			if _, ok := err.(*trie.MissingNodeError); !ok {
+				return err
+			}
+		}
+		return nil
+	}
+	return d.candidateTrie.TryUpdate(key, weight.Bytes())
+}
+
+// SetProxyVote replaces the given custodian's proxy vote: the previous
+// allocations are backed out of the per-candidate aggregates, the new ones
+// added, and the merkle root updated. An empty vote retracts the custodian's
+// allocations entirely. Every newly allocated candidate must be registered.
+func (d *DposContext) SetProxyVote(custodianAddr common.Address, vote *ProxyVote) error {
+	custodian := custodianAddr.Bytes()
+	old, err := d.ProxyAllocations(custodianAddr)
+	if err != nil {
+		return err
+	}
+	for _, alloc := range old {
+		if err := d.shiftProxyWeight(alloc.Candidate, new(big.Int).Neg(alloc.Weight)); err != nil {
+			return err
+		}
+	}
+	if len(vote.Allocations) == 0 {
+		if err := d.voteTrie.TryDelete(append(custodian, proxyRootSuffix...)); err != nil {
+			if _, ok := err.(*trie.MissingNodeError); !ok {
+				return err
+			}
+		}
+		if err := d.voteTrie.TryDelete(append(custodian, proxyAllocSuffix...)); err != nil {
+			if _, ok := err.(*trie.MissingNodeError); !ok {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, alloc := range vote.Allocations {
+		candidateInTrie, err := d.candidateTrie.TryGet(alloc.Candidate.Bytes())
+		if err != nil {
+			return err
+		}
+		if candidateInTrie == nil {
+			return fmt.Errorf("invalid candidate in proxy vote: %x", alloc.Candidate)
+		}
+		if err := d.shiftProxyWeight(alloc.Candidate, alloc.Weight); err != nil {
+			return err
+		}
+	}
+	enc, err := rlp.EncodeToBytes(vote.Allocations)
+	if err != nil {
+		return err
+	}
+	if err := d.voteTrie.TryUpdate(append(custodian, proxyAllocSuffix...), enc); err != nil {
+		return err
+	}
+	return d.voteTrie.TryUpdate(append(custodian, proxyRootSuffix...), vote.Root.Bytes())
+}
+
+// Compounding reports whether the given delegator opted into reward
+// auto-compounding.
+func (d *DposContext) Compounding(delegatorAddr common.Address) bool {
+	return len(d.voteTrie.Get(append(delegatorAddr.Bytes(), compoundSuffix...))) > 0
+}
+
+// SetCompounding opts the given delegator into or out of reward
+// auto-compounding.
+func (d *DposContext) SetCompounding(delegatorAddr common.Address, compound bool) error {
+	key := append(delegatorAddr.Bytes(), compoundSuffix...)
+	if !compound {
+		if err := d.voteTrie.TryDelete(key); err != nil {
+			if _, ok := err.(*trie.MissingNodeError); !ok {
+				return err
+			}
+		}
+		return nil
+	}
+	return d.voteTrie.TryUpdate(key, []byte{1})
+}
+
+// BondedReward returns the rewards the given delegator compounded into its
+// stake so far.
+func (d *DposContext) BondedReward(delegatorAddr common.Address) *big.Int {
+	return new(big.Int).SetBytes(d.voteTrie.Get(append(delegatorAddr.Bytes(), bondedSuffix...)))
+}
+
+// AddBondedReward compounds a reward cut into the given delegator's bonded
+// stake.
+func (d *DposContext) AddBondedReward(delegatorAddr common.Address, amount *big.Int) error {
+	bonded := d.BondedReward(delegatorAddr)
+	bonded.Add(bonded, amount)
+	return d.voteTrie.TryUpdate(append(delegatorAddr.Bytes(), bondedSuffix...), bonded.Bytes())
+}
+
+// ClearBondedReward removes the bonded reward accumulator of the given
+// delegator, after its amount has been paid out.
+func (d *DposContext) ClearBondedReward(delegatorAddr common.Address) error {
+	if err := d.voteTrie.TryDelete(append(delegatorAddr.Bytes(), bondedSuffix...)); err != nil {
+		if _, ok := err.(*trie.MissingNodeError); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// VoteNonce returns the meta-vote replay protection nonce of the given
+// delegator. A delegator who never voted off-chain has nonce zero.
+func (d *DposContext) VoteNonce(delegatorAddr common.Address) uint64 {
+	enc := d.voteTrie.Get(append(delegatorAddr.Bytes(), voteNonceSuffix...))
+	if len(enc) == 0 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(enc)
+}
+
+// SetVoteNonce updates the meta-vote replay protection nonce of the given
+// delegator.
+func (d *DposContext) SetVoteNonce(delegatorAddr common.Address, nonce uint64) error {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, nonce)
+	return d.voteTrie.TryUpdate(append(delegatorAddr.Bytes(), voteNonceSuffix...), enc)
+}
+
+// VoteTime returns the unix time the given delegator last cast or
+// re-confirmed its vote, or zero if the vote predates the expiry mode.
+func (d *DposContext) VoteTime(delegatorAddr common.Address) int64 {
+	enc := d.voteTrie.Get(append(delegatorAddr.Bytes(), voteTimeSuffix...))
+	if len(enc) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(enc))
+}
+
+// SetVoteTime stamps the given delegator's vote with its confirmation time.
+func (d *DposContext) SetVoteTime(delegatorAddr common.Address, now int64) error {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, uint64(now))
+	return d.voteTrie.TryUpdate(append(delegatorAddr.Bytes(), voteTimeSuffix...), enc)
+}
+
+// ClearVoteTime removes the confirmation stamp of the given delegator's vote.
+func (d *DposContext) ClearVoteTime(delegatorAddr common.Address) error {
+	if err := d.voteTrie.TryDelete(append(delegatorAddr.Bytes(), voteTimeSuffix...)); err != nil {
+		if _, ok := err.(*trie.MissingNodeError); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddBalanceSample accumulates one balance sample of the given delegator for
+// the running epoch, feeding the average-balance vote weighting.
+func (d *DposContext) AddBalanceSample(delegatorAddr common.Address, balance *big.Int) error {
+	delegator := delegatorAddr.Bytes()
+	sum := new(big.Int)
+	if enc := d.voteTrie.Get(append(delegator, balanceSumSuffix...)); len(enc) > 0 {
+		sum.SetBytes(enc)
+	}
+	sum.Add(sum, balance)
+	if err := d.voteTrie.TryUpdate(append(delegator, balanceSumSuffix...), sum.Bytes()); err != nil {
+		return err
+	}
+	cnt := uint64(0)
+	if enc := d.voteTrie.Get(append(delegator, balanceCntSuffix...)); len(enc) == 8 {
+		cnt = binary.BigEndian.Uint64(enc)
+	}
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, cnt+1)
+	return d.voteTrie.TryUpdate(append(delegator, balanceCntSuffix...), enc)
+}
+
+// AverageBalance returns the average of the balance samples accumulated for
+// the given delegator during the running epoch, or nil if no sample was taken
+// yet.
+func (d *DposContext) AverageBalance(delegatorAddr common.Address) *big.Int {
+	delegator := delegatorAddr.Bytes()
+	enc := d.voteTrie.Get(append(delegator, balanceCntSuffix...))
+	if len(enc) != 8 {
+		return nil
+	}
+	cnt := binary.BigEndian.Uint64(enc)
+	if cnt == 0 {
+		return nil
+	}
+	sum := new(big.Int).SetBytes(d.voteTrie.Get(append(delegator, balanceSumSuffix...)))
+	return sum.Div(sum, new(big.Int).SetUint64(cnt))
+}
+
+// ClearBalanceSamples removes the balance accumulators of the given
+// delegator, starting a fresh sampling window for the next epoch.
+func (d *DposContext) ClearBalanceSamples(delegatorAddr common.Address) error {
+	delegator := delegatorAddr.Bytes()
+	if err := d.voteTrie.TryDelete(append(delegator, balanceSumSuffix...)); err != nil {
+		if _, ok := err.(*trie.MissingNodeError); !ok {
+			return err
+		}
+	}
+	if err := d.voteTrie.TryDelete(append(delegator, balanceCntSuffix...)); err != nil {
+		if _, ok := err.(*trie.MissingNodeError); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// GovernanceNonce returns the replay protection nonce of governance
+// operations. A chain that never saw one has nonce zero.
+func (d *DposContext) GovernanceNonce() uint64 {
+	enc := d.candidateTrie.Get(governanceNonceKey)
+	if len(enc) == 0 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(enc)
+}
+
+// SetGovernanceNonce updates the replay protection nonce of governance
+// operations.
+func (d *DposContext) SetGovernanceNonce(nonce uint64) error {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, nonce)
+	return d.candidateTrie.TryUpdate(governanceNonceKey, enc)
+}
+
+// PendingValidatorOverride returns the validator set a governance override
+// scheduled for the next epoch, or nil if no override is pending.
+func (d *DposContext) PendingValidatorOverride() ([]common.Address, error) {
+	enc, err := d.candidateTrie.TryGet(governanceOverrideKey)
+	if err != nil || enc == nil {
+		return nil, err
+	}
+	var validators []common.Address
+	if err := rlp.DecodeBytes(enc, &validators); err != nil {
+		return nil, err
+	}
+	return validators, nil
+}
+
+// SetPendingValidatorOverride schedules a governance validator override for
+// the next epoch transition.
+func (d *DposContext) SetPendingValidatorOverride(validators []common.Address) error {
+	enc, err := rlp.EncodeToBytes(validators)
+	if err != nil {
+		return err
+	}
+	return d.candidateTrie.TryUpdate(governanceOverrideKey, enc)
+}
+
+// ClearPendingValidatorOverride removes a pending governance validator
+// override after it has been applied.
+func (d *DposContext) ClearPendingValidatorOverride() error {
+	return d.candidateTrie.TryDelete(governanceOverrideKey)
+}
+
+// EvidenceUsed reports whether double-sign evidence with the given id was
+// already consumed, preventing the same conflict from being slashed twice.
+func (d *DposContext) EvidenceUsed(id common.Hash) (bool, error) {
+	enc, err := d.candidateTrie.TryGet(append(evidenceUsedPrefix, id.Bytes()...))
+	if err != nil {
+		return false, err
+	}
+	return len(enc) > 0, nil
+}
+
+// MarkEvidenceUsed records double-sign evidence with the given id as
+// consumed.
+func (d *DposContext) MarkEvidenceUsed(id common.Hash) error {
+	return d.candidateTrie.TryUpdate(append(evidenceUsedPrefix, id.Bytes()...), []byte{1})
+}
+
+// Commit writes all five tries to the backing trie database and returns the
+// proto pinning the new roots.
+func (d *DposContext) Commit() (*DposContextProto, error) {
+	epochRoot, err := d.epochTrie.Commit(nil)
+	if err != nil {
+		return nil, err
+	}
+	delegateRoot, err := d.delegateTrie.Commit(nil)
+	if err != nil {
+		return nil, err
+	}
+	voteRoot, err := d.voteTrie.Commit(nil)
+	if err != nil {
+		return nil, err
+	}
+	candidateRoot, err := d.candidateTrie.Commit(nil)
+	if err != nil {
+		return nil, err
+	}
+	mintCntRoot, err := d.mintCntTrie.Commit(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &DposContextProto{
+		EpochHash:     epochRoot,
+		DelegateHash:  delegateRoot,
+		VoteHash:      voteRoot,
+		CandidateHash: candidateRoot,
+		MintCntHash:   mintCntRoot,
+	}, nil
+}
+
+// Roots returns the non-empty root hashes of the five tries.
+func (d *DposContext) Roots() []common.Hash {
+	roots := make([]common.Hash, 0, 5)
+	for _, root := range []common.Hash{
+		d.epochTrie.Hash(),
+		d.delegateTrie.Hash(),
+		d.voteTrie.Hash(),
+		d.candidateTrie.Hash(),
+		d.mintCntTrie.Hash(),
+	} {
+		if root == EmptyRootHash {
+			continue
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// Flush writes the trie nodes of the committed context through to the
+// persistent database. It must be called after Commit, once the enclosing
+// block is sealed onto the chain. All five tries share a single database
+// batch, so epoch-boundary blocks rewriting the whole epoch and mint counter
+// tries don't degrade into a burst of small writes.
+func (d *DposContext) Flush() error {
+	return d.db.CommitRoots(d.Roots(), false)
+}
+
+// CandidateTrie returns the trie holding the registered candidates.
+func (d *DposContext) CandidateTrie() *trie.Trie { return d.candidateTrie }
+
+// DelegateTrie returns the trie holding the candidate -> delegator mappings.
+func (d *DposContext) DelegateTrie() *trie.Trie { return d.delegateTrie }
+
+// VoteTrie returns the trie holding the delegator -> candidate mappings.
+func (d *DposContext) VoteTrie() *trie.Trie { return d.voteTrie }
+
+// EpochTrie returns the trie holding the active validator set.
+func (d *DposContext) EpochTrie() *trie.Trie { return d.epochTrie }
+
+// MintCntTrie returns the trie holding the per epoch block counters.
+func (d *DposContext) MintCntTrie() *trie.Trie { return d.mintCntTrie }
+
+// DB returns the backing trie database.
+func (d *DposContext) DB() *trie.Database { return d.db }
+
+// SetEpoch replaces the epoch trie, e.g. when reverting to a snapshot.
+func (d *DposContext) SetEpoch(epoch *trie.Trie) { d.epochTrie = epoch }
+
+// SetDelegate replaces the delegate trie, e.g. when reverting to a snapshot.
+func (d *DposContext) SetDelegate(delegate *trie.Trie) { d.delegateTrie = delegate }
+
+// SetVote replaces the vote trie, e.g. when reverting to a snapshot.
+func (d *DposContext) SetVote(vote *trie.Trie) { d.voteTrie = vote }
+
+// SetCandidate replaces the candidate trie, e.g. when reverting to a snapshot.
+func (d *DposContext) SetCandidate(candidate *trie.Trie) { d.candidateTrie = candidate }
+
+// SetMintCnt replaces the mint counter trie, e.g. when reverting to a snapshot.
+func (d *DposContext) SetMintCnt(mintCnt *trie.Trie) { d.mintCntTrie = mintCnt }
+
+// validatorsCacheSize is the number of decoded validator sets kept in memory.
+// The set changes only at epoch transitions, so a handful of entries covers
+// the head plus the occasional historical query.
+const validatorsCacheSize = 16
+
+// validatorsCache caches decoded validator sets keyed by epoch trie root.
+// Block verification and the miner ask for the set on every block, while the
+// underlying trie entry changes once per epoch; keying by root invalidates
+// the cache naturally whenever the set is rewritten.
+var validatorsCache, _ = lru.New(validatorsCacheSize)
+
+// GetValidators retrieves the active validator set of the current epoch.
+func (d *DposContext) GetValidators() ([]common.Address, error) {
+	root := d.epochTrie.Hash()
+	if cached, ok := validatorsCache.Get(root); ok {
+		return append([]common.Address(nil), cached.([]common.Address)...), nil
+	}
+	var validators []common.Address
+	validatorsRLP := d.epochTrie.Get(epochValidatorKey)
+	if err := rlp.DecodeBytes(validatorsRLP, &validators); err != nil {
+		return nil, fmt.Errorf("failed to decode validators: %s", err)
+	}
+	validatorsCache.Add(root, append([]common.Address(nil), validators...))
+	return validators, nil
+}
+
+// SetValidators updates the active validator set of the current epoch.
+func (d *DposContext) SetValidators(validators []common.Address) error {
+	validatorsRLP, err := rlp.EncodeToBytes(validators)
+	if err != nil {
+		return fmt.Errorf("failed to encode validators to rlp bytes: %s", err)
+	}
+	d.epochTrie.Update(epochValidatorKey, validatorsRLP)
+	return nil
+}
+
+// EpochChange is the canonical record of one epoch transition, written into
+// the epoch trie alongside the validator set it installed. Since the epoch
+// trie root is committed to by every block header, the record makes elections
+// provable to light clients with a single trie proof, instead of re-derivable
+// only by full execution.
+type EpochChange struct {
+	Epoch       uint64           // Epoch the transition elected validators for
+	Validators  []common.Address // Final ordered validator set
+	Kicked      []common.Address // Validators removed for inactivity
+	TalliesHash common.Hash      // Hash of the rlp encoded vote tallies
+}
+
+// GetEpochChange retrieves the record of the transition that installed the
+// current validator set, or nil for epochs predating the records.
+func (d *DposContext) GetEpochChange() (*EpochChange, error) {
+	changeRLP := d.epochTrie.Get(epochChangeKey)
+	if len(changeRLP) == 0 {
+		return nil, nil
+	}
+	change := new(EpochChange)
+	if err := rlp.DecodeBytes(changeRLP, change); err != nil {
+		return nil, fmt.Errorf("failed to decode epoch change: %s", err)
+	}
+	return change, nil
+}
+
+// SetEpochChange stores the record of the epoch transition being applied.
+func (d *DposContext) SetEpochChange(change *EpochChange) error {
+	changeRLP, err := rlp.EncodeToBytes(change)
+	if err != nil {
+		return fmt.Errorf("failed to encode epoch change to rlp bytes: %s", err)
+	}
+	d.epochTrie.Update(epochChangeKey, changeRLP)
+	return nil
+}
+
+// GetEpochLayout retrieves the slot layout cycle of the current epoch: a
+// sequence of indexes into the validator set repeated over the epoch's slots.
+// Nil means the epoch uses the uniform round robin.
+func (d *DposContext) GetEpochLayout() []byte {
+	return d.epochTrie.Get(epochLayoutKey)
+}
+
+// SetEpochLayout stores the slot layout cycle of the current epoch.
+func (d *DposContext) SetEpochLayout(layout []byte) {
+	d.epochTrie.Update(epochLayoutKey, layout)
+}
+
+// GetMintCnt retrieves the number of blocks the given validator minted within
+// the given epoch.
+func (d *DposContext) GetMintCnt(epoch int64, validator common.Address) int64 {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(epoch))
+	cntBytes := d.mintCntTrie.Get(append(key, validator.Bytes()...))
+	if cntBytes == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(cntBytes))
+}
+
+// DposContextDump is the full rendering of a dpos context for debug dumps:
+// every candidate, delegation, vote, validator and mint counter at the block
+// the context is rooted in, serving forensic analysis and test fixture
+// generation.
+type DposContextDump struct {
+	Roots       *DposContextProto                    `json:"roots"`
+	Validators  []common.Address                     `json:"validators"`
+	Candidates  []common.Address                     `json:"candidates"`
+	Delegations map[common.Address][]common.Address  `json:"delegations"` // Delegators keyed by the candidate they back
+	Votes       map[common.Address]common.Address    `json:"votes"`       // Voted candidate keyed by delegator
+	MintCnt     map[uint64]map[common.Address]uint64 `json:"mintCnt"`     // Per validator block counters keyed by epoch
+}
+
+// RawDump walks all five tries of the context and returns their full
+// contents. Auxiliary entries such as governance keys and kickout cooldowns
+// are skipped; the dumped trie roots pin the exact revision the dump was
+// taken at.
+func (d *DposContext) RawDump() (*DposContextDump, error) {
+	validators, err := d.GetValidators()
+	if err != nil {
+		return nil, err
+	}
+	dump := &DposContextDump{
+		Roots:       d.ToProto(),
+		Validators:  validators,
+		Delegations: make(map[common.Address][]common.Address),
+		Votes:       make(map[common.Address]common.Address),
+		MintCnt:     make(map[uint64]map[common.Address]uint64),
+	}
+	iter := trie.NewIterator(d.candidateTrie.NodeIterator(nil))
+	for iter.Next() {
+		if len(iter.Key) != common.AddressLength {
+			continue
+		}
+		dump.Candidates = append(dump.Candidates, common.BytesToAddress(iter.Value))
+	}
+	iter = trie.NewIterator(d.delegateTrie.NodeIterator(nil))
+	for iter.Next() {
+		if len(iter.Key) != 2*common.AddressLength {
+			continue
+		}
+		candidate := common.BytesToAddress(iter.Key[:common.AddressLength])
+		dump.Delegations[candidate] = append(dump.Delegations[candidate], common.BytesToAddress(iter.Key[common.AddressLength:]))
+	}
+	iter = trie.NewIterator(d.voteTrie.NodeIterator(nil))
+	for iter.Next() {
+		if len(iter.Key) != common.AddressLength {
+			continue
+		}
+		dump.Votes[common.BytesToAddress(iter.Key)] = common.BytesToAddress(iter.Value)
+	}
+	iter = trie.NewIterator(d.mintCntTrie.NodeIterator(nil))
+	for iter.Next() {
+		if len(iter.Key) != 8+common.AddressLength {
+			continue
+		}
+		epoch := binary.BigEndian.Uint64(iter.Key[:8])
+		if dump.MintCnt[epoch] == nil {
+			dump.MintCnt[epoch] = make(map[common.Address]uint64)
+		}
+		dump.MintCnt[epoch][common.BytesToAddress(iter.Key[8:])] = binary.BigEndian.Uint64(iter.Value)
+	}
+	return dump, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// CheckConsistency verifies the cross-trie invariants of the dpos context:
+//
+//   - every vote trie entry has a matching delegate trie entry and the voted
+//     candidate is registered in the candidate trie
+//   - every delegate trie entry is backed by a vote trie entry pointing at
+//     the same candidate
+//
+// It returns the first violation found, or nil if the context is consistent.
+// Validators of the current epoch are deliberately not required to still be
+// candidates: a kickout removes the candidate entry while the epoch trie
+// keeps the set elected at the last boundary.
+func (d *DposContext) CheckConsistency() error {
+	// Walk the vote trie and require matching delegate and candidate entries
+	voteIter := trie.NewIterator(d.voteTrie.NodeIterator(nil))
+	for voteIter.Next() {
+		// Skip auxiliary entries such as meta-vote nonces
+		if len(voteIter.Key) != common.AddressLength {
+			continue
+		}
+		delegator, candidate := voteIter.Key, voteIter.Value
+		if got := d.candidateTrie.Get(candidate); got == nil {
+			return fmt.Errorf("vote of %x points at unregistered candidate %x", delegator, candidate)
+		}
+		if got := d.delegateTrie.Get(append(candidate, delegator...)); !bytes.Equal(got, delegator) {
+			return fmt.Errorf("vote of %x for %x has no matching delegate entry", delegator, candidate)
+		}
+	}
+	// Walk the delegate trie and require backing vote entries
+	delegateIter := trie.NewIterator(d.delegateTrie.NodeIterator(nil))
+	for delegateIter.Next() {
+		if len(delegateIter.Key) != 2*common.AddressLength {
+			return fmt.Errorf("malformed delegate trie key %x", delegateIter.Key)
+		}
+		candidate, delegator := delegateIter.Key[:common.AddressLength], delegateIter.Key[common.AddressLength:]
+		if !bytes.Equal(delegateIter.Value, delegator) {
+			return fmt.Errorf("delegate entry %x carries mismatching value %x", delegateIter.Key, delegateIter.Value)
+		}
+		if got := d.voteTrie.Get(delegator); !bytes.Equal(got, candidate) {
+			return fmt.Errorf("delegate entry of %x under %x not backed by a vote", delegator, candidate)
+		}
+	}
+	// The epoch trie must hold a decodable, non-empty validator set
+	validators, err := d.GetValidators()
+	if err != nil {
+		return fmt.Errorf("corrupted validator set: %v", err)
+	}
+	if len(validators) == 0 {
+		return fmt.Errorf("empty validator set")
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func testProto() *DposContextProto {
+	return &DposContextProto{
+		EpochHash:     common.HexToHash("01"),
+		DelegateHash:  common.HexToHash("02"),
+		CandidateHash: common.HexToHash("03"),
+		VoteHash:      common.HexToHash("04"),
+		MintCntHash:   common.HexToHash("05"),
+	}
+}
+
+// Headers without a dpos context must keep encoding, decoding and hashing as
+// they did before the context field existed.
+func TestHeaderRLPWithoutDposContext(t *testing.T) {
+	header := &Header{
+		Difficulty: big.NewInt(131072),
+		Number:     big.NewInt(100),
+		GasLimit:   3141592,
+		Time:       big.NewInt(1426516743),
+	}
+	hash := header.Hash()
+
+	enc, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	var dec Header
+	if err := rlp.DecodeBytes(enc, &dec); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if dec.DposContext != nil {
+		t.Errorf("decoded header has a dpos context, want nil")
+	}
+	if dec.Hash() != hash {
+		t.Errorf("hash mismatch after round trip: got %x, want %x", dec.Hash(), hash)
+	}
+}
+
+// Headers carrying a dpos context must round-trip it and report the same hash.
+func TestHeaderRLPWithDposContext(t *testing.T) {
+	header := &Header{
+		Difficulty:  big.NewInt(131072),
+		Number:      big.NewInt(100),
+		GasLimit:    3141592,
+		Time:        big.NewInt(1426516743),
+		DposContext: testProto(),
+	}
+	hash := header.Hash()
+
+	enc, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	var dec Header
+	if err := rlp.DecodeBytes(enc, &dec); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if dec.DposContext == nil {
+		t.Fatalf("decoded header lost its dpos context")
+	}
+	if !dec.DposContext.Equal(header.DposContext) {
+		t.Errorf("dpos context mismatch: got %+v, want %+v", dec.DposContext, header.DposContext)
+	}
+	if dec.Hash() != hash {
+		t.Errorf("hash mismatch after round trip: got %x, want %x", dec.Hash(), hash)
+	}
+}
+
+// A bare root list must decode as envelope version zero and re-encode
+// byte-identically.
+func TestDposContextProtoBareRoundTrip(t *testing.T) {
+	enc, err := rlp.EncodeToBytes(testProto())
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	var dec DposContextProto
+	if err := rlp.DecodeBytes(enc, &dec); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if dec.EncodingVersion() != 0 {
+		t.Errorf("encoding version: got %d, want 0", dec.EncodingVersion())
+	}
+	reenc, err := rlp.EncodeToBytes(&dec)
+	if err != nil {
+		t.Fatalf("re-encode error: %v", err)
+	}
+	if !bytes.Equal(reenc, enc) {
+		t.Errorf("re-encoded proto mismatch:\ngot:  %x\nwant: %x", reenc, enc)
+	}
+}
+
+// A versioned envelope with extension fields this client does not know must
+// survive a decode/encode cycle verbatim.
+func TestDposContextProtoEnvelopeRoundTrip(t *testing.T) {
+	proto := testProto()
+	out := []interface{}{
+		uint64(DposContextVersion),
+		proto.EpochHash, proto.DelegateHash, proto.CandidateHash, proto.VoteHash, proto.MintCntHash,
+		[]byte("extension"),
+	}
+	enc, err := rlp.EncodeToBytes(out)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	var dec DposContextProto
+	if err := rlp.DecodeBytes(enc, &dec); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if dec.EncodingVersion() != DposContextVersion {
+		t.Errorf("encoding version: got %d, want %d", dec.EncodingVersion(), DposContextVersion)
+	}
+	if !dec.Equal(proto) {
+		t.Errorf("proto mismatch: got %+v, want %+v", &dec, proto)
+	}
+	reenc, err := rlp.EncodeToBytes(&dec)
+	if err != nil {
+		t.Fatalf("re-encode error: %v", err)
+	}
+	if !bytes.Equal(reenc, enc) {
+		t.Errorf("re-encoded envelope mismatch:\ngot:  %x\nwant: %x", reenc, enc)
+	}
+}
+
+// A bare version-0 list carrying a tail extension must survive a
+// decode/encode cycle verbatim too: tail fields ride without a version bump.
+func TestDposContextProtoBareTailRoundTrip(t *testing.T) {
+	proto := testProto()
+	out := []interface{}{
+		proto.EpochHash, proto.DelegateHash, proto.CandidateHash, proto.VoteHash, proto.MintCntHash,
+		[]byte("extension"),
+	}
+	enc, err := rlp.EncodeToBytes(out)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	var dec DposContextProto
+	if err := rlp.DecodeBytes(enc, &dec); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if dec.EncodingVersion() != 0 {
+		t.Errorf("encoding version: got %d, want 0", dec.EncodingVersion())
+	}
+	if !dec.Equal(proto) {
+		t.Errorf("proto mismatch: got %+v, want %+v", &dec, proto)
+	}
+	reenc, err := rlp.EncodeToBytes(&dec)
+	if err != nil {
+		t.Fatalf("re-encode error: %v", err)
+	}
+	if !bytes.Equal(reenc, enc) {
+		t.Errorf("re-encoded proto mismatch:\ngot:  %x\nwant: %x", reenc, enc)
+	}
+}
+
+// Envelope versions newer than the client understands are rejected.
+func TestDposContextProtoUnknownVersion(t *testing.T) {
+	proto := testProto()
+	out := []interface{}{
+		uint64(DposContextVersion + 1),
+		proto.EpochHash, proto.DelegateHash, proto.CandidateHash, proto.VoteHash, proto.MintCntHash,
+	}
+	enc, err := rlp.EncodeToBytes(out)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	var dec DposContextProto
+	if err := rlp.DecodeBytes(enc, &dec); err == nil {
+		t.Errorf("decoding version %d succeeded, want error", DposContextVersion+1)
+	}
+}
+
+func TestDposContextProtoEqual(t *testing.T) {
+	a, b := testProto(), testProto()
+	if !a.Equal(b) {
+		t.Errorf("identical protos reported unequal")
+	}
+	b.VoteHash = common.HexToHash("ff")
+	if a.Equal(b) {
+		t.Errorf("differing protos reported equal")
+	}
+	var nilProto *DposContextProto
+	if nilProto.Equal(a) || a.Equal(nilProto) {
+		t.Errorf("nil compared equal to a non-nil proto")
+	}
+	if !nilProto.Equal(nil) {
+		t.Errorf("two nil protos reported unequal")
+	}
+}
+
+// Tests that the hot/cold partition used by the differential validator sync
+// splits the recorded roots without losing or duplicating any: the hot tries
+// gate sealing, the cold delegation tries are backfilled later.
+func TestDposContextProtoRootPartition(t *testing.T) {
+	proto := testProto()
+	hot, cold := proto.HotRoots(), proto.ColdRoots()
+	if len(hot) != 3 || hot[0] != proto.EpochHash || hot[1] != proto.CandidateHash || hot[2] != proto.MintCntHash {
+		t.Errorf("hot roots: got %v, want epoch, candidate and mintCnt", hot)
+	}
+	if len(cold) != 2 || cold[0] != proto.DelegateHash || cold[1] != proto.VoteHash {
+		t.Errorf("cold roots: got %v, want delegate and vote", cold)
+	}
+	if len(hot)+len(cold) != len(proto.Roots()) {
+		t.Errorf("partition size mismatch: %d hot + %d cold, want %d total", len(hot), len(cold), len(proto.Roots()))
+	}
+
+	// Zero and empty roots are skipped on both sides, matching Roots
+	proto.CandidateHash = common.Hash{}
+	proto.VoteHash = EmptyRootHash
+	if hot := proto.HotRoots(); len(hot) != 2 {
+		t.Errorf("hot roots with zeroed candidate: got %v, want 2 entries", hot)
+	}
+	if cold := proto.ColdRoots(); len(cold) != 1 {
+		t.Errorf("cold roots with empty vote: got %v, want 1 entry", cold)
+	}
+}
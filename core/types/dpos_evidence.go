@@ -0,0 +1,127 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// evidenceSealLength is the length of the validator seal at the end of a
+// header's extra data, mirroring the engine's layout.
+const evidenceSealLength = 65
+
+// Errors returned when verifying double-sign evidence.
+var (
+	ErrEvidenceIncomplete  = errors.New("evidence must carry two sealed headers")
+	ErrEvidenceNoConflict  = errors.New("evidence headers do not conflict")
+	ErrEvidenceWrongSigner = errors.New("evidence header not sealed by its validator")
+)
+
+// HeaderEvidence proves a validator signed two conflicting headers for the
+// same time slot. Anyone observing the conflict can wrap it in a
+// ReportEvidence transaction; verification recovers the offender from the
+// header seals, so the evidence itself needs no further authentication.
+type HeaderEvidence struct {
+	Header1 *Header
+	Header2 *Header
+}
+
+// evidenceSealHash recomputes the hash the validator sealed, the header hash
+// with the seal bytes stripped from the extra data.
+func evidenceSealHash(header *Header) (hash common.Hash) {
+	hasher := sha3.NewKeccak256()
+	rlp.Encode(hasher, []interface{}{
+		header.ParentHash,
+		header.UncleHash,
+		header.Validator,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		header.Extra[:len(header.Extra)-evidenceSealLength],
+		header.MixDigest,
+		header.Nonce,
+		header.DposContext.Root(),
+	})
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// evidenceSigner recovers the address that sealed the given header.
+func evidenceSigner(header *Header) (common.Address, error) {
+	if len(header.Extra) < evidenceSealLength {
+		return common.Address{}, ErrEvidenceIncomplete
+	}
+	seal := header.Extra[len(header.Extra)-evidenceSealLength:]
+	pubkey, err := crypto.Ecrecover(evidenceSealHash(header).Bytes(), seal)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	return signer, nil
+}
+
+// Verify checks that the two headers genuinely conflict: distinct headers for
+// the same time slot, both attributed to and sealed by the same validator. It
+// returns the offending validator.
+func (ev *HeaderEvidence) Verify() (common.Address, error) {
+	if ev.Header1 == nil || ev.Header2 == nil || ev.Header1.DposContext == nil || ev.Header2.DposContext == nil {
+		return common.Address{}, ErrEvidenceIncomplete
+	}
+	if ev.Header1.Hash() == ev.Header2.Hash() {
+		return common.Address{}, ErrEvidenceNoConflict
+	}
+	if ev.Header1.Time.Cmp(ev.Header2.Time) != 0 {
+		return common.Address{}, ErrEvidenceNoConflict
+	}
+	if ev.Header1.Validator != ev.Header2.Validator || ev.Header1.Validator == (common.Address{}) {
+		return common.Address{}, ErrEvidenceNoConflict
+	}
+	for _, header := range []*Header{ev.Header1, ev.Header2} {
+		signer, err := evidenceSigner(header)
+		if err != nil {
+			return common.Address{}, err
+		}
+		if signer != header.Validator {
+			return common.Address{}, ErrEvidenceWrongSigner
+		}
+	}
+	return ev.Header1.Validator, nil
+}
+
+// ID returns the canonical identifier of the evidence, invariant under the
+// order of the two headers, used for replay protection and pool deduplication.
+func (ev *HeaderEvidence) ID() common.Hash {
+	first, second := ev.Header1.Hash(), ev.Header2.Hash()
+	if bytes.Compare(first.Bytes(), second.Bytes()) > 0 {
+		first, second = second, first
+	}
+	return common.BytesToHash(crypto.Keccak256(first.Bytes(), second.Bytes()))
+}
@@ -0,0 +1,260 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// EIP-712 type hashes of the governance domain, mirroring the meta-vote
+// domain: the chain id is the only variable part, so an override signed for
+// one network can never be replayed on another.
+var (
+	overrideDomainTypeHash = crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId)"))
+	overrideDomainName     = crypto.Keccak256([]byte("DPoS Governance"))
+	overrideDomainVersion  = crypto.Keccak256([]byte("1"))
+	overrideTypeHash       = crypto.Keccak256([]byte("ValidatorOverride(address[] validators,uint256 nonce)"))
+	blacklistTypeHash      = crypto.Keccak256([]byte("Blacklist(address account,uint256 expiry,uint256 nonce)"))
+	candidateAuthTypeHash  = crypto.Keccak256([]byte("CandidateAuthorization(address candidate,uint8 txType,bytes32 payloadHash,uint256 nonce)"))
+)
+
+var (
+	// ErrInvalidOverrideSig is returned when a signature carried by a
+	// validator override does not recover to a valid public key.
+	ErrInvalidOverrideSig = errors.New("invalid validator override v, r, s values")
+)
+
+// ValidatorOverride is a break-glass governance operation: a quorum of the
+// governance signers configured in genesis may replace the validator set at
+// the next epoch, recovering a network whose validator majority lost its
+// keys. The nonce provides chain-wide replay protection.
+type ValidatorOverride struct {
+	Validators []common.Address
+	Nonce      uint64
+
+	// Signatures of the governance signers over the typed data hash
+	V []*big.Int
+	R []*big.Int
+	S []*big.Int
+}
+
+// TypedDataHash returns the EIP-712 digest the governance signers sign.
+func (vo *ValidatorOverride) TypedDataHash(chainID *big.Int) common.Hash {
+	domainSeparator := crypto.Keccak256(
+		overrideDomainTypeHash,
+		overrideDomainName,
+		overrideDomainVersion,
+		common.LeftPadBytes(chainID.Bytes(), 32),
+	)
+	validators := make([]byte, 0, len(vo.Validators)*32)
+	for _, validator := range vo.Validators {
+		validators = append(validators, common.LeftPadBytes(validator.Bytes(), 32)...)
+	}
+	structHash := crypto.Keccak256(
+		overrideTypeHash,
+		crypto.Keccak256(validators),
+		common.LeftPadBytes(new(big.Int).SetUint64(vo.Nonce).Bytes(), 32),
+	)
+	return common.BytesToHash(crypto.Keccak256(
+		[]byte{0x19, 0x01},
+		domainSeparator,
+		structHash,
+	))
+}
+
+// RecoverSigners extracts the signer addresses from the override's
+// signatures. The caller is responsible for checking the signers against the
+// configured governance set and quorum.
+func (vo *ValidatorOverride) RecoverSigners(chainID *big.Int) ([]common.Address, error) {
+	return recoverGovernanceSigners(vo.TypedDataHash(chainID), vo.V, vo.R, vo.S)
+}
+
+// recoverGovernanceSigners extracts the signer addresses from the signatures
+// a governance operation carries over its typed data hash.
+func recoverGovernanceSigners(hash common.Hash, V, R, S []*big.Int) ([]common.Address, error) {
+	if len(V) != len(R) || len(V) != len(S) {
+		return nil, ErrInvalidOverrideSig
+	}
+	signers := make([]common.Address, 0, len(V))
+	for i := range V {
+		if V[i] == nil || R[i] == nil || S[i] == nil {
+			return nil, ErrInvalidOverrideSig
+		}
+		v := byte(new(big.Int).Sub(V[i], big.NewInt(27)).Uint64())
+		if !crypto.ValidateSignatureValues(v, R[i], S[i], true) {
+			return nil, ErrInvalidOverrideSig
+		}
+		sig := make([]byte, 65)
+		copy(sig[32-len(R[i].Bytes()):32], R[i].Bytes())
+		copy(sig[64-len(S[i].Bytes()):64], S[i].Bytes())
+		sig[64] = v
+
+		pubkey, err := crypto.Ecrecover(hash.Bytes(), sig)
+		if err != nil {
+			return nil, err
+		}
+		var signer common.Address
+		copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// Blacklist is a governance operation barring an address from standing for
+// election until the expiry time: the address may not re-register as a
+// candidate and its votes are ignored by the tally. An expiry at or before
+// the current block time lifts an earlier entry. Like a validator override,
+// it is authorized by a quorum of the governance signers and shares their
+// replay protection nonce.
+type Blacklist struct {
+	Account common.Address
+	Expiry  uint64
+	Nonce   uint64
+
+	// Signatures of the governance signers over the typed data hash
+	V []*big.Int
+	R []*big.Int
+	S []*big.Int
+}
+
+// TypedDataHash returns the EIP-712 digest the governance signers sign.
+func (b *Blacklist) TypedDataHash(chainID *big.Int) common.Hash {
+	domainSeparator := crypto.Keccak256(
+		overrideDomainTypeHash,
+		overrideDomainName,
+		overrideDomainVersion,
+		common.LeftPadBytes(chainID.Bytes(), 32),
+	)
+	structHash := crypto.Keccak256(
+		blacklistTypeHash,
+		common.LeftPadBytes(b.Account.Bytes(), 32),
+		common.LeftPadBytes(new(big.Int).SetUint64(b.Expiry).Bytes(), 32),
+		common.LeftPadBytes(new(big.Int).SetUint64(b.Nonce).Bytes(), 32),
+	)
+	return common.BytesToHash(crypto.Keccak256(
+		[]byte{0x19, 0x01},
+		domainSeparator,
+		structHash,
+	))
+}
+
+// RecoverSigners extracts the signer addresses from the blacklist entry's
+// signatures. The caller is responsible for checking the signers against the
+// configured governance set and quorum.
+func (b *Blacklist) RecoverSigners(chainID *big.Int) ([]common.Address, error) {
+	return recoverGovernanceSigners(b.TypedDataHash(chainID), b.V, b.R, b.S)
+}
+
+// SignBlacklist appends the given governance signer's signature to the
+// blacklist entry.
+func SignBlacklist(blacklist *Blacklist, chainID *big.Int, prv *ecdsa.PrivateKey) (*Blacklist, error) {
+	sig, err := crypto.Sign(blacklist.TypedDataHash(chainID).Bytes(), prv)
+	if err != nil {
+		return nil, err
+	}
+	cpy := *blacklist
+	cpy.V = append(append([]*big.Int(nil), blacklist.V...), new(big.Int).SetBytes([]byte{sig[64] + 27}))
+	cpy.R = append(append([]*big.Int(nil), blacklist.R...), new(big.Int).SetBytes(sig[:32]))
+	cpy.S = append(append([]*big.Int(nil), blacklist.S...), new(big.Int).SetBytes(sig[32:64]))
+	return &cpy, nil
+}
+
+// CandidateOwnerSet is the payload of a SetCandidateOwners operation: the
+// owner addresses and the number of them that must co-sign the candidate's
+// management operations from then on. An empty set removes multisig control,
+// returning the candidate to its own key.
+type CandidateOwnerSet struct {
+	Threshold uint64
+	Owners    []common.Address
+}
+
+// CandidateAuthorization wraps a candidate-management operation with the
+// owner signatures authorizing it. The signed digest binds the candidate, the
+// operation type, the inner payload and the candidate's owner nonce, so a
+// signature authorizes exactly one operation, once, on one candidate.
+type CandidateAuthorization struct {
+	Payload []byte
+	Nonce   uint64
+
+	// Signatures of the candidate owners over the typed data hash
+	V []*big.Int
+	R []*big.Int
+	S []*big.Int
+}
+
+// TypedDataHash returns the EIP-712 digest the candidate owners sign for the
+// given candidate and operation type.
+func (ca *CandidateAuthorization) TypedDataHash(chainID *big.Int, candidate common.Address, txType TxType) common.Hash {
+	domainSeparator := crypto.Keccak256(
+		overrideDomainTypeHash,
+		overrideDomainName,
+		overrideDomainVersion,
+		common.LeftPadBytes(chainID.Bytes(), 32),
+	)
+	structHash := crypto.Keccak256(
+		candidateAuthTypeHash,
+		common.LeftPadBytes(candidate.Bytes(), 32),
+		common.LeftPadBytes([]byte{byte(txType)}, 32),
+		crypto.Keccak256(ca.Payload),
+		common.LeftPadBytes(new(big.Int).SetUint64(ca.Nonce).Bytes(), 32),
+	)
+	return common.BytesToHash(crypto.Keccak256(
+		[]byte{0x19, 0x01},
+		domainSeparator,
+		structHash,
+	))
+}
+
+// RecoverSigners extracts the signer addresses from the authorization's
+// signatures. The caller is responsible for checking the signers against the
+// candidate's owner set and threshold.
+func (ca *CandidateAuthorization) RecoverSigners(chainID *big.Int, candidate common.Address, txType TxType) ([]common.Address, error) {
+	return recoverGovernanceSigners(ca.TypedDataHash(chainID, candidate, txType), ca.V, ca.R, ca.S)
+}
+
+// SignCandidateAuthorization appends the given owner's signature to the
+// authorization.
+func SignCandidateAuthorization(auth *CandidateAuthorization, chainID *big.Int, candidate common.Address, txType TxType, prv *ecdsa.PrivateKey) (*CandidateAuthorization, error) {
+	sig, err := crypto.Sign(auth.TypedDataHash(chainID, candidate, txType).Bytes(), prv)
+	if err != nil {
+		return nil, err
+	}
+	cpy := *auth
+	cpy.V = append(append([]*big.Int(nil), auth.V...), new(big.Int).SetBytes([]byte{sig[64] + 27}))
+	cpy.R = append(append([]*big.Int(nil), auth.R...), new(big.Int).SetBytes(sig[:32]))
+	cpy.S = append(append([]*big.Int(nil), auth.S...), new(big.Int).SetBytes(sig[32:64]))
+	return &cpy, nil
+}
+
+// SignValidatorOverride appends the given governance signer's signature to
+// the override.
+func SignValidatorOverride(override *ValidatorOverride, chainID *big.Int, prv *ecdsa.PrivateKey) (*ValidatorOverride, error) {
+	sig, err := crypto.Sign(override.TypedDataHash(chainID).Bytes(), prv)
+	if err != nil {
+		return nil, err
+	}
+	cpy := *override
+	cpy.V = append(append([]*big.Int(nil), override.V...), new(big.Int).SetBytes([]byte{sig[64] + 27}))
+	cpy.R = append(append([]*big.Int(nil), override.R...), new(big.Int).SetBytes(sig[:32]))
+	cpy.S = append(append([]*big.Int(nil), override.S...), new(big.Int).SetBytes(sig[32:64]))
+	return &cpy, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// EIP-712 type hashes of the meta-vote domain. The domain is deliberately
+// small: the chain id is the only variable part, so a vote signed for one
+// network can never be replayed on another.
+var (
+	metaVoteDomainTypeHash = crypto.Keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId)"))
+	metaVoteDomainName     = crypto.Keccak256([]byte("DPoS Vote"))
+	metaVoteDomainVersion  = crypto.Keccak256([]byte("1"))
+	metaVoteTypeHash       = crypto.Keccak256([]byte("Vote(address candidate,uint256 nonce)"))
+)
+
+var (
+	// ErrInvalidMetaVoteSig is returned when the signature carried by a
+	// meta-vote does not recover to a valid public key.
+	ErrInvalidMetaVoteSig = errors.New("invalid meta-vote v, r, s values")
+)
+
+// MetaVoteMsg is a vote signed off-chain with an EIP-712 typed message. Any
+// account may wrap it in a MetaVote transaction and submit it on-chain; the
+// resulting delegation is attributed to the signer while the relayer pays
+// for gas. The nonce provides per-delegator replay protection.
+type MetaVoteMsg struct {
+	Candidate common.Address
+	Nonce     uint64
+
+	// Signature of the delegator over the typed data hash
+	V *big.Int
+	R *big.Int
+	S *big.Int
+}
+
+// TypedDataHash returns the EIP-712 digest the delegator signs.
+func (mv *MetaVoteMsg) TypedDataHash(chainID *big.Int) common.Hash {
+	domainSeparator := crypto.Keccak256(
+		metaVoteDomainTypeHash,
+		metaVoteDomainName,
+		metaVoteDomainVersion,
+		common.LeftPadBytes(chainID.Bytes(), 32),
+	)
+	structHash := crypto.Keccak256(
+		metaVoteTypeHash,
+		common.LeftPadBytes(mv.Candidate.Bytes(), 32),
+		common.LeftPadBytes(new(big.Int).SetUint64(mv.Nonce).Bytes(), 32),
+	)
+	return common.BytesToHash(crypto.Keccak256(
+		[]byte{0x19, 0x01},
+		domainSeparator,
+		structHash,
+	))
+}
+
+// Recover extracts the delegator address from the vote's signature.
+func (mv *MetaVoteMsg) Recover(chainID *big.Int) (common.Address, error) {
+	if mv.V == nil || mv.R == nil || mv.S == nil {
+		return common.Address{}, ErrInvalidMetaVoteSig
+	}
+	v := byte(new(big.Int).Sub(mv.V, big.NewInt(27)).Uint64())
+	if !crypto.ValidateSignatureValues(v, mv.R, mv.S, true) {
+		return common.Address{}, ErrInvalidMetaVoteSig
+	}
+	sig := make([]byte, 65)
+	copy(sig[32-len(mv.R.Bytes()):32], mv.R.Bytes())
+	copy(sig[64-len(mv.S.Bytes()):64], mv.S.Bytes())
+	sig[64] = v
+
+	pubkey, err := crypto.Ecrecover(mv.TypedDataHash(chainID).Bytes(), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var delegator common.Address
+	copy(delegator[:], crypto.Keccak256(pubkey[1:])[12:])
+	return delegator, nil
+}
+
+// SignMetaVote signs the vote with the given delegator key.
+func SignMetaVote(vote *MetaVoteMsg, chainID *big.Int, prv *ecdsa.PrivateKey) (*MetaVoteMsg, error) {
+	sig, err := crypto.Sign(vote.TypedDataHash(chainID).Bytes(), prv)
+	if err != nil {
+		return nil, err
+	}
+	cpy := *vote
+	cpy.R = new(big.Int).SetBytes(sig[:32])
+	cpy.S = new(big.Int).SetBytes(sig[32:64])
+	cpy.V = new(big.Int).SetBytes([]byte{sig[64] + 27})
+	return &cpy, nil
+}
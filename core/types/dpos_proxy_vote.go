@@ -0,0 +1,95 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrProxyVoteEmpty is returned when a proxy vote carries a merkle root
+	// but no allocations backing it.
+	ErrProxyVoteEmpty = errors.New("proxy vote has a root but no allocations")
+
+	// ErrProxyVoteWeight is returned when a proxy allocation carries a
+	// missing or non-positive weight.
+	ErrProxyVoteWeight = errors.New("proxy allocation weight must be positive")
+
+	// ErrProxyVoteDuplicate is returned when a proxy vote allocates to the
+	// same candidate twice.
+	ErrProxyVoteDuplicate = errors.New("duplicate candidate in proxy vote")
+)
+
+// ProxyAllocation is the aggregate vote weight a custodian assigns to a
+// single candidate on behalf of its users.
+type ProxyAllocation struct {
+	Candidate common.Address
+	Weight    *big.Int
+}
+
+// ProxyVote is the on-chain representation of custodial voting: an exchange
+// or custodian votes with the pooled stake of its users, publishing only the
+// per-candidate aggregates plus a merkle root over the individual user
+// preferences. The chain tallies the aggregates; the root lets users and
+// auditors prove, via ProxyVoteLeaf and the merkle verification precompile,
+// that a specific user's preference is part of the published allocation.
+//
+// Submitting a proxy vote replaces the custodian's previous one entirely; an
+// empty allocation list with a zero root retracts it.
+type ProxyVote struct {
+	Root        common.Hash
+	Allocations []ProxyAllocation
+}
+
+// Validate performs the payload sanity checks that do not need chain state:
+// the allocation list must match the presence of a root, name every candidate
+// at most once and carry positive weights. It returns the total allocated
+// weight, which the caller checks against the custodian's stake.
+func (pv *ProxyVote) Validate() (*big.Int, error) {
+	if pv.Root != (common.Hash{}) && len(pv.Allocations) == 0 {
+		return nil, ErrProxyVoteEmpty
+	}
+	total := new(big.Int)
+	seen := make(map[common.Address]bool, len(pv.Allocations))
+	for _, alloc := range pv.Allocations {
+		if alloc.Weight == nil || alloc.Weight.Sign() <= 0 {
+			return nil, ErrProxyVoteWeight
+		}
+		if seen[alloc.Candidate] {
+			return nil, ErrProxyVoteDuplicate
+		}
+		seen[alloc.Candidate] = true
+		total.Add(total, alloc.Weight)
+	}
+	return total, nil
+}
+
+// ProxyVoteLeaf returns the merkle leaf a custodian commits to for a single
+// user: keccak256(user ++ candidate ++ weight), with the weight left-padded
+// to 32 bytes. Inner nodes hash their children as a sorted pair, matching the
+// merkle verification precompile.
+func ProxyVoteLeaf(user, candidate common.Address, weight *big.Int) common.Hash {
+	return common.BytesToHash(crypto.Keccak256(
+		user.Bytes(),
+		candidate.Bytes(),
+		common.LeftPadBytes(weight.Bytes(), 32),
+	))
+}
@@ -0,0 +1,52 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// StakingStatsAddr is the system account whose storage mirrors each
+// validator's lifetime staking statistics, updated by the consensus engine
+// at epoch boundaries so on-chain contracts can read them.
+var StakingStatsAddr = common.HexToAddress("0x0000000000000000000000000000000000000200")
+
+// Storage layout of the staking statistics account. The counters are laid
+// out like three solidity mappings rooted at consecutive slots, so a
+// contract can declare
+//
+//	mapping(address => uint256) at slot 0, 1 and 2
+//
+// and read the mirrored values natively.
+const (
+	StakingStatsBlocksSlot  = iota // Lifetime blocks produced
+	StakingStatsRewardsSlot        // Cumulative minted block rewards in wei
+	StakingStatsSlashesSlot        // Cumulative slashed amounts in wei
+)
+
+// StakingStatSlot returns the storage slot holding the given validator's
+// counter in the staking statistics account, following the solidity mapping
+// layout keccak256(pad32(validator) . pad32(mappingSlot)).
+func StakingStatSlot(validator common.Address, mappingSlot uint64) common.Hash {
+	buf := make([]byte, 64)
+	copy(buf[12:32], validator.Bytes())
+	binary.BigEndian.PutUint64(buf[56:64], mappingSlot)
+	return common.BytesToHash(crypto.Keccak256(buf))
+}
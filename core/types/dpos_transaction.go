@@ -0,0 +1,403 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// TxType discriminates regular value/contract transactions from the special
+// delegated proof-of-stake operations of this fork.
+type TxType uint8
+
+const (
+	// Binary is a plain value transfer or contract interaction.
+	Binary TxType = iota
+	// LoginCandidate registers the sender as an election candidate.
+	LoginCandidate
+	// LogoutCandidate removes the sender from the candidate list.
+	LogoutCandidate
+	// Delegate casts the sender's vote for the candidate in the payload.
+	Delegate
+	// UnDelegate retracts the sender's vote from the candidate in the payload.
+	UnDelegate
+	// MetaVote submits a vote signed off-chain by a delegator; the sender of
+	// the enclosing transaction merely relays it and pays for gas.
+	MetaVote
+	// SetCommission declares the commission rate the sender, a registered
+	// candidate, deducts from delegator rewards.
+	SetCommission
+	// ForceValidators schedules a governance validator override, signed by a
+	// quorum of the governance multisig configured in genesis, replacing the
+	// validator set at the next epoch.
+	ForceValidators
+	// ReportEvidence submits proof of a validator double-signing its slot;
+	// the offender is slashed and the reporting sender rewarded.
+	ReportEvidence
+	// Redelegate atomically moves the sender's vote from one candidate to
+	// another, without the undelegate/delegate round trip.
+	Redelegate
+	// ProxyVoteTx publishes a custodian's aggregate per-candidate vote
+	// allocations plus a merkle root over the individual user preferences.
+	ProxyVoteTx
+	// BlacklistCandidate bars an address from standing for election, signed
+	// by a quorum of the governance multisig configured in genesis.
+	BlacklistCandidate
+	// RotateSigner schedules a new sealing key for the sending candidate,
+	// effective at the next epoch, without touching its votes.
+	RotateSigner
+	// SetCandidateOwners records the owner set of a multisig-controlled
+	// candidate; from then on its management operations require a threshold
+	// of owner signatures instead of the candidate's own key.
+	SetCandidateOwners
+	// SetOperatorGroup declares the operator group of the sending candidate,
+	// so correlated failures across one operator's validators can be
+	// penalized harder than independent ones.
+	SetOperatorGroup
+	// SetRewardRecipient registers the address the sending candidate's reward
+	// payouts are sent to, e.g. a cold wallet or a splitting contract,
+	// distinct from both the candidate address and its sealing key.
+	SetRewardRecipient
+	// VoteBlockInterval records the block interval the sending candidate
+	// prefers; an epoch adopts a new interval when a supermajority of its
+	// elected validators voted for it, within the governance bounds.
+	VoteBlockInterval
+	// ScheduleExit announces the sending candidate's planned departure,
+	// effective at the epoch in the payload: elections from that epoch on
+	// skip the candidate, so the shutdown does not register as a liveness
+	// failure. A zero epoch cancels a pending announcement.
+	ScheduleExit
+	// RewardDistribution is a system transaction paying out the block reward.
+	// It is synthesized by the consensus engine, never submitted by users.
+	RewardDistribution
+	// EpochMaintenance is a system transaction running the epoch transition:
+	// fee pool payout, kickouts, downtime slashing and the election. It is
+	// synthesized by the consensus engine, never submitted by users.
+	EpochMaintenance
+)
+
+// IsSystem reports whether the type is an engine-synthesized system
+// transaction rather than a user operation.
+func (t TxType) IsSystem() bool {
+	return t == RewardDistribution || t == EpochMaintenance
+}
+
+// IsValidatorOp reports whether the type is a validator housekeeping
+// operation — evidence reporting, candidate registration, sealing key
+// rotation and exit scheduling — whose timely inclusion keeps a validator
+// participating in consensus.
+func (t TxType) IsValidatorOp() bool {
+	return t == ReportEvidence || t == LoginCandidate || t == RotateSigner || t == ScheduleExit
+}
+
+// Reserved system addresses a transaction is sent to in order to trigger the
+// corresponding dpos state transition. Transactions to these addresses bypass
+// the EVM and are applied by the state transition directly.
+var (
+	LoginCandidateAddr  = common.HexToAddress("0x0000000000000000000000000000000000000101")
+	LogoutCandidateAddr = common.HexToAddress("0x0000000000000000000000000000000000000102")
+	DelegateAddr        = common.HexToAddress("0x0000000000000000000000000000000000000103")
+	UnDelegateAddr      = common.HexToAddress("0x0000000000000000000000000000000000000104")
+	MetaVoteAddr        = common.HexToAddress("0x0000000000000000000000000000000000000105")
+	SetCommissionAddr   = common.HexToAddress("0x0000000000000000000000000000000000000106")
+	ForceValidatorsAddr = common.HexToAddress("0x0000000000000000000000000000000000000107")
+	ReportEvidenceAddr  = common.HexToAddress("0x0000000000000000000000000000000000000108")
+	RedelegateAddr      = common.HexToAddress("0x0000000000000000000000000000000000000109")
+	ProxyVoteAddr       = common.HexToAddress("0x000000000000000000000000000000000000010a")
+	BlacklistAddr       = common.HexToAddress("0x000000000000000000000000000000000000010b")
+	RotateSignerAddr    = common.HexToAddress("0x000000000000000000000000000000000000010c")
+	CandidateOwnersAddr = common.HexToAddress("0x000000000000000000000000000000000000010d")
+	OperatorGroupAddr   = common.HexToAddress("0x000000000000000000000000000000000000010e")
+	RewardRecipientAddr = common.HexToAddress("0x000000000000000000000000000000000000010f")
+	BlockIntervalAddr   = common.HexToAddress("0x0000000000000000000000000000000000000110")
+	ScheduleExitAddr    = common.HexToAddress("0x0000000000000000000000000000000000000111")
+)
+
+// Reserved system addresses of the maintenance transactions the consensus
+// engine synthesizes at block and epoch boundaries. They live in a separate
+// range from the user-facing dpos operations above.
+var (
+	RewardDistributionAddr = common.HexToAddress("0x0000000000000000000000000000000000000201")
+	EpochMaintenanceAddr   = common.HexToAddress("0x0000000000000000000000000000000000000202")
+)
+
+// FeePoolAddr is the account transaction fees accrue in on networks that pool
+// fees per epoch instead of paying the block proposer directly. The pool is
+// paid out to the epoch's validators at the epoch transition.
+var FeePoolAddr = common.HexToAddress("0x0000000000000000000000000000000000000100")
+
+// txTypeOf derives the dpos transaction type from the recipient address.
+func txTypeOf(to *common.Address) TxType {
+	if to == nil {
+		return Binary
+	}
+	switch *to {
+	case LoginCandidateAddr:
+		return LoginCandidate
+	case LogoutCandidateAddr:
+		return LogoutCandidate
+	case DelegateAddr:
+		return Delegate
+	case UnDelegateAddr:
+		return UnDelegate
+	case MetaVoteAddr:
+		return MetaVote
+	case SetCommissionAddr:
+		return SetCommission
+	case ForceValidatorsAddr:
+		return ForceValidators
+	case ReportEvidenceAddr:
+		return ReportEvidence
+	case RedelegateAddr:
+		return Redelegate
+	case ProxyVoteAddr:
+		return ProxyVoteTx
+	case BlacklistAddr:
+		return BlacklistCandidate
+	case RotateSignerAddr:
+		return RotateSigner
+	case CandidateOwnersAddr:
+		return SetCandidateOwners
+	case OperatorGroupAddr:
+		return SetOperatorGroup
+	case RewardRecipientAddr:
+		return SetRewardRecipient
+	case BlockIntervalAddr:
+		return VoteBlockInterval
+	case ScheduleExitAddr:
+		return ScheduleExit
+	case RewardDistributionAddr:
+		return RewardDistribution
+	case EpochMaintenanceAddr:
+		return EpochMaintenance
+	default:
+		return Binary
+	}
+}
+
+// String implements fmt.Stringer, naming the operation for logs and traces.
+func (t TxType) String() string {
+	switch t {
+	case Binary:
+		return "binary"
+	case LoginCandidate:
+		return "loginCandidate"
+	case LogoutCandidate:
+		return "logoutCandidate"
+	case Delegate:
+		return "delegate"
+	case UnDelegate:
+		return "unDelegate"
+	case MetaVote:
+		return "metaVote"
+	case SetCommission:
+		return "setCommission"
+	case ForceValidators:
+		return "forceValidators"
+	case ReportEvidence:
+		return "reportEvidence"
+	case Redelegate:
+		return "redelegate"
+	case ProxyVoteTx:
+		return "proxyVote"
+	case BlacklistCandidate:
+		return "blacklistCandidate"
+	case RotateSigner:
+		return "rotateSigner"
+	case SetCandidateOwners:
+		return "setCandidateOwners"
+	case SetOperatorGroup:
+		return "setOperatorGroup"
+	case SetRewardRecipient:
+		return "setRewardRecipient"
+	case VoteBlockInterval:
+		return "voteBlockInterval"
+	case ScheduleExit:
+		return "scheduleExit"
+	case RewardDistribution:
+		return "rewardDistribution"
+	case EpochMaintenance:
+		return "epochMaintenance"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(t))
+	}
+}
+
+// Type returns the dpos transaction type of the transaction.
+func (tx *Transaction) Type() TxType {
+	return txTypeOf(tx.data.Recipient)
+}
+
+// Type returns the dpos transaction type of the message.
+func (m Message) Type() TxType {
+	return txTypeOf(m.to)
+}
+
+// NewLoginCandidateTransaction creates a transaction registering the sender
+// as an election candidate.
+func NewLoginCandidateTransaction(nonce uint64, gasLimit uint64, gasPrice *big.Int) *Transaction {
+	return NewTransaction(nonce, LoginCandidateAddr, big.NewInt(0), gasLimit, gasPrice, nil)
+}
+
+// NewLogoutCandidateTransaction creates a transaction removing the sender
+// from the candidate list.
+func NewLogoutCandidateTransaction(nonce uint64, gasLimit uint64, gasPrice *big.Int) *Transaction {
+	return NewTransaction(nonce, LogoutCandidateAddr, big.NewInt(0), gasLimit, gasPrice, nil)
+}
+
+// NewDelegateTransaction creates a transaction casting the sender's vote for
+// the given candidate.
+func NewDelegateTransaction(nonce uint64, candidate common.Address, gasLimit uint64, gasPrice *big.Int) *Transaction {
+	return NewTransaction(nonce, DelegateAddr, big.NewInt(0), gasLimit, gasPrice, candidate.Bytes())
+}
+
+// NewCompoundDelegateTransaction creates a delegate transaction that also
+// opts the sender's delegation into reward auto-compounding: reward cuts
+// accrue as bonded vote weight instead of spendable balance.
+func NewCompoundDelegateTransaction(nonce uint64, candidate common.Address, gasLimit uint64, gasPrice *big.Int) *Transaction {
+	return NewTransaction(nonce, DelegateAddr, big.NewInt(0), gasLimit, gasPrice, append(candidate.Bytes(), 1))
+}
+
+// NewUnDelegateTransaction creates a transaction retracting the sender's vote
+// from the given candidate.
+func NewUnDelegateTransaction(nonce uint64, candidate common.Address, gasLimit uint64, gasPrice *big.Int) *Transaction {
+	return NewTransaction(nonce, UnDelegateAddr, big.NewInt(0), gasLimit, gasPrice, candidate.Bytes())
+}
+
+// NewMetaVoteTransaction creates a relay transaction carrying a vote signed
+// off-chain by a delegator. The relayer's account pays for gas, the vote is
+// attributed to the delegator that signed it.
+func NewMetaVoteTransaction(nonce uint64, vote *MetaVoteMsg, gasLimit uint64, gasPrice *big.Int) (*Transaction, error) {
+	payload, err := rlp.EncodeToBytes(vote)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransaction(nonce, MetaVoteAddr, big.NewInt(0), gasLimit, gasPrice, payload), nil
+}
+
+// NewForceValidatorsTransaction creates a transaction carrying a governance
+// validator override signed by a quorum of the governance multisig.
+func NewForceValidatorsTransaction(nonce uint64, override *ValidatorOverride, gasLimit uint64, gasPrice *big.Int) (*Transaction, error) {
+	payload, err := rlp.EncodeToBytes(override)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransaction(nonce, ForceValidatorsAddr, big.NewInt(0), gasLimit, gasPrice, payload), nil
+}
+
+// NewRedelegateTransaction creates a transaction moving the sender's vote
+// from one candidate to another in a single atomic operation.
+func NewRedelegateTransaction(nonce uint64, from, to common.Address, gasLimit uint64, gasPrice *big.Int) *Transaction {
+	return NewTransaction(nonce, RedelegateAddr, big.NewInt(0), gasLimit, gasPrice, append(from.Bytes(), to.Bytes()...))
+}
+
+// NewReportEvidenceTransaction creates a transaction submitting double-sign
+// evidence. The sender collects the reporter reward if the evidence holds.
+func NewReportEvidenceTransaction(nonce uint64, evidence *HeaderEvidence, gasLimit uint64, gasPrice *big.Int) (*Transaction, error) {
+	payload, err := rlp.EncodeToBytes(evidence)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransaction(nonce, ReportEvidenceAddr, big.NewInt(0), gasLimit, gasPrice, payload), nil
+}
+
+// NewProxyVoteTransaction creates a transaction publishing the sender's
+// custodial vote allocations, replacing any previous ones.
+func NewProxyVoteTransaction(nonce uint64, vote *ProxyVote, gasLimit uint64, gasPrice *big.Int) (*Transaction, error) {
+	payload, err := rlp.EncodeToBytes(vote)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransaction(nonce, ProxyVoteAddr, big.NewInt(0), gasLimit, gasPrice, payload), nil
+}
+
+// NewRotateSignerTransaction creates a transaction scheduling a new sealing
+// key for the sending candidate, effective at the next epoch.
+func NewRotateSignerTransaction(nonce uint64, signer common.Address, gasLimit uint64, gasPrice *big.Int) *Transaction {
+	return NewTransaction(nonce, RotateSignerAddr, big.NewInt(0), gasLimit, gasPrice, signer.Bytes())
+}
+
+// NewSetCandidateOwnersTransaction creates a transaction recording the owner
+// set controlling the sending candidate's management operations. Candidates
+// already under multisig control wrap the owner set in a signed
+// CandidateAuthorization instead.
+func NewSetCandidateOwnersTransaction(nonce uint64, owners *CandidateOwnerSet, gasLimit uint64, gasPrice *big.Int) (*Transaction, error) {
+	payload, err := rlp.EncodeToBytes(owners)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransaction(nonce, CandidateOwnersAddr, big.NewInt(0), gasLimit, gasPrice, payload), nil
+}
+
+// NewSetOperatorGroupTransaction creates a transaction declaring the operator
+// group of the sending candidate. The zero group clears the declaration.
+func NewSetOperatorGroupTransaction(nonce uint64, group common.Hash, gasLimit uint64, gasPrice *big.Int) *Transaction {
+	return NewTransaction(nonce, OperatorGroupAddr, big.NewInt(0), gasLimit, gasPrice, group.Bytes())
+}
+
+// NewSetRewardRecipientTransaction creates a transaction registering the
+// address the sending candidate's reward payouts are sent to. The zero
+// recipient clears the registration, paying the candidate directly again.
+func NewSetRewardRecipientTransaction(nonce uint64, recipient common.Address, gasLimit uint64, gasPrice *big.Int) *Transaction {
+	return NewTransaction(nonce, RewardRecipientAddr, big.NewInt(0), gasLimit, gasPrice, recipient.Bytes())
+}
+
+// NewVoteBlockIntervalTransaction creates a transaction recording the block
+// interval, in seconds, the sending candidate prefers the network to run at.
+func NewVoteBlockIntervalTransaction(nonce uint64, interval uint64, gasLimit uint64, gasPrice *big.Int) *Transaction {
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, interval)
+	return NewTransaction(nonce, BlockIntervalAddr, big.NewInt(0), gasLimit, gasPrice, payload)
+}
+
+// NewScheduleExitTransaction creates a transaction announcing the sending
+// candidate's planned departure, effective at the given epoch. A zero epoch
+// cancels a previously scheduled exit.
+func NewScheduleExitTransaction(nonce uint64, epoch uint64, gasLimit uint64, gasPrice *big.Int) *Transaction {
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, epoch)
+	return NewTransaction(nonce, ScheduleExitAddr, big.NewInt(0), gasLimit, gasPrice, payload)
+}
+
+// NewSystemTransaction creates an engine-synthesized maintenance transaction
+// for the block at the given number. System transactions are unsigned, carry
+// no value and a zero gas price; the block number doubles as the nonce so
+// every block's system transactions hash uniquely.
+func NewSystemTransaction(to common.Address, number uint64) *Transaction {
+	return NewTransaction(number, to, big.NewInt(0), 0, big.NewInt(0), nil)
+}
+
+// NewBlacklistTransaction creates a transaction carrying a governance
+// blacklist entry signed by a quorum of the governance multisig.
+func NewBlacklistTransaction(nonce uint64, blacklist *Blacklist, gasLimit uint64, gasPrice *big.Int) (*Transaction, error) {
+	payload, err := rlp.EncodeToBytes(blacklist)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransaction(nonce, BlacklistAddr, big.NewInt(0), gasLimit, gasPrice, payload), nil
+}
+
+// NewSetCommissionTransaction creates a transaction declaring the commission
+// rate, in percent, the sender deducts from delegator rewards.
+func NewSetCommissionTransaction(nonce uint64, rate uint64, gasLimit uint64, gasPrice *big.Int) *Transaction {
+	return NewTransaction(nonce, SetCommissionAddr, big.NewInt(0), gasLimit, gasPrice, []byte{byte(rate)})
+}
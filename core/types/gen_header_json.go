@@ -30,6 +30,8 @@ func (h Header) MarshalJSON() ([]byte, error) {
 		Extra       hexutil.Bytes  `json:"extraData"        gencodec:"required"`
 		MixDigest   common.Hash    `json:"mixHash"          gencodec:"required"`
 		Nonce       BlockNonce     `json:"nonce"            gencodec:"required"`
+		Validator   common.Address `json:"validator"`
+		DposContext *DposContextProto `json:"dposContext"      rlp:"nil"`
 		Hash        common.Hash    `json:"hash"`
 	}
 	var enc Header
@@ -48,6 +50,8 @@ func (h Header) MarshalJSON() ([]byte, error) {
 	enc.Extra = h.Extra
 	enc.MixDigest = h.MixDigest
 	enc.Nonce = h.Nonce
+	enc.Validator = h.Validator
+	enc.DposContext = h.DposContext
 	enc.Hash = h.Hash()
 	return json.Marshal(&enc)
 }
@@ -69,6 +73,8 @@ func (h *Header) UnmarshalJSON(input []byte) error {
 		Extra       *hexutil.Bytes  `json:"extraData"        gencodec:"required"`
 		MixDigest   *common.Hash    `json:"mixHash"          gencodec:"required"`
 		Nonce       *BlockNonce     `json:"nonce"            gencodec:"required"`
+		Validator   *common.Address `json:"validator"`
+		DposContext *DposContextProto `json:"dposContext"      rlp:"nil"`
 	}
 	var dec Header
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -134,5 +140,11 @@ func (h *Header) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 'nonce' for Header")
 	}
 	h.Nonce = *dec.Nonce
+	if dec.Validator != nil {
+		h.Validator = *dec.Validator
+	}
+	if dec.DposContext != nil {
+		h.DposContext = dec.DposContext
+	}
 	return nil
 }
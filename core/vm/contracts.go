@@ -17,6 +17,7 @@
 package vm
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"errors"
 	"math/big"
@@ -57,6 +58,8 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{6}): &bn256Add{},
 	common.BytesToAddress([]byte{7}): &bn256ScalarMul{},
 	common.BytesToAddress([]byte{8}): &bn256Pairing{},
+	common.BytesToAddress([]byte{9}):  &merkleVerify{},
+	common.BytesToAddress([]byte{10}): &voteWeightRegistry{},
 }
 
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
@@ -358,3 +361,118 @@ func (c *bn256Pairing) Run(input []byte) ([]byte, error) {
 	}
 	return false32Byte, nil
 }
+
+// errBadMerkleInput is returned if the merkle proof input is malformed.
+var errBadMerkleInput = errors.New("bad merkle proof size")
+
+// merkleVerify implements keccak256 merkle proof verification as a native
+// contract, backing the audit trail of custodial proxy votes. The input is
+// the root followed by the leaf followed by the proof nodes, each 32 bytes;
+// inner nodes hash their children as a sorted pair. Returns 32 bytes holding
+// one if the proof links the leaf to the root, zero otherwise.
+type merkleVerify struct{}
+
+func (c *merkleVerify) RequiredGas(input []byte) uint64 {
+	return params.MerkleVerifyBaseGas + uint64(len(input)/32)*params.MerkleVerifyPerNodeGas
+}
+
+func (c *merkleVerify) Run(input []byte) ([]byte, error) {
+	if len(input) < 64 || len(input)%32 != 0 {
+		return nil, errBadMerkleInput
+	}
+	root, node := input[:32], input[32:64]
+	for i := 64; i < len(input); i += 32 {
+		sibling := input[i : i+32]
+		if bytes.Compare(node, sibling) <= 0 {
+			node = crypto.Keccak256(node, sibling)
+		} else {
+			node = crypto.Keccak256(sibling, node)
+		}
+	}
+	if bytes.Equal(node, root) {
+		return true32Byte, nil
+	}
+	return false32Byte, nil
+}
+
+// VoteWeightRegistryAddr is the address of the vote weight hook registry
+// precompile. Hook callbacks arrive with this address as their caller, so
+// subscribed contracts can authenticate notifications.
+var VoteWeightRegistryAddr = common.BytesToAddress([]byte{10})
+
+// VoteWeightHookSelector is the call selector of the callback delivered to
+// subscribed contracts: onVoteWeightChanged(address account, address
+// candidate, uint8 kind).
+var VoteWeightHookSelector = crypto.Keccak256([]byte("onVoteWeightChanged(address,address,uint8)"))[:4]
+
+// maxWeightHookSubscribers caps the registry so the callbacks fired by one
+// staking operation stay bounded.
+const maxWeightHookSubscribers = 16
+
+// Registry errors surfaced to calling contracts.
+var (
+	errBadRegistryInput  = errors.New("bad vote weight registry input")
+	errRegistryFull      = errors.New("vote weight registry full")
+	errAlreadySubscribed = errors.New("already subscribed to vote weight hooks")
+	errNotSubscribed     = errors.New("not subscribed to vote weight hooks")
+	errNoRegistryContext = errors.New("vote weight registry needs dpos context access")
+)
+
+// voteWeightRegistry is the opt-in registry of vote weight hook subscribers,
+// implemented as a native contract. A contract calls it with a single opcode
+// byte — zero to subscribe itself, one to unsubscribe — and from then on
+// receives an onVoteWeightChanged callback whenever a staking operation
+// changes an account's effective vote weight, letting liquid staking style
+// derivative tokens stay consistent with the delegations backing them. The
+// registry lives in the candidate trie, so subscriptions are part of the
+// consensus state like any other dpos entry.
+type voteWeightRegistry struct{}
+
+func (c *voteWeightRegistry) RequiredGas(input []byte) uint64 {
+	return params.WeightHookRegisterGas
+}
+
+// Run implements PrecompiledContract for completeness; the registry needs
+// the consensus tries next to its input and is dispatched through RunContext.
+func (c *voteWeightRegistry) Run(input []byte) ([]byte, error) {
+	return nil, errNoRegistryContext
+}
+
+// RunContext handles a subscription update with access to the dpos context
+// of the executing block.
+func (c *voteWeightRegistry) RunContext(evm *EVM, caller common.Address, input []byte) ([]byte, error) {
+	if evm.DposContext == nil {
+		return nil, errNoRegistryContext
+	}
+	if len(input) != 1 {
+		return nil, errBadRegistryInput
+	}
+	subscribers := evm.DposContext.WeightHookSubscribers()
+	switch input[0] {
+	case 0:
+		for _, subscriber := range subscribers {
+			if subscriber == caller {
+				return nil, errAlreadySubscribed
+			}
+		}
+		if len(subscribers) >= maxWeightHookSubscribers {
+			return nil, errRegistryFull
+		}
+		subscribers = append(subscribers, caller)
+	case 1:
+		found := false
+		for i, subscriber := range subscribers {
+			if subscriber == caller {
+				subscribers = append(subscribers[:i], subscribers[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errNotSubscribed
+		}
+	default:
+		return nil, errBadRegistryInput
+	}
+	return nil, evm.DposContext.SetWeightHookSubscribers(subscribers)
+}
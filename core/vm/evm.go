@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -48,6 +49,14 @@ func run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
 			precompiles = PrecompiledContractsByzantium
 		}
 		if p := precompiles[*contract.CodeAddr]; p != nil {
+			// The vote weight registry needs the dpos context next to its
+			// input, so it bypasses the stateless precompile runner
+			if registry, ok := p.(*voteWeightRegistry); ok {
+				if !contract.UseGas(registry.RequiredGas(input)) {
+					return nil, ErrOutOfGas
+				}
+				return registry.RunContext(evm, contract.Caller(), input)
+			}
 			return RunPrecompiledContract(p, input, contract)
 		}
 	}
@@ -75,6 +84,10 @@ type Context struct {
 	BlockNumber *big.Int       // Provides information for NUMBER
 	Time        *big.Int       // Provides information for TIME
 	Difficulty  *big.Int       // Provides information for DIFFICULTY
+
+	// DposContext gives dpos transactions access to the consensus tries of
+	// the block being processed. It is nil outside of block processing.
+	DposContext *types.DposContext
 }
 
 // EVM is the Ethereum Virtual Machine base object and provides
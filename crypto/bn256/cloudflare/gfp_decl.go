@@ -5,6 +5,11 @@ package bn256
 // This file contains forward declarations for the architecture-specific
 // assembly implementations of these functions, provided that they exist.
 
+// hasBMI2 gates the MULX based multiplication path in the amd64 assembly.
+// Feature detection used to lean on a runtime internal that newer Go
+// releases removed; stay on the portable path unconditionally.
+var hasBMI2 = false
+
 // go:noescape
 func gfpNeg(c, a *gfP)
 
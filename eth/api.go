@@ -306,29 +306,51 @@ func NewPublicDebugAPI(eth *Ethereum) *PublicDebugAPI {
 	return &PublicDebugAPI{eth: eth}
 }
 
-// DumpBlock retrieves the entire state of the database at a given block.
-func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error) {
+// BlockDump couples the world state dump of a block with the dpos consensus
+// state committed next to it, which lives outside the world state trie.
+type BlockDump struct {
+	state.Dump
+	DposContext *types.DposContextDump `json:"dposContext,omitempty"`
+}
+
+// DumpBlock retrieves the entire state of the database at a given block. On
+// dpos chains withDposContext additionally includes the full dpos context —
+// candidates, delegations, votes, validators and mint counters — in the dump.
+func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber, withDposContext *bool) (BlockDump, error) {
+	var (
+		block   *types.Block
+		stateDb *state.StateDB
+		err     error
+	)
 	if blockNr == rpc.PendingBlockNumber {
 		// If we're dumping the pending state, we need to request
 		// both the pending block as well as the pending state from
 		// the miner and operate on those
-		_, stateDb := api.eth.miner.Pending()
-		return stateDb.RawDump(), nil
-	}
-	var block *types.Block
-	if blockNr == rpc.LatestBlockNumber {
-		block = api.eth.blockchain.CurrentBlock()
+		block, stateDb = api.eth.miner.Pending()
 	} else {
-		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
-	}
-	if block == nil {
-		return state.Dump{}, fmt.Errorf("block #%d not found", blockNr)
+		if blockNr == rpc.LatestBlockNumber {
+			block = api.eth.blockchain.CurrentBlock()
+		} else {
+			block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
+		}
+		if block == nil {
+			return BlockDump{}, fmt.Errorf("block #%d not found", blockNr)
+		}
+		if stateDb, err = api.eth.BlockChain().StateAt(block.Root()); err != nil {
+			return BlockDump{}, err
+		}
 	}
-	stateDb, err := api.eth.BlockChain().StateAt(block.Root())
-	if err != nil {
-		return state.Dump{}, err
+	dump := BlockDump{Dump: stateDb.RawDump()}
+	if withDposContext != nil && *withDposContext && block.Header().DposContext != nil {
+		dposContext, err := types.NewDposContextFromProto(trie.NewDatabase(api.eth.chainDb), block.Header().DposContext)
+		if err != nil {
+			return BlockDump{}, err
+		}
+		if dump.DposContext, err = dposContext.RawDump(); err != nil {
+			return BlockDump{}, err
+		}
 	}
-	return stateDb.RawDump(), nil
+	return dump, nil
 }
 
 // PrivateDebugAPI is the collection of Ethereum full node APIs exposed over
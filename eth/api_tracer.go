@@ -86,8 +86,9 @@ type blockTraceResult struct {
 // txTraceTask represents a single transaction trace task when an entire block
 // is being traced.
 type txTraceTask struct {
-	statedb *state.StateDB // Intermediate state prepped for tracing
-	index   int            // Transaction offset in the block
+	statedb     *state.StateDB     // Intermediate state prepped for tracing
+	dposContext *types.DposContext // Intermediate dpos context, nil outside dpos chains
+	index       int                // Transaction offset in the block
 }
 
 // TraceChain returns the structured logs created during the execution of EVM
@@ -406,6 +407,13 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 	if err != nil {
 		return nil, err
 	}
+	// On dpos chains the transactions may also mutate the consensus tries
+	var dposContext *types.DposContext
+	if api.config.Dpos != nil {
+		if dposContext, err = types.NewDposContextFromProto(trie.NewDatabase(api.eth.chainDb), parent.Header().DposContext); err != nil {
+			return nil, err
+		}
+	}
 	// Execute all the transaction contained within the block concurrently
 	var (
 		signer = types.MakeSigner(api.config, block.Number())
@@ -429,6 +437,7 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 			for task := range jobs {
 				msg, _ := txs[task.index].AsMessage(signer)
 				vmctx := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
+				vmctx.DposContext = task.dposContext
 
 				res, err := api.traceTx(ctx, msg, vmctx, task.statedb, config)
 				if err != nil {
@@ -443,11 +452,16 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 	var failed error
 	for i, tx := range txs {
 		// Send the trace task over for execution
-		jobs <- &txTraceTask{statedb: statedb.Copy(), index: i}
+		task := &txTraceTask{statedb: statedb.Copy(), index: i}
+		if dposContext != nil {
+			task.dposContext = dposContext.Copy()
+		}
+		jobs <- task
 
 		// Generate the next state snapshot fast without tracing
 		msg, _ := tx.AsMessage(signer)
 		vmctx := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
+		vmctx.DposContext = dposContext
 
 		vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{})
 		if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
@@ -558,6 +572,12 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, hash common.Ha
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
 func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, vmctx vm.Context, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
+	// Dpos transactions bypass the EVM and mutate the consensus tries
+	// directly, so EVM tracers have nothing to hook into. Report the trie
+	// mutations the operation performs instead.
+	if message.Type() != types.Binary {
+		return api.traceDposTx(message, vmctx, statedb)
+	}
 	// Assemble the structured logger or the JavaScript tracer
 	var (
 		tracer vm.Tracer
@@ -615,6 +635,123 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 	}
 }
 
+// dposTraceEntry describes one consensus trie key a dpos operation touches.
+type dposTraceEntry struct {
+	Trie string        `json:"trie"` // Touched trie: "candidate", "delegate" or "vote"
+	Key  hexutil.Bytes `json:"key"`  // Trie key being updated or deleted
+	Op   string        `json:"op"`   // "update" or "delete"
+}
+
+// dposTraceResult is the trace of a transaction that bypassed the EVM and
+// mutated the dpos context of its block instead.
+type dposTraceResult struct {
+	Gas     uint64           `json:"gas"`
+	Failed  bool             `json:"failed"`
+	Type    string           `json:"type"`
+	Entries []dposTraceEntry `json:"entries"`
+}
+
+// traceDposTx executes a dpos transaction and reports the consensus trie keys
+// it touches, giving visibility into why the dpos context root changed.
+func (api *PrivateDebugAPI) traceDposTx(message core.Message, vmctx vm.Context, statedb *state.StateDB) (interface{}, error) {
+	if vmctx.DposContext == nil {
+		return nil, errors.New("dpos transactions cannot be traced without a dpos context")
+	}
+	// Derive the touched keys from the pre-transaction context, which still
+	// knows about votes the operation is going to move or remove
+	entries := api.dposTraceEntries(message, vmctx.DposContext)
+
+	vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{})
+	_, gas, failed, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	if failed {
+		// A failed operation is reverted and touches nothing
+		entries = nil
+	}
+	return &dposTraceResult{
+		Gas:     gas,
+		Failed:  failed,
+		Type:    message.Type().String(),
+		Entries: entries,
+	}, nil
+}
+
+// dposTraceEntries derives the trie keys the given dpos operation touches
+// from the pre-transaction dpos context.
+func (api *PrivateDebugAPI) dposTraceEntries(message core.Message, dposContext *types.DposContext) []dposTraceEntry {
+	var (
+		entries []dposTraceEntry
+		from    = message.From()
+	)
+	switch message.Type() {
+	case types.LoginCandidate:
+		entries = append(entries,
+			dposTraceEntry{"candidate", types.KickoutCooldownKey(from), "delete"},
+			dposTraceEntry{"candidate", types.CandidateKey(from), "update"})
+
+	case types.LogoutCandidate:
+		entries = append(entries, dposTraceEntry{"candidate", types.CandidateKey(from), "delete"})
+		// Removing a candidate also clears all delegations pointing at it
+		prefix := from.Bytes()
+		iter := trie.NewIterator(dposContext.DelegateTrie().NodeIterator(prefix))
+		for iter.Next() {
+			if !bytes.HasPrefix(iter.Key, prefix) {
+				break
+			}
+			delegator := common.BytesToAddress(iter.Value)
+			entries = append(entries,
+				dposTraceEntry{"delegate", types.DelegateKey(from, delegator), "delete"},
+				dposTraceEntry{"vote", types.VoteKey(delegator), "delete"})
+		}
+
+	case types.Delegate:
+		if len(message.Data()) != common.AddressLength {
+			break
+		}
+		candidate := common.BytesToAddress(message.Data())
+		if old, err := dposContext.VoteTrie().TryGet(from.Bytes()); err == nil && old != nil {
+			entries = append(entries, dposTraceEntry{"delegate", types.DelegateKey(common.BytesToAddress(old), from), "delete"})
+		}
+		entries = append(entries,
+			dposTraceEntry{"delegate", types.DelegateKey(candidate, from), "update"},
+			dposTraceEntry{"vote", types.VoteKey(from), "update"})
+
+	case types.UnDelegate:
+		if len(message.Data()) != common.AddressLength {
+			break
+		}
+		candidate := common.BytesToAddress(message.Data())
+		entries = append(entries,
+			dposTraceEntry{"delegate", types.DelegateKey(candidate, from), "delete"},
+			dposTraceEntry{"vote", types.VoteKey(from), "delete"})
+
+	case types.MetaVote:
+		// The delegation is attributed to the off-chain signer of the vote,
+		// not to the relaying sender
+		vote := new(types.MetaVoteMsg)
+		if err := rlp.DecodeBytes(message.Data(), vote); err != nil {
+			break
+		}
+		delegator, err := vote.Recover(api.config.ChainID)
+		if err != nil {
+			break
+		}
+		if old, err := dposContext.VoteTrie().TryGet(delegator.Bytes()); err == nil && old != nil {
+			entries = append(entries, dposTraceEntry{"delegate", types.DelegateKey(common.BytesToAddress(old), delegator), "delete"})
+		}
+		entries = append(entries,
+			dposTraceEntry{"delegate", types.DelegateKey(vote.Candidate, delegator), "update"},
+			dposTraceEntry{"vote", types.VoteKey(delegator), "update"},
+			dposTraceEntry{"vote", types.VoteNonceKey(delegator), "update"})
+
+	case types.SetCommission:
+		entries = append(entries, dposTraceEntry{"candidate", types.CommissionKey(from), "update"})
+	}
+	return entries
+}
+
 // computeTxEnv returns the execution environment of a certain transaction.
 func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, reexec uint64) (core.Message, vm.Context, *state.StateDB, error) {
 	// Create the parent state database
@@ -630,6 +767,13 @@ func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, ree
 	if err != nil {
 		return nil, vm.Context{}, nil, err
 	}
+	// On dpos chains the transactions may also mutate the consensus tries
+	var dposContext *types.DposContext
+	if api.config.Dpos != nil {
+		if dposContext, err = types.NewDposContextFromProto(trie.NewDatabase(api.eth.chainDb), parent.Header().DposContext); err != nil {
+			return nil, vm.Context{}, nil, err
+		}
+	}
 	// Recompute transactions up to the target index.
 	signer := types.MakeSigner(api.config, block.Number())
 
@@ -637,6 +781,7 @@ func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, ree
 		// Assemble the transaction call message and return if the requested offset
 		msg, _ := tx.AsMessage(signer)
 		context := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
+		context.DposContext = dposContext
 		if idx == txIndex {
 			return msg, context, statedb, nil
 		}
@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/consensus/dpos"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
@@ -144,7 +145,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	}
 	var (
 		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, DposArchive: config.DposArchive}
 	)
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, eth.chainConfig, eth.engine, vmConfig)
 	if err != nil {
@@ -158,6 +159,37 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
 
+	// Let the dpos RPC API resolve state roots to balances for vote tallies
+	if dposEngine, ok := eth.engine.(*dpos.Dpos); ok {
+		dposEngine.SetStateFn(eth.blockchain.StateAt)
+		dposEngine.SetStandby(config.DposStandby)
+		dposEngine.SetReplica(config.DposReplica)
+		dposEngine.SetGasPriceVote(config.GasPrice)
+		dposEngine.SetEmptyBlockSuppression(config.DposHeartbeatSlots)
+		// A read replica serves analytics traffic straight off the dpos
+		// tries, so give the node cache a generous default unless the
+		// operator tuned it explicitly
+		dposCache := config.DposCache
+		if config.DposReplica && dposCache == 0 {
+			dposCache = replicaDposCache
+		}
+		dposEngine.SetNodeCacheSize(dposCache)
+		dposEngine.SetForkMonitor(config.DposForkWatch)
+		if config.DposFaultInject != "" {
+			if err := dposEngine.SetFaultInjection(config.DposFaultInject); err != nil {
+				return nil, err
+			}
+		}
+		// Replicas answer delegation queries from the index; seed it during
+		// startup instead of waiting for an operator-run offline backfill.
+		// Live imports keep it at the head from here on.
+		if config.DposReplica && rawdb.ReadDelegationIndexHead(chainDb) != eth.blockchain.CurrentBlock().Hash() {
+			if err := eth.blockchain.BackfillDelegationIndex(0); err != nil {
+				log.Warn("Delegation index backfill failed", "err", err)
+			}
+		}
+	}
+
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
@@ -166,6 +198,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
+	if _, ok := eth.engine.(*dpos.Dpos); ok {
+		eth.protocolManager.validatorSlots = config.DposValidatorSlots
+	}
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine)
 	eth.miner.SetExtra(makeExtraData(config.ExtraData))
 
@@ -210,6 +245,16 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Data
 
 // CreateConsensusEngine creates the required type of consensus engine instance for an Ethereum service
 func CreateConsensusEngine(ctx *node.ServiceContext, config *ethash.Config, chainConfig *params.ChainConfig, db ethdb.Database) consensus.Engine {
+	// If delegated proof-of-stake is requested, set it up
+	if chainConfig.Dpos != nil {
+		engine := dpos.New(chainConfig.Dpos, db)
+		// A chain migrating from proof-of-authority keeps the clique engine
+		// around for the blocks below the fork
+		if chainConfig.Clique != nil && chainConfig.Dpos.ForkBlock > 0 {
+			engine.SetLegacyEngine(clique.New(chainConfig.Clique, db), chainConfig.Dpos.ForkBlock)
+		}
+		return engine
+	}
 	// If proof-of-authority is requested, set it up
 	if chainConfig.Clique != nil {
 		return clique.New(chainConfig.Clique, db)
@@ -333,6 +378,10 @@ func (s *Ethereum) SetEtherbase(etherbase common.Address) {
 }
 
 func (s *Ethereum) StartMining(local bool) error {
+	if s.config.DposReplica {
+		log.Error("Cannot start mining on a dpos read replica")
+		return fmt.Errorf("node runs as a dpos read replica")
+	}
 	eb, err := s.Etherbase()
 	if err != nil {
 		log.Error("Cannot start mining without etherbase", "err", err)
@@ -346,6 +395,19 @@ func (s *Ethereum) StartMining(local bool) error {
 		}
 		clique.Authorize(eb, wallet.SignHash)
 	}
+	if dpos, ok := s.engine.(*dpos.Dpos); ok {
+		wallet, err := s.accountManager.Find(accounts.Account{Address: eb})
+		if wallet == nil || err != nil {
+			log.Error("Etherbase account unavailable locally", "err", err)
+			return fmt.Errorf("signer missing: %v", err)
+		}
+		dpos.Authorize(eb, wallet.SignHash)
+		// On a migrated chain the clique engine still seals the blocks
+		// below the fork, so authorize it alongside
+		if legacy, ok := dpos.LegacyEngine().(*clique.Clique); ok {
+			legacy.Authorize(eb, wallet.SignHash)
+		}
+	}
 	if local {
 		// If local (CPU) mining is started, we can disable the transaction rejection
 		// mechanism introduced to speed sync times. CPU mining on mainnet is ludicrous
@@ -399,6 +461,7 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 		maxPeers -= s.config.LightPeers
 	}
 	// Start the networking layer and the light server if requested
+	s.protocolManager.selfID = srvr.Self().ID
 	s.protocolManager.Start(maxPeers)
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
@@ -409,6 +472,11 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 // Stop implements node.Service, terminating all internal goroutines used by the
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
+	// Give a sealing validator the chance to cover its imminent slot before
+	// the teardown, so routine restarts don't register as missed blocks
+	if engine, ok := s.engine.(*dpos.Dpos); ok && s.config.DposShutdownGrace > 0 && s.miner.Mining() {
+		engine.AwaitSlotIdle(s.blockchain, s.config.DposShutdownGrace)
+	}
 	s.bloomIndexer.Close()
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
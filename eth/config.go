@@ -33,6 +33,10 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 )
 
+// replicaDposCache is the dpos trie node cache size in megabytes a read
+// replica runs with when the operator did not tune the cache explicitly.
+const replicaDposCache = 512
+
 // DefaultConfig contains default settings for use on the Ethereum main net.
 var DefaultConfig = Config{
 	SyncMode: downloader.FastSync,
@@ -83,6 +87,11 @@ type Config struct {
 	SyncMode  downloader.SyncMode
 	NoPruning bool
 
+	// DposArchive retains every historical version of the dpos consensus
+	// tries even when state pruning is on, so dpos_* queries work at any
+	// block. Implied by NoPruning.
+	DposArchive bool `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
@@ -112,6 +121,45 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// DposStandby makes the node seal as a hot-standby, yielding to a live
+	// primary sharing the validator key.
+	DposStandby bool `toml:",omitempty"`
+
+	// DposHeartbeatSlots makes the validator skip sealing when there are no
+	// user transactions, producing a heartbeat block every given number of
+	// slots instead. Zero disables the suppression.
+	DposHeartbeatSlots uint64 `toml:",omitempty"`
+
+	// DposCache is the memory budget in megabytes of the dedicated dpos trie
+	// node cache. Zero disables the cache.
+	DposCache int `toml:",omitempty"`
+
+	// DposForkWatch enables the malicious-fork monitor, alerting when a
+	// validator double-signs its slot or a non-canonical fork grows to the
+	// given number of blocks. Zero disables the monitor.
+	DposForkWatch uint64 `toml:",omitempty"`
+
+	// DposFaultInject arms the testing-only failure injector with the given
+	// spec, making the validator skip slots, seal late or double-sign on
+	// purpose. Strictly for private integration test networks.
+	DposFaultInject string `toml:",omitempty"`
+
+	// DposReplica runs the node as a read replica tuned for RPC providers:
+	// sealing is disabled, the dpos trie node cache gets a generous default
+	// and dump queries page with their maximum size.
+	DposReplica bool `toml:",omitempty"`
+
+	// DposValidatorSlots reserves the given number of peer connection slots
+	// for sessions proven to belong to active validators, keeping the
+	// validator mesh connected when the node sits at maxpeers with leechers.
+	DposValidatorSlots int `toml:",omitempty"`
+
+	// DposShutdownGrace holds a shutdown back until the local validator has
+	// sealed its imminent slot or the slot passed, for at most this duration,
+	// so routine restarts don't show up as missed blocks. Zero tears down
+	// immediately.
+	DposShutdownGrace time.Duration `toml:",omitempty"`
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 }
@@ -1370,10 +1370,10 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 	// Start syncing state of the reported head block. This should get us most of
 	// the state of the pivot block.
-	stateSync := d.syncState(latest.Root)
-	defer stateSync.Cancel()
+	sync := d.syncState(latest.Root)
+	defer sync.Cancel()
 	go func() {
-		if err := stateSync.Wait(); err != nil && err != errCancelStateFetch {
+		if err := sync.Wait(); err != nil && err != errCancelStateFetch {
 			d.queue.Close() // wake up WaitResults
 		}
 	}()
@@ -1386,8 +1386,9 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 	// To cater for moving pivot points, track the pivot block and subsequently
 	// accumulated download results separately.
 	var (
-		oldPivot *fetchResult   // Locked in pivot block, might change eventually
-		oldTail  []*fetchResult // Downloaded content after the pivot
+		oldPivot     *fetchResult   // Locked in pivot block, might change eventually
+		oldTail      []*fetchResult // Downloaded content after the pivot
+		dposBackfill *stateSync     // Background retrieval of the pivot's delegation tries
 	)
 	for {
 		// Wait for the next batch of downloaded data to be available, and if the pivot
@@ -1396,12 +1397,19 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 		if len(results) == 0 {
 			// If pivot sync is done, stop
 			if oldPivot == nil {
-				return stateSync.Cancel()
+				// Don't finish the sync with the delegation tries still in
+				// flight, a later full block may touch them
+				if dposBackfill != nil {
+					if err := dposBackfill.Wait(); err != nil {
+						return err
+					}
+				}
+				return sync.Cancel()
 			}
 			// If sync failed, stop
 			select {
 			case <-d.cancelCh:
-				return stateSync.Cancel()
+				return sync.Cancel()
 			default:
 			}
 		}
@@ -1420,18 +1428,18 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 			}
 		}
 		P, beforeP, afterP := splitAroundPivot(pivot, results)
-		if err := d.commitFastSyncData(beforeP, stateSync); err != nil {
+		if err := d.commitFastSyncData(beforeP, sync); err != nil {
 			return err
 		}
 		if P != nil {
 			// If new pivot block found, cancel old state retrieval and restart
 			if oldPivot != P {
-				stateSync.Cancel()
+				sync.Cancel()
 
-				stateSync = d.syncState(P.Header.Root)
-				defer stateSync.Cancel()
+				sync = d.syncState(P.Header.Root)
+				defer sync.Cancel()
 				go func() {
-					if err := stateSync.Wait(); err != nil && err != errCancelStateFetch {
+					if err := sync.Wait(); err != nil && err != errCancelStateFetch {
 						d.queue.Close() // wake up WaitResults
 					}
 				}()
@@ -1439,13 +1447,29 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 			}
 			// Wait for completion, occasionally checking for pivot staleness
 			select {
-			case <-stateSync.done:
-				if stateSync.err != nil {
-					return stateSync.err
+			case <-sync.done:
+				if sync.err != nil {
+					return sync.err
+				}
+				// On dpos chains the pivot must not become the head without
+				// the hot consensus tries: verifying seals and sealing need
+				// the elected set, the candidate metadata and the production
+				// counters straight away. The delegation tries are only
+				// consulted by dpos transactions and the next election, so
+				// they are backfilled concurrently with the block import
+				// behind the pivot.
+				if proto := P.Header.DposContext; proto != nil {
+					hotSync := d.syncDposState(proto.HotRoots())
+					if err := hotSync.Wait(); err != nil {
+						return err
+					}
 				}
 				if err := d.commitPivotBlock(P); err != nil {
 					return err
 				}
+				if proto := P.Header.DposContext; proto != nil && dposBackfill == nil {
+					dposBackfill = d.syncDposState(proto.ColdRoots())
+				}
 				oldPivot = nil
 
 			case <-time.After(time.Second):
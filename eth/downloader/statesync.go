@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
@@ -69,6 +70,20 @@ func (d *Downloader) syncState(root common.Hash) *stateSync {
 	return s
 }
 
+// syncDposState starts downloading the dpos consensus tries rooted at the
+// given hashes. It reuses the state sync machinery, the scheduled tasks are
+// just nodes of the consensus tries instead of the account trie.
+func (d *Downloader) syncDposState(roots []common.Hash) *stateSync {
+	s := newDposSync(d, roots)
+	select {
+	case d.stateSyncStart <- s:
+	case <-d.quitCh:
+		s.err = errCancelStateFetch
+		close(s.done)
+	}
+	return s
+}
+
 // stateFetcher manages the active state sync and accepts requests
 // on its behalf.
 func (d *Downloader) stateFetcher() {
@@ -248,6 +263,21 @@ func newStateSync(d *Downloader, root common.Hash) *stateSync {
 	}
 }
 
+// newDposSync creates a download scheduler for a set of dpos consensus
+// tries. This method does not yet start the sync. The user needs to call run
+// to initiate.
+func newDposSync(d *Downloader, roots []common.Hash) *stateSync {
+	return &stateSync{
+		d:       d,
+		sched:   types.NewDposTrieSync(d.stateDB, roots...),
+		keccak:  sha3.NewKeccak256(),
+		tasks:   make(map[common.Hash]*stateTask),
+		deliver: make(chan *stateReq),
+		cancel:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
 // run starts the task assignment and response processing loop, blocking until
 // it finishes, and finally notifying any goroutines waiting for the loop to
 // finish.
@@ -28,6 +28,7 @@ import (
 	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -168,6 +169,89 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	return rpcSub, nil
 }
 
+// DposPendingOperation is the decoded form of a dpos operation waiting in the
+// transaction pool, as delivered by the DposPendingOperations subscription.
+type DposPendingOperation struct {
+	Hash      common.Hash     `json:"hash"`
+	Type      string          `json:"type"`
+	From      common.Address  `json:"from"`
+	Candidate *common.Address `json:"candidate,omitempty"` // Candidate the operation votes for or retracts from
+	Previous  *common.Address `json:"previous,omitempty"`  // Candidate a redelegation moves the vote away from
+}
+
+// decodeDposOperation decodes a pooled transaction into its dpos operation,
+// or reports that the transaction is not a user submitted dpos operation.
+func decodeDposOperation(tx *types.Transaction) (*DposPendingOperation, bool) {
+	txType := tx.Type()
+	if txType == types.Binary || txType.IsSystem() {
+		return nil, false
+	}
+	var signer types.Signer = types.FrontierSigner{}
+	if tx.Protected() {
+		signer = types.NewEIP155Signer(tx.ChainId())
+	}
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, false
+	}
+	op := &DposPendingOperation{Hash: tx.Hash(), Type: txType.String(), From: from}
+	data := tx.Data()
+	switch txType {
+	case types.Delegate, types.UnDelegate:
+		// A delegation may carry a trailing compounding flag after the target
+		if len(data) >= common.AddressLength {
+			candidate := common.BytesToAddress(data[:common.AddressLength])
+			op.Candidate = &candidate
+		}
+	case types.Redelegate:
+		if len(data) == 2*common.AddressLength {
+			previous := common.BytesToAddress(data[:common.AddressLength])
+			candidate := common.BytesToAddress(data[common.AddressLength:])
+			op.Previous = &previous
+			op.Candidate = &candidate
+		}
+	}
+	return op, true
+}
+
+// DposPendingOperations creates a subscription delivering the dpos operations
+// entering the transaction pool in decoded form: votes, vote retractions,
+// candidate registrations and the rest of the user operation family. Campaign
+// dashboards can render incoming votes live instead of waiting for the epoch
+// to close on them.
+func (api *PublicFilterAPI) DposPendingOperations(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		txs := make(chan core.NewTxsEvent, 128)
+		pendingTxSub := api.backend.SubscribeNewTxsEvent(txs)
+
+		for {
+			select {
+			case ev := <-txs:
+				for _, tx := range ev.Txs {
+					if op, ok := decodeDposOperation(tx); ok {
+						notifier.Notify(rpcSub.ID, op)
+					}
+				}
+			case <-rpcSub.Err():
+				pendingTxSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				pendingTxSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with eth_getFilterChanges.
 //
@@ -27,6 +27,7 @@ import (
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 var maxPrice = big.NewInt(500 * params.Shannon)
@@ -40,11 +41,14 @@ type Config struct {
 // Oracle recommends gas prices based on the content of recent
 // blocks. Suitable for both light and full clients.
 type Oracle struct {
-	backend   ethapi.Backend
-	lastHead  common.Hash
-	lastPrice *big.Int
-	cacheLock sync.RWMutex
-	fetchLock sync.Mutex
+	backend      ethapi.Backend
+	lastHead     common.Hash
+	lastPrice    *big.Int
+	defaultPrice *big.Int
+	cacheLock    sync.RWMutex
+	fetchLock    sync.Mutex
+
+	triedb *trie.Database // lazily built to read the dpos gas price floor
 
 	checkBlocks, maxEmpty, maxBlocks int
 	percentile                       int
@@ -64,15 +68,46 @@ func NewOracle(backend ethapi.Backend, params Config) *Oracle {
 		percent = 100
 	}
 	return &Oracle{
-		backend:     backend,
-		lastPrice:   params.Default,
-		checkBlocks: blocks,
-		maxEmpty:    blocks / 2,
-		maxBlocks:   blocks * 5,
-		percentile:  percent,
+		backend:      backend,
+		lastPrice:    params.Default,
+		defaultPrice: params.Default,
+		checkBlocks:  blocks,
+		maxEmpty:     blocks / 2,
+		maxBlocks:    blocks * 5,
+		percentile:   percent,
 	}
 }
 
+// dposActive reports whether the dpos engine governs the chain at the given
+// head. Under dpos blocks arrive in fixed slots regardless of demand, so the
+// oracle's sampling heuristics, built for difficulty-adjusted block times,
+// need adjusting.
+func (gpo *Oracle) dposActive(head *types.Header) bool {
+	config := gpo.backend.ChainConfig()
+	return config != nil && config.IsDpos(head.Number)
+}
+
+// dposPriceFloor returns the validator-voted network minimum gas price at the
+// given head, or nil when the chain is not running dpos or the validators
+// never voted a floor in. The transaction pool rejects anything below the
+// floor, so suggesting less would only produce stuck transactions.
+func (gpo *Oracle) dposPriceFloor(head *types.Header) *big.Int {
+	if !gpo.dposActive(head) || head.DposContext == nil {
+		return nil
+	}
+	if gpo.triedb == nil {
+		gpo.triedb = trie.NewDatabase(gpo.backend.ChainDb())
+	}
+	dposContext, err := types.NewDposContextFromProto(gpo.triedb, head.DposContext)
+	if err != nil {
+		return nil
+	}
+	if floor := dposContext.MinGasPrice(); floor > 0 {
+		return new(big.Int).SetUint64(floor)
+	}
+	return nil
+}
+
 // SuggestPrice returns the recommended gas price.
 func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	gpo.cacheLock.RLock()
@@ -98,11 +133,18 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 		return lastPrice, nil
 	}
 
+	dpos := gpo.dposActive(head)
+	floor := gpo.dposPriceFloor(head)
+
 	blockNum := head.Number.Uint64()
 	ch := make(chan getBlockPricesResult, gpo.checkBlocks)
 	sent := 0
 	exp := 0
-	var blockPrices []*big.Int
+	var (
+		blockPrices []*big.Int
+		emptyBlocks int
+		oldestTime  = head.Time.Uint64()
+	)
 	for sent < gpo.checkBlocks && blockNum > 0 {
 		go gpo.getBlockPrices(ctx, types.MakeSigner(gpo.backend.ChainConfig(), big.NewInt(int64(blockNum))), blockNum, ch)
 		sent++
@@ -116,10 +158,21 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 			return lastPrice, res.err
 		}
 		exp--
+		if res.time > 0 && res.time < oldestTime {
+			oldestTime = res.time
+		}
 		if res.price != nil {
 			blockPrices = append(blockPrices, res.price)
 			continue
 		}
+		if dpos {
+			// With fixed block times an empty block is an idle slot, a
+			// genuine signal of zero congestion. Count it instead of
+			// scanning deeper for older full blocks, which would keep the
+			// suggestion tracking a busy period long since over.
+			emptyBlocks++
+			continue
+		}
 		if maxEmpty > 0 {
 			maxEmpty--
 			continue
@@ -131,6 +184,32 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 			blockNum--
 		}
 	}
+	if dpos {
+		// Under empty-block suppression an idle validator sits out its slot
+		// entirely, so slots missing from the sampled window are as strong
+		// an idle signal as empty blocks. Recover them from the timestamps.
+		interval := uint64(10)
+		if dposConfig := gpo.backend.ChainConfig().Dpos; dposConfig != nil && dposConfig.BlockInterval > 0 {
+			interval = dposConfig.BlockInterval
+		}
+		if head.Time.Uint64() > oldestTime {
+			if slots := (head.Time.Uint64()-oldestTime)/interval + 1; slots > uint64(len(blockPrices)+emptyBlocks) {
+				emptyBlocks = int(slots) - len(blockPrices)
+			}
+		}
+		// Every idle slot votes for the cheapest price a transaction could
+		// have paid in it: the validator-voted floor, or the configured
+		// default when the validators never voted one in
+		idle := floor
+		if idle == nil {
+			idle = gpo.defaultPrice
+		}
+		if idle != nil {
+			for i := 0; i < emptyBlocks; i++ {
+				blockPrices = append(blockPrices, idle)
+			}
+		}
+	}
 	price := lastPrice
 	if len(blockPrices) > 0 {
 		sort.Sort(bigIntArray(blockPrices))
@@ -139,6 +218,9 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	if price.Cmp(maxPrice) > 0 {
 		price = new(big.Int).Set(maxPrice)
 	}
+	if floor != nil && price.Cmp(floor) < 0 {
+		price = new(big.Int).Set(floor)
+	}
 
 	gpo.cacheLock.Lock()
 	gpo.lastHead = headHash
@@ -149,6 +231,7 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 
 type getBlockPricesResult struct {
 	price *big.Int
+	time  uint64
 	err   error
 }
 
@@ -163,7 +246,7 @@ func (t transactionsByGasPrice) Less(i, j int) bool { return t[i].GasPrice().Cmp
 func (gpo *Oracle) getBlockPrices(ctx context.Context, signer types.Signer, blockNum uint64, ch chan getBlockPricesResult) {
 	block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(blockNum))
 	if block == nil {
-		ch <- getBlockPricesResult{nil, err}
+		ch <- getBlockPricesResult{nil, 0, err}
 		return
 	}
 
@@ -173,13 +256,18 @@ func (gpo *Oracle) getBlockPrices(ctx context.Context, signer types.Signer, bloc
 	sort.Sort(transactionsByGasPrice(txs))
 
 	for _, tx := range txs {
+		// Engine-synthesized system transactions carry a zero gas price and
+		// say nothing about what users paid
+		if tx.Type().IsSystem() {
+			continue
+		}
 		sender, err := types.Sender(signer, tx)
 		if err == nil && sender != block.Coinbase() {
-			ch <- getBlockPricesResult{tx.GasPrice(), nil}
+			ch <- getBlockPricesResult{tx.GasPrice(), block.Time().Uint64(), nil}
 			return
 		}
 	}
-	ch <- getBlockPricesResult{nil, nil}
+	ch <- getBlockPricesResult{nil, block.Time().Uint64(), nil}
 }
 
 type bigIntArray []*big.Int
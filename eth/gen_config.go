@@ -4,6 +4,7 @@ package eth
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -33,7 +34,11 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
-		DocRoot                 string `toml:"-"`
+		DposStandby             bool          `toml:",omitempty"`
+		DposReplica             bool          `toml:",omitempty"`
+		DposValidatorSlots      int           `toml:",omitempty"`
+		DposShutdownGrace       time.Duration `toml:",omitempty"`
+		DocRoot                 string        `toml:"-"`
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
@@ -52,6 +57,10 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.DposStandby = c.DposStandby
+	enc.DposReplica = c.DposReplica
+	enc.DposValidatorSlots = c.DposValidatorSlots
+	enc.DposShutdownGrace = c.DposShutdownGrace
 	enc.DocRoot = c.DocRoot
 	return &enc, nil
 }
@@ -74,7 +83,11 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
-		DocRoot                 *string `toml:"-"`
+		DposStandby             *bool          `toml:",omitempty"`
+		DposReplica             *bool          `toml:",omitempty"`
+		DposValidatorSlots      *int           `toml:",omitempty"`
+		DposShutdownGrace       *time.Duration `toml:",omitempty"`
+		DocRoot                 *string        `toml:"-"`
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -128,6 +141,18 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.DposStandby != nil {
+		c.DposStandby = *dec.DposStandby
+	}
+	if dec.DposReplica != nil {
+		c.DposReplica = *dec.DposReplica
+	}
+	if dec.DposValidatorSlots != nil {
+		c.DposValidatorSlots = *dec.DposValidatorSlots
+	}
+	if dec.DposShutdownGrace != nil {
+		c.DposShutdownGrace = *dec.DposShutdownGrace
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -28,9 +28,11 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/dpos"
 	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/fetcher"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -40,6 +42,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
+	lru "github.com/hashicorp/golang-lru"
 )
 
 const (
@@ -49,6 +52,9 @@ const (
 	// txChanSize is the size of channel listening to NewTxsEvent.
 	// The number is referenced from the size of tx pool.
 	txChanSize = 4096
+
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
 )
 
 var (
@@ -72,12 +78,19 @@ type ProtocolManager struct {
 	txpool      txPool
 	blockchain  *core.BlockChain
 	chainconfig *params.ChainConfig
-	maxPeers    int
+	engine         consensus.Engine
+	maxPeers       int
+	validatorSlots int         // Connection slots reserved for sessions of proven active validators
+	dposForkID     common.Hash // Hash of the dpos governance parameters advertised in the handshake
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
 	peers      *peerSet
 
+	heartbeats *lru.ARCCache   // Recently seen heartbeat hashes to break gossip loops
+	headerSigs *lru.ARCCache   // Recently seen bridge co-signature hashes to break gossip loops
+	selfID     discover.NodeID // Node id of the local node, for session bound signatures
+
 	SubProtocols []p2p.Protocol
 
 	eventMux      *event.TypeMux
@@ -106,12 +119,16 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		txpool:      txpool,
 		blockchain:  blockchain,
 		chainconfig: config,
+		engine:      engine,
+		dposForkID:  dposForkID(config),
 		peers:       newPeerSet(),
 		newPeerCh:   make(chan *peer),
 		noMorePeers: make(chan struct{}),
 		txsyncCh:    make(chan *txsync),
 		quitSync:    make(chan struct{}),
 	}
+	manager.heartbeats, _ = lru.NewARC(maxKnownHbs)
+	manager.headerSigs, _ = lru.NewARC(maxKnownSigs)
 	// Figure out whether to allow fast sync or not
 	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
 		log.Warn("Blockchain not empty, fast sync disabled")
@@ -202,6 +219,10 @@ func (pm *ProtocolManager) removePeer(id string) {
 
 func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.maxPeers = maxPeers
+	if pm.validatorSlots >= maxPeers {
+		log.Warn("Reserved validator slots capped below the peer limit", "slots", pm.validatorSlots, "maxpeers", maxPeers)
+		pm.validatorSlots = maxPeers - 1
+	}
 
 	// broadcast transactions
 	pm.txsCh = make(chan core.NewTxsEvent, txChanSize)
@@ -212,6 +233,12 @@ func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.minedBlockSub = pm.eventMux.Subscribe(core.NewMinedBlockEvent{})
 	go pm.minedBroadcastLoop()
 
+	// gossip validator heartbeats and bridge co-signatures on dpos networks
+	if engine, ok := pm.engine.(*dpos.Dpos); ok {
+		go pm.heartbeatLoop(engine)
+		go pm.bridgeSigLoop(engine)
+	}
+
 	// start sync handlers
 	go pm.syncer()
 	go pm.txsyncLoop()
@@ -249,9 +276,19 @@ func (pm *ProtocolManager) newPeer(pv int, p *p2p.Peer, rw p2p.MsgReadWriter) *p
 // handle is the callback invoked to manage the life cycle of an eth peer. When
 // this function terminates, the peer is disconnected.
 func (pm *ProtocolManager) handle(p *peer) error {
-	// Ignore maxPeers if this is a trusted peer
-	if pm.peers.Len() >= pm.maxPeers && !p.Peer.Info().Network.Trusted {
-		return p2p.DiscTooManyPeers
+	// Ignore maxPeers if this is a trusted peer. The last validatorSlots of
+	// the allowance are reserved for the validator mesh: an unproven peer may
+	// borrow a reserved slot, but is dropped again unless it proves an active
+	// validator identity in time, so leechers cannot pin those slots down.
+	reserved := false
+	if !p.Peer.Info().Network.Trusted {
+		free := pm.maxPeers - pm.peers.Len()
+		if free <= 0 {
+			return p2p.DiscTooManyPeers
+		}
+		if free <= pm.validatorSlots-pm.peers.ValidatorLen() {
+			reserved = true
+		}
 	}
 	p.Log().Debug("Ethereum peer connected", "name", p.Name())
 
@@ -263,7 +300,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		number  = head.Number.Uint64()
 		td      = pm.blockchain.GetTd(hash, number)
 	)
-	if err := p.Handshake(pm.networkID, td, hash, genesis.Hash()); err != nil {
+	if err := p.Handshake(pm.networkID, td, hash, genesis.Hash(), pm.dposForkID); err != nil {
 		p.Log().Debug("Ethereum handshake failed", "err", err)
 		return err
 	}
@@ -285,6 +322,30 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	// after this will be sent via broadcasts.
 	pm.syncTransactions(p)
 
+	// If we validate on a dpos network, prove our identity to the peer so it
+	// pushes new blocks to us with priority
+	if p.version >= eth63 {
+		pm.sendValidatorIntro(p)
+	}
+
+	// A peer occupying a reserved validator slot must earn it: evict it unless
+	// it proves an active validator identity before the timer runs out
+	if reserved {
+		p.introDrop = time.AfterFunc(validatorIntroTimeout, func() {
+			if pm.isActiveValidator(p.Validator()) {
+				return
+			}
+			p.Log().Debug("Evicting unproven peer from reserved validator slot")
+			pm.removePeer(p.id)
+		})
+		defer func() {
+			if p.introDrop != nil {
+				p.introDrop.Stop()
+				p.introDrop = nil
+			}
+		}()
+	}
+
 	// If we're DAO hard-fork aware, validate any remote peer with regard to the hard-fork
 	if daoBlock := pm.chainconfig.DAOForkBlock; daoBlock != nil {
 		// Request the peer's DAO fork header for extra-data validation
@@ -474,6 +535,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 
 	case msg.Code == GetBlockBodiesMsg:
+		pm.deferHeavyServing()
 		// Decode the retrieval message
 		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
 		if _, err := msgStream.List(); err != nil {
@@ -527,6 +589,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 
 	case p.version >= eth63 && msg.Code == GetNodeDataMsg:
+		pm.deferHeavyServing()
 		// Decode the retrieval message
 		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
 		if _, err := msgStream.List(); err != nil {
@@ -565,6 +628,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 
 	case p.version >= eth63 && msg.Code == GetReceiptsMsg:
+		pm.deferHeavyServing()
 		// Decode the retrieval message
 		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
 		if _, err := msgStream.List(); err != nil {
@@ -640,6 +704,12 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		request.Block.ReceivedAt = msg.ReceivedAt
 		request.Block.ReceivedFrom = p
 
+		// Feed the announced header to the malicious-fork monitor before
+		// import, so double-signs and long side forks are caught even when
+		// the block itself never makes it into the chain
+		if engine, ok := pm.engine.(*dpos.Dpos); ok {
+			engine.ObserveRemoteHeader(pm.blockchain, request.Block.Header())
+		}
 		// Mark the peer as owning the block and schedule it for import
 		p.MarkBlock(request.Block.Hash())
 		pm.fetcher.Enqueue(p.id, request.Block)
@@ -682,6 +752,90 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		}
 		pm.txpool.AddRemotes(txs)
 
+	case p.version >= eth63 && msg.Code == HeartbeatMsg:
+		// Validator heartbeats are only meaningful on dpos networks
+		engine, ok := pm.engine.(*dpos.Dpos)
+		if !ok {
+			break
+		}
+		var hb dpos.Heartbeat
+		if err := msg.Decode(&hb); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		// Mark the heartbeat as known to the peer and drop duplicates
+		hash := hb.SigHash()
+		p.MarkHeartbeat(hash)
+		if _, seen := pm.heartbeats.Get(hash); seen {
+			break
+		}
+		if err := hb.Verify(time.Now().Unix()); err != nil {
+			log.Debug("Discarded invalid validator heartbeat", "validator", hb.Validator, "err", err)
+			break
+		}
+		pm.heartbeats.Add(hash, struct{}{})
+		engine.RecordHeartbeat(&hb)
+		pm.BroadcastHeartbeat(&hb)
+
+	case p.version >= eth63 && msg.Code == HeaderSigMsg:
+		// Bridge co-signatures are only meaningful on dpos networks
+		engine, ok := pm.engine.(*dpos.Dpos)
+		if !ok {
+			break
+		}
+		var hs dpos.HeaderSignature
+		if err := msg.Decode(&hs); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		// Mark the co-signature as known to the peer and drop duplicates
+		hash := hs.SigHash()
+		p.MarkHeaderSignature(hash)
+		if _, seen := pm.headerSigs.Get(hash); seen {
+			break
+		}
+		if err := hs.Verify(); err != nil {
+			log.Debug("Discarded invalid bridge co-signature", "validator", hs.Validator, "err", err)
+			break
+		}
+		pm.headerSigs.Add(hash, struct{}{})
+		if err := engine.RecordHeaderSignature(pm.blockchain, &hs); err != nil {
+			log.Debug("Discarded bridge co-signature", "validator", hs.Validator, "number", hs.Number, "err", err)
+			break
+		}
+		pm.BroadcastHeaderSignature(&hs)
+
+	case p.version >= eth63 && msg.Code == ValidatorIntroMsg:
+		// A peer proves its session belongs to a validator key
+		if _, ok := pm.engine.(*dpos.Dpos); !ok {
+			break
+		}
+		var intro validatorIntroData
+		if err := msg.Decode(&intro); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		if delta := time.Now().Unix() - int64(intro.Time); delta > validatorIntroDrift || delta < -validatorIntroDrift {
+			p.Log().Debug("Discarded expired validator intro", "validator", intro.Validator)
+			break
+		}
+		hash := validatorIntroHash(p.ID(), pm.selfID, intro.Time, pm.blockchain.Genesis().Hash())
+		pubkey, err := crypto.Ecrecover(hash.Bytes(), intro.Sig)
+		if err != nil {
+			return errResp(ErrDecode, "invalid validator intro signature: %v", err)
+		}
+		var signer common.Address
+		copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+		if signer != intro.Validator {
+			p.Log().Debug("Discarded forged validator intro", "claimed", intro.Validator, "signer", signer)
+			break
+		}
+		p.SetValidator(intro.Validator)
+		p.Log().Debug("Peer proved validator identity", "validator", intro.Validator)
+
+		// A proof from an active validator settles a borrowed connection slot
+		if p.introDrop != nil && pm.isActiveValidator(intro.Validator) {
+			p.introDrop.Stop()
+			p.introDrop = nil
+		}
+
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
 	}
@@ -704,12 +858,24 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 			log.Error("Propagating dangling block", "number", block.Number(), "hash", hash)
 			return
 		}
-		// Send the block to a subset of our peers
-		transfer := peers[:int(math.Sqrt(float64(len(peers))))]
+		// Push the block to all proven validator peers first: the producer of
+		// the next slot must hold the parent before its slot starts
+		var rest []*peer
+		sent := 0
+		for _, peer := range peers {
+			if peer.Validator() != (common.Address{}) {
+				peer.AsyncSendNewBlock(block, td)
+				sent++
+			} else {
+				rest = append(rest, peer)
+			}
+		}
+		// Send the block to a subset of the remaining peers
+		transfer := rest[:int(math.Sqrt(float64(len(rest))))]
 		for _, peer := range transfer {
 			peer.AsyncSendNewBlock(block, td)
 		}
-		log.Trace("Propagated block", "hash", hash, "recipients", len(transfer), "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
+		log.Trace("Propagated block", "hash", hash, "recipients", sent+len(transfer), "validators", sent, "duration", common.PrettyDuration(time.Since(block.ReceivedAt)))
 		return
 	}
 	// Otherwise if the block is indeed in out own chain, announce it
@@ -740,6 +906,181 @@ func (pm *ProtocolManager) BroadcastTxs(txs types.Transactions) {
 	}
 }
 
+// validatorIntroDrift is the maximum age, in seconds, of an accepted
+// validator intro, bounding how long a captured intro stays usable.
+const validatorIntroDrift = int64(60)
+
+// validatorIntroTimeout is how long a peer may occupy a reserved validator
+// connection slot before proving an active validator identity.
+const validatorIntroTimeout = 10 * time.Second
+
+// deferHeavyServing holds bulk request serving back while the local validator
+// sits in the final second of its own slot, so sealing, signing and block
+// propagation are not starved of bandwidth and CPU by leechers. Outside that
+// window, and on nodes that are not the upcoming validator, it returns
+// immediately.
+func (pm *ProtocolManager) deferHeavyServing() {
+	engine, ok := pm.engine.(*dpos.Dpos)
+	if !ok {
+		return
+	}
+	wait := engine.SealCriticalWait(pm.blockchain.CurrentBlock(), time.Now().Unix())
+	if wait == 0 {
+		return
+	}
+	serveDeferMeter.Mark(1)
+	serveDeferTimer.Time(func() { time.Sleep(wait) })
+}
+
+// isActiveValidator reports whether the address belongs to the validator set
+// at the current chain head, i.e. whether its sessions deserve a reserved
+// connection slot.
+func (pm *ProtocolManager) isActiveValidator(addr common.Address) bool {
+	if addr == (common.Address{}) {
+		return false
+	}
+	header := pm.blockchain.CurrentHeader()
+	if header == nil || header.DposContext == nil {
+		return false
+	}
+	dposContext, err := pm.blockchain.DposContextAt(header)
+	if err != nil {
+		return false
+	}
+	validators, err := dposContext.GetValidators()
+	if err != nil {
+		return false
+	}
+	for _, validator := range validators {
+		if validator == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// validatorIntroHash returns the hash a validator intro signature covers. The
+// two node ids tie the signature to one concrete p2p session.
+func validatorIntroHash(sender, receiver discover.NodeID, time uint64, genesis common.Hash) common.Hash {
+	enc, _ := rlp.EncodeToBytes([]interface{}{sender, receiver, time, genesis})
+	return crypto.Keccak256Hash(enc)
+}
+
+// sendValidatorIntro proves the local validator identity to the given peer if
+// a signer is authorized on the dpos engine. Peers that connected before the
+// signer was authorized are introduced to once the next block is sealed and
+// reconnections occur, which is good enough for a propagation optimization.
+func (pm *ProtocolManager) sendValidatorIntro(p *peer) {
+	engine, ok := pm.engine.(*dpos.Dpos)
+	if !ok {
+		return
+	}
+	now := uint64(time.Now().Unix())
+	validator, sig, err := engine.Sign(validatorIntroHash(pm.selfID, p.ID(), now, pm.blockchain.Genesis().Hash()))
+	if err != nil {
+		p.Log().Debug("Failed to sign validator intro", "err", err)
+		return
+	}
+	if sig == nil {
+		return // no signer authorized, nothing to prove
+	}
+	if err := p2p.Send(p.rw, ValidatorIntroMsg, &validatorIntroData{Validator: validator, Time: now, Sig: sig}); err != nil {
+		p.Log().Debug("Failed to send validator intro", "err", err)
+	}
+}
+
+// BroadcastHeartbeat forwards a validator heartbeat to all peers which are not
+// known to already have it.
+func (pm *ProtocolManager) BroadcastHeartbeat(hb *dpos.Heartbeat) {
+	hash := hb.SigHash()
+	peers := pm.peers.PeersWithoutHeartbeat(hash)
+	for _, peer := range peers {
+		if peer.version < eth63 {
+			continue
+		}
+		peer.SendHeartbeat(hb)
+	}
+	log.Trace("Broadcast validator heartbeat", "validator", hb.Validator, "recipients", len(peers))
+}
+
+// BroadcastHeaderSignature forwards a bridge co-signature to all peers which
+// are not known to already have it.
+func (pm *ProtocolManager) BroadcastHeaderSignature(hs *dpos.HeaderSignature) {
+	hash := hs.SigHash()
+	peers := pm.peers.PeersWithoutHeaderSignature(hash)
+	for _, peer := range peers {
+		if peer.version < eth63 {
+			continue
+		}
+		peer.SendHeaderSignature(hs)
+	}
+	log.Trace("Broadcast bridge co-signature", "validator", hs.Validator, "number", hs.Number, "recipients", len(peers))
+}
+
+// bridgeSigLoop watches the chain head and co-signs epoch boundary headers
+// with the local validator key, gossiping the signatures so every node can
+// aggregate a validator-signed header for bridge relays.
+func (pm *ProtocolManager) bridgeSigLoop(engine *dpos.Dpos) {
+	headCh := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	headSub := pm.blockchain.SubscribeChainHeadEvent(headCh)
+	defer headSub.Unsubscribe()
+
+	for {
+		select {
+		case head := <-headCh:
+			header := head.Block.Header()
+			parent := pm.blockchain.GetHeaderByHash(header.ParentHash)
+			if !engine.IsEpochBoundary(parent, header) {
+				continue
+			}
+			hs, err := engine.SignBridgeHeader(header)
+			if err != nil {
+				log.Warn("Failed to co-sign epoch boundary header", "number", header.Number, "err", err)
+				continue
+			}
+			if hs == nil {
+				continue // no signer authorized, nothing to contribute
+			}
+			pm.headerSigs.Add(hs.SigHash(), struct{}{})
+			if err := engine.RecordHeaderSignature(pm.blockchain, hs); err != nil {
+				log.Debug("Dropped own bridge co-signature", "number", hs.Number, "err", err)
+				continue
+			}
+			pm.BroadcastHeaderSignature(hs)
+
+		case <-headSub.Err():
+			return
+		}
+	}
+}
+
+// heartbeatLoop periodically signs and gossips a liveness heartbeat while a
+// validator signer is authorized on the dpos engine.
+func (pm *ProtocolManager) heartbeatLoop(engine *dpos.Dpos) {
+	ticker := time.NewTicker(engine.HeartbeatPeriod())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			hb, err := engine.SignHeartbeat(time.Now().Unix())
+			if err != nil {
+				log.Warn("Failed to sign validator heartbeat", "err", err)
+				continue
+			}
+			if hb == nil {
+				continue // no signer authorized, nothing to announce
+			}
+			pm.heartbeats.Add(hb.SigHash(), struct{}{})
+			engine.RecordHeartbeat(hb)
+			pm.BroadcastHeartbeat(hb)
+
+		case <-pm.quitSync:
+			return
+		}
+	}
+}
+
 // Mined broadcast loop
 func (pm *ProtocolManager) minedBroadcastLoop() {
 	// automatically stops if unsubscribe
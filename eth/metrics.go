@@ -54,6 +54,8 @@ var (
 	miscInTrafficMeter        = metrics.NewRegisteredMeter("eth/misc/in/traffic", nil)
 	miscOutPacketsMeter       = metrics.NewRegisteredMeter("eth/misc/out/packets", nil)
 	miscOutTrafficMeter       = metrics.NewRegisteredMeter("eth/misc/out/traffic", nil)
+	serveDeferMeter           = metrics.NewRegisteredMeter("eth/serve/defer/packets", nil)
+	serveDeferTimer           = metrics.NewRegisteredTimer("eth/serve/defer/wait", nil)
 )
 
 // meteredMsgReadWriter is a wrapper around a p2p.MsgReadWriter, capable of
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/dpos"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -39,6 +40,8 @@ var (
 const (
 	maxKnownTxs    = 32768 // Maximum transactions hashes to keep in the known list (prevent DOS)
 	maxKnownBlocks = 1024  // Maximum block hashes to keep in the known list (prevent DOS)
+	maxKnownHbs    = 1024  // Maximum heartbeat hashes to keep in the known list (prevent DOS)
+	maxKnownSigs   = 1024  // Maximum bridge signature hashes to keep in the known list (prevent DOS)
 
 	// maxQueuedTxs is the maximum number of transaction lists to queue up before
 	// dropping broadcasts. This is a sensitive number as a transaction list might
@@ -78,15 +81,19 @@ type peer struct {
 	*p2p.Peer
 	rw p2p.MsgReadWriter
 
-	version  int         // Protocol version negotiated
-	forkDrop *time.Timer // Timed connection dropper if forks aren't validated in time
+	version   int         // Protocol version negotiated
+	forkDrop  *time.Timer // Timed connection dropper if forks aren't validated in time
+	introDrop *time.Timer // Timed connection dropper if a reserved validator slot isn't earned in time
 
-	head common.Hash
-	td   *big.Int
-	lock sync.RWMutex
+	head      common.Hash
+	td        *big.Int
+	validator common.Address // Proven validator identity of the peer, zero if none
+	lock      sync.RWMutex
 
 	knownTxs    *set.Set                  // Set of transaction hashes known to be known by this peer
 	knownBlocks *set.Set                  // Set of block hashes known to be known by this peer
+	knownHbs    *set.Set                  // Set of heartbeat hashes known to be known by this peer
+	knownSigs   *set.Set                  // Set of bridge signature hashes known to be known by this peer
 	queuedTxs   chan []*types.Transaction // Queue of transactions to broadcast to the peer
 	queuedProps chan *propEvent           // Queue of blocks to broadcast to the peer
 	queuedAnns  chan *types.Block         // Queue of blocks to announce to the peer
@@ -101,6 +108,8 @@ func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 		id:          fmt.Sprintf("%x", p.ID().Bytes()[:8]),
 		knownTxs:    set.New(),
 		knownBlocks: set.New(),
+		knownHbs:    set.New(),
+		knownSigs:   set.New(),
 		queuedTxs:   make(chan []*types.Transaction, maxQueuedTxs),
 		queuedProps: make(chan *propEvent, maxQueuedProps),
 		queuedAnns:  make(chan *types.Block, maxQueuedAnns),
@@ -173,6 +182,24 @@ func (p *peer) SetHead(hash common.Hash, td *big.Int) {
 	p.td.Set(td)
 }
 
+// Validator retrieves the proven validator identity of the peer, or the zero
+// address if the peer never introduced itself as a validator.
+func (p *peer) Validator() common.Address {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.validator
+}
+
+// SetValidator marks the peer as speaking for the given validator after its
+// intro signature checked out.
+func (p *peer) SetValidator(validator common.Address) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.validator = validator
+}
+
 // MarkBlock marks a block as known for the peer, ensuring that the block will
 // never be propagated to this particular peer.
 func (p *peer) MarkBlock(hash common.Hash) {
@@ -193,6 +220,40 @@ func (p *peer) MarkTransaction(hash common.Hash) {
 	p.knownTxs.Add(hash)
 }
 
+// MarkHeartbeat marks a heartbeat as known for the peer, ensuring that it
+// will never be propagated to this particular peer.
+func (p *peer) MarkHeartbeat(hash common.Hash) {
+	// If we reached the memory allowance, drop a previously known heartbeat hash
+	for p.knownHbs.Size() >= maxKnownHbs {
+		p.knownHbs.Pop()
+	}
+	p.knownHbs.Add(hash)
+}
+
+// SendHeartbeat forwards a validator heartbeat to the peer and includes its
+// hash in the peer's known set for future reference.
+func (p *peer) SendHeartbeat(hb *dpos.Heartbeat) error {
+	p.knownHbs.Add(hb.SigHash())
+	return p2p.Send(p.rw, HeartbeatMsg, hb)
+}
+
+// MarkHeaderSignature marks a bridge co-signature as known for the peer,
+// ensuring that it will never be propagated to this particular peer.
+func (p *peer) MarkHeaderSignature(hash common.Hash) {
+	// If we reached the memory allowance, drop a previously known signature hash
+	for p.knownSigs.Size() >= maxKnownSigs {
+		p.knownSigs.Pop()
+	}
+	p.knownSigs.Add(hash)
+}
+
+// SendHeaderSignature forwards a bridge co-signature to the peer and includes
+// its hash in the peer's known set for future reference.
+func (p *peer) SendHeaderSignature(hs *dpos.HeaderSignature) error {
+	p.knownSigs.Add(hs.SigHash())
+	return p2p.Send(p.rw, HeaderSigMsg, hs)
+}
+
 // SendTransactions sends transactions to the peer and includes the hashes
 // in its transaction hash set for future reference.
 func (p *peer) SendTransactions(txs types.Transactions) error {
@@ -328,8 +389,9 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 }
 
 // Handshake executes the eth protocol handshake, negotiating version number,
-// network IDs, difficulties, head and genesis blocks.
-func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
+// network IDs, difficulties, head and genesis blocks, plus the dpos fork ID
+// covering the governance parameters of the chain.
+func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, forkID common.Hash) error {
 	// Send out own handshake in a new thread
 	errc := make(chan error, 2)
 	var status statusData // safe to read after two values have been received from errc
@@ -341,10 +403,11 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 			TD:              td,
 			CurrentBlock:    head,
 			GenesisBlock:    genesis,
+			DposForkID:      forkID,
 		})
 	}()
 	go func() {
-		errc <- p.readStatus(network, &status, genesis)
+		errc <- p.readStatus(network, &status, genesis, forkID)
 	}()
 	timeout := time.NewTimer(handshakeTimeout)
 	defer timeout.Stop()
@@ -362,7 +425,7 @@ func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis
 	return nil
 }
 
-func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
+func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash, forkID common.Hash) (err error) {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
 		return err
@@ -380,6 +443,9 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 	if status.GenesisBlock != genesis {
 		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])
 	}
+	if status.DposForkID != forkID {
+		return errResp(ErrDposForkIDMismatch, "%x (!= %x)", status.DposForkID[:8], forkID[:8])
+	}
 	if status.NetworkId != network {
 		return errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkId, network)
 	}
@@ -462,6 +528,21 @@ func (ps *peerSet) Len() int {
 	return len(ps.peers)
 }
 
+// ValidatorLen returns the number of peers in the set that proved a validator
+// identity for their session.
+func (ps *peerSet) ValidatorLen() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	count := 0
+	for _, p := range ps.peers {
+		if p.Validator() != (common.Address{}) {
+			count++
+		}
+	}
+	return count
+}
+
 // PeersWithoutBlock retrieves a list of peers that do not have a given block in
 // their set of known hashes.
 func (ps *peerSet) PeersWithoutBlock(hash common.Hash) []*peer {
@@ -492,6 +573,36 @@ func (ps *peerSet) PeersWithoutTx(hash common.Hash) []*peer {
 	return list
 }
 
+// PeersWithoutHeartbeat retrieves a list of peers that do not have a given
+// heartbeat in their set of known hashes.
+func (ps *peerSet) PeersWithoutHeartbeat(hash common.Hash) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.knownHbs.Has(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// PeersWithoutHeaderSignature retrieves a list of peers that do not have a
+// given bridge co-signature in their set of known hashes.
+func (ps *peerSet) PeersWithoutHeaderSignature(hash common.Hash) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.knownSigs.Has(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
 // BestPeer retrieves the known peer with the currently highest total difficulty.
 func (ps *peerSet) BestPeer() *peer {
 	ps.lock.RLock()
@@ -24,7 +24,9 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -41,7 +43,7 @@ var ProtocolName = "eth"
 var ProtocolVersions = []uint{eth63, eth62}
 
 // ProtocolLengths are the number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{17, 8}
+var ProtocolLengths = []uint64{20, 8}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
@@ -62,6 +64,11 @@ const (
 	NodeDataMsg    = 0x0e
 	GetReceiptsMsg = 0x0f
 	ReceiptsMsg    = 0x10
+
+	// Protocol messages belonging to the dpos extension of eth/63
+	HeartbeatMsg      = 0x11
+	ValidatorIntroMsg = 0x12
+	HeaderSigMsg      = 0x13
 )
 
 type errCode int
@@ -76,6 +83,7 @@ const (
 	ErrNoStatusMsg
 	ErrExtraStatusMsg
 	ErrSuspendedPeer
+	ErrDposForkIDMismatch
 )
 
 func (e errCode) String() string {
@@ -93,6 +101,7 @@ var errorToString = map[int]string{
 	ErrNoStatusMsg:             "No status message",
 	ErrExtraStatusMsg:          "Extra status message",
 	ErrSuspendedPeer:           "Suspended peer",
+	ErrDposForkIDMismatch:      "Dpos fork ID mismatch",
 }
 
 type txPool interface {
@@ -115,6 +124,61 @@ type statusData struct {
 	TD              *big.Int
 	CurrentBlock    common.Hash
 	GenesisBlock    common.Hash
+	DposForkID      common.Hash
+}
+
+// dposForkID condenses the consensus-critical dpos parameters of a chain
+// configuration into a single hash advertised during the eth handshake. Peers
+// whose governance history diverged - a different epoch length, emission
+// schedule, election rules or slashing policy - would reject each other's
+// blocks during verification anyway, so comparing fork IDs up front lets them
+// disconnect before wasting bandwidth on a doomed sync. Node-local tuning
+// knobs like sealing pauses, standby mode and the pool anti-spam minimums do
+// not contribute, and chains without dpos advertise the zero hash.
+func dposForkID(config *params.ChainConfig) common.Hash {
+	if config == nil || config.Dpos == nil {
+		return common.Hash{}
+	}
+	d := config.Dpos
+	enc, _ := rlp.EncodeToBytes([]interface{}{
+		d.Validators,
+		d.ForkBlock,
+		d.BlockInterval,
+		d.EpochInterval,
+		d.MaxValidatorSize,
+		d.MaxBlockDrift,
+		bigOrZero(d.BlockReward),
+		d.HalvingInterval,
+		bigOrZero(d.HardCap),
+		d.ValidatorSizeSchedule,
+		d.KickoutWindow,
+		d.VoteExpiryEpochs,
+		d.MaxRedelegationsPerEpoch,
+		d.SlashMissedPercent,
+		d.SlashPenaltyPercent,
+		d.BurnSlashed,
+		d.CorrelationPenalty,
+		d.EvidencePenaltyPercent,
+		d.EvidenceRewardPercent,
+		d.EvidenceMaxAge,
+		d.VoteWeightMode,
+		d.ProposerOrdering,
+		bigOrZero(d.MaxVoteWeight),
+		bigOrZero(d.MinVoteThreshold),
+		d.MinSelfStakePercent,
+		d.PoolFees,
+		d.GovernanceSigners,
+		d.GovernanceThreshold,
+	})
+	return crypto.Keccak256Hash(enc)
+}
+
+// bigOrZero normalizes an optional big integer parameter for encoding.
+func bigOrZero(x *big.Int) *big.Int {
+	if x == nil {
+		return common.Big0
+	}
+	return x
 }
 
 // newBlockHashesData is the network packet for the block announcements.
@@ -167,6 +231,16 @@ func (hn *hashOrNumber) DecodeRLP(s *rlp.Stream) error {
 	return err
 }
 
+// validatorIntroData is the network packet a validator sends right after the
+// handshake to prove the session belongs to a validator key. The signature
+// covers both node ids, so an intro cannot be replayed on other sessions, and
+// the timestamp bounds how long a captured intro stays usable.
+type validatorIntroData struct {
+	Validator common.Address // Validator address the session claims to speak for
+	Time      uint64         // Unix time the intro was signed at
+	Sig       []byte         // Signature over sender id, receiver id, time and genesis
+}
+
 // newBlockData is the network packet for the block propagation message.
 type newBlockData struct {
 	Block *types.Block
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/dpos"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/log"
@@ -203,6 +204,7 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 	if atomic.LoadUint32(&pm.fastSync) == 1 {
 		log.Info("Fast sync complete, auto disabling")
 		atomic.StoreUint32(&pm.fastSync, 0)
+		pm.reconcileDposState()
 	}
 	atomic.StoreUint32(&pm.acceptTxs, 1) // Mark initial sync done
 	if head := pm.blockchain.CurrentBlock(); head.NumberU64() > 0 {
@@ -215,3 +217,19 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 		go pm.BroadcastBlock(head, false)
 	}
 }
+
+// reconcileDposState cross-checks the dpos tries a completed fast sync
+// downloaded and rebuilds the delegation index from them. A mismatch means
+// the synced consensus state is internally inconsistent, so sealing is
+// paused before the miner can start producing blocks on top of it; the
+// operator resumes it after a resync or repair.
+func (pm *ProtocolManager) reconcileDposState() {
+	engine, ok := pm.engine.(*dpos.Dpos)
+	if !ok {
+		return
+	}
+	if err := pm.blockchain.ReconcileDelegationIndex(); err != nil {
+		log.Error("Synced dpos state failed reconciliation", "err", err)
+		engine.PauseSealing("synced dpos state failed reconciliation")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SignVoteFn signs a vote transaction on behalf of the given delegator. It
+// matches the signer function shape used by the abi/bind package, so wallet
+// backed signers can be passed through unchanged.
+type SignVoteFn func(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error)
+
+// ValidatorsAt returns the active validator set at the given block number. A
+// nil block number queries the latest known block.
+func (ec *Client) ValidatorsAt(ctx context.Context, blockNumber *big.Int) ([]common.Address, error) {
+	var result []common.Address
+	err := ec.c.CallContext(ctx, &result, "dpos_getValidators", toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// DposCandidate is the RPC representation of a registered election candidate.
+type DposCandidate struct {
+	Address    common.Address `json:"address"`
+	Commission uint64         `json:"commission"`
+}
+
+// CandidatesAt returns the registered election candidates at the given block
+// number, together with their declared commission rates. A nil block number
+// queries the latest known block.
+func (ec *Client) CandidatesAt(ctx context.Context, blockNumber *big.Int) ([]DposCandidate, error) {
+	var result []DposCandidate
+	err := ec.c.CallContext(ctx, &result, "dpos_getCandidates", toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// VotesOf returns the candidate the given delegator voted for at the given
+// block number, or the zero address if the delegator has no active vote.
+func (ec *Client) VotesOf(ctx context.Context, delegator common.Address, blockNumber *big.Int) (common.Address, error) {
+	var result common.Address
+	err := ec.c.CallContext(ctx, &result, "dpos_getVotes", delegator, toBlockNumArg(blockNumber))
+	return result, err
+}
+
+// SendVote assembles a Delegate transaction voting for the given candidate,
+// has it signed through the provided signer function and submits it to the
+// pool. The account nonce and gas price are resolved from the connected node
+// if not supplied.
+func (ec *Client) SendVote(ctx context.Context, delegator, candidate common.Address, gasPrice *big.Int, sign SignVoteFn) (*types.Transaction, error) {
+	nonce, err := ec.PendingNonceAt(ctx, delegator)
+	if err != nil {
+		return nil, err
+	}
+	if gasPrice == nil {
+		if gasPrice, err = ec.SuggestGasPrice(ctx); err != nil {
+			return nil, err
+		}
+	}
+	chainID, err := ec.NetworkID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx := types.NewDelegateTransaction(nonce, candidate, dposTxGasLimit, gasPrice)
+	signed, err := sign(types.NewEIP155Signer(chainID), delegator, tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ec.SendTransaction(ctx, signed); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+// dposTxGasLimit covers the intrinsic gas of any of the fixed size dpos
+// operations with a comfortable margin.
+const dposTxGasLimit = uint64(100000)
@@ -33,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/dpos"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth"
@@ -663,13 +664,23 @@ func (s *Service) reportPending(conn *websocket.Conn) error {
 
 // nodeStats is the information to report about the local node.
 type nodeStats struct {
-	Active   bool `json:"active"`
-	Syncing  bool `json:"syncing"`
-	Mining   bool `json:"mining"`
-	Hashrate int  `json:"hashrate"`
-	Peers    int  `json:"peers"`
-	GasPrice int  `json:"gasPrice"`
-	Uptime   int  `json:"uptime"`
+	Active   bool       `json:"active"`
+	Syncing  bool       `json:"syncing"`
+	Mining   bool       `json:"mining"`
+	Hashrate int        `json:"hashrate"`
+	Peers    int        `json:"peers"`
+	GasPrice int        `json:"gasPrice"`
+	Uptime   int        `json:"uptime"`
+	Dpos     *dposStats `json:"dpos,omitempty"`
+}
+
+// dposStats is the consensus health info reported for nodes on a dpos
+// network, so dashboards show duty status next to the networking stats.
+type dposStats struct {
+	Validator     bool  `json:"validator"`     // Whether the local signer is in the active validator set
+	NextSlot      int64 `json:"nextSlot"`      // Unix time of the next slot assigned to the local signer
+	MintedInEpoch int64 `json:"mintedInEpoch"` // Blocks produced by the local signer this epoch
+	KickoutRisk   bool  `json:"kickoutRisk"`   // Whether the production trails the kickout threshold
 }
 
 // reportPending retrieves various stats about the node at the networking and
@@ -677,10 +688,11 @@ type nodeStats struct {
 func (s *Service) reportStats(conn *websocket.Conn) error {
 	// Gather the syncing and mining infos from the local miner instance
 	var (
-		mining   bool
-		hashrate int
-		syncing  bool
-		gasprice int
+		mining     bool
+		hashrate   int
+		syncing    bool
+		gasprice   int
+		dposReport *dposStats
 	)
 	if s.eth != nil {
 		mining = s.eth.Miner().Mining()
@@ -691,6 +703,20 @@ func (s *Service) reportStats(conn *websocket.Conn) error {
 
 		price, _ := s.eth.APIBackend.SuggestPrice(context.Background())
 		gasprice = int(price.Uint64())
+
+		// On dpos networks, report the consensus duty status of the signer
+		if engine, ok := s.engine.(*dpos.Dpos); ok {
+			if dposContext, err := s.eth.BlockChain().DposContextAt(s.eth.BlockChain().CurrentHeader()); err == nil {
+				if health, err := engine.Health(dposContext, time.Now().Unix()); err == nil {
+					dposReport = &dposStats{
+						Validator:     health.Validator,
+						NextSlot:      health.NextSlot,
+						MintedInEpoch: health.MintedInEpoch,
+						KickoutRisk:   health.KickoutRisk,
+					}
+				}
+			}
+		}
 	} else {
 		sync := s.les.Downloader().Progress()
 		syncing = s.les.BlockChain().CurrentHeader().Number.Uint64() >= sync.HighestBlock
@@ -708,6 +734,7 @@ func (s *Service) reportStats(conn *websocket.Conn) error {
 			GasPrice: gasprice,
 			Syncing:  syncing,
 			Uptime:   100,
+			Dpos:     dposReport,
 		},
 	}
 	report := map[string][]interface{}{
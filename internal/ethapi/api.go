@@ -24,6 +24,7 @@ import (
 	"math/big"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/accounts"
@@ -31,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus/dpos"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
@@ -853,9 +855,51 @@ func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx
 		return nil, err
 	}
 	fields["totalDifficulty"] = (*hexutil.Big)(s.b.GetTd(b.Hash()))
+	s.addDposFields(fields, b.Header())
 	return fields, err
 }
 
+// proposerRecoverer is implemented by consensus engines able to recover the
+// sealer of a header from its seal signature, like the dpos engine.
+type proposerRecoverer interface {
+	RecoverProposer(header *types.Header) (common.Address, error)
+}
+
+// addDposFields annotates a marshalled dpos block with its consensus context:
+// the proposer recovered from the seal signature plus the epoch and slot the
+// block's timestamp falls into. Recovery is answered from the engine's
+// signature cache, so the annotation adds no per-request ecrecover cost.
+func (s *PublicBlockChainAPI) addDposFields(fields map[string]interface{}, head *types.Header) {
+	config := s.b.ChainConfig()
+	if config.Dpos == nil || !config.IsDpos(head.Number) {
+		return
+	}
+	engine, ok := s.b.Engine().(proposerRecoverer)
+	if !ok {
+		return
+	}
+	// Unsealed headers like the genesis carry no recoverable signature and
+	// are returned without the extra fields
+	proposer, err := engine.RecoverProposer(head)
+	if err != nil {
+		return
+	}
+	timestamp := head.Time.Int64()
+	epochInterval := config.Dpos.EpochIntervalSeconds()
+	fields["proposer"] = proposer
+	fields["epoch"] = hexutil.Uint64(timestamp / epochInterval)
+	fields["slot"] = hexutil.Uint64(timestamp % epochInterval / config.Dpos.BlockIntervalSeconds())
+	// Surface the operator note embedded in the vanity, as text when it is
+	// valid UTF-8 and as hex bytes otherwise
+	if note := dpos.NoteFromExtra(head.Extra); len(note) > 0 {
+		if utf8.Valid(note) {
+			fields["proposerNote"] = string(note)
+		} else {
+			fields["proposerNote"] = hexutil.Bytes(note)
+		}
+	}
+}
+
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
 type RPCTransaction struct {
 	BlockHash        common.Hash     `json:"blockHash"`
@@ -1464,6 +1508,50 @@ func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64) {
 	api.b.SetHead(uint64(number))
 }
 
+// SetHeadToEpoch rewinds the head of the blockchain to the first block of the
+// given dpos epoch. The account state and the consensus context are restored
+// together, since both are pinned by that block's header. Meant for dev and
+// private chains iterating on governance or reward logic; the rewound-to
+// state must still be available, so archive mode is effectively required.
+func (api *PrivateDebugAPI) SetHeadToEpoch(ctx context.Context, epoch uint64) error {
+	config := api.b.ChainConfig()
+	if config.Dpos == nil {
+		return errors.New("not a dpos chain")
+	}
+	interval := config.Dpos.EpochIntervalSeconds()
+	head, err := api.b.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return err
+	}
+	if head.Time.Int64()/interval < int64(epoch) {
+		return fmt.Errorf("epoch %d is beyond the current head", epoch)
+	}
+	// Timestamps grow strictly under the slot schedule, so the first block of
+	// the epoch can be binary searched by block number
+	lo, hi := uint64(0), head.Number.Uint64()
+	for lo < hi {
+		mid := (lo + hi) / 2
+		header, err := api.b.HeaderByNumber(ctx, rpc.BlockNumber(mid))
+		if err != nil {
+			return err
+		}
+		if header.Time.Int64()/interval >= int64(epoch) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	boundary, err := api.b.HeaderByNumber(ctx, rpc.BlockNumber(lo))
+	if err != nil {
+		return err
+	}
+	if boundary.Time.Int64()/interval != int64(epoch) {
+		return fmt.Errorf("no block was produced in epoch %d", epoch)
+	}
+	api.b.SetHead(lo)
+	return nil
+}
+
 // PublicNetAPI offers network related RPC methods
 type PublicNetAPI struct {
 	net            *p2p.Server
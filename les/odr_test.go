@@ -117,7 +117,8 @@ type callmsg struct {
 	types.Message
 }
 
-func (callmsg) CheckNonce() bool { return false }
+func (callmsg) CheckNonce() bool      { return false }
+func (callmsg) Type() types.TxType    { return types.Binary }
 
 func odrContractCall(ctx context.Context, db ethdb.Database, config *params.ChainConfig, bc *core.BlockChain, lc *light.LightChain, bhash common.Hash) []byte {
 	data := common.Hex2Bytes("60CD26850000000000000000000000000000000000000000000000000000000000000000")
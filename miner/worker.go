@@ -66,7 +66,8 @@ type Work struct {
 	config *params.ChainConfig
 	signer types.Signer
 
-	state     *state.StateDB // apply state changes here
+	state       *state.StateDB     // apply state changes here
+	dposContext *types.DposContext // dpos context of the block under construction
 	ancestors *set.Set       // ancestor set (used for checking uncle parent validity)
 	family    *set.Set       // family set (used for checking uncle invalidity)
 	uncles    *set.Set       // uncle set
@@ -79,6 +80,8 @@ type Work struct {
 	txs      []*types.Transaction
 	receipts []*types.Receipt
 
+	deadline time.Time // Wall clock budget for block building within the sealing slot, zero for none
+
 	createdAt time.Time
 }
 
@@ -87,6 +90,19 @@ type Result struct {
 	Block *types.Block
 }
 
+// gasLimitVoter is implemented by consensus engines that derive the block gas
+// limit from on-chain validator votes instead of the miner-local target.
+type gasLimitVoter interface {
+	CalcGasLimit(parent *types.Header, dposContext *types.DposContext) uint64
+}
+
+// slotDeadliner is implemented by consensus engines whose blocks must be
+// sealed within a fixed time slot; the miner budgets block building so the
+// block can still be finalized, signed and propagated before the slot ends.
+type slotDeadliner interface {
+	SealDeadline(header *types.Header) time.Time
+}
+
 // worker is the main object which takes care of applying messages to the new state
 type worker struct {
 	config *params.ChainConfig
@@ -360,10 +376,24 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 	if err != nil {
 		return err
 	}
+	var dposContext *types.DposContext
+	if self.config.IsDpos(header.Number) {
+		// At the clique-to-dpos fork the parent carries no dpos context;
+		// the transition block starts from the migration context instead
+		if migrator, ok := self.engine.(core.DposMigrator); ok && !self.config.IsDpos(parent.Number()) {
+			dposContext, err = migrator.MigrationContext(self.chain, parent.Header())
+		} else {
+			dposContext, err = types.NewDposContextFromProto(self.chain.StateCache().TrieDB(), parent.Header().DposContext)
+		}
+		if err != nil {
+			return err
+		}
+	}
 	work := &Work{
-		config:    self.config,
-		signer:    types.NewEIP155Signer(self.config.ChainID),
-		state:     state,
+		config:      self.config,
+		signer:      types.NewEIP155Signer(self.config.ChainID),
+		state:       state,
+		dposContext: dposContext,
 		ancestors: set.New(),
 		family:    set.New(),
 		uncles:    set.New(),
@@ -448,6 +478,16 @@ func (self *worker) commitNewWork() {
 	if self.config.DAOForkSupport && self.config.DAOForkBlock != nil && self.config.DAOForkBlock.Cmp(header.Number) == 0 {
 		misc.ApplyDAOHardFork(work.state)
 	}
+	// Engines that derive the gas limit from on-chain validator votes
+	// override the miner-local targeting
+	if voter, ok := self.engine.(gasLimitVoter); ok && work.dposContext != nil {
+		header.GasLimit = voter.CalcGasLimit(parent.Header(), work.dposContext)
+	}
+	// Budget block building on slot-bound engines, so the block is sealed
+	// and propagated with margin before the slot ends
+	if deadliner, ok := self.engine.(slotDeadliner); ok {
+		work.deadline = deadliner.SealDeadline(header)
+	}
 	pending, err := self.eth.TxPool().Pending()
 	if err != nil {
 		log.Error("Failed to fetch pending transactions", "err", err)
@@ -478,8 +518,27 @@ func (self *worker) commitNewWork() {
 	for _, hash := range badUncles {
 		delete(self.possibleUncles, hash)
 	}
+	// Append the engine-synthesized system transactions after the user
+	// transactions, so rewards and epoch maintenance earn regular receipts
+	if systemEngine, ok := self.engine.(core.SystemTxEngine); ok {
+		systemTxs, err := systemEngine.SystemTransactions(self.chain, header)
+		if err != nil {
+			log.Error("Failed to derive system transactions", "err", err)
+			return
+		}
+		for _, tx := range systemTxs {
+			work.state.Prepare(tx.Hash(), common.Hash{}, len(work.txs))
+			receipt, err := core.ApplySystemTransaction(systemEngine, self.chain, self.config, work.state, header, work.dposContext, tx, header.GasUsed)
+			if err != nil {
+				log.Error("Failed to apply system transaction", "hash", tx.Hash(), "err", err)
+				return
+			}
+			work.txs = append(work.txs, tx)
+			work.receipts = append(work.receipts, receipt)
+		}
+	}
 	// Create the new block to seal with the consensus engine
-	if work.Block, err = self.engine.Finalize(self.chain, header, work.state, work.txs, uncles, work.receipts); err != nil {
+	if work.Block, err = self.engine.Finalize(self.chain, header, work.state, work.txs, uncles, work.receipts, work.dposContext); err != nil {
 		log.Error("Failed to finalize block for sealing", "err", err)
 		return
 	}
@@ -528,6 +587,12 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 	var coalescedLogs []*types.Log
 
 	for {
+		// Stop filling once the slot budget runs out; an overrunning block
+		// would miss its slot and be rejected by the validator schedule
+		if !env.deadline.IsZero() && !time.Now().Before(env.deadline) {
+			log.Debug("Slot budget exhausted, interrupting block fill", "deadline", env.deadline, "txs", env.tcount)
+			break
+		}
 		// If we don't have enough gas for any further transactions then we're done
 		if env.gasPool.Gas() < params.TxGas {
 			log.Trace("Not enough gas for further transactions", "have", env.gasPool, "want", params.TxGas)
@@ -607,10 +672,17 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 
 func (env *Work) commitTransaction(tx *types.Transaction, bc *core.BlockChain, coinbase common.Address, gp *core.GasPool) (error, []*types.Log) {
 	snap := env.state.Snapshot()
+	var dposSnap int
+	if env.dposContext != nil {
+		dposSnap = env.dposContext.Snapshot()
+	}
 
-	receipt, _, err := core.ApplyTransaction(env.config, bc, &coinbase, gp, env.state, env.header, tx, &env.header.GasUsed, vm.Config{})
+	receipt, _, err := core.ApplyTransaction(env.config, bc, &coinbase, gp, env.state, env.header, tx, &env.header.GasUsed, vm.Config{}, env.dposContext)
 	if err != nil {
 		env.state.RevertToSnapshot(snap)
+		if env.dposContext != nil {
+			env.dposContext.RevertToSnapShot(dposSnap)
+		}
 		return err, nil
 	}
 	env.txs = append(env.txs, tx)
@@ -0,0 +1,183 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains a wrapper for the dpos consensus client.
+
+package geth
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DposClient provides access to the dpos consensus APIs.
+type DposClient struct {
+	client *rpc.Client
+}
+
+// NewDposClient connects a client to the given URL.
+func NewDposClient(rawurl string) (client *DposClient, _ error) {
+	rawClient, err := rpc.Dial(rawurl)
+	return &DposClient{rawClient}, err
+}
+
+// blockNumberArg converts a mobile block number into its RPC representation.
+// A negative number selects the latest known block.
+func blockNumberArg(number int64) string {
+	if number < 0 {
+		return "latest"
+	}
+	return hexutil.EncodeUint64(uint64(number))
+}
+
+// GetValidators retrieves the validator set active at the given block number.
+// If number is <0, the latest known block is used.
+func (dc *DposClient) GetValidators(ctx *Context, number int64) (validators *Addresses, _ error) {
+	var result []common.Address
+	if err := dc.client.CallContext(ctx.context, &result, "dpos_getValidators", blockNumberArg(number)); err != nil {
+		return nil, err
+	}
+	return &Addresses{result}, nil
+}
+
+// GetVotes retrieves the candidate the given delegator votes for at the given
+// block number, or the zero address if it casts no vote. If number is <0, the
+// latest known block is used.
+func (dc *DposClient) GetVotes(ctx *Context, delegator *Address, number int64) (candidate *Address, _ error) {
+	var result common.Address
+	if err := dc.client.CallContext(ctx.context, &result, "dpos_getVotes", delegator.address, blockNumberArg(number)); err != nil {
+		return nil, err
+	}
+	return &Address{result}, nil
+}
+
+// DposCandidate represents a registered election candidate together with the
+// figures a wallet needs to render a candidate list.
+type DposCandidate struct {
+	address        common.Address
+	commission     int64
+	delegatorCount int
+	stake          *big.Int
+	weight         *big.Int
+	selfStakeShort bool
+}
+
+// GetAddress returns the candidate's address.
+func (c *DposCandidate) GetAddress() *Address { return &Address{c.address} }
+
+// GetCommission returns the candidate's commission rate in basis points.
+func (c *DposCandidate) GetCommission() int64 { return c.commission }
+
+// GetDelegatorCount returns the number of distinct delegators voting for the
+// candidate.
+func (c *DposCandidate) GetDelegatorCount() int { return c.delegatorCount }
+
+// GetStake returns the raw stake delegated to the candidate.
+func (c *DposCandidate) GetStake() *BigInt { return &BigInt{c.stake} }
+
+// GetWeight returns the effective vote weight the election sorts by.
+func (c *DposCandidate) GetWeight() *BigInt { return &BigInt{c.weight} }
+
+// GetSelfStakeShort returns whether the self-stake floor bars the candidate
+// from election.
+func (c *DposCandidate) GetSelfStakeShort() bool { return c.selfStakeShort }
+
+// DposCandidates represents a slice of candidates.
+type DposCandidates struct{ candidates []*DposCandidate }
+
+// Size returns the number of candidates in the slice.
+func (c *DposCandidates) Size() int {
+	return len(c.candidates)
+}
+
+// Get returns the candidate at the given index from the slice.
+func (c *DposCandidates) Get(index int) (candidate *DposCandidate, _ error) {
+	if index < 0 || index >= len(c.candidates) {
+		return nil, errors.New("index out of bounds")
+	}
+	return c.candidates[index], nil
+}
+
+// GetCandidates retrieves all registered candidates at the given block number,
+// ordered by decreasing vote weight. If number is <0, the latest known block
+// is used.
+func (dc *DposClient) GetCandidates(ctx *Context, number int64) (candidates *DposCandidates, _ error) {
+	var result []struct {
+		Address        common.Address `json:"address"`
+		Commission     uint64         `json:"commission"`
+		DelegatorCount int            `json:"delegatorCount"`
+		Stake          *big.Int       `json:"stake"`
+		Weight         *big.Int       `json:"weight"`
+		SelfStakeShort bool           `json:"selfStakeShort"`
+	}
+	if err := dc.client.CallContext(ctx.context, &result, "dpos_getCandidates", blockNumberArg(number)); err != nil {
+		return nil, err
+	}
+	wrapped := make([]*DposCandidate, 0, len(result))
+	for _, entry := range result {
+		candidate := &DposCandidate{
+			address:        entry.Address,
+			commission:     int64(entry.Commission),
+			delegatorCount: entry.DelegatorCount,
+			stake:          entry.Stake,
+			weight:         entry.Weight,
+			selfStakeShort: entry.SelfStakeShort,
+		}
+		if candidate.stake == nil {
+			candidate.stake = new(big.Int)
+		}
+		if candidate.weight == nil {
+			candidate.weight = new(big.Int)
+		}
+		wrapped = append(wrapped, candidate)
+	}
+	return &DposCandidates{wrapped}, nil
+}
+
+// NewDelegateTransaction creates a transaction casting the sender's vote for
+// the given candidate.
+func NewDelegateTransaction(nonce int64, candidate *Address, gasLimit int64, gasPrice *BigInt) *Transaction {
+	return &Transaction{types.NewDelegateTransaction(uint64(nonce), candidate.address, uint64(gasLimit), gasPrice.bigint)}
+}
+
+// NewUnDelegateTransaction creates a transaction withdrawing the sender's vote
+// from the given candidate.
+func NewUnDelegateTransaction(nonce int64, candidate *Address, gasLimit int64, gasPrice *BigInt) *Transaction {
+	return &Transaction{types.NewUnDelegateTransaction(uint64(nonce), candidate.address, uint64(gasLimit), gasPrice.bigint)}
+}
+
+// NewRedelegateTransaction creates a transaction moving the sender's vote from
+// one candidate to another in a single step.
+func NewRedelegateTransaction(nonce int64, from *Address, to *Address, gasLimit int64, gasPrice *BigInt) *Transaction {
+	return &Transaction{types.NewRedelegateTransaction(uint64(nonce), from.address, to.address, uint64(gasLimit), gasPrice.bigint)}
+}
+
+// NewLoginCandidateTransaction creates a transaction registering the sender as
+// an election candidate.
+func NewLoginCandidateTransaction(nonce int64, gasLimit int64, gasPrice *BigInt) *Transaction {
+	return &Transaction{types.NewLoginCandidateTransaction(uint64(nonce), uint64(gasLimit), gasPrice.bigint)}
+}
+
+// NewLogoutCandidateTransaction creates a transaction removing the sender from
+// the candidate list.
+func NewLogoutCandidateTransaction(nonce int64, gasLimit int64, gasPrice *BigInt) *Transaction {
+	return &Transaction{types.NewLogoutCandidateTransaction(uint64(nonce), uint64(gasLimit), gasPrice.bigint)}
+}
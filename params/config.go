@@ -23,10 +23,12 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 )
 
-// Genesis hashes to enforce below configs on.
+// Genesis hashes to enforce below configs on. The hashes differ from the
+// upstream networks': the dpos header fields are part of the header encoding,
+// so every genesis block hashes differently than its upstream counterpart.
 var (
-	MainnetGenesisHash = common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3")
-	TestnetGenesisHash = common.HexToHash("0x41941023680923e0fe4d74a34bdac8141f2540e3ae90623718e47d66d1ca4a2d")
+	MainnetGenesisHash = common.HexToHash("0x0471cd8ee4b6a21ceddb7d04303b75da91842da1c2b31aea8303ba7cd3474251")
+	TestnetGenesisHash = common.HexToHash("0x62d172d2333236b7a7309946305393c8aaea4ee59562b9f722fb05dc029b5021")
 )
 
 var (
@@ -83,16 +85,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -122,6 +124,7 @@ type ChainConfig struct {
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
+	Dpos   *DposConfig   `json:"dpos,omitempty"`
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -143,6 +146,230 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
+// DposConfig is the consensus engine configs for delegated proof-of-stake based sealing.
+type DposConfig struct {
+	Validators []common.Address `json:"validators"` // Genesis validator list
+
+	// ForkBlock activates dpos at the given block on a chain that started
+	// out under clique, the clique signers of the latest checkpoint seeding
+	// the initial candidate and validator set. Zero means the chain runs
+	// dpos from genesis; a non-zero value requires the clique config to be
+	// present as well, covering the blocks below the fork.
+	ForkBlock uint64 `json:"forkBlock,omitempty"`
+
+	// MintCntFixBlock activates the reworked mint counter accounting at the
+	// given block: a block sealed exactly on an epoch boundary is credited to
+	// the epoch whose validator set produced it — the closing one — instead
+	// of the one it opens, and stored counters are bounds-checked so a
+	// corrupted value cannot be carried forward. The first block under the
+	// fork re-derives the retained counters from headers, cleaning up the
+	// historical misattribution. Zero keeps the legacy accounting; new
+	// networks should set the fork to block one.
+	MintCntFixBlock uint64 `json:"mintCntFixBlock,omitempty"`
+
+	// Timing and sizing overrides for private and developer networks. Zero
+	// values select the engine defaults (10s blocks, 24h epochs, 21
+	// validators); public networks should leave them unset.
+	BlockInterval    uint64 `json:"blockInterval,omitempty"`    // Number of seconds between two consecutive blocks
+	EpochInterval    uint64 `json:"epochInterval,omitempty"`    // Number of seconds a validator epoch lasts
+	MaxValidatorSize uint64 `json:"maxValidatorSize,omitempty"` // Number of validators elected per epoch
+
+	// MinBlockInterval and MaxBlockInterval bound adaptive block interval
+	// voting: validators signal their preferred interval within the bounds
+	// and an epoch adopts a new one when a supermajority of its elected
+	// validators agrees. Both unset disables the voting and the interval
+	// stays fixed at BlockInterval.
+	MinBlockInterval uint64 `json:"minBlockInterval,omitempty"`
+	MaxBlockInterval uint64 `json:"maxBlockInterval,omitempty"`
+
+	// MaxBlockDrift is the number of seconds a block timestamp may drift off
+	// its slot boundary before the slot lookup rejects it, absorbing mild
+	// clock skew between validators. Zero keeps the strict alignment.
+	MaxBlockDrift uint64 `json:"maxBlockDrift,omitempty"`
+
+	// RefuseSealOnSkew makes a validator sit its slot out when the clock
+	// skew estimated from peer blocks and heartbeats grows so large that a
+	// sealed block would land in the wrong slot anyway.
+	RefuseSealOnSkew bool `json:"refuseSealOnSkew,omitempty"`
+
+	// SealFailurePause auto-pauses sealing after the given number of
+	// consecutive failed sealing attempts, until the operator resumes it.
+	// Zero keeps retrying every slot.
+	SealFailurePause uint64 `json:"sealFailurePause,omitempty"`
+
+	// Emission schedule of the network. A nil BlockReward selects the
+	// engine's built-in default, a zero HalvingInterval keeps the reward
+	// constant and a nil HardCap leaves the supply minted by the schedule
+	// unbounded.
+	BlockReward     *big.Int `json:"blockReward,omitempty"`     // Reward in wei minted per block at launch
+	HalvingInterval uint64   `json:"halvingInterval,omitempty"` // Number of blocks after which the reward halves
+	HardCap         *big.Int `json:"hardCap,omitempty"`         // Total supply in wei the schedule may mint
+
+	// ValidatorSizeSchedule grows (or shrinks) the validator set over time.
+	// Each step applies from its epoch on; steps must be ordered by epoch.
+	// An empty schedule keeps MaxValidatorSize for the whole chain.
+	ValidatorSizeSchedule []ValidatorSizeStep `json:"validatorSizeSchedule,omitempty"`
+
+	// KickoutWindow is the number of past epochs the kickout check aggregates
+	// block production over, so a validator cannot dodge it by alternating a
+	// barely active epoch with a dead one. Zero or one keeps the classic
+	// single-epoch check; the window is capped by the mint counter retention.
+	KickoutWindow uint64 `json:"kickoutWindow,omitempty"`
+
+	// VoteExpiryEpochs expires delegations that were not re-confirmed, by
+	// re-sending the delegate operation, for the given number of epochs, so
+	// dead accounts stop determining elections forever. Zero disables
+	// expiry. Votes cast before the mode was enabled carry no confirmation
+	// stamp and never expire.
+	VoteExpiryEpochs uint64 `json:"voteExpiryEpochs,omitempty"`
+
+	// MaxRedelegationsPerEpoch caps how often a delegator may move its vote
+	// between candidates within one epoch through redelegate operations,
+	// preventing rapid vote shuffling around an election. Zero leaves
+	// redelegation unlimited.
+	MaxRedelegationsPerEpoch uint64 `json:"maxRedelegationsPerEpoch,omitempty"`
+
+	// MaxDelegatorsPerCandidate caps how many distinct delegators a single
+	// candidate may accumulate, bounding the tally work one candidate can
+	// demand at the election. Delegations beyond the cap fail with a
+	// distinct log instead of silently growing the delegate trie. Zero
+	// leaves the delegator count unbounded.
+	MaxDelegatorsPerCandidate uint64 `json:"maxDelegatorsPerCandidate,omitempty"`
+
+	// Downtime slashing. A validator that missed more than SlashMissedPercent
+	// of its expected slots in an epoch loses SlashPenaltyPercent of its
+	// balance at the epoch transition. The penalty is split across the
+	// validators that stayed active, or burned when BurnSlashed is set.
+	// A zero percentage disables slashing.
+	SlashMissedPercent  uint64 `json:"slashMissedPercent,omitempty"`
+	SlashPenaltyPercent uint64 `json:"slashPenaltyPercent,omitempty"`
+	BurnSlashed         bool   `json:"burnSlashed,omitempty"`
+
+	// CorrelationPenalty amplifies the downtime penalties of validators that
+	// declared the same operator group and failed the same epoch: each
+	// failing member's slashing percentage is multiplied by the number of
+	// failing group members (capped at confiscation), and its kickout
+	// cooldown is stretched by the same factor. Discourages one operator
+	// from running a large slice of the set on shared infrastructure.
+	CorrelationPenalty bool `json:"correlationPenalty,omitempty"`
+
+	// Double-sign evidence. A ReportEvidence transaction proving a validator
+	// sealed two conflicting headers for the same slot slashes
+	// EvidencePenaltyPercent of the offender's balance, of which
+	// EvidenceRewardPercent goes to the reporter and the remainder is burned.
+	// Evidence about slots more than EvidenceMaxAge blocks in the past is
+	// rejected; zero accepts evidence of any age. A zero penalty disables
+	// evidence processing.
+	EvidencePenaltyPercent uint64 `json:"evidencePenaltyPercent,omitempty"`
+	EvidenceRewardPercent  uint64 `json:"evidenceRewardPercent,omitempty"`
+	EvidenceMaxAge         uint64 `json:"evidenceMaxAge,omitempty"`
+
+	// VoteWeightMode selects the curve translating delegated stake into
+	// election weight: "linear" (the default), "sqrt" or "quadratic-capped".
+	// "average-balance" weighs votes linearly, but by the average balance
+	// sampled over the previous epoch instead of a single snapshot.
+	VoteWeightMode string `json:"voteWeightMode,omitempty"`
+
+	// ProposerOrdering selects how the elected validators are laid out over
+	// an epoch's slots: "shuffle" (the default) gives every validator the
+	// same number of slots in seed-shuffled order, while "stake-weighted"
+	// assigns slot counts proportional to each validator's vote weight.
+	ProposerOrdering string `json:"proposerOrdering,omitempty"`
+
+	// MaxVoteWeight caps the election weight, in wei of stake, any single
+	// delegator can contribute to a candidate's tally. Stakes above the cap
+	// still vote, but only count at the cap. Nil disables the cap.
+	MaxVoteWeight *big.Int `json:"maxVoteWeight,omitempty"`
+
+	// MinVoteThreshold excludes candidates whose effective vote weight stays
+	// below the threshold from the election, unless dropping them would
+	// leave fewer candidates than the safe size. Nil disables the check.
+	MinVoteThreshold *big.Int `json:"minVoteThreshold,omitempty"`
+
+	// MinSelfStakePercent requires a candidate's own delegation to cover at
+	// least this percentage of its total raw stake. Candidates below the
+	// floor keep their registration and delegations, but are excluded from
+	// elections until the self-stake recovers. Zero disables the check.
+	MinSelfStakePercent uint64 `json:"minSelfStakePercent,omitempty"`
+
+	// TotalSupply declares the total token supply in wei, serving as the
+	// denominator of the staking participation rate reported over RPC. The
+	// chain does not track issuance itself, so networks that want the rate
+	// state their supply here; nil leaves the rate unreported.
+	TotalSupply *big.Int `json:"totalSupply,omitempty"`
+
+	// Anti-spam minimums; nil disables the respective check. Accounts below
+	// MinCandidateBalance cannot register as candidates, accounts below
+	// MinDelegateBalance cannot cast votes.
+	MinCandidateBalance *big.Int `json:"minCandidateBalance,omitempty"`
+	MinDelegateBalance  *big.Int `json:"minDelegateBalance,omitempty"`
+
+	// CandidateDeposit is locked from an account registering as a candidate
+	// and earned back progressively as the validator produces its expected
+	// blocks. The unrefunded remainder is forfeited when the candidate
+	// leaves the candidate list, voluntarily or by kickout. Nil disables
+	// deposits.
+	CandidateDeposit *big.Int `json:"candidateDeposit,omitempty"`
+
+	// PoolFees diverts transaction fees from the block proposer into a pool
+	// that is split across the validators of an epoch proportionally to the
+	// blocks each of them produced, reducing the incentive to manipulate
+	// proposer ordering.
+	PoolFees bool `json:"poolFees,omitempty"`
+
+	// Break-glass governance multisig. A ForceValidators transaction signed
+	// by at least GovernanceThreshold of the GovernanceSigners replaces the
+	// validator set at the next epoch, recovering a network whose validator
+	// majority lost its keys. An empty signer list or a zero threshold
+	// disables the mechanism.
+	GovernanceSigners   []common.Address `json:"governanceSigners,omitempty"`
+	GovernanceThreshold uint64           `json:"governanceThreshold,omitempty"`
+
+	// MirrorStakingStats mirrors each validator's lifetime produced-block,
+	// reward and slash counters into the storage of the staking statistics
+	// system account at epoch boundaries, so on-chain contracts like
+	// insurance or reputation schemes can read them. The mirror is part of
+	// consensus state, hence opt-in per network.
+	MirrorStakingStats bool `json:"mirrorStakingStats,omitempty"`
+
+	// RequireGenesisAttestations demands that every genesis validator has
+	// countersigned the chain launch: the genesis extra-data must end with
+	// one signature per validator over the genesis hash, checked at startup.
+	// A misconfigured copy of the genesis specification then fails to start
+	// instead of silently forming a forked network.
+	RequireGenesisAttestations bool `json:"requireGenesisAttestations,omitempty"`
+}
+
+// ValidatorSizeStep is one entry of a validator set growth schedule: from
+// epoch FromEpoch on, Size validators are elected per epoch.
+type ValidatorSizeStep struct {
+	FromEpoch uint64 `json:"fromEpoch"`
+	Size      uint64 `json:"size"`
+}
+
+// String implements the stringer interface, returning the consensus engine details.
+func (c *DposConfig) String() string {
+	return "dpos"
+}
+
+// EpochIntervalSeconds returns the configured epoch length in seconds,
+// falling back to the engine default of one day when unset.
+func (c *DposConfig) EpochIntervalSeconds() int64 {
+	if c.EpochInterval > 0 {
+		return int64(c.EpochInterval)
+	}
+	return 86400
+}
+
+// BlockIntervalSeconds returns the configured slot length in seconds, falling
+// back to the engine default of ten seconds when unset.
+func (c *DposConfig) BlockIntervalSeconds() int64 {
+	if c.BlockInterval > 0 {
+		return int64(c.BlockInterval)
+	}
+	return 10
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -151,6 +378,8 @@ func (c *ChainConfig) String() string {
 		engine = c.Ethash
 	case c.Clique != nil:
 		engine = c.Clique
+	case c.Dpos != nil:
+		engine = c.Dpos
 	default:
 		engine = "unknown"
 	}
@@ -173,6 +402,19 @@ func (c *ChainConfig) IsHomestead(num *big.Int) bool {
 	return isForked(c.HomesteadBlock, num)
 }
 
+// IsDpos returns whether blocks at the given number are sealed and processed
+// under the dpos rules. On a pure dpos chain that is every block; on a chain
+// migrating from clique it is the blocks from the fork block on.
+func (c *ChainConfig) IsDpos(num *big.Int) bool {
+	if c.Dpos == nil {
+		return false
+	}
+	if c.Dpos.ForkBlock == 0 {
+		return true
+	}
+	return num != nil && num.Uint64() >= c.Dpos.ForkBlock
+}
+
 // IsDAOFork returns whether num is either equal to the DAO fork block or greater.
 func (c *ChainConfig) IsDAOFork(num *big.Int) bool {
 	return isForked(c.DAOForkBlock, num)
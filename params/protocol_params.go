@@ -34,6 +34,28 @@ const (
 	CallNewAccountGas     uint64 = 25000 // Paid for CALL when the destination address didn't exist prior.
 	TxGas                 uint64 = 21000 // Per transaction not creating a contract. NOTE: Not payable on data of calls between transactions.
 	TxGasContractCreation uint64 = 53000 // Per transaction that creates a contract. NOTE: Not payable on data of calls between transactions.
+
+	// Extra intrinsic gas of the dpos operations, on top of TxGas, sized to
+	// the amount of trie work each operation causes.
+	LoginCandidateGas  uint64 = 20000 // Single insert into the candidate trie
+	LogoutCandidateGas uint64 = 50000 // Removes the candidate and all delegations pointing at it
+	DelegateGas        uint64 = 40000 // Touches the vote trie and up to two delegate trie entries
+	UnDelegateGas      uint64 = 30000 // Deletes one vote and one delegate trie entry
+	MetaVoteGas        uint64 = 45000 // Delegate work plus a signature recovery and nonce bump
+	SetCommissionGas   uint64 = 25000 // Single update of the candidate commission entry
+	ForceValidatorsGas uint64 = 100000 // Multiple signature recoveries plus the pending override entry
+	ReportEvidenceGas  uint64 = 150000 // Two header signature recoveries plus the slashing bookkeeping
+	RedelegateGas      uint64 = 45000  // Moves a vote and delegate entry pair between candidates
+	ProxyVoteGas       uint64 = 80000 // Replaces the allocation list, root and per-candidate aggregates
+	BlacklistGas       uint64 = 100000 // Multiple signature recoveries plus the blacklist entry
+	RotateSignerGas    uint64 = 25000 // Single update of the candidate rotation entry
+	CandidateOwnersGas uint64 = 60000 // Owner signature recoveries plus the owner set entry
+	OperatorGroupGas   uint64 = 25000 // Single update of the candidate group entry
+	RewardRecipientGas uint64 = 25000 // Single update of the candidate payout entry
+	BlockIntervalGas   uint64 = 25000 // Single update of the candidate interval vote entry
+
+	WeightHookRegisterGas uint64 = 20000 // Single update of the vote weight hook registry entry
+	WeightHookCallGas     uint64 = 50000 // Gas stipend each vote weight hook callback runs with
 	TxDataZeroGas         uint64 = 4     // Per byte of data attached to a transaction that equals zero. NOTE: Not payable on data of calls between transactions.
 	QuadCoeffDiv          uint64 = 512   // Divisor for the quadratic particle of the memory cost equation.
 	SstoreSetGas          uint64 = 20000 // Once per SLOAD operation.
@@ -77,6 +99,8 @@ const (
 	Bn256ScalarMulGas       uint64 = 40000  // Gas needed for an elliptic curve scalar multiplication
 	Bn256PairingBaseGas     uint64 = 100000 // Base price for an elliptic curve pairing check
 	Bn256PairingPerPointGas uint64 = 80000  // Per-point price for an elliptic curve pairing check
+	MerkleVerifyBaseGas     uint64 = 600    // Base price for a merkle proof verification
+	MerkleVerifyPerNodeGas  uint64 = 60     // Per-node price for a merkle proof verification
 )
 
 var (
@@ -198,6 +198,9 @@ func makeDecoder(typ reflect.Type, tags tags) (dec decoder, err error) {
 	case typ == rawValueType:
 		return decodeRawValue, nil
 	case typ.Implements(decoderInterface):
+		if kind == reflect.Ptr && tags.nilOK {
+			return decodeOptionalDecoder, nil
+		}
 		return decodeDecoder, nil
 	case kind != reflect.Ptr && reflect.PtrTo(typ).Implements(decoderInterface):
 		return decodeDecoderNoPtr, nil
@@ -538,6 +541,23 @@ func decodeDecoderNoPtr(s *Stream, val reflect.Value) error {
 	return val.Addr().Interface().(Decoder).DecodeRLP(s)
 }
 
+// decodeOptionalDecoder handles pointer-typed struct fields with struct tag
+// "nil" whose type implements Decoder. Empty values decode as a nil pointer
+// instead of being handed to DecodeRLP, mirroring makeOptionalPtrDecoder.
+func decodeOptionalDecoder(s *Stream, val reflect.Value) error {
+	kind, size, err := s.Kind()
+	if err != nil || size == 0 && kind != Byte {
+		// rearm s.Kind. This is important because the input
+		// position must advance to the next value even though
+		// we don't read anything.
+		s.kind = -1
+		// set the pointer to nil.
+		val.Set(reflect.Zero(val.Type()))
+		return err
+	}
+	return decodeDecoder(s, val)
+}
+
 func decodeDecoder(s *Stream, val reflect.Value) error {
 	// Decoder instances are not handled using the pointer rule if the type
 	// implements Decoder with pointer receiver (i.e. always)
@@ -661,6 +661,33 @@ func TestDecodeDecoder(t *testing.T) {
 	}
 }
 
+func TestDecodeOptionalDecoder(t *testing.T) {
+	var s struct {
+		T1 uint
+		T2 *testDecoder `rlp:"nil"`
+	}
+	// A non-empty value is handed to DecodeRLP as usual.
+	if err := Decode(bytes.NewReader(unhex("C20102")), &s); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if s.T2 == nil {
+		t.Errorf("*testDecoder has not been allocated")
+	} else if !s.T2.called {
+		t.Errorf("DecodeRLP was not called for *testDecoder")
+	}
+
+	// Empty values decode as nil without invoking DecodeRLP, even though
+	// the pointer type implements Decoder.
+	for _, input := range []string{"C201C0", "C20180"} {
+		if err := Decode(bytes.NewReader(unhex(input)), &s); err != nil {
+			t.Fatalf("Decode error for %s: %v", input, err)
+		}
+		if s.T2 != nil {
+			t.Errorf("input %s: *testDecoder is not nil", input)
+		}
+	}
+}
+
 type byteDecoder byte
 
 func (bd *byteDecoder) DecodeRLP(s *Stream) error {
@@ -666,6 +666,71 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	return nil
 }
 
+// CommitRoots iterates over several tries and flushes them out in a single
+// database batch. It behaves like calling Commit for each root in turn, but
+// shares one write batch between them, which matters for callers persisting a
+// group of small tries per block, like the dpos context at epoch boundaries.
+func (db *Database) CommitRoots(roots []common.Hash, report bool) error {
+	db.lock.RLock()
+
+	start := time.Now()
+	batch := db.diskdb.NewBatch()
+
+	// Move all of the accumulated preimages into the write batch
+	for hash, preimage := range db.preimages {
+		if err := batch.Put(db.secureKey(hash[:]), preimage); err != nil {
+			log.Error("Failed to commit preimage from trie database", "err", err)
+			db.lock.RUnlock()
+			return err
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	// Move the tries themselves into the batch, flushing if enough data is accumulated
+	nodes, storage := len(db.nodes), db.nodesSize
+	for _, root := range roots {
+		if err := db.commit(root, batch); err != nil {
+			log.Error("Failed to commit trie from trie database", "err", err)
+			db.lock.RUnlock()
+			return err
+		}
+	}
+	// Write batch ready, unlock for readers during persistence
+	if err := batch.Write(); err != nil {
+		log.Error("Failed to write tries to disk", "err", err)
+		db.lock.RUnlock()
+		return err
+	}
+	db.lock.RUnlock()
+
+	// Write successful, clear out the flushed data
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.preimages = make(map[common.Hash][]byte)
+	db.preimagesSize = 0
+
+	for _, root := range roots {
+		db.uncache(root)
+	}
+	memcacheCommitTimeTimer.Update(time.Since(start))
+	memcacheCommitSizeMeter.Mark(int64(storage - db.nodesSize))
+	memcacheCommitNodesMeter.Mark(int64(nodes - len(db.nodes)))
+
+	logger := log.Info
+	if !report {
+		logger = log.Debug
+	}
+	logger("Persisted tries from memory database", "tries", len(roots), "nodes", nodes-len(db.nodes), "size", storage-db.nodesSize, "time", time.Since(start),
+		"livenodes", len(db.nodes), "livesize", db.nodesSize)
+
+	return nil
+}
+
 // commit is the private locked version of Commit.
 func (db *Database) commit(hash common.Hash, batch ethdb.Batch) error {
 	// If the node does not exist, it's a previously committed node